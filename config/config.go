@@ -1,8 +1,12 @@
 package config
 
 import (
+	"log"
 	"os"
 	"reports-api/models"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var AppConfig *models.Config
@@ -16,5 +20,194 @@ func InitConfig() {
 		Environment:     os.Getenv("env"),
 		BotToken:        os.Getenv("BOT_TOKEN"),
 		ChatID:          os.Getenv("CHAT_ID"),
+		TokenSecret:     os.Getenv("TOKEN_SECRET"),
+		TokenIssuer:     getEnv("TOKEN_ISSUER", "reports-api"),
+		AccessTokenTTL:  getDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDurationEnv("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+		PasswordPolicy: models.PasswordPolicy{
+			MinLength:      getIntEnv("PASSWORD_MIN_LENGTH", 8),
+			RequireUpper:   getBoolEnv("PASSWORD_REQUIRE_UPPER", false),
+			RequireLower:   getBoolEnv("PASSWORD_REQUIRE_LOWER", false),
+			RequireDigit:   getBoolEnv("PASSWORD_REQUIRE_DIGIT", true),
+			RequireSpecial: getBoolEnv("PASSWORD_REQUIRE_SPECIAL", false),
+		},
+		PasswordResetTTL: getDurationEnv("PASSWORD_RESET_TTL", time.Hour),
+		LoginMaxAttempts: getIntEnv("LOGIN_MAX_ATTEMPTS", 5),
+		LoginLockoutBase: getDurationEnv("LOGIN_LOCKOUT_BASE", time.Minute),
+		LoginLockoutMax:  getDurationEnv("LOGIN_LOCKOUT_MAX", time.Hour),
+		RateLimits: map[string]models.RateLimitRule{
+			models.RateLimitDefault: getRateLimitEnv("RATE_LIMIT_DEFAULT", models.RateLimitRule{Max: 300, Window: time.Minute}),
+			models.RateLimitAuth:    getRateLimitEnv("RATE_LIMIT_AUTH", models.RateLimitRule{Max: 10, Window: time.Minute}),
+			models.RateLimitList:    getRateLimitEnv("RATE_LIMIT_LIST", models.RateLimitRule{Max: 200, Window: time.Minute}),
+			models.RateLimitCreate:  getRateLimitEnv("RATE_LIMIT_CREATE", models.RateLimitRule{Max: 10, Window: time.Minute}),
+			models.RateLimitUpdate:  getRateLimitEnv("RATE_LIMIT_UPDATE", models.RateLimitRule{Max: 20, Window: time.Minute}),
+			models.RateLimitDelete:  getRateLimitEnv("RATE_LIMIT_DELETE", models.RateLimitRule{Max: 5, Window: time.Minute}),
+		},
+		RateLimitStore: getEnv("RATE_LIMIT_STORE", "memory"),
+		TrustedProxies: getListEnv("TRUSTED_PROXIES"),
+		ProxyHeader:    getEnv("PROXY_HEADER", "X-Real-IP"),
+		AutoMigrate:    getBoolEnv("AUTO_MIGRATE", false),
+		Ticket: models.TicketConfig{
+			Format:     getEnv("TICKET_FORMAT", "TK-{date}-{seq}"),
+			DateLayout: getEnv("TICKET_DATE_LAYOUT", "20060102"),
+			SeqWidth:   getIntEnv("TICKET_SEQ_WIDTH", 4),
+			Reset:      getTicketResetEnv("TICKET_RESET", models.TicketResetMonthly),
+			Location:   getLocationEnv("TICKET_TIMEZONE", "Asia/Bangkok"),
+		},
+		SLA: models.SLAConfig{
+			DefaultPriority: getIntEnv("SLA_DEFAULT_PRIORITY", 2),
+			CheckInterval:   getDurationEnv("SLA_CHECK_INTERVAL", 5*time.Minute),
+			WarnBefore:      getDurationEnv("SLA_WARN_BEFORE", 30*time.Minute),
+			NotifyTelegram:  getBoolEnv("SLA_NOTIFY_TELEGRAM", true),
+			BusinessHours:   getBoolEnv("SLA_BUSINESS_HOURS", true),
+		},
+		BusinessLocation: getLocationEnv("BUSINESS_TIMEZONE", "Asia/Bangkok"),
+		TrashRetention:   getDurationEnv("TRASH_RETENTION", 30*24*time.Hour),
+		Bulk: models.BulkConfig{
+			MaxTasks:         getIntEnv("BULK_MAX_TASKS", 500),
+			TelegramInterval: getDurationEnv("BULK_TELEGRAM_INTERVAL", 3*time.Second),
+		},
+		Idempotency: models.IdempotencyConfig{
+			Window:      getDurationEnv("IDEMPOTENCY_WINDOW", 24*time.Hour),
+			LockTimeout: getDurationEnv("IDEMPOTENCY_LOCK_TIMEOUT", 5*time.Minute),
+		},
+		Duplicate: models.DuplicateConfig{
+			Policy:     getDuplicatePolicyEnv("DUPLICATE_POLICY", models.DuplicatePolicyWarn),
+			Window:     getDurationEnv("DUPLICATE_WINDOW", 30*time.Minute),
+			Similarity: getFloatEnv("DUPLICATE_SIMILARITY", 0.6),
+		},
 	}
 }
+
+// getEnv returns the environment variable or the fallback when it is unset
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getListEnv splits a comma-separated list from the environment, skipping empty entries
+func getListEnv(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getDurationEnv parses a duration such as "15m" or "168h" from the environment
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("⚠️ Invalid duration for %s: %q, using default %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// getIntEnv parses an integer from the environment
+func getIntEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("⚠️ Invalid integer for %s: %q, using default %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// getFloatEnv parses a decimal number from the environment
+func getFloatEnv(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("⚠️ Invalid number for %s: %q, using default %g", key, value, fallback)
+		return fallback
+	}
+	return f
+}
+
+// getBoolEnv parses a boolean such as "true" or "0" from the environment
+func getBoolEnv(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️ Invalid boolean for %s: %q, using default %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+// getRateLimitEnv parses a limit such as "10/1m" (10 requests per minute) from the environment
+func getRateLimitEnv(key string, fallback models.RateLimitRule) models.RateLimitRule {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	maxStr, windowStr, ok := strings.Cut(value, "/")
+	max, err := strconv.Atoi(strings.TrimSpace(maxStr))
+	if !ok || err != nil || max <= 0 {
+		log.Printf("⚠️ Invalid rate limit for %s: %q, using default %d/%s", key, value, fallback.Max, fallback.Window)
+		return fallback
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(windowStr))
+	if err != nil || window <= 0 {
+		log.Printf("⚠️ Invalid rate limit window for %s: %q, using default %d/%s", key, value, fallback.Max, fallback.Window)
+		return fallback
+	}
+	return models.RateLimitRule{Max: max, Window: window}
+}
+
+// getTicketResetEnv reads the ticket sequence reset period (daily, monthly, yearly or never)
+func getTicketResetEnv(key, fallback string) string {
+	value := strings.ToLower(os.Getenv(key))
+	switch value {
+	case "":
+		return fallback
+	case models.TicketResetDaily, models.TicketResetMonthly, models.TicketResetYearly, models.TicketResetNever:
+		return value
+	}
+	log.Printf("⚠️ Invalid ticket reset period for %s: %q, using default %s", key, value, fallback)
+	return fallback
+}
+
+// getDuplicatePolicyEnv reads a duplicate report policy (off, warn or link) from the environment
+func getDuplicatePolicyEnv(key, fallback string) string {
+	value := strings.ToLower(os.Getenv(key))
+	switch value {
+	case "":
+		return fallback
+	case models.DuplicatePolicyOff, models.DuplicatePolicyWarn, models.DuplicatePolicyLink:
+		return value
+	}
+	log.Printf("⚠️ Invalid duplicate policy for %s: %q, using default %s", key, value, fallback)
+	return fallback
+}
+
+// getLocationEnv loads a time zone such as "Asia/Bangkok" from the environment.
+// Hosts without tzdata fall back to UTC+7, the zone the rest of the API formats times in.
+func getLocationEnv(key, fallback string) *time.Location {
+	name := getEnv(key, fallback)
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("⚠️ Invalid time zone for %s: %q (%v), using UTC+7", key, name, err)
+		return time.FixedZone("UTC+7", 7*60*60)
+	}
+	return loc
+}
@@ -0,0 +1,340 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the versioned schema shipped inside the binary
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockName serialises migrations between API replicas starting at the same time
+const migrationLockName = "reports_api_schema_migrations"
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// ErrDirtyMigration is returned when a previous migration failed halfway and needs `migrate force`
+var ErrDirtyMigration = errors.New("database schema is dirty, fix it manually and run `migrate force <version>`")
+
+// Migration is one versioned schema change with its rollback
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState describes a migration and whether it has been applied
+type MigrationState struct {
+	Migration
+	Applied   bool
+	Dirty     bool
+	AppliedAt *time.Time
+}
+
+// LoadMigrations reads the embedded migrations ordered by version
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		body, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration and returns how many were applied
+func MigrateUp() (int, error) {
+	var applied int
+	err := withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		migrations, states, err := loadStates(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if states[m.Version].Applied {
+				continue
+			}
+			log.Printf("⬆️ Applying migration %04d_%s", m.Version, m.Name)
+			if err := applyMigration(ctx, conn, m, m.Up, true); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown rolls back the latest `steps` applied migrations
+func MigrateDown(steps int) (int, error) {
+	var reverted int
+	err := withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		migrations, states, err := loadStates(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && reverted < steps; i-- {
+			m := migrations[i]
+			if !states[m.Version].Applied {
+				continue
+			}
+			log.Printf("⬇️ Reverting migration %04d_%s", m.Version, m.Name)
+			if err := applyMigration(ctx, conn, m, m.Down, false); err != nil {
+				return err
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// MigrateForce marks every migration up to version as applied and clean, and later ones as pending.
+// It does not run any SQL; use it after fixing a failed migration by hand.
+func MigrateForce(version int) error {
+	return withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		migrations, err := LoadMigrations()
+		if err != nil {
+			return err
+		}
+		if err := ensureMigrationTable(ctx, conn); err != nil {
+			return err
+		}
+
+		if _, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version > ?`, version); err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if m.Version > version {
+				break
+			}
+			_, err := conn.ExecContext(ctx, `
+				INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, 0)
+				ON DUPLICATE KEY UPDATE dirty = 0
+			`, m.Version, m.Name)
+			if err != nil {
+				return err
+			}
+		}
+		log.Printf("🔧 Schema version forced to %d", version)
+		return nil
+	})
+}
+
+// MigrationStatus reports every known migration and whether it has been applied
+func MigrationStatus() ([]MigrationState, error) {
+	var result []MigrationState
+	err := withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		migrations, states, err := loadStatesAllowDirty(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			state := states[m.Version]
+			state.Migration = m
+			result = append(result, state)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 for an empty database
+func SchemaVersion() (int, error) {
+	states, err := MigrationStatus()
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for _, s := range states {
+		if s.Applied && s.Version > version {
+			version = s.Version
+		}
+	}
+	return version, nil
+}
+
+// withMigrationLock runs fn on a dedicated connection holding a MySQL named lock
+func withMigrationLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open migration connection: %w", err)
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, 60)`, migrationLockName).Scan(&locked); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return errors.New("timed out waiting for the migration lock")
+	}
+	defer conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, migrationLockName)
+
+	return fn(ctx, conn)
+}
+
+func ensureMigrationTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INT          NOT NULL PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL,
+			dirty      TINYINT(1)   NOT NULL DEFAULT 0,
+			applied_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// loadStates returns the known migrations and their state, refusing to continue on a dirty schema
+func loadStates(ctx context.Context, conn *sql.Conn) ([]Migration, map[int]MigrationState, error) {
+	migrations, states, err := loadStatesAllowDirty(ctx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	for version, s := range states {
+		if s.Dirty {
+			return nil, nil, fmt.Errorf("migration %d: %w", version, ErrDirtyMigration)
+		}
+	}
+	return migrations, states, nil
+}
+
+func loadStatesAllowDirty(ctx context.Context, conn *sql.Conn) ([]Migration, map[int]MigrationState, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ensureMigrationTable(ctx, conn); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, dirty, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	states := make(map[int]MigrationState)
+	for rows.Next() {
+		var s MigrationState
+		var appliedAt time.Time
+		if err := rows.Scan(&s.Version, &s.Dirty, &appliedAt); err != nil {
+			return nil, nil, err
+		}
+		s.Applied = true
+		s.AppliedAt = &appliedAt
+		states[s.Version] = s
+	}
+	return migrations, states, rows.Err()
+}
+
+// applyMigration runs one direction of a migration. MySQL commits DDL implicitly, so the version row
+// is marked dirty first and only cleaned up once every statement succeeded.
+func applyMigration(ctx context.Context, conn *sql.Conn, m Migration, script string, up bool) error {
+	if up {
+		_, err := conn.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, 1)`, m.Version, m.Name)
+		if err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+		}
+	} else {
+		if _, err := conn.ExecContext(ctx, `UPDATE schema_migrations SET dirty = 1 WHERE version = ?`, m.Version); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+		}
+	}
+
+	for i, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %04d_%s statement %d failed: %w", m.Version, m.Name, i+1, err)
+		}
+	}
+
+	var err error
+	if up {
+		_, err = conn.ExecContext(ctx, `UPDATE schema_migrations SET dirty = 0, applied_at = CURRENT_TIMESTAMP WHERE version = ?`, m.Version)
+	} else {
+		_, err = conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+	return nil
+}
+
+// splitStatements splits a migration script into statements terminated by ";" at the end of a line.
+// Full-line "--" comments are dropped.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+			statements = append(statements, stmt)
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS scores;
+DROP TABLE IF EXISTS progress;
+DROP TABLE IF EXISTS resolutions;
+DROP TABLE IF EXISTS tasks;
+DROP TABLE IF EXISTS telegram_chat;
+DROP TABLE IF EXISTS responsibilities;
+DROP TABLE IF EXISTS systems_program;
+DROP TABLE IF EXISTS `type`;
+DROP TABLE IF EXISTS issue_types;
+DROP TABLE IF EXISTS ip_phones;
+DROP TABLE IF EXISTS departments;
+DROP TABLE IF EXISTS branches;
@@ -0,0 +1,155 @@
+-- Baseline schema of the helpdesk database.
+-- Every statement is idempotent so that the migration can also be recorded against an existing database.
+
+CREATE TABLE IF NOT EXISTS branches (
+    id         INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name       VARCHAR(255) NULL,
+    created_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP    NULL,
+    created_by INT          NULL,
+    updated_by INT          NULL,
+    deleted_by INT          NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS departments (
+    id         INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name       VARCHAR(255) NULL,
+    branch_id  INT          NULL,
+    created_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP    NULL,
+    created_by INT          NULL,
+    updated_by INT          NULL,
+    deleted_by INT          NULL,
+    INDEX idx_departments_branch (branch_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS ip_phones (
+    id            INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    number        INT          NULL,
+    name          VARCHAR(255) NULL,
+    department_id INT          NULL,
+    created_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at    TIMESTAMP    NULL,
+    created_by    INT          NULL,
+    updated_by    INT          NULL,
+    deleted_by    INT          NULL,
+    INDEX idx_ip_phones_department (department_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS issue_types (
+    id         INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Legacy lookup table kept for compatibility; program types now live in issue_types
+CREATE TABLE IF NOT EXISTS `type` (
+    id         INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS systems_program (
+    id         INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name       VARCHAR(255) NULL,
+    priority   INT          NULL DEFAULT 2,
+    type       INT          NULL,
+    created_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP    NULL,
+    created_by INT          NULL,
+    updated_by INT          NULL,
+    deleted_by INT          NULL,
+    INDEX idx_systems_program_type (type)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS responsibilities (
+    id                INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name              VARCHAR(255) NULL,
+    telegram_username VARCHAR(255) NULL,
+    telegram_user     VARCHAR(255) NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS telegram_chat (
+    id          INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    chat_id     VARCHAR(64)  NULL,
+    chat_name   VARCHAR(255) NULL,
+    report_id   INT          NULL,
+    assignto_id INT          NULL,
+    solution_id INT          NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS tasks (
+    id            INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    ticket_no     VARCHAR(32)  NULL,
+    phone_id      INT          NULL,
+    phone_else    VARCHAR(255) NULL,
+    system_id     INT          NOT NULL DEFAULT 0,
+    issue_type    INT          NULL,
+    issue_else    VARCHAR(255) NULL,
+    department_id INT          NULL,
+    text          TEXT         NULL,
+    reported_by   VARCHAR(255) NULL,
+    assignto_id   INT          NULL,
+    assignto      VARCHAR(255) NULL,
+    status        INT          NOT NULL DEFAULT 0,
+    solution      TEXT         NULL,
+    solution_id   INT          NULL,
+    telegram_id   INT          NULL,
+    file_paths    JSON         NULL,
+    created_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    resolved_at   TIMESTAMP    NULL,
+    deleted_at    TIMESTAMP    NULL,
+    created_by    INT          NULL,
+    updated_by    INT          NULL,
+    INDEX idx_tasks_ticket_no (ticket_no),
+    INDEX idx_tasks_status (status),
+    INDEX idx_tasks_department (department_id),
+    INDEX idx_tasks_phone (phone_id),
+    INDEX idx_tasks_system (system_id),
+    INDEX idx_tasks_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS resolutions (
+    id          INT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    tasks_id    INT       NOT NULL,
+    text        TEXT      NULL,
+    telegram_id INT       NULL,
+    file_paths  JSON      NULL,
+    resolved_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_resolutions_task (tasks_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS progress (
+    id            INT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    task_id       INT       NOT NULL,
+    progress_text TEXT      NULL,
+    file_paths    JSON      NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_progress_task (task_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS scores (
+    department_id INT NOT NULL,
+    year          INT NOT NULL,
+    month         INT NOT NULL,
+    score         INT NOT NULL DEFAULT 100,
+    PRIMARY KEY (department_id, year, month)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS users (
+    id             INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    username       VARCHAR(100) NOT NULL,
+    password       VARCHAR(255) NOT NULL,
+    plain_password VARCHAR(255) NULL,
+    role           VARCHAR(20)  NOT NULL DEFAULT 'user',
+    created_at     TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at     TIMESTAMP    NULL,
+    UNIQUE KEY uq_users_username (username)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
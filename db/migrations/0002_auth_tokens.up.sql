@@ -0,0 +1,20 @@
+-- Token storage for signed access/refresh tokens.
+-- Refresh tokens and access token revocations live in the database so that
+-- logout and rotation survive restarts and are shared by every API replica.
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    jti        CHAR(36)  NOT NULL PRIMARY KEY,
+    user_id    INT       NOT NULL,
+    expires_at DATETIME  NOT NULL,
+    revoked_at DATETIME  NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_refresh_tokens_user (user_id),
+    INDEX idx_refresh_tokens_expires (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti        CHAR(36)  NOT NULL PRIMARY KEY,
+    expires_at DATETIME  NOT NULL,
+    revoked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_revoked_tokens_expires (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+ALTER TABLE users MODIFY role VARCHAR(20) NOT NULL DEFAULT 'user';
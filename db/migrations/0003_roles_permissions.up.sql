@@ -0,0 +1,34 @@
+-- Role based permissions.
+-- Roles and their permissions are data so that admins can adjust them through
+-- PUT /api/authEntry/roles/:role without a redeploy.
+
+ALTER TABLE users MODIFY role VARCHAR(50) NOT NULL DEFAULT 'user';
+
+CREATE TABLE IF NOT EXISTS roles (
+    name        VARCHAR(50)  NOT NULL PRIMARY KEY,
+    description VARCHAR(255) NULL,
+    created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role       VARCHAR(50) NOT NULL,
+    permission VARCHAR(50) NOT NULL,
+    PRIMARY KEY (role, permission),
+    CONSTRAINT fk_role_permissions_role FOREIGN KEY (role) REFERENCES roles (name) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT IGNORE INTO roles (name, description) VALUES
+    ('admin', 'Full access including user and role management'),
+    ('technician', 'Works on tasks: progress, resolutions and assignment'),
+    ('user', 'Reports problems and follows their status');
+
+INSERT IGNORE INTO role_permissions (role, permission) VALUES
+    ('admin', 'tasks:read'), ('admin', 'tasks:create'), ('admin', 'tasks:write'),
+    ('admin', 'tasks:assign'), ('admin', 'tasks:delete'), ('admin', 'progress:write'),
+    ('admin', 'resolutions:write'), ('admin', 'masterdata:read'), ('admin', 'masterdata:write'),
+    ('admin', 'scores:write'), ('admin', 'reports:export'), ('admin', 'users:manage'),
+    ('admin', 'roles:manage'),
+    ('technician', 'tasks:read'), ('technician', 'tasks:create'), ('technician', 'tasks:write'),
+    ('technician', 'tasks:assign'), ('technician', 'progress:write'), ('technician', 'resolutions:write'),
+    ('technician', 'masterdata:read'), ('technician', 'reports:export'),
+    ('user', 'tasks:read'), ('user', 'tasks:create'), ('user', 'masterdata:read');
@@ -0,0 +1,3 @@
+-- Plaintext passwords cannot be restored; the column comes back empty.
+DROP TABLE IF EXISTS password_reset_tokens;
+ALTER TABLE users ADD COLUMN plain_password VARCHAR(255) NULL AFTER password;
@@ -0,0 +1,25 @@
+-- Password lifecycle.
+-- Plaintext passwords are no longer stored: drop the column.
+-- MySQL has no DROP COLUMN IF EXISTS, so the drop is skipped through a prepared statement when the
+-- column is already gone (e.g. the database was cleaned up by hand before migrations were recorded).
+
+SET @drop_plain_password = (
+    SELECT IF(COUNT(*) > 0, 'ALTER TABLE users DROP COLUMN plain_password', 'DO 0')
+    FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND COLUMN_NAME = 'plain_password'
+);
+PREPARE drop_plain_password FROM @drop_plain_password;
+EXECUTE drop_plain_password;
+DEALLOCATE PREPARE drop_plain_password;
+
+-- One-time reset tokens issued by an admin. Only the SHA-256 of the token is stored.
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    token_hash CHAR(64)  NOT NULL PRIMARY KEY,
+    user_id    INT       NOT NULL,
+    created_by INT       NOT NULL,
+    expires_at DATETIME  NOT NULL,
+    used_at    DATETIME  NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_password_reset_tokens_user (user_id),
+    INDEX idx_password_reset_tokens_expires (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,25 @@
+-- Login brute-force protection and audit trail.
+
+-- Failed attempts per username. A row exists only while there are unforgiven failures or a lockout.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    username       VARCHAR(100) NOT NULL PRIMARY KEY,
+    failed_count   INT          NOT NULL DEFAULT 0,
+    lockout_count  INT          NOT NULL DEFAULT 0,
+    locked_until   DATETIME     NULL,
+    last_failed_at DATETIME     NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Every login attempt, successful or not
+CREATE TABLE IF NOT EXISTS login_history (
+    id             BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id        INT          NULL,
+    username       VARCHAR(100) NOT NULL,
+    ip_address     VARCHAR(45)  NULL,
+    user_agent     VARCHAR(255) NULL,
+    success        TINYINT(1)   NOT NULL,
+    failure_reason VARCHAR(50)  NULL,
+    created_at     TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_login_history_user (user_id, created_at),
+    INDEX idx_login_history_username (username, created_at),
+    INDEX idx_login_history_created (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,9 @@
+-- Shared rate limit counters.
+-- Used when RATE_LIMIT_STORE=mysql so that every API replica enforces the same limits.
+
+CREATE TABLE IF NOT EXISTS rate_limit_counters (
+    bucket   VARCHAR(255) NOT NULL PRIMARY KEY,
+    hits     INT          NOT NULL,
+    reset_at DATETIME(3)  NOT NULL,
+    INDEX idx_rate_limit_counters_reset (reset_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,9 @@
+-- Ticket number counters.
+-- One row per reset period (for example 202501 for monthly numbering); last_no is incremented
+-- atomically with LAST_INSERT_ID(last_no + 1) so concurrent reports never share a number.
+
+CREATE TABLE IF NOT EXISTS ticket_sequences (
+    period_key VARCHAR(16) NOT NULL PRIMARY KEY,
+    last_no    INT         NOT NULL,
+    updated_at TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,14 @@
+-- Task status history.
+-- One row per status change of a task; from_status is NULL for the initial status written when
+-- the task is reported. Status values are the models.TaskStatus constants.
+
+CREATE TABLE IF NOT EXISTS task_status_history (
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    task_id     INT          NOT NULL,
+    from_status INT          NULL,
+    to_status   INT          NOT NULL,
+    changed_by  INT          NULL,
+    reason      VARCHAR(500) NULL,
+    changed_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_task_status_history_task (task_id, changed_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
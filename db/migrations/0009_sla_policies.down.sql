@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS sla_events;
+
+ALTER TABLE tasks
+    DROP INDEX idx_tasks_resolution_due,
+    DROP COLUMN first_response_at,
+    DROP COLUMN resolution_due_at,
+    DROP COLUMN response_due_at,
+    DROP COLUMN sla_policy_id;
+
+DROP TABLE IF EXISTS sla_policies;
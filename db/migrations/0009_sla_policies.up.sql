@@ -0,0 +1,42 @@
+-- SLA policies.
+-- A policy sets the first-response and resolution targets for a program priority
+-- (systems_program.priority, 1 = highest). branch_id and issue_type_id narrow a policy;
+-- the most specific matching policy wins (branch + issue type, then branch, then issue type).
+
+CREATE TABLE IF NOT EXISTS sla_policies (
+    id                 INT AUTO_INCREMENT PRIMARY KEY,
+    priority           INT       NOT NULL,
+    branch_id          INT       NULL,
+    issue_type_id      INT       NULL,
+    response_minutes   INT       NOT NULL,
+    resolution_minutes INT       NOT NULL,
+    created_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    created_by         INT       NULL,
+    updated_by         INT       NULL,
+    INDEX idx_sla_policies_priority (priority)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT INTO sla_policies (priority, response_minutes, resolution_minutes) VALUES
+    (1, 30, 240),
+    (2, 120, 1440),
+    (3, 480, 4320);
+
+-- Due timestamps computed when a task is reported or edited
+ALTER TABLE tasks
+    ADD COLUMN sla_policy_id     INT       NULL AFTER status,
+    ADD COLUMN response_due_at   TIMESTAMP NULL AFTER sla_policy_id,
+    ADD COLUMN resolution_due_at TIMESTAMP NULL AFTER response_due_at,
+    ADD COLUMN first_response_at TIMESTAMP NULL AFTER resolution_due_at,
+    ADD INDEX idx_tasks_resolution_due (resolution_due_at);
+
+-- Warnings and breaches raised by the SLA checker; one row per task and kind
+CREATE TABLE IF NOT EXISTS sla_events (
+    id        INT AUTO_INCREMENT PRIMARY KEY,
+    task_id   INT         NOT NULL,
+    kind      VARCHAR(32) NOT NULL,
+    due_at    TIMESTAMP   NULL,
+    raised_at TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_sla_events_task_kind (task_id, kind),
+    INDEX idx_sla_events_raised_at (raised_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS holidays;
+DROP TABLE IF EXISTS working_hours;
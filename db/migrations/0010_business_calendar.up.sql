@@ -0,0 +1,34 @@
+-- Business calendar.
+-- working_hours holds the opening periods of each weekday (0 = Sunday); rows with a NULL branch_id
+-- are the default used by branches without their own rows. A day may have several periods
+-- (for example a lunch break). holidays with a NULL branch_id close every branch.
+
+CREATE TABLE IF NOT EXISTS working_hours (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    branch_id  INT       NULL,
+    weekday    TINYINT   NOT NULL,
+    start_time TIME      NOT NULL,
+    end_time   TIME      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by INT       NULL,
+    INDEX idx_working_hours_branch (branch_id, weekday)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT INTO working_hours (branch_id, weekday, start_time, end_time) VALUES
+    (NULL, 1, '08:00:00', '17:00:00'),
+    (NULL, 2, '08:00:00', '17:00:00'),
+    (NULL, 3, '08:00:00', '17:00:00'),
+    (NULL, 4, '08:00:00', '17:00:00'),
+    (NULL, 5, '08:00:00', '17:00:00');
+
+CREATE TABLE IF NOT EXISTS holidays (
+    id           INT AUTO_INCREMENT PRIMARY KEY,
+    branch_id    INT          NULL,
+    holiday_date DATE         NOT NULL,
+    name         VARCHAR(255) NOT NULL,
+    source       VARCHAR(16)  NOT NULL DEFAULT 'manual',
+    created_at   TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by   INT          NULL,
+    INDEX idx_holidays_date (holiday_date),
+    INDEX idx_holidays_branch (branch_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
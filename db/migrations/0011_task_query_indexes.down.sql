@@ -0,0 +1,7 @@
+ALTER TABLE tasks
+    DROP INDEX idx_tasks_status_created,
+    DROP INDEX idx_tasks_issue_type,
+    DROP INDEX idx_tasks_created_by,
+    DROP INDEX idx_tasks_assignto,
+    DROP INDEX idx_tasks_resolved_at,
+    DROP INDEX idx_tasks_updated_at;
@@ -0,0 +1,10 @@
+-- Indexes for the filters and sort keys of GET /api/v1/problems.
+-- InnoDB appends the primary key to every secondary index, so each index also serves the id tie-breaker.
+
+ALTER TABLE tasks
+    ADD INDEX idx_tasks_updated_at (updated_at),
+    ADD INDEX idx_tasks_resolved_at (resolved_at),
+    ADD INDEX idx_tasks_assignto (assignto_id),
+    ADD INDEX idx_tasks_created_by (created_by),
+    ADD INDEX idx_tasks_issue_type (issue_type),
+    ADD INDEX idx_tasks_status_created (status, created_at);
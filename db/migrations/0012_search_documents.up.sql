@@ -0,0 +1,30 @@
+-- Full-text search index over task text, progress entries and resolutions.
+-- One row per searchable record; the repositories keep it in sync in the same transaction as the record.
+-- The ngram parser splits text into 2-character tokens (ngram_token_size), so Thai text without spaces is searchable.
+
+CREATE TABLE IF NOT EXISTS search_documents (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    source     VARCHAR(16) NOT NULL,
+    source_id  INT         NOT NULL,
+    task_id    INT         NOT NULL,
+    body       MEDIUMTEXT  NOT NULL,
+    updated_at TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_search_documents_source (source, source_id),
+    INDEX idx_search_documents_task (task_id),
+    FULLTEXT INDEX ft_search_documents_body (body) WITH PARSER ngram
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT INTO search_documents (source, source_id, task_id, body)
+SELECT 'task', id, id, CONCAT_WS('\n', NULLIF(text, ''), NULLIF(issue_else, ''))
+FROM tasks
+WHERE IFNULL(text, '') <> '' OR IFNULL(issue_else, '') <> '';
+
+INSERT INTO search_documents (source, source_id, task_id, body)
+SELECT 'progress', id, task_id, progress_text
+FROM progress
+WHERE IFNULL(progress_text, '') <> '';
+
+INSERT INTO search_documents (source, source_id, task_id, body)
+SELECT 'resolution', id, tasks_id, text
+FROM resolutions
+WHERE IFNULL(text, '') <> '' AND tasks_id IS NOT NULL;
@@ -0,0 +1,22 @@
+ALTER TABLE systems_program DROP INDEX idx_systems_program_deleted_at;
+ALTER TABLE ip_phones DROP INDEX idx_ip_phones_deleted_at;
+ALTER TABLE departments DROP INDEX idx_departments_deleted_at;
+ALTER TABLE branches DROP INDEX idx_branches_deleted_at;
+
+ALTER TABLE users
+    DROP INDEX idx_users_deleted_at,
+    DROP COLUMN deleted_by;
+
+ALTER TABLE progress
+    DROP INDEX idx_progress_deleted_at,
+    DROP COLUMN deleted_by,
+    DROP COLUMN deleted_at;
+
+ALTER TABLE resolutions
+    DROP INDEX idx_resolutions_deleted_at,
+    DROP COLUMN deleted_by,
+    DROP COLUMN deleted_at;
+
+ALTER TABLE tasks
+    DROP INDEX idx_tasks_deleted_at,
+    DROP COLUMN deleted_by;
@@ -0,0 +1,25 @@
+-- Soft delete for tasks, resolutions, progress, master data and users.
+-- Deleted rows keep deleted_at/deleted_by until they are restored or purged by the retention job.
+
+ALTER TABLE tasks
+    ADD COLUMN deleted_by INT NULL AFTER deleted_at,
+    ADD INDEX idx_tasks_deleted_at (deleted_at);
+
+ALTER TABLE resolutions
+    ADD COLUMN deleted_at TIMESTAMP NULL,
+    ADD COLUMN deleted_by INT       NULL,
+    ADD INDEX idx_resolutions_deleted_at (deleted_at);
+
+ALTER TABLE progress
+    ADD COLUMN deleted_at TIMESTAMP NULL,
+    ADD COLUMN deleted_by INT       NULL,
+    ADD INDEX idx_progress_deleted_at (deleted_at);
+
+ALTER TABLE users
+    ADD COLUMN deleted_by INT NULL AFTER deleted_at,
+    ADD INDEX idx_users_deleted_at (deleted_at);
+
+ALTER TABLE branches ADD INDEX idx_branches_deleted_at (deleted_at);
+ALTER TABLE departments ADD INDEX idx_departments_deleted_at (deleted_at);
+ALTER TABLE ip_phones ADD INDEX idx_ip_phones_deleted_at (deleted_at);
+ALTER TABLE systems_program ADD INDEX idx_systems_program_deleted_at (deleted_at);
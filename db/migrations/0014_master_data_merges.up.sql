@@ -0,0 +1,13 @@
+-- Audit log of merged branches, departments, IP phones and programs.
+-- Each row records the survivor, the merged source records and how many dependents moved.
+
+CREATE TABLE IF NOT EXISTS master_data_merges (
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    kind        VARCHAR(32) NOT NULL,
+    survivor_id INT         NOT NULL,
+    source_ids  JSON        NOT NULL,
+    summary     JSON        NOT NULL,
+    merged_by   INT         NULL,
+    merged_at   TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_master_data_merges_kind (kind, merged_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
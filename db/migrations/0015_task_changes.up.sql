@@ -0,0 +1,15 @@
+-- Field-level audit log of tasks, progress entries and resolutions.
+-- One row per change; fields is a JSON array of {"field", "old", "new"} and entity_id is the id of
+-- the progress entry or resolution, or the task id. Status changes stay in task_status_history.
+
+CREATE TABLE IF NOT EXISTS task_changes (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    task_id    INT         NOT NULL,
+    entity     VARCHAR(16) NOT NULL,
+    entity_id  INT         NOT NULL,
+    action     VARCHAR(16) NOT NULL,
+    fields     JSON        NOT NULL,
+    changed_by INT         NULL,
+    changed_at TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_task_changes_task (task_id, changed_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+ALTER TABLE resolutions DROP COLUMN version;
+ALTER TABLE progress DROP COLUMN version;
+ALTER TABLE tasks DROP COLUMN version;
@@ -0,0 +1,6 @@
+-- Row versions for optimistic locking of tasks, progress entries and resolutions.
+-- Every update increments version; GET responses expose it as the ETag and updates must send it back in If-Match.
+
+ALTER TABLE tasks ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE progress ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE resolutions ADD COLUMN version INT NOT NULL DEFAULT 1;
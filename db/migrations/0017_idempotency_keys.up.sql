@@ -0,0 +1,12 @@
+-- Responses of create requests sent with an Idempotency-Key, replayed when the request is retried.
+-- bucket is the SHA-256 of the user, method, path and key; status_code is NULL while the first request runs.
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    bucket       CHAR(64)     NOT NULL PRIMARY KEY,
+    status_code  INT          NULL,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    body         MEDIUMBLOB   NULL,
+    created_at   DATETIME(3)  NOT NULL,
+    expires_at   DATETIME(3)  NOT NULL,
+    INDEX idx_idempotency_keys_expires (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,17 @@
+-- Reports attached to an open task as duplicates instead of opening their own ticket (DUPLICATE_POLICY=link).
+-- similarity is the text similarity with the task when the report was attached.
+
+CREATE TABLE IF NOT EXISTS task_incidents (
+    id            INT AUTO_INCREMENT PRIMARY KEY,
+    task_id       INT          NOT NULL,
+    phone_id      INT          NULL,
+    phone_else    VARCHAR(255) NULL,
+    department_id INT          NULL,
+    text          TEXT         NULL,
+    reported_by   VARCHAR(255) NULL,
+    file_paths    JSON         NULL,
+    similarity    DECIMAL(4,3) NOT NULL DEFAULT 0,
+    created_by    INT          NULL,
+    created_at    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_task_incidents_task (task_id, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,15 @@
+-- Typed links between tasks. Each link is stored once: duplicate_of (task duplicates linked task),
+-- parent_of (task is the parent of linked task), blocks (task blocks linked task) or related (lower id first).
+-- inherit passes a resolution from the parent to the child or from the original to the duplicate.
+
+CREATE TABLE IF NOT EXISTS task_links (
+    id             INT AUTO_INCREMENT PRIMARY KEY,
+    task_id        INT         NOT NULL,
+    linked_task_id INT         NOT NULL,
+    type           VARCHAR(16) NOT NULL,
+    inherit        TINYINT(1)  NOT NULL DEFAULT 0,
+    created_by     INT         NULL,
+    created_at     TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_task_links (task_id, linked_task_id, type),
+    INDEX idx_task_links_linked (linked_task_id, type)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission = 'problemrecords:write';
+DROP TABLE IF EXISTS problem_record_tasks;
+DROP TABLE IF EXISTS problem_records;
@@ -0,0 +1,31 @@
+-- Problem records: the underlying defect behind recurring tasks, with its root cause and known-error workaround.
+-- A task belongs to at most one problem record (problem_record_tasks.task_id is unique).
+
+CREATE TABLE IF NOT EXISTS problem_records (
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    title       VARCHAR(255) NOT NULL,
+    system_id   INT          NULL,
+    description TEXT         NULL,
+    root_cause  TEXT         NULL,
+    workaround  TEXT         NULL,
+    status      VARCHAR(16)  NOT NULL DEFAULT 'open',
+    created_by  INT          NULL,
+    updated_by  INT          NULL,
+    created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP    NULL,
+    INDEX idx_problem_records_status (status),
+    INDEX idx_problem_records_system (system_id, status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS problem_record_tasks (
+    problem_record_id INT       NOT NULL,
+    task_id           INT       NOT NULL,
+    attached_by       INT       NULL,
+    attached_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (problem_record_id, task_id),
+    UNIQUE KEY uq_problem_record_tasks_task (task_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT IGNORE INTO role_permissions (role, permission) VALUES
+    ('admin', 'problemrecords:write'), ('technician', 'problemrecords:write');
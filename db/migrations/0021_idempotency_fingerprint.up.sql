@@ -0,0 +1,5 @@
+-- fingerprint is the SHA-256 of the request body (form fields and file names and sizes for multipart),
+-- so a retry that reuses a key for a different submission is rejected instead of replayed.
+-- Keys stored before this column existed keep an empty fingerprint and are not compared.
+
+ALTER TABLE idempotency_keys ADD COLUMN fingerprint CHAR(64) NOT NULL DEFAULT '' AFTER bucket;
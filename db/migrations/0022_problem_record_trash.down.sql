@@ -0,0 +1,7 @@
+-- Trashed problem records would show up as live ones again, so they are dropped first.
+DELETE FROM problem_records WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE problem_records
+    DROP INDEX idx_problem_records_deleted_at,
+    DROP COLUMN deleted_by,
+    DROP COLUMN deleted_at;
@@ -0,0 +1,6 @@
+-- Deleting a problem record moves it to the trash like the other records; its tasks are detached at delete time.
+
+ALTER TABLE problem_records
+    ADD COLUMN deleted_at TIMESTAMP NULL,
+    ADD COLUMN deleted_by INT       NULL,
+    ADD INDEX idx_problem_records_deleted_at (deleted_at);
@@ -3,8 +3,8 @@ package handlers
 import (
 	"log"
 	"net/url"
-	"reports-api/db"
 	"reports-api/models"
+	"reports-api/repository"
 	"reports-api/utils"
 	"strconv"
 	"strings"
@@ -26,36 +26,11 @@ func ListBranchesHandler(c *fiber.Ctx) error {
 	pagination := utils.GetPaginationParams(c)
 	offset := utils.CalculateOffset(pagination.Page, pagination.Limit)
 
-	// Get total count
-	var total int
-	err := db.DB.QueryRow(`SELECT COUNT(*) FROM branches WHERE deleted_at IS NULL`).Scan(&total)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count branches"})
-	}
-
-	// Get paginated data
-	rows, err := db.DB.Query(`
-		SELECT id, name, created_at, updated_at, deleted_at, created_by, updated_by, deleted_by 
-		FROM branches 
-		WHERE deleted_at IS NULL 
-		ORDER BY id DESC 
-		LIMIT ? OFFSET ?
-	`, pagination.Limit, offset)
+	branches, total, err := repos.Branches.List(c.UserContext(), repository.ListQuery{Limit: pagination.Limit, Offset: offset})
 	if err != nil {
+		log.Printf("Error listing branches: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query branches"})
 	}
-	defer rows.Close()
-
-	var branches []models.Branch
-	for rows.Next() {
-		var b models.Branch
-		err := rows.Scan(&b.ID, &b.Name, &b.CreatedAt, &b.UpdatedAt, &b.DeletedAt, &b.CreatedBy, &b.UpdatedBy, &b.DeletedBy)
-		if err != nil {
-			log.Printf("Error scanning branch: %v", err)
-			continue
-		}
-		branches = append(branches, b)
-	}
 
 	log.Printf("Getting branches Success")
 	return c.JSON(models.PaginatedResponse{
@@ -86,12 +61,16 @@ func CreateBranchHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	res, err := db.DB.Exec(`INSERT INTO branches (name, created_by, updated_by) VALUES (?, ?, ?)`, req.Name, req.CreatedBy, req.UpdatedBy)
+	actor, err := utils.AuditActor(c, req.CreatedBy, req.UpdatedBy)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	id, err := repos.Branches.Create(c.UserContext(), req.Name, actor)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert branch"})
 	}
 
-	id, _ := res.LastInsertId()
 	return c.JSON(fiber.Map{"success": true, "id": id})
 }
 
@@ -118,8 +97,12 @@ func UpdateBranchHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	_, err = db.DB.Exec(`UPDATE branches SET name=?, updated_by=?, updated_at=CURRENT_TIMESTAMP WHERE id=? AND deleted_at IS NULL`, req.Name, req.UpdatedBy, id)
+	actor, err := utils.AuditActor(c, req.UpdatedBy)
 	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	if err := repos.Branches.Update(c.UserContext(), id, req.Name, actor); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update branch"})
 	}
 
@@ -127,29 +110,37 @@ func UpdateBranchHandler(c *fiber.Ctx) error {
 }
 
 // @Summary Delete branch
-// @Description Delete a branch
+// @Description Move a branch to the trash. While live departments, IP phones, tasks and scores depend on it, mode decides what happens:
+// @Description block (default) answers 409 with the dependents, cascade moves them to the trash too,
+// @Description reassign moves them to reassign_to first.
 // @Tags branches
 // @Accept json
 // @Produce json
 // @Param id path string true "Branch ID"
+// @Param mode query string false "block, cascade or reassign" default(block)
+// @Param reassign_to query int false "Branch ID receiving the dependents in reassign mode"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/branch/delete/{id} [delete]
 func DeleteBranchHandler(c *fiber.Ctx) error {
-	idStr := c.Params("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
-	}
-
-	_, err = db.DB.Exec(`DELETE FROM branches WHERE id=?`, id)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete branch"})
-	}
+	return deleteMasterData(c, models.TrashBranches, "Branch not found", "Failed to delete branch")
+}
 
-	log.Printf("Deleted branch ID: %d", id)
-	return c.JSON(fiber.Map{"success": true})
+// @Summary Get branch dependents
+// @Description Count the live departments, IP phones, tasks and scores that depend on a branch
+// @Tags branches
+// @Produce json
+// @Param id path string true "Branch ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/branch/dependents/{id} [get]
+func GetBranchDependentsHandler(c *fiber.Ctx) error {
+	return masterDataDependents(c, models.TrashBranches, "Branch not found")
 }
 
 // @Summary Get branch details
@@ -169,37 +160,12 @@ func GetBranchDetailHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
 	}
 
-	var branchDetail models.BranchDetail
-	err = db.DB.QueryRow(`
-		SELECT id, name, created_at, updated_at 
-		FROM branches 
-		WHERE id = ? AND deleted_at IS NULL
-	`, id).Scan(&branchDetail.ID, &branchDetail.Name, &branchDetail.CreatedAt, &branchDetail.UpdatedAt)
-
+	branchDetail, err := repos.Branches.Get(c.UserContext(), id)
 	if err != nil {
 		log.Printf("Error fetching branch details: %v", err)
 		return c.Status(404).JSON(fiber.Map{"error": "Branch not found"})
 	}
 
-	err = db.DB.QueryRow(`
-		SELECT COUNT(*) 
-		FROM departments 
-		WHERE branch_id = ? AND deleted_at IS NULL
-	`, id).Scan(&branchDetail.DepartmentsCount)
-	if err != nil {
-		log.Printf("Error counting departments: %v", err)
-	}
-
-	err = db.DB.QueryRow(`
-		SELECT COUNT(*) FROM ip_phones ip
-		JOIN departments d ON ip.department_id = d.id
-		WHERE d.branch_id = ? AND ip.deleted_at IS NULL
-	`, id).Scan(&branchDetail.IPPhonesCount)
-
-	if err != nil {
-		log.Printf("Error counting IP phones: %v", err)
-	}
-
 	log.Printf("Getting branch details Success for ID: %d", id)
 	return c.JSON(fiber.Map{"success": true, "data": branchDetail})
 }
@@ -242,41 +208,11 @@ func ListBranchesQueryHandler(c *fiber.Ctx) error {
 	decodedQuery = strings.ReplaceAll(decodedQuery, "'", "")
 	decodedQuery = strings.ReplaceAll(decodedQuery, "\"", "")
 
-	searchPattern := "%" + decodedQuery + "%"
-
-	// Get total count
-	var total int
-	err = db.DB.QueryRow(`
-		SELECT COUNT(*) FROM branches
-		WHERE deleted_at IS NULL AND name LIKE ?
-	`, searchPattern).Scan(&total)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count search results"})
-	}
-
-	// Get search results with pagination
-	rows, err := db.DB.Query(`
-		SELECT id, name, created_at, updated_at, deleted_at, created_by, updated_by, deleted_by
-		FROM branches
-		WHERE deleted_at IS NULL AND name LIKE ?
-		ORDER BY id DESC
-		LIMIT ? OFFSET ?
-	`, searchPattern, pagination.Limit, offset)
+	branches, total, err := repos.Branches.List(c.UserContext(), repository.ListQuery{Search: decodedQuery, Limit: pagination.Limit, Offset: offset})
 	if err != nil {
+		log.Printf("Error searching branches: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to search branches"})
 	}
-	defer rows.Close()
-
-	var branches []models.Branch
-	for rows.Next() {
-		var b models.Branch
-		err := rows.Scan(&b.ID, &b.Name, &b.CreatedAt, &b.UpdatedAt, &b.DeletedAt, &b.CreatedBy, &b.UpdatedBy, &b.DeletedBy)
-		if err != nil {
-			log.Printf("Error scanning branch: %v", err)
-			continue
-		}
-		branches = append(branches, b)
-	}
 
 	log.Printf("Searching branches with query: %s, found %d results", query, len(branches))
 	return c.JSON(models.PaginatedResponse{
@@ -290,3 +226,31 @@ func ListBranchesQueryHandler(c *fiber.Ctx) error {
 		},
 	})
 }
+
+// @Summary Merge branches
+// @Description Move the departments of the source branches to the survivor, move the sources to the trash and record the merge.
+// @Description With dry_run nothing changes and the response shows what would move.
+// @Tags branches
+// @Accept json
+// @Produce json
+// @Param request body models.MergeRequest true "Survivor, sources and dry run"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/branch/merge [post]
+func MergeBranchesHandler(c *fiber.Ctx) error {
+	return mergeMasterData(c, models.TrashBranches)
+}
+
+// @Summary Find duplicate branches
+// @Description Suggest merge candidates: pairs of branches with similar names
+// @Tags branches
+// @Produce json
+// @Param threshold query number false "Minimum similarity between 0 and 1" default(0.8)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/branch/duplicates [get]
+func GetBranchDuplicatesHandler(c *fiber.Ctx) error {
+	return masterDataDuplicates(c, models.TrashBranches)
+}
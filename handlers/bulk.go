@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"reports-api/config"
+	"reports-api/handlers/common"
+	"reports-api/models"
+	"reports-api/repository"
+	"reports-api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// bulkRequestError is an invalid bulk request, answered with 400
+type bulkRequestError string
+
+func (e bulkRequestError) Error() string { return string(e) }
+
+// bulkRequestErrorf formats a bulkRequestError
+func bulkRequestErrorf(format string, args ...any) error {
+	return bulkRequestError(fmt.Sprintf(format, args...))
+}
+
+// bulkTaskIDs resolves the tasks selected by ids or by filter, at most BULK_MAX_TASKS of them
+func bulkTaskIDs(c *fiber.Ctx, req models.BulkTaskRequest) ([]int, error) {
+	maxTasks := config.AppConfig.Bulk.MaxTasks
+	if len(req.IDs) > 0 && req.Filter != "" {
+		return nil, bulkRequestErrorf("use either ids or filter")
+	}
+
+	if req.Filter == "" {
+		if len(req.IDs) == 0 {
+			return nil, bulkRequestErrorf("ids or filter is required")
+		}
+		if len(req.IDs) > maxTasks {
+			return nil, bulkRequestErrorf("at most %d tasks per call", maxTasks)
+		}
+		seen := make(map[int]bool, len(req.IDs))
+		ids := make([]int, 0, len(req.IDs))
+		for _, id := range req.IDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+	}
+
+	values, err := url.ParseQuery(req.Filter)
+	if err != nil {
+		return nil, bulkRequestErrorf("invalid filter")
+	}
+	search, _, err := parseTaskSearch(valuesQuery(values))
+	if err != nil {
+		return nil, bulkRequestErrorf("%v", err)
+	}
+	search.Limit = maxTasks
+
+	result, err := repos.Tasks.Search(c.UserContext(), search)
+	if err != nil {
+		return nil, err
+	}
+	if result.Total > maxTasks {
+		return nil, bulkRequestErrorf("filter matches %d tasks, at most %d per call", result.Total, maxTasks)
+	}
+	if len(result.Tasks) == 0 {
+		return nil, bulkRequestErrorf("filter matches no tasks")
+	}
+	ids := make([]int, 0, len(result.Tasks))
+	for _, t := range result.Tasks {
+		ids = append(ids, t.ID)
+	}
+	return ids, nil
+}
+
+// runBulk resolves the selected tasks, applies fn to them and queues a Telegram redraw of every changed task.
+// validate checks the action fields of the request; a bulkRequestError is answered with 400.
+func runBulk(c *fiber.Ctx, action string, validate func(context.Context, models.BulkTaskRequest) error, fn func(models.BulkTaskRequest, int) common.BulkItemFunc, telegram, notifyAssignee bool) error {
+	var req models.BulkTaskRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := validate(c.UserContext(), req); err != nil {
+		var reqErr bulkRequestError
+		if errors.As(err, &reqErr) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Printf("Error validating bulk %s: %v", action, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to run bulk operation"})
+	}
+
+	ids, err := bulkTaskIDs(c, req)
+	if err != nil {
+		var reqErr bulkRequestError
+		if errors.As(err, &reqErr) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Printf("Error selecting tasks for bulk %s: %v", action, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query tasks"})
+	}
+
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	result, err := common.RunBulk(c.UserContext(), repos, ids, req.Atomic, fn(req, actor))
+	if err != nil {
+		log.Printf("Error running bulk %s: %v", action, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to run bulk operation"})
+	}
+	if result.RolledBack {
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": models.ErrBulkAborted.Error(), "data": result})
+	}
+
+	if telegram {
+		for _, item := range result.Items {
+			if item.Success {
+				queueTaskTelegram(notifyAssignee, item.ID)
+			}
+		}
+	}
+	log.Printf("Bulk %s: %d succeeded, %d failed by user ID: %d", action, result.Succeeded, result.Failed, actor)
+	return c.JSON(fiber.Map{"success": result.Failed == 0, "data": result})
+}
+
+// @Summary Bulk assign tasks
+// @Description Assign the tasks selected by ids or filter; each task is reported separately
+// @Tags problems
+// @Accept json
+// @Produce json
+// @Param request body models.BulkTaskRequest true "ids or filter, assignedto_id and assign_to"
+// @Success 200 {object} models.BulkResult
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problems/bulk/assign [post]
+func BulkAssignTasksHandler(c *fiber.Ctx) error {
+	return runBulk(c, "assign", func(_ context.Context, req models.BulkTaskRequest) error {
+		if req.AssignedtoID <= 0 {
+			return bulkRequestError("assignedto_id is required")
+		}
+		return nil
+	}, func(req models.BulkTaskRequest, actor int) common.BulkItemFunc {
+		return common.BulkAssign(req.AssignedtoID, req.Assignto, actor)
+	}, true, true)
+}
+
+// @Summary Bulk change task status
+// @Description Move the tasks selected by ids or filter to a status; illegal transitions fail their task only
+// @Tags problems
+// @Accept json
+// @Produce json
+// @Param request body models.BulkTaskRequest true "ids or filter, status and reason"
+// @Success 200 {object} models.BulkResult
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problems/bulk/status [post]
+func BulkTaskStatusHandler(c *fiber.Ctx) error {
+	return runBulk(c, "status", func(_ context.Context, req models.BulkTaskRequest) error {
+		if req.Status == nil || !req.Status.Valid() {
+			return bulkRequestError("Invalid status")
+		}
+		return nil
+	}, func(req models.BulkTaskRequest, actor int) common.BulkItemFunc {
+		return common.BulkStatus(*req.Status, req.Reason, actor)
+	}, true, false)
+}
+
+// @Summary Bulk change task department
+// @Description Move the tasks selected by ids or filter to a department
+// @Tags problems
+// @Accept json
+// @Produce json
+// @Param request body models.BulkTaskRequest true "ids or filter and department_id"
+// @Success 200 {object} models.BulkResult
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problems/bulk/department [post]
+func BulkTaskDepartmentHandler(c *fiber.Ctx) error {
+	return runBulk(c, "department", func(ctx context.Context, req models.BulkTaskRequest) error {
+		return bulkTargetExists(req.DepartmentID, "department_id", func(id int) error {
+			_, err := repos.Departments.Get(ctx, id)
+			return err
+		})
+	}, func(req models.BulkTaskRequest, actor int) common.BulkItemFunc {
+		return common.BulkDepartment(req.DepartmentID, actor)
+	}, true, false)
+}
+
+// @Summary Bulk change task program
+// @Description Move the tasks selected by ids or filter to a program
+// @Tags problems
+// @Accept json
+// @Produce json
+// @Param request body models.BulkTaskRequest true "ids or filter and program_id"
+// @Success 200 {object} models.BulkResult
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problems/bulk/program [post]
+func BulkTaskProgramHandler(c *fiber.Ctx) error {
+	return runBulk(c, "program", func(ctx context.Context, req models.BulkTaskRequest) error {
+		return bulkTargetExists(req.ProgramID, "program_id", func(id int) error {
+			_, err := repos.Programs.Get(ctx, id)
+			return err
+		})
+	}, func(req models.BulkTaskRequest, actor int) common.BulkItemFunc {
+		return common.BulkProgram(req.ProgramID, actor)
+	}, true, false)
+}
+
+// @Summary Bulk add task progress
+// @Description Add the same progress note to the open tasks selected by ids or filter
+// @Tags problems
+// @Accept json
+// @Produce json
+// @Param request body models.BulkTaskRequest true "ids or filter and text"
+// @Success 200 {object} models.BulkResult
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problems/bulk/progress [post]
+func BulkTaskProgressHandler(c *fiber.Ctx) error {
+	return runBulk(c, "progress", func(_ context.Context, req models.BulkTaskRequest) error {
+		if req.Text == "" {
+			return bulkRequestError("text is required")
+		}
+		return nil
+	}, func(req models.BulkTaskRequest, actor int) common.BulkItemFunc {
+		return common.BulkProgress(req.Text, actor)
+	}, true, false)
+}
+
+// @Summary Bulk delete tasks
+// @Description Move the tasks selected by ids or filter to the trash
+// @Tags problems
+// @Accept json
+// @Produce json
+// @Param request body models.BulkTaskRequest true "ids or filter"
+// @Success 200 {object} models.BulkResult
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problems/bulk/delete [post]
+func BulkDeleteTasksHandler(c *fiber.Ctx) error {
+	return runBulk(c, "delete", func(context.Context, models.BulkTaskRequest) error { return nil }, func(_ models.BulkTaskRequest, actor int) common.BulkItemFunc {
+		return common.BulkDelete(actor)
+	}, false, false)
+}
+
+// bulkTargetExists checks the department or program a bulk call moves tasks to
+func bulkTargetExists(id int, field string, get func(int) error) error {
+	if id <= 0 {
+		return bulkRequestErrorf("%s is required", field)
+	}
+	if err := get(id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return bulkRequestErrorf("%s %d not found", field, id)
+		}
+		return err
+	}
+	return nil
+}
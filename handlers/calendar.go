@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"reports-api/handlers/common"
+	"reports-api/models"
+	"reports-api/repository"
+	"reports-api/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxICSSize limits the size of an imported holiday calendar
+const maxICSSize = 1 << 20
+
+// optionalBranchID reads the branch_id query parameter; empty means the default schedule or every branch
+func optionalBranchID(c *fiber.Ctx) (*int, bool) {
+	raw := c.Query("branch_id")
+	if raw == "" {
+		return nil, true
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		return nil, false
+	}
+	return &id, true
+}
+
+// validDate reports whether s is a date in the 2006-01-02 layout
+func validDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// @Summary Get working hours
+// @Description Get the working hours of a branch, or the default schedule when branch_id is omitted
+// @Tags calendar
+// @Produce json
+// @Param branch_id query int false "Branch ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/calendar/hours [get]
+func GetWorkingHoursHandler(c *fiber.Ctx) error {
+	branchID, ok := optionalBranchID(c)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid branch_id"})
+	}
+
+	periods, err := repos.Calendar.WorkingHours(c.UserContext(), branchID)
+	if err != nil {
+		log.Printf("Error getting working hours: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query working hours"})
+	}
+	// สาขาที่ไม่มีเวลาทำการของตัวเองใช้ตารางเริ่มต้น
+	inherited := false
+	if branchID != nil && len(periods) == 0 {
+		if periods, err = repos.Calendar.WorkingHours(c.UserContext(), nil); err != nil {
+			log.Printf("Error getting default working hours: %v", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to query working hours"})
+		}
+		inherited = true
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"data":      models.WorkingHours{BranchID: branchID, Periods: periods},
+		"inherited": inherited,
+	})
+}
+
+// @Summary Replace working hours
+// @Description Replace the working hours of a branch, or the default schedule when branch_id is null.
+// @Description An empty period list makes a branch fall back to the default schedule.
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param hours body models.WorkingHours true "Working hours"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/calendar/hours/update [put]
+func UpdateWorkingHoursHandler(c *fiber.Ctx) error {
+	var req models.WorkingHours
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if req.BranchID != nil && *req.BranchID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid branch_id"})
+	}
+	for _, p := range req.Periods {
+		if _, _, err := common.ParseWorkingPeriod(p); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	err = repos.InTx(c.UserContext(), func(tx *repository.Repositories) error {
+		return tx.Calendar.ReplaceWorkingHours(c.UserContext(), req.BranchID, req.Periods, actor)
+	})
+	if err != nil {
+		log.Printf("Error replacing working hours: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update working hours"})
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// @Summary List holidays
+// @Description List holidays; with branch_id the company-wide holidays are included
+// @Tags calendar
+// @Produce json
+// @Param branch_id query int false "Branch ID"
+// @Param from query string false "First date (2006-01-02)"
+// @Param to query string false "Last date (2006-01-02)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/calendar/holidays [get]
+func ListHolidaysHandler(c *fiber.Ctx) error {
+	branchID, ok := optionalBranchID(c)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid branch_id"})
+	}
+	q := models.HolidayQuery{BranchID: branchID, From: c.Query("from"), To: c.Query("to")}
+	if (q.From != "" && !validDate(q.From)) || (q.To != "" && !validDate(q.To)) {
+		return c.Status(400).JSON(fiber.Map{"error": "from and to must be dates in YYYY-MM-DD format"})
+	}
+
+	holidays, err := repos.Calendar.ListHolidays(c.UserContext(), q)
+	if err != nil {
+		log.Printf("Error listing holidays: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query holidays"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": holidays})
+}
+
+// @Summary Create holiday
+// @Description Add a holiday for a branch, or for every branch when branch_id is null
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param holiday body models.HolidayRequest true "Holiday data"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/calendar/holidays/create [post]
+func CreateHolidayHandler(c *fiber.Ctx) error {
+	var req models.HolidayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if !validDate(req.Date) {
+		return c.Status(400).JSON(fiber.Map{"error": "date must be in YYYY-MM-DD format"})
+	}
+	if req.Name == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "name is required"})
+	}
+	if req.BranchID != nil && *req.BranchID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid branch_id"})
+	}
+
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	created, err := repos.Calendar.CreateHoliday(c.UserContext(), req, models.HolidaySourceManual, actor)
+	if err != nil {
+		log.Printf("Error creating holiday: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert holiday"})
+	}
+	if !created {
+		return c.Status(409).JSON(fiber.Map{"error": "A holiday already exists on this date"})
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// @Summary Delete holiday
+// @Description Delete a holiday
+// @Tags calendar
+// @Produce json
+// @Param id path string true "Holiday ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/calendar/holidays/delete/{id} [delete]
+func DeleteHolidayHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	if err := repos.Calendar.DeleteHoliday(c.UserContext(), id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Holiday not found"})
+		}
+		log.Printf("Error deleting holiday %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete holiday"})
+	}
+
+	log.Printf("Deleted holiday ID: %d by user ID: %d", id, actor)
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// @Summary Import holidays from ICS
+// @Description Import the all-day events of an iCalendar file as holidays. Send the file as multipart field "file"
+// @Description or as the raw request body. Dates that already have a holiday are skipped.
+// @Tags calendar
+// @Accept multipart/form-data
+// @Produce json
+// @Param branch_id query int false "Branch ID; omit for company-wide holidays"
+// @Param file formData file false "iCalendar (.ics) file"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/calendar/holidays/import [post]
+func ImportHolidaysHandler(c *fiber.Ctx) error {
+	branchID, ok := optionalBranchID(c)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid branch_id"})
+	}
+
+	var src io.Reader
+	if fh, err := c.FormFile("file"); err == nil {
+		if fh.Size > maxICSSize {
+			return c.Status(400).JSON(fiber.Map{"error": "ICS file is too large"})
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Failed to read ICS file"})
+		}
+		defer f.Close()
+		src = f
+	} else {
+		body := c.Body()
+		if len(body) == 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "ICS file is required"})
+		}
+		if len(body) > maxICSSize {
+			return c.Status(400).JSON(fiber.Map{"error": "ICS file is too large"})
+		}
+		src = bytes.NewReader(body)
+	}
+
+	holidays, err := common.ParseICSHolidays(src)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	imported, skipped := 0, 0
+	err = repos.InTx(c.UserContext(), func(tx *repository.Repositories) error {
+		for _, h := range holidays {
+			h.BranchID = branchID
+			if strings.TrimSpace(h.Name) == "" {
+				h.Name = "Holiday"
+			}
+			created, err := tx.Calendar.CreateHoliday(c.UserContext(), h, models.HolidaySourceICS, actor)
+			if err != nil {
+				return err
+			}
+			if created {
+				imported++
+			} else {
+				skipped++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error importing holidays: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to import holidays"})
+	}
+
+	log.Printf("Imported %d holidays (%d skipped) by user ID: %d", imported, skipped, actor)
+	return c.JSON(fiber.Map{"success": true, "imported": imported, "skipped": skipped})
+}
+
+// @Summary Elapsed business time
+// @Description Working time between two timestamps on the calendar of a branch (default schedule when omitted)
+// @Tags calendar
+// @Produce json
+// @Param branch_id query int false "Branch ID"
+// @Param from query string true "Start time (RFC3339)"
+// @Param to query string true "End time (RFC3339)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/calendar/elapsed [get]
+func GetBusinessElapsedHandler(c *fiber.Ctx) error {
+	branchID, ok := optionalBranchID(c)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid branch_id"})
+	}
+	from, err := time.Parse(time.RFC3339, c.Query("from"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "from must be an RFC3339 timestamp"})
+	}
+	to, err := time.Parse(time.RFC3339, c.Query("to"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "to must be an RFC3339 timestamp"})
+	}
+
+	id := 0
+	if branchID != nil {
+		id = *branchID
+	}
+	cal, err := common.LoadBusinessCalendar(c.UserContext(), repos, id)
+	if err != nil {
+		log.Printf("Error loading business calendar: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load business calendar"})
+	}
+
+	elapsed := cal.Elapsed(from, to)
+	wall := time.Duration(0)
+	if to.After(from) {
+		wall = to.Sub(from)
+	}
+	return c.JSON(fiber.Map{
+		"success":          true,
+		"business_seconds": int64(elapsed.Seconds()),
+		"wall_seconds":     int64(wall.Seconds()),
+	})
+}
@@ -0,0 +1,149 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"reports-api/models"
+	"reports-api/repository"
+)
+
+// BulkItemFunc applies a bulk action to one task inside the bulk transaction.
+// It must report expected failures (see bulkItemError) before writing anything.
+type BulkItemFunc func(ctx context.Context, tx *repository.Repositories, task *models.TaskRecord) error
+
+// bulkItemError reports whether err fails only its task; any other error rolls back the whole call
+func bulkItemError(err error) bool {
+	return errors.Is(err, repository.ErrNotFound) ||
+		errors.Is(err, models.ErrInvalidStatusTransition) ||
+		errors.Is(err, models.ErrTaskClosed)
+}
+
+// RunBulk applies fn to every task in one transaction and reports each task.
+// A task that fails with an expected error is skipped, or rolls back every change when atomic is set;
+// any other error rolls back the call and is returned.
+func RunBulk(ctx context.Context, r *repository.Repositories, ids []int, atomic bool, fn BulkItemFunc) (*models.BulkResult, error) {
+	result := &models.BulkResult{}
+	err := r.InTx(ctx, func(tx *repository.Repositories) error {
+		result.Items = make([]models.BulkItemResult, 0, len(ids))
+		for _, id := range ids {
+			task, err := tx.Tasks.Get(ctx, id)
+			if err == nil {
+				err = fn(ctx, tx, task)
+			}
+			if err != nil && !bulkItemError(err) {
+				return err
+			}
+			item := models.BulkItemResult{ID: id, Success: err == nil}
+			if err != nil {
+				item.Error = err.Error()
+				if errors.Is(err, repository.ErrNotFound) {
+					item.Error = "task not found"
+				}
+			}
+			result.Items = append(result.Items, item)
+			if err != nil && atomic {
+				return models.ErrBulkAborted
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, models.ErrBulkAborted) {
+		result.RolledBack = true
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.Total = len(ids)
+	for _, item := range result.Items {
+		if item.Success && !result.RolledBack {
+			result.Succeeded++
+		} else if !item.Success {
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// BulkAssign assigns every task; pending and reopened tasks move to in progress as with a single assignment
+func BulkAssign(assigneeID int, assignto string, actor int) BulkItemFunc {
+	return func(ctx context.Context, tx *repository.Repositories, task *models.TaskRecord) error {
+		if err := tx.Tasks.Assign(ctx, task.ID, repository.TaskAssignment{
+			AssignedtoID: assigneeID,
+			Assignto:     assignto,
+			UpdatedBy:    actor,
+		}); err != nil {
+			return err
+		}
+		if err := RecordTaskChanges(ctx, tx, task, actor); err != nil {
+			return err
+		}
+		if task.Status == models.TaskStatusPending || task.Status == models.TaskStatusReopened {
+			_, err := TransitionTask(ctx, tx, task.ID, task.Status, models.TaskStatusInProgress, actor, "assigned to "+assignto)
+			return err
+		}
+		return nil
+	}
+}
+
+// BulkStatus moves every task to status; tasks already in it are left unchanged
+func BulkStatus(status models.TaskStatus, reason string, actor int) BulkItemFunc {
+	return func(ctx context.Context, tx *repository.Repositories, task *models.TaskRecord) error {
+		_, err := TransitionTask(ctx, tx, task.ID, task.Status, status, actor, reason)
+		return err
+	}
+}
+
+// BulkDepartment moves every task to a department and recomputes its SLA due dates
+func BulkDepartment(departmentID, actor int) BulkItemFunc {
+	return func(ctx context.Context, tx *repository.Repositories, task *models.TaskRecord) error {
+		if err := tx.Tasks.SetDepartment(ctx, task.ID, departmentID, actor); err != nil {
+			return err
+		}
+		if err := RecordTaskChanges(ctx, tx, task, actor); err != nil {
+			return err
+		}
+		return ApplyTaskSLA(ctx, tx, task.ID)
+	}
+}
+
+// BulkProgram moves every task to a program and recomputes its SLA due dates
+func BulkProgram(programID, actor int) BulkItemFunc {
+	return func(ctx context.Context, tx *repository.Repositories, task *models.TaskRecord) error {
+		if err := tx.Tasks.SetProgram(ctx, task.ID, programID, actor); err != nil {
+			return err
+		}
+		if err := RecordTaskChanges(ctx, tx, task, actor); err != nil {
+			return err
+		}
+		return ApplyTaskSLA(ctx, tx, task.ID)
+	}
+}
+
+// BulkProgress adds the same progress note to every open task and moves it to in progress
+func BulkProgress(text string, actor int) BulkItemFunc {
+	return func(ctx context.Context, tx *repository.Repositories, task *models.TaskRecord) error {
+		if task.Status.IsClosed() {
+			return models.ErrTaskClosed
+		}
+		if _, err := TransitionTask(ctx, tx, task.ID, task.Status, models.TaskStatusInProgress, actor, "progress added"); err != nil {
+			return err
+		}
+		progressID, err := tx.Progress.Create(ctx, task.ID, text, nil)
+		if err != nil {
+			return err
+		}
+		return RecordChange(ctx, tx, task.ID, models.ChangeEntityProgress, int(progressID), models.ChangeActionCreate, actor, DiffEntry("", text, nil, nil)...)
+	}
+}
+
+// BulkDelete moves every task to the trash
+func BulkDelete(actor int) BulkItemFunc {
+	return func(ctx context.Context, tx *repository.Repositories, task *models.TaskRecord) error {
+		if err := tx.Tasks.Delete(ctx, task.ID, actor); err != nil {
+			return err
+		}
+		return RecordChange(ctx, tx, task.ID, models.ChangeEntityTask, task.ID, models.ChangeActionDelete, actor)
+	}
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"reports-api/models"
+	"reports-api/repository"
+	"testing"
+)
+
+// fakeTasks serves task rows from memory; the methods it does not override panic when called
+type fakeTasks struct {
+	repository.TaskRepository
+	tasks map[int]*models.TaskRecord
+}
+
+func (f *fakeTasks) Get(ctx context.Context, id int) (*models.TaskRecord, error) {
+	task, ok := f.tasks[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return task, nil
+}
+
+var errBulkBroken = errors.New("connection lost")
+
+func TestRunBulk(t *testing.T) {
+	r := &repository.Repositories{Tasks: &fakeTasks{tasks: map[int]*models.TaskRecord{
+		1: {ID: 1}, 2: {ID: 2}, 3: {ID: 3}, 4: {ID: 4},
+	}}}
+	// task 2 fails as an expected per-task error, task 3 as an unexpected one
+	fn := func(ctx context.Context, tx *repository.Repositories, task *models.TaskRecord) error {
+		switch task.ID {
+		case 2:
+			return models.ErrInvalidStatusTransition
+		case 3:
+			return errBulkBroken
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		ids     []int
+		atomic  bool
+		want    *models.BulkResult
+		wantErr error
+	}{
+		{
+			name: "skips failed tasks",
+			ids:  []int{1, 2, 9, 4},
+			want: &models.BulkResult{Total: 4, Succeeded: 2, Failed: 2, Items: []models.BulkItemResult{
+				{ID: 1, Success: true},
+				{ID: 2, Error: models.ErrInvalidStatusTransition.Error()},
+				{ID: 9, Error: "task not found"},
+				{ID: 4, Success: true},
+			}},
+		},
+		{
+			name:   "atomic call rolls back at the first failure",
+			ids:    []int{1, 2, 4},
+			atomic: true,
+			want: &models.BulkResult{Total: 3, Succeeded: 0, Failed: 1, RolledBack: true, Items: []models.BulkItemResult{
+				{ID: 1, Success: true},
+				{ID: 2, Error: models.ErrInvalidStatusTransition.Error()},
+			}},
+		},
+		{
+			name:   "atomic call without failures",
+			ids:    []int{1, 4},
+			atomic: true,
+			want: &models.BulkResult{Total: 2, Succeeded: 2, Items: []models.BulkItemResult{
+				{ID: 1, Success: true},
+				{ID: 4, Success: true},
+			}},
+		},
+		{
+			name:    "unexpected error aborts the call",
+			ids:     []int{1, 3, 4},
+			wantErr: errBulkBroken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RunBulk(context.Background(), r, tt.ids, tt.atomic, fn)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RunBulk() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RunBulk() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
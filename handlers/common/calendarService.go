@@ -0,0 +1,285 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"reports-api/config"
+	"reports-api/models"
+	"reports-api/repository"
+	"strings"
+	"time"
+)
+
+// maxCalendarDays bounds the day-by-day walk of BusinessCalendar (about ten years)
+const maxCalendarDays = 3660
+
+type minuteRange struct {
+	start, end int // minutes since midnight
+}
+
+// BusinessCalendar computes elapsed and due times counting only working hours.
+// A calendar without any working period counts wall-clock time.
+type BusinessCalendar struct {
+	location *time.Location
+	hours    map[time.Weekday][]minuteRange
+	holidays map[string]bool
+}
+
+// NewBusinessCalendar builds a calendar from working periods and holiday dates (2006-01-02)
+func NewBusinessCalendar(periods []models.WorkingPeriod, holidays []string, loc *time.Location) (*BusinessCalendar, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	cal := &BusinessCalendar{
+		location: loc,
+		hours:    make(map[time.Weekday][]minuteRange),
+		holidays: make(map[string]bool, len(holidays)),
+	}
+	for _, p := range periods {
+		start, end, err := ParseWorkingPeriod(p)
+		if err != nil {
+			return nil, err
+		}
+		day := time.Weekday(p.Weekday)
+		cal.hours[day] = append(cal.hours[day], minuteRange{start, end})
+	}
+	for _, d := range holidays {
+		cal.holidays[d] = true
+	}
+	return cal, nil
+}
+
+// ParseWorkingPeriod validates a period and returns its start and end as minutes since midnight
+func ParseWorkingPeriod(p models.WorkingPeriod) (start, end int, err error) {
+	if p.Weekday < 0 || p.Weekday > 6 {
+		return 0, 0, fmt.Errorf("weekday must be between 0 (Sunday) and 6, got %d", p.Weekday)
+	}
+	start, err = parseTimeOfDay(p.StartTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err = parseTimeOfDay(p.EndTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("end_time %s must be after start_time %s", p.EndTime, p.StartTime)
+	}
+	return start, end, nil
+}
+
+// parseTimeOfDay accepts "15:04", "15:04:05" and "24:00" (end of day)
+func parseTimeOfDay(s string) (int, error) {
+	if s == "24:00" || s == "24:00:00" {
+		return 24 * 60, nil
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Hour()*60 + t.Minute(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+}
+
+// hasHours reports whether the calendar has at least one working period
+func (c *BusinessCalendar) hasHours() bool {
+	for _, periods := range c.hours {
+		if len(periods) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWorkingDay reports whether the day of t has working hours and is not a holiday
+func (c *BusinessCalendar) IsWorkingDay(t time.Time) bool {
+	t = t.In(c.location)
+	return len(c.hours[t.Weekday()]) > 0 && !c.holidays[t.Format("2006-01-02")]
+}
+
+// periodsOn returns the working periods of the day starting at midnight as absolute times
+func (c *BusinessCalendar) periodsOn(midnight time.Time) [][2]time.Time {
+	if c.holidays[midnight.Format("2006-01-02")] {
+		return nil
+	}
+	var out [][2]time.Time
+	for _, p := range c.hours[midnight.Weekday()] {
+		y, m, d := midnight.Date()
+		out = append(out, [2]time.Time{
+			time.Date(y, m, d, 0, p.start, 0, 0, c.location),
+			time.Date(y, m, d, 0, p.end, 0, 0, c.location),
+		})
+	}
+	return out
+}
+
+// startOfDay returns local midnight of the day of t
+func (c *BusinessCalendar) startOfDay(t time.Time) time.Time {
+	y, m, d := t.In(c.location).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, c.location)
+}
+
+// Elapsed returns the working time between from and to; it is 0 when to is not after from
+func (c *BusinessCalendar) Elapsed(from, to time.Time) time.Duration {
+	if !to.After(from) {
+		return 0
+	}
+	if !c.hasHours() {
+		return to.Sub(from)
+	}
+
+	var total time.Duration
+	day := c.startOfDay(from)
+	for i := 0; i < maxCalendarDays && day.Before(to); i++ {
+		for _, p := range c.periodsOn(day) {
+			start, end := p[0], p[1]
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			if end.After(start) {
+				total += end.Sub(start)
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return total
+}
+
+// Add returns the time at which d of working time has passed since start
+func (c *BusinessCalendar) Add(start time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return start
+	}
+	if !c.hasHours() {
+		return start.Add(d)
+	}
+
+	remaining := d
+	day := c.startOfDay(start)
+	for i := 0; i < maxCalendarDays; i++ {
+		for _, p := range c.periodsOn(day) {
+			from, end := p[0], p[1]
+			if !end.After(start) {
+				continue
+			}
+			if from.Before(start) {
+				from = start
+			}
+			available := end.Sub(from)
+			if remaining <= available {
+				return from.Add(remaining)
+			}
+			remaining -= available
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	// ปฏิทินไม่มีเวลาทำงานเพียงพอ (เช่น วันหยุดทั้งปี) ใช้เวลาปกติแทน
+	return start.Add(d)
+}
+
+// LoadBusinessCalendar builds the calendar of a branch from its working hours (or the default schedule)
+// and the company-wide and branch holidays
+func LoadBusinessCalendar(ctx context.Context, r *repository.Repositories, branchID int) (*BusinessCalendar, error) {
+	periods, err := r.Calendar.EffectiveWorkingHours(ctx, branchID)
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := r.Calendar.HolidayDates(ctx, branchID)
+	if err != nil {
+		return nil, err
+	}
+	return NewBusinessCalendar(periods, holidays, config.AppConfig.BusinessLocation)
+}
+
+// ParseICSHolidays reads the all-day VEVENTs of an iCalendar file as holidays.
+// Multi-day events become one holiday per day; timed events are skipped.
+func ParseICSHolidays(r io.Reader) ([]models.HolidayRequest, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// บรรทัดที่ขึ้นต้นด้วยช่องว่างเป็นส่วนต่อของบรรทัดก่อนหน้า (line folding)
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ICS: %w", err)
+	}
+
+	var holidays []models.HolidayRequest
+	var inCalendar, inEvent bool
+	var summary, dtStart, dtEnd string
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VCALENDAR"):
+			inCalendar = true
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			inEvent, summary, dtStart, dtEnd = true, "", "", ""
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			inEvent = false
+			days, err := icsEventDays(dtStart, dtEnd)
+			if err != nil {
+				return nil, err
+			}
+			for _, day := range days {
+				holidays = append(holidays, models.HolidayRequest{Date: day, Name: icsUnescape(summary)})
+			}
+		case !inEvent:
+		case name == "SUMMARY":
+			summary = value
+		case name == "DTSTART":
+			// เฉพาะกิจกรรมทั้งวัน (VALUE=DATE) เช่น 20250101
+			if len(value) == len("20060102") {
+				dtStart = value
+			}
+		case name == "DTEND":
+			dtEnd = value
+		}
+	}
+	if !inCalendar {
+		return nil, fmt.Errorf("not an iCalendar file")
+	}
+	return holidays, nil
+}
+
+// icsEventDays expands an all-day event into dates; DTEND is exclusive and defaults to the next day
+func icsEventDays(dtStart, dtEnd string) ([]string, error) {
+	if dtStart == "" {
+		return nil, nil
+	}
+	start, err := time.Parse("20060102", dtStart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DTSTART %q: %w", dtStart, err)
+	}
+	end := start.AddDate(0, 0, 1)
+	if len(dtEnd) >= len("20060102") {
+		if parsed, err := time.Parse("20060102", dtEnd[:8]); err == nil && parsed.After(start) {
+			end = parsed
+		}
+	}
+
+	var days []string
+	for d := start; d.Before(end) && len(days) < 366; d = d.AddDate(0, 0, 1) {
+		days = append(days, d.Format("2006-01-02"))
+	}
+	return days, nil
+}
+
+// icsUnescape reverts the text escaping of RFC 5545
+func icsUnescape(s string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
@@ -0,0 +1,105 @@
+package common
+
+import (
+	"reports-api/models"
+	"testing"
+	"time"
+)
+
+var testLocation = time.FixedZone("ICT", 7*60*60)
+
+// testCalendar works Monday to Friday 08:30-12:00 and 13:00-17:30; Friday 2026-10-23 is a holiday
+func testCalendar(t *testing.T) *BusinessCalendar {
+	t.Helper()
+	var periods []models.WorkingPeriod
+	for day := 1; day <= 5; day++ {
+		periods = append(periods,
+			models.WorkingPeriod{Weekday: day, StartTime: "08:30", EndTime: "12:00"},
+			models.WorkingPeriod{Weekday: day, StartTime: "13:00", EndTime: "17:30"},
+		)
+	}
+	cal, err := NewBusinessCalendar(periods, []string{"2026-10-23"}, testLocation)
+	if err != nil {
+		t.Fatalf("NewBusinessCalendar() error = %v", err)
+	}
+	return cal
+}
+
+// at returns 2026-10-day hh:mm in testLocation; 2026-10-19 is a Monday
+func at(day, hour, minute int) time.Time {
+	return time.Date(2026, time.October, day, hour, minute, 0, 0, testLocation)
+}
+
+func TestBusinessCalendarAdd(t *testing.T) {
+	cal := testCalendar(t)
+	tests := []struct {
+		name  string
+		start time.Time
+		d     time.Duration
+		want  time.Time
+	}{
+		{"within a period", at(19, 9, 0), time.Hour, at(19, 10, 0)},
+		{"skips lunch", at(19, 11, 30), time.Hour, at(19, 13, 30)},
+		{"ends exactly at the end of a period", at(19, 8, 30), 3*time.Hour + 30*time.Minute, at(19, 12, 0)},
+		{"starts before opening", at(19, 7, 0), 30 * time.Minute, at(19, 9, 0)},
+		{"carries over to the next day", at(19, 17, 0), time.Hour, at(20, 9, 0)},
+		{"skips the weekend", at(17, 10, 0), time.Hour, at(19, 9, 30)},
+		{"skips a holiday and the weekend", at(22, 17, 0), time.Hour, at(26, 9, 0)},
+		{"zero duration", at(18, 3, 0), 0, at(18, 3, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cal.Add(tt.start, tt.d); !got.Equal(tt.want) {
+				t.Errorf("Add(%v, %v) = %v, want %v", tt.start, tt.d, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBusinessCalendarElapsed(t *testing.T) {
+	cal := testCalendar(t)
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     time.Duration
+	}{
+		{"within a period", at(19, 9, 0), at(19, 10, 0), time.Hour},
+		{"across lunch", at(19, 11, 0), at(19, 14, 0), 2 * time.Hour},
+		{"whole working day", at(19, 0, 0), at(20, 0, 0), 8 * time.Hour},
+		{"over the weekend", at(16, 17, 0), at(19, 9, 0), time.Hour},
+		{"over a holiday", at(22, 8, 0), at(26, 8, 0), 8 * time.Hour},
+		{"outside working hours", at(19, 18, 0), at(19, 23, 0), 0},
+		{"to before from", at(19, 10, 0), at(19, 9, 0), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cal.Elapsed(tt.from, tt.to); got != tt.want {
+				t.Errorf("Elapsed(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBusinessCalendarAddElapsedRoundTrip(t *testing.T) {
+	cal := testCalendar(t)
+	start := at(19, 10, 15)
+	for _, d := range []time.Duration{time.Minute, 2 * time.Hour, 8 * time.Hour, 40 * time.Hour} {
+		if got := cal.Elapsed(start, cal.Add(start, d)); got != d {
+			t.Errorf("Elapsed(start, Add(start, %v)) = %v", d, got)
+		}
+	}
+}
+
+func TestBusinessCalendarWithoutHours(t *testing.T) {
+	cal, err := NewBusinessCalendar(nil, nil, testLocation)
+	if err != nil {
+		t.Fatalf("NewBusinessCalendar() error = %v", err)
+	}
+	start := at(17, 22, 0)
+	if got, want := cal.Add(start, 3*time.Hour), start.Add(3*time.Hour); !got.Equal(want) {
+		t.Errorf("Add() = %v, want wall-clock %v", got, want)
+	}
+	if got := cal.Elapsed(start, start.Add(90*time.Minute)); got != 90*time.Minute {
+		t.Errorf("Elapsed() = %v, want 1h30m", got)
+	}
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"context"
+	"math"
+	"reports-api/config"
+	"reports-api/models"
+	"reports-api/repository"
+	"sort"
+	"strings"
+)
+
+// duplicateCandidateLimit bounds the open tasks compared with a new report
+const duplicateCandidateLimit = 50
+
+// maxSimilarityRunes bounds the letters compared per text, so long reports stay cheap to compare
+const maxSimilarityRunes = 500
+
+// TextSimilarity compares two report texts like NameSimilarity, on at most maxSimilarityRunes letters of each
+func TextSimilarity(a, b string) float64 {
+	na, nb := normalizeName(a), normalizeName(b)
+	if len(na) > maxSimilarityRunes {
+		na = na[:maxSimilarityRunes]
+	}
+	if len(nb) > maxSimilarityRunes {
+		nb = nb[:maxSimilarityRunes]
+	}
+	longest := max(len(na), len(nb))
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(na, nb))/float64(longest)
+}
+
+// FindDuplicateTasks returns the open tasks matching q whose text is at least DUPLICATE_SIMILARITY similar
+// to text, reported within DUPLICATE_WINDOW. The most similar come first, and tasks of the same department
+// before tasks that only share the branch. A report without text has no duplicates.
+func FindDuplicateTasks(ctx context.Context, r *repository.Repositories, q repository.DuplicateQuery, text string) ([]models.DuplicateCandidate, error) {
+	cfg := config.AppConfig.Duplicate
+	if strings.TrimSpace(text) == "" {
+		return []models.DuplicateCandidate{}, nil
+	}
+	q.Within = cfg.Window
+	q.Limit = duplicateCandidateLimit
+	candidates, err := r.Tasks.DuplicateCandidates(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := []models.DuplicateCandidate{}
+	for _, c := range candidates {
+		c.Similarity = math.Round(TextSimilarity(text, c.Text)*1000) / 1000
+		if c.Similarity < cfg.Similarity {
+			continue
+		}
+		c.CreatedAt = Fixtimefeature(c.CreatedAt)
+		duplicates = append(duplicates, c)
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		if duplicates[i].Similarity != duplicates[j].Similarity {
+			return duplicates[i].Similarity > duplicates[j].Similarity
+		}
+		return duplicates[i].SameDepartment && !duplicates[j].SameDepartment
+	})
+	return duplicates, nil
+}
+
+// AttachIncident stores a report as an incident of an existing task and records it in the task's change log
+func AttachIncident(ctx context.Context, r *repository.Repositories, incident models.TaskIncident, actor int) (int64, error) {
+	var id int64
+	err := r.InTx(ctx, func(tx *repository.Repositories) error {
+		var err error
+		id, err = tx.Incidents.Create(ctx, incident)
+		if err != nil {
+			return err
+		}
+		fields := DiffEntry("", incident.Text, nil, incident.FilePaths)
+		return RecordChange(ctx, tx, incident.TaskID, models.ChangeEntityIncident, int(id), models.ChangeActionCreate, actor, fields...)
+	})
+	return id, err
+}
@@ -0,0 +1,29 @@
+package common
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTextSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{"ignores case, spaces and punctuation", "Printer jammed!", "printer JAMMED", 1},
+		{"thai with spaces", "เครื่องพิมพ์เสีย", "เครื่องพิมพ์ เสีย", 1},
+		{"one edit in four", "abcd", "abce", 0.75},
+		{"nothing in common", "abc", "xyz", 0},
+		{"empty texts", "", " !? ", 0},
+		{"only the first letters are compared", strings.Repeat("a", maxSimilarityRunes) + "bbb", strings.Repeat("a", maxSimilarityRunes) + "ccc", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TextSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("TextSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package common
+
+import (
+	"database/sql"
+	"fmt"
+	"reports-api/config"
+	"reports-api/db"
+	"reports-api/models"
+	"strings"
+	"time"
+)
+
+// Login failure reasons recorded in login_history
+const (
+	LoginFailureBadCredentials = "invalid_credentials"
+	LoginFailureLocked         = "locked"
+)
+
+// LoginLockRemaining returns how long the username is still locked out, or 0 when it may log in
+func LoginLockRemaining(username string) (time.Duration, error) {
+	var lockedUntil sql.NullTime
+	err := db.DB.QueryRow(
+		`SELECT locked_until FROM login_attempts WHERE username = ?`,
+		normalizeUsername(username),
+	).Scan(&lockedUntil)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load login attempts: %w", err)
+	}
+	if !lockedUntil.Valid {
+		return 0, nil
+	}
+
+	remaining := time.Until(lockedUntil.Time)
+	if remaining < 0 {
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+// RecordLoginFailure counts a failed attempt and locks the username once the limit is reached.
+// Each consecutive lockout doubles in length up to LoginLockoutMax.
+// It returns the new lockout duration, or 0 when the account is not locked.
+func RecordLoginFailure(username string) (time.Duration, error) {
+	cfg := config.AppConfig
+	username = normalizeUsername(username)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var failed, lockouts int
+	err = tx.QueryRow(
+		`SELECT failed_count, lockout_count FROM login_attempts WHERE username = ? FOR UPDATE`,
+		username,
+	).Scan(&failed, &lockouts)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to load login attempts: %w", err)
+	}
+
+	failed++
+	var lockFor time.Duration
+	var lockedUntil *time.Time
+	if cfg.LoginMaxAttempts > 0 && failed >= cfg.LoginMaxAttempts {
+		lockFor = lockoutDuration(lockouts)
+		until := time.Now().Add(lockFor)
+		lockedUntil = &until
+		lockouts++
+		failed = 0
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO login_attempts (username, failed_count, lockout_count, locked_until, last_failed_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON DUPLICATE KEY UPDATE failed_count = VALUES(failed_count), lockout_count = VALUES(lockout_count),
+			locked_until = VALUES(locked_until), last_failed_at = VALUES(last_failed_at)
+	`, username, failed, lockouts, lockedUntil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return lockFor, tx.Commit()
+}
+
+// ResetLoginFailures clears the failure counter after a successful login
+func ResetLoginFailures(username string) error {
+	_, err := db.DB.Exec(`DELETE FROM login_attempts WHERE username = ?`, normalizeUsername(username))
+	return err
+}
+
+// UnlockLogin lifts a lockout on behalf of an admin; it reports whether a lock was cleared
+func UnlockLogin(username string) (bool, error) {
+	res, err := db.DB.Exec(`DELETE FROM login_attempts WHERE username = ?`, normalizeUsername(username))
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// RecordLoginHistory appends an entry to the login audit trail
+func RecordLoginHistory(userID *int, username, ip, userAgent string, success bool, reason string) error {
+	var failureReason *string
+	if !success && reason != "" {
+		failureReason = &reason
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	_, err := db.DB.Exec(
+		`INSERT INTO login_history (user_id, username, ip_address, user_agent, success, failure_reason) VALUES (?, ?, ?, ?, ?, ?)`,
+		userID, username, ip, userAgent, success, failureReason,
+	)
+	return err
+}
+
+// LoginHistoryFilter narrows the login history query; zero values are ignored
+type LoginHistoryFilter struct {
+	Username string
+	UserID   int
+	Success  *bool
+	From     string
+	To       string
+	Limit    int
+	Offset   int
+}
+
+// ListLoginHistory returns login history entries, newest first, with the total number of matches
+func ListLoginHistory(filter LoginHistoryFilter) ([]models.LoginHistory, int, error) {
+	where := []string{"1=1"}
+	var args []interface{}
+	if filter.Username != "" {
+		where = append(where, "username = ?")
+		args = append(args, filter.Username)
+	}
+	if filter.UserID > 0 {
+		where = append(where, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Success != nil {
+		where = append(where, "success = ?")
+		args = append(args, *filter.Success)
+	}
+	if filter.From != "" {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		where = append(where, "created_at < DATE_ADD(?, INTERVAL 1 DAY)")
+		args = append(args, filter.To)
+	}
+	whereSQL := strings.Join(where, " AND ")
+
+	var total int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM login_history WHERE `+whereSQL, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count login history: %w", err)
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT id, user_id, username, IFNULL(ip_address, ''), IFNULL(user_agent, ''), success, failure_reason, created_at
+		FROM login_history
+		WHERE `+whereSQL+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query login history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []models.LoginHistory{}
+	for rows.Next() {
+		var h models.LoginHistory
+		if err := rows.Scan(&h.ID, &h.UserID, &h.Username, &h.IPAddress, &h.UserAgent, &h.Success, &h.FailureReason, &h.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan login history: %w", err)
+		}
+		history = append(history, h)
+	}
+	return history, total, rows.Err()
+}
+
+// lockoutDuration doubles the base lockout for every previous lockout, capped at the maximum
+func lockoutDuration(previousLockouts int) time.Duration {
+	cfg := config.AppConfig
+	d := cfg.LoginLockoutBase
+	for i := 0; i < previousLockouts && d < cfg.LoginLockoutMax; i++ {
+		d *= 2
+	}
+	if d > cfg.LoginLockoutMax {
+		d = cfg.LoginLockoutMax
+	}
+	return d
+}
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reports-api/models"
+	"reports-api/repository"
+)
+
+// masterDataDelete returns the soft delete of a master data kind
+func masterDataDelete(r *repository.Repositories, kind string) (func(ctx context.Context, id, actor int) error, error) {
+	switch kind {
+	case models.TrashBranches:
+		return r.Branches.Delete, nil
+	case models.TrashDepartments:
+		return r.Departments.Delete, nil
+	case models.TrashPhones:
+		return r.Phones.Delete, nil
+	case models.TrashPrograms:
+		return r.Programs.Delete, nil
+	}
+	return nil, fmt.Errorf("invalid master data kind %q", kind)
+}
+
+// DeleteMasterData moves a branch, department, IP phone or program to the trash in one transaction.
+// In block mode it fails with models.ErrHasDependents while live records depend on it; cascade mode
+// trashes the dependent departments, IP phones and tasks too; reassign mode re-points them, with the task incidents
+// and the scores of a department, to target first. Cascade leaves scores and incidents on the trashed department
+// so a restore gets them back; purging it deletes the scores and clears the department of the incidents.
+// The returned dependents are counted before the delete; the record stays locked until the transaction ends,
+// so no dependent can be added between the count and the delete.
+func DeleteMasterData(ctx context.Context, r *repository.Repositories, kind string, id int, mode string, target, actor int) (*models.Dependents, error) {
+	var deps *models.Dependents
+	err := r.InTx(ctx, func(tx *repository.Repositories) error {
+		del, err := masterDataDelete(tx, kind)
+		if err != nil {
+			return err
+		}
+		if deps, err = tx.MasterData.Dependents(ctx, kind, id); err != nil {
+			return err
+		}
+
+		switch mode {
+		case models.DeleteModeBlock:
+			if deps.Blocking() {
+				return models.ErrHasDependents
+			}
+		case models.DeleteModeReassign:
+			if target == id {
+				return models.ErrInvalidReassignTarget
+			}
+			if _, err := tx.MasterData.Dependents(ctx, kind, target); err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return models.ErrInvalidReassignTarget
+				}
+				return err
+			}
+			if err := reassignMasterData(ctx, tx, kind, id, target, actor); err != nil {
+				return err
+			}
+		case models.DeleteModeCascade:
+			taskIDs, err := tx.MasterData.DependentTasks(ctx, kind, id)
+			if err != nil {
+				return err
+			}
+			for _, taskID := range taskIDs {
+				if err := tx.Tasks.Delete(ctx, taskID, actor); err != nil {
+					return fmt.Errorf("failed to delete task %d: %w", taskID, err)
+				}
+				if err := RecordChange(ctx, tx, taskID, models.ChangeEntityTask, taskID, models.ChangeActionDelete, actor); err != nil {
+					return err
+				}
+			}
+			if err := tx.MasterData.DeleteChildren(ctx, kind, id, actor); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid delete mode %q", mode)
+		}
+		return del(ctx, id, actor)
+	})
+	return deps, err
+}
+
+// reassignMasterData re-points the records referencing from to to, merges the scores of a department into to,
+// and records the change of every live task it moves
+func reassignMasterData(ctx context.Context, tx *repository.Repositories, kind string, from, to, actor int) error {
+	taskIDs, err := tx.MasterData.DependentTasks(ctx, kind, from)
+	if err != nil {
+		return err
+	}
+	err = TrackTasks(ctx, tx, taskIDs, actor, func() error {
+		return tx.MasterData.Reassign(ctx, kind, from, to, actor)
+	})
+	if err != nil || kind != models.TrashDepartments {
+		return err
+	}
+	return tx.MasterData.MergeScores(ctx, from, to)
+}
@@ -0,0 +1,147 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reports-api/models"
+	"reports-api/repository"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MergeMasterData re-points the departments, IP phones, tasks, task incidents, problem records and scores of the
+// source records to the survivor, and the holidays, SLA policies and working hours of source branches the survivor
+// does not set itself, moves the sources to the trash and records the merge, all in one transaction.
+// A dry run only counts what would move.
+func MergeMasterData(ctx context.Context, r *repository.Repositories, kind string, req models.MergeRequest, actor int) (*models.MergeResult, error) {
+	if len(req.SourceIDs) == 0 {
+		return nil, fmt.Errorf("%w: source_ids is required", models.ErrInvalidMerge)
+	}
+	seen := map[int]bool{req.SurvivorID: true}
+	for _, id := range req.SourceIDs {
+		if seen[id] {
+			return nil, fmt.Errorf("%w: source %d is the survivor or listed twice", models.ErrInvalidMerge, id)
+		}
+		seen[id] = true
+	}
+
+	result := &models.MergeResult{Kind: kind, SurvivorID: req.SurvivorID, DryRun: req.DryRun}
+	err := r.InTx(ctx, func(tx *repository.Repositories) error {
+		del, err := masterDataDelete(tx, kind)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.MasterData.Dependents(ctx, kind, req.SurvivorID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("%w: survivor %d not found", models.ErrInvalidMerge, req.SurvivorID)
+			}
+			return err
+		}
+
+		for _, id := range req.SourceIDs {
+			deps, err := tx.MasterData.Dependents(ctx, kind, id)
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return fmt.Errorf("%w: source %d not found", models.ErrInvalidMerge, id)
+				}
+				return err
+			}
+			source := models.MergeSource{ID: id, Dependents: *deps}
+			if kind == models.TrashDepartments {
+				if source.ScoresCombined, err = tx.MasterData.ScoreConflicts(ctx, id, req.SurvivorID); err != nil {
+					return err
+				}
+			}
+			result.Sources = append(result.Sources, source)
+			if req.DryRun {
+				continue
+			}
+
+			if err := reassignMasterData(ctx, tx, kind, id, req.SurvivorID, actor); err != nil {
+				return err
+			}
+			if err := del(ctx, id, actor); err != nil {
+				return err
+			}
+		}
+		if req.DryRun {
+			return nil
+		}
+
+		result.ID, err = tx.MasterData.RecordMerge(ctx, models.MergeLogEntry{
+			Kind:       kind,
+			SurvivorID: req.SurvivorID,
+			SourceIDs:  req.SourceIDs,
+			Sources:    result.Sources,
+			MergedBy:   &actor,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// normalizeName lowercases a name and drops everything but letters, digits and marks,
+// so "I.T." and "it" compare equal
+func normalizeName(name string) []rune {
+	var out []rune
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// levenshtein returns the edit distance between two rune slices
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// NameSimilarity compares two names after normalizeName: 1 for equal names, 0 for nothing in common
+func NameSimilarity(a, b string) float64 {
+	na, nb := normalizeName(a), normalizeName(b)
+	longest := max(len(na), len(nb))
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(na, nb))/float64(longest)
+}
+
+// FindMergeCandidates returns the pairs of records in the same group whose names are at least
+// threshold similar, most similar first
+func FindMergeCandidates(names []models.MasterDataName, threshold float64) []models.MergeCandidate {
+	candidates := []models.MergeCandidate{}
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			if names[i].Group != names[j].Group {
+				continue
+			}
+			if s := NameSimilarity(names[i].Name, names[j].Name); s >= threshold {
+				candidates = append(candidates, models.MergeCandidate{A: names[i], B: names[j], Similarity: s})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Similarity > candidates[j].Similarity })
+	return candidates
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"reflect"
+	"reports-api/models"
+	"testing"
+)
+
+func TestFindMergeCandidates(t *testing.T) {
+	names := []models.MasterDataName{
+		{ID: 1, Name: "IT Support", Group: 1},
+		{ID: 2, Name: "it-support", Group: 1},
+		{ID: 3, Name: "IT Supprt", Group: 1},
+		{ID: 4, Name: "IT Support", Group: 2},
+		{ID: 5, Name: "Accounting", Group: 1},
+	}
+	tests := []struct {
+		name      string
+		threshold float64
+		want      [][2]int
+	}{
+		{"exact matches only", 1, [][2]int{{1, 2}}},
+		{"most similar first, same group only", 0.8, [][2]int{{1, 2}, {1, 3}, {2, 3}}},
+		{"nothing above threshold", 1.1, [][2]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := [][2]int{}
+			for _, c := range FindMergeCandidates(names, tt.threshold) {
+				if c.Similarity < tt.threshold {
+					t.Errorf("pair %d-%d has similarity %v below %v", c.A.ID, c.B.ID, c.Similarity, tt.threshold)
+				}
+				got = append(got, [2]int{c.A.ID, c.B.ID})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindMergeCandidates(%v) = %v, want %v", tt.threshold, got, tt.want)
+			}
+		})
+	}
+}
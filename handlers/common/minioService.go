@@ -287,3 +287,17 @@ func DeleteImage(objectName string) error {
 	log.Printf("Successfully deleted %s", objectName)
 	return nil
 }
+
+// DeleteFileURLs ลบไฟล์ใน MinIO ตาม URL ที่มี prefix= ของ object
+func DeleteFileURLs(urls []string) {
+	for _, url := range urls {
+		if strings.Contains(url, "prefix=") {
+			parts := strings.Split(url, "prefix=")
+			if len(parts) > 1 {
+				objectName := parts[1]
+				DeleteImage(objectName)
+				log.Printf("Deleted file: %s", objectName)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reports-api/config"
+	"reports-api/db"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+	ErrWrongPassword     = errors.New("current password is incorrect")
+)
+
+// ValidatePassword checks a new password against the configured password policy
+func ValidatePassword(password string) error {
+	policy := config.AppConfig.PasswordPolicy
+
+	if len([]rune(password)) < policy.MinLength {
+		return fmt.Errorf("password must be at least %d characters", policy.MinLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSpecial = true
+		}
+	}
+
+	var missing []string
+	if policy.RequireUpper && !hasUpper {
+		missing = append(missing, "an uppercase letter")
+	}
+	if policy.RequireLower && !hasLower {
+		missing = append(missing, "a lowercase letter")
+	}
+	if policy.RequireDigit && !hasDigit {
+		missing = append(missing, "a digit")
+	}
+	if policy.RequireSpecial && !hasSpecial {
+		missing = append(missing, "a special character")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("password must contain %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// HashPassword returns the bcrypt hash of a password
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hashed), nil
+}
+
+// SetUserPassword stores a new password hash and signs the user out of every other session
+func SetUserPassword(userID int, password string) error {
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := storeUserPassword(tx, userID, hashed); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// storeUserPassword writes a password hash and revokes the user's refresh tokens inside tx
+func storeUserPassword(tx *sql.Tx, userID int, hashed string) error {
+	if _, err := tx.Exec(`UPDATE users SET password = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, hashed, userID); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL`, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// ChangePassword replaces the user's password after verifying the current one
+func ChangePassword(userID int, oldPassword, newPassword string) error {
+	var current string
+	if err := db.DB.QueryRow(`SELECT password FROM users WHERE id = ? AND deleted_at IS NULL`, userID).Scan(&current); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrWrongPassword
+		}
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(current), []byte(oldPassword)); err != nil {
+		return ErrWrongPassword
+	}
+	return SetUserPassword(userID, newPassword)
+}
+
+// CreatePasswordResetToken issues a one-time reset token for a user.
+// Only the SHA-256 of the token is stored; the raw value is returned once to the admin.
+func CreatePasswordResetToken(userID, createdBy int) (string, time.Time, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	expiresAt := time.Now().UTC().Add(config.AppConfig.PasswordResetTTL)
+
+	// A new token replaces any outstanding one
+	if _, err := db.DB.Exec(`UPDATE password_reset_tokens SET used_at = UTC_TIMESTAMP() WHERE user_id = ? AND used_at IS NULL`, userID); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to invalidate previous reset tokens: %w", err)
+	}
+	_, err := db.DB.Exec(
+		`INSERT INTO password_reset_tokens (token_hash, user_id, created_by, expires_at) VALUES (?, ?, ?, ?)`,
+		hashResetToken(token), userID, createdBy, expiresAt,
+	)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to store reset token: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// ResetPasswordWithToken redeems a reset token and sets the new password in one transaction,
+// so the token is only spent when the password has changed
+func ResetPasswordWithToken(token, newPassword string) (int, error) {
+	hashed, err := HashPassword(newPassword)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(
+		`SELECT user_id FROM password_reset_tokens WHERE token_hash = ? AND used_at IS NULL AND expires_at > UTC_TIMESTAMP() FOR UPDATE`,
+		hashResetToken(token),
+	).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrInvalidResetToken
+		}
+		return 0, fmt.Errorf("failed to load reset token: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE password_reset_tokens SET used_at = UTC_TIMESTAMP() WHERE token_hash = ?`, hashResetToken(token)); err != nil {
+		return 0, fmt.Errorf("failed to consume reset token: %w", err)
+	}
+	if err := storeUserPassword(tx, userID, hashed); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// PurgeExpiredResetTokens removes reset tokens that can no longer be redeemed
+func PurgeExpiredResetTokens() error {
+	_, err := db.DB.Exec(`DELETE FROM password_reset_tokens WHERE expires_at < UTC_TIMESTAMP() OR used_at IS NOT NULL`)
+	return err
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"log"
+	"reports-api/db"
+	"reports-api/models"
+	"sync"
+	"time"
+)
+
+// permissionCacheTTL bounds how long a replica keeps role permissions before reloading them
+const permissionCacheTTL = time.Minute
+
+var permissionCache = struct {
+	sync.RWMutex
+	roles    map[string]map[string]bool
+	loadedAt time.Time
+}{}
+
+// HasPermission reports whether the role has been granted the permission
+func HasPermission(role, permission string) bool {
+	roles := rolePermissions()
+	return roles[role][permission]
+}
+
+// RoleExists reports whether the role is known to the permission model
+func RoleExists(role string) bool {
+	_, ok := rolePermissions()[role]
+	return ok
+}
+
+// InvalidatePermissionCache forces the next permission check to reload from the database
+func InvalidatePermissionCache() {
+	permissionCache.Lock()
+	permissionCache.loadedAt = time.Time{}
+	permissionCache.Unlock()
+}
+
+// rolePermissions returns the cached role→permission set, reloading it when stale.
+// When the reload fails the last loaded set is kept and retried on the next check; before
+// anything was loaded every permission is denied, so grants revoked in the database never come back.
+func rolePermissions() map[string]map[string]bool {
+	permissionCache.RLock()
+	roles, loadedAt := permissionCache.roles, permissionCache.loadedAt
+	permissionCache.RUnlock()
+	if roles != nil && time.Since(loadedAt) < permissionCacheTTL {
+		return roles
+	}
+
+	loaded, err := LoadRolePermissions()
+	if err != nil {
+		log.Printf("Error loading role permissions, keeping the last loaded set: %v", err)
+		if roles == nil {
+			return map[string]map[string]bool{}
+		}
+		return roles
+	}
+	if len(loaded) == 0 {
+		// roles ยังไม่ถูก seed (ยังไม่ได้รัน migration)
+		loaded = defaultRolePermissionSet()
+	}
+
+	permissionCache.Lock()
+	permissionCache.roles = loaded
+	permissionCache.loadedAt = time.Now()
+	permissionCache.Unlock()
+	return loaded
+}
+
+// LoadRolePermissions reads every role and its granted permissions from the database
+func LoadRolePermissions() (map[string]map[string]bool, error) {
+	rows, err := db.DB.Query(`
+		SELECT r.name, IFNULL(rp.permission, '')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role = r.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := map[string]map[string]bool{}
+	for rows.Next() {
+		var role, permission string
+		if err := rows.Scan(&role, &permission); err != nil {
+			return nil, err
+		}
+		if roles[role] == nil {
+			roles[role] = map[string]bool{}
+		}
+		if permission != "" {
+			roles[role][permission] = true
+		}
+	}
+	return roles, rows.Err()
+}
+
+func defaultRolePermissionSet() map[string]map[string]bool {
+	roles := map[string]map[string]bool{}
+	for role, permissions := range models.DefaultRolePermissions {
+		roles[role] = map[string]bool{}
+		for _, p := range permissions {
+			roles[role][p] = true
+		}
+	}
+	return roles
+}
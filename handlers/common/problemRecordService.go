@@ -0,0 +1,89 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"reports-api/models"
+	"reports-api/repository"
+	"strings"
+)
+
+// NormalizeProblemRecord trims a problem record request and defaults its status to open.
+// It returns models.ErrInvalidProblemRecord without a title or with an unknown status.
+func NormalizeProblemRecord(req *models.ProblemRecordRequest) error {
+	req.Title = strings.TrimSpace(req.Title)
+	req.Workaround = strings.TrimSpace(req.Workaround)
+	req.Status = strings.TrimSpace(req.Status)
+	if req.Status == "" {
+		req.Status = models.ProblemRecordOpen
+	}
+	if req.SystemID != nil && *req.SystemID <= 0 {
+		req.SystemID = nil
+	}
+	if req.Title == "" {
+		return fmt.Errorf("%w: title is required", models.ErrInvalidProblemRecord)
+	}
+	if !models.ValidProblemRecordStatus(req.Status) {
+		return fmt.Errorf("%w: unknown status %q", models.ErrInvalidProblemRecord, req.Status)
+	}
+	return nil
+}
+
+// AttachProblemRecordTasks attaches tasks to a problem record and records it in the change log of each task.
+// A missing record or task returns repository.ErrNotFound and a task of another problem record
+// models.ErrTaskHasProblemRecord; nothing is attached then.
+func AttachProblemRecordTasks(ctx context.Context, r *repository.Repositories, id int, taskIDs []int, actor int) error {
+	return r.InTx(ctx, func(tx *repository.Repositories) error {
+		if _, err := tx.ProblemRecords.Get(ctx, id); err != nil {
+			return err
+		}
+		for _, taskID := range taskIDs {
+			if _, err := tx.Tasks.Get(ctx, taskID); err != nil {
+				return err
+			}
+			if err := tx.ProblemRecords.AttachTask(ctx, id, taskID, actor); err != nil {
+				return fmt.Errorf("task %d: %w", taskID, err)
+			}
+			change := models.FieldChange{Field: "problem_record_id", New: id}
+			if err := RecordChange(ctx, tx, taskID, models.ChangeEntityProblemRecord, id, models.ChangeActionCreate, actor, change); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DetachProblemRecordTask detaches a task from a problem record and records it in the task's change log
+func DetachProblemRecordTask(ctx context.Context, r *repository.Repositories, id, taskID, actor int) error {
+	return r.InTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.ProblemRecords.DetachTask(ctx, id, taskID); err != nil {
+			return err
+		}
+		change := models.FieldChange{Field: "problem_record_id", Old: id}
+		return RecordChange(ctx, tx, taskID, models.ChangeEntityProblemRecord, id, models.ChangeActionDelete, actor, change)
+	})
+}
+
+// DeleteProblemRecord detaches every task of a problem record, recording it in each task's change log,
+// and moves the record to the trash. A missing or trashed record returns repository.ErrNotFound.
+func DeleteProblemRecord(ctx context.Context, r *repository.Repositories, id, actor int) error {
+	return r.InTx(ctx, func(tx *repository.Repositories) error {
+		if _, err := tx.ProblemRecords.Get(ctx, id); err != nil {
+			return err
+		}
+		taskIDs, err := tx.ProblemRecords.TaskIDs(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, taskID := range taskIDs {
+			if err := tx.ProblemRecords.DetachTask(ctx, id, taskID); err != nil {
+				return fmt.Errorf("failed to detach task %d: %w", taskID, err)
+			}
+			change := models.FieldChange{Field: "problem_record_id", Old: id}
+			if err := RecordChange(ctx, tx, taskID, models.ChangeEntityProblemRecord, id, models.ChangeActionDelete, actor, change); err != nil {
+				return err
+			}
+		}
+		return tx.ProblemRecords.Delete(ctx, id, actor)
+	})
+}
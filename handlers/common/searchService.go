@@ -0,0 +1,107 @@
+package common
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// snippetRadius is the number of characters kept on each side of the first match
+const snippetRadius = 60
+
+// searchTerms splits a query into the terms highlighted in snippets, longest first
+func searchTerms(query string) [][]rune {
+	var terms [][]rune
+	for _, f := range strings.Fields(query) {
+		f = strings.Trim(f, `"+-*()<>~`)
+		if f != "" {
+			terms = append(terms, []rune(strings.ToLower(f)))
+		}
+	}
+	// คำที่ยาวกว่าต้องถูกเลือกก่อน เพื่อไม่ให้คำสั้นที่ซ้อนกันตัดไฮไลต์
+	sort.SliceStable(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	return terms
+}
+
+// hasPrefixAt reports whether text[i:] starts with term
+func hasPrefixAt(text []rune, i int, term []rune) bool {
+	if i+len(term) > len(text) {
+		return false
+	}
+	for k, r := range term {
+		if text[i+k] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchSnippet returns an HTML-escaped excerpt of body around the first match of query,
+// with every matching term wrapped in <mark>. Without an exact match it returns the start of body.
+func SearchSnippet(body, query string) string {
+	text := []rune(strings.Join(strings.Fields(body), " "))
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+	terms := searchTerms(query)
+
+	// ตำแหน่งของทุกคำที่ตรง (ยาวสุดก่อน) ใช้ได้กับภาษาไทยที่ไม่มีช่องว่างระหว่างคำ
+	marks := make([]int, len(text)) // length of the match starting at i
+	first := -1
+	for i := 0; i < len(text); {
+		matched := 0
+		for _, term := range terms {
+			if hasPrefixAt(lower, i, term) {
+				matched = len(term)
+				break
+			}
+		}
+		if matched == 0 {
+			i++
+			continue
+		}
+		marks[i] = matched
+		if first < 0 {
+			first = i
+		}
+		i += matched
+	}
+
+	start, end := 0, len(text)
+	if first >= 0 {
+		start = first - snippetRadius
+		end = first + snippetRadius*2
+	} else {
+		end = snippetRadius * 3
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		if n := marks[i]; n > 0 {
+			stop := i + n
+			if stop > end {
+				stop = end
+			}
+			b.WriteString("<mark>" + html.EscapeString(string(text[i:stop])) + "</mark>")
+			i = stop
+			continue
+		}
+		b.WriteString(html.EscapeString(string(text[i])))
+		i++
+	}
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearchSnippet(t *testing.T) {
+	long := strings.Repeat("x", 100) + " printer jammed " + strings.Repeat("y", 200)
+
+	tests := []struct {
+		name  string
+		body  string
+		query string
+		want  string
+	}{
+		{"marks every match", "printer on floor 2 and printer on floor 3", "printer", "<mark>printer</mark> on floor 2 and <mark>printer</mark> on floor 3"},
+		{"case-insensitive keeps the original case", "Printer Jammed", "printer", "<mark>Printer</mark> Jammed"},
+		{"longest term wins over a nested one", "the printer is off", "print printer", "the <mark>printer</mark> is off"},
+		{"operators are stripped from terms", "vpn down", `+"vpn"`, "<mark>vpn</mark> down"},
+		{"collapses whitespace", "a\n\n  b   printer", "printer", "a b <mark>printer</mark>"},
+		{"escapes html", "<b>printer</b> & co", "printer", "&lt;b&gt;<mark>printer</mark>&lt;/b&gt; &amp; co"},
+		{"thai without spaces", "เครื่องพิมพ์เสียที่ชั้นสอง", "เสีย", "เครื่องพิมพ์<mark>เสีย</mark>ที่ชั้นสอง"},
+		{"no match returns the start", "nothing here", "printer", "nothing here"},
+		{"empty query", "nothing here", "", "nothing here"},
+		{
+			"cuts around the first match",
+			long, "jammed",
+			"…" + strings.Repeat("x", 51) + " printer <mark>jammed</mark> " + strings.Repeat("y", 113) + "…",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SearchSnippet(tt.body, tt.query); got != tt.want {
+				t.Errorf("SearchSnippet(%q, %q) = %q, want %q", tt.body, tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reports-api/config"
+	"reports-api/models"
+	"reports-api/repository"
+	"time"
+)
+
+// ComputeSLADue returns the first-response and resolution due dates of a task reported at start.
+// Targets are counted in working hours of cal; a nil calendar counts wall-clock time.
+func ComputeSLADue(policy *models.SLAPolicy, start time.Time, cal *BusinessCalendar) (responseDue, resolutionDue time.Time) {
+	response := time.Duration(policy.ResponseMinutes) * time.Minute
+	resolution := time.Duration(policy.ResolutionMinutes) * time.Minute
+	if cal == nil {
+		return start.Add(response), start.Add(resolution)
+	}
+	return cal.Add(start, response), cal.Add(start, resolution)
+}
+
+// ApplyTaskSLA matches the task against the SLA policies and stores its due dates.
+// A task without a matching policy has its due dates cleared.
+// Call it inside InTx after the task's program, department or issue type is written.
+func ApplyTaskSLA(ctx context.Context, r *repository.Repositories, taskID int) error {
+	target, err := r.SLA.Target(ctx, taskID, config.AppConfig.SLA.DefaultPriority)
+	if err != nil {
+		return fmt.Errorf("failed to load SLA target: %w", err)
+	}
+
+	policy, err := r.SLA.MatchPolicy(ctx, target.Priority, target.BranchID, target.IssueTypeID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return r.SLA.SetTaskDue(ctx, taskID, nil, nil, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to match SLA policy: %w", err)
+	}
+
+	var cal *BusinessCalendar
+	if config.AppConfig.SLA.BusinessHours {
+		if cal, err = LoadBusinessCalendar(ctx, r, target.BranchID); err != nil {
+			return fmt.Errorf("failed to load business calendar: %w", err)
+		}
+	}
+
+	responseDue, resolutionDue := ComputeSLADue(policy, target.CreatedAt, cal)
+	return r.SLA.SetTaskDue(ctx, taskID, &policy.ID, &responseDue, &resolutionDue)
+}
+
+// CheckSLA raises a warning for every open task within WarnBefore of a due date and a breach for
+// every task past it. Each event is raised once per task; it returns the number of new events.
+func CheckSLA(ctx context.Context, r *repository.Repositories, now time.Time) (int, error) {
+	warnBefore := config.AppConfig.SLA.WarnBefore
+	tasks, err := r.SLA.DueTasks(ctx, now.Add(warnBefore))
+	if err != nil {
+		return 0, err
+	}
+
+	type dueEvent struct {
+		kind  string
+		dueAt time.Time
+	}
+	raised := 0
+	for _, t := range tasks {
+		var events []dueEvent
+		if t.FirstResponseAt == nil && t.ResponseDueAt != nil {
+			if kind := slaEventKind(*t.ResponseDueAt, now, warnBefore, models.SLAEventResponseWarning, models.SLAEventResponseBreached); kind != "" {
+				events = append(events, dueEvent{kind, *t.ResponseDueAt})
+			}
+		}
+		if t.ResolutionDueAt != nil {
+			if kind := slaEventKind(*t.ResolutionDueAt, now, warnBefore, models.SLAEventResolutionWarning, models.SLAEventResolutionBreached); kind != "" {
+				events = append(events, dueEvent{kind, *t.ResolutionDueAt})
+			}
+		}
+
+		for _, ev := range events {
+			isNew, err := r.SLA.RecordEvent(ctx, t.TaskID, ev.kind, ev.dueAt)
+			if err != nil {
+				return raised, err
+			}
+			if !isNew {
+				continue
+			}
+			raised++
+			notifySLAEvent(models.SLAEvent{
+				TaskID:   t.TaskID,
+				Ticket:   t.Ticket,
+				Kind:     ev.kind,
+				DueAt:    ev.dueAt.Format(time.RFC3339),
+				RaisedAt: now.Format(time.RFC3339),
+			}, ev.dueAt, t.ReportMessageID)
+		}
+	}
+	return raised, nil
+}
+
+// slaEventKind returns the breach kind once due has passed, the warning kind within warnBefore of it, or ""
+func slaEventKind(due, now time.Time, warnBefore time.Duration, warning, breached string) string {
+	switch {
+	case !now.Before(due):
+		return breached
+	case !now.Before(due.Add(-warnBefore)):
+		return warning
+	}
+	return ""
+}
+
+var slaEventTexts = map[string]string{
+	models.SLAEventResponseWarning:    "⚠️ *ใกล้ครบกำหนดตอบรับงาน*",
+	models.SLAEventResponseBreached:   "🚨 *เกินกำหนดตอบรับงาน*",
+	models.SLAEventResolutionWarning:  "⚠️ *ใกล้ครบกำหนดแก้ไขงาน*",
+	models.SLAEventResolutionBreached: "🚨 *เกินกำหนดแก้ไขงาน*",
+}
+
+// notifySLAEvent logs the event and, when enabled, replies to the task's Telegram message
+func notifySLAEvent(ev models.SLAEvent, dueAt time.Time, reportMessageID int) {
+	log.Printf("⏰ SLA %s for task %d (%s), due %s", ev.Kind, ev.TaskID, ev.Ticket, ev.DueAt)
+	if !config.AppConfig.SLA.NotifyTelegram || reportMessageID <= 0 {
+		return
+	}
+
+	text := slaEventTexts[ev.Kind] + "\n━━━━━━━━━━━━━━\n"
+	text += "🎫 *Ticket No:* " + EscapeMarkdown(ev.Ticket) + "\n"
+	text += "📅 *กำหนดเวลา:* " + FormatResolvedAt(dueAt) + "\n"
+	if _, err := SendReply(reportMessageID, text); err != nil {
+		log.Printf("❌ Failed to send SLA notification for task %d: %v", ev.TaskID, err)
+	}
+}
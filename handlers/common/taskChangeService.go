@@ -0,0 +1,235 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"reports-api/models"
+	"reports-api/repository"
+	"sort"
+)
+
+// taskChangeFields lists the task columns tracked by the change log, in the order they are reported.
+// The status is left out: its changes are kept in task_status_history.
+var taskChangeFields = []struct {
+	name  string
+	value func(t *models.TaskRecord) interface{}
+}{
+	{"phone_id", func(t *models.TaskRecord) interface{} { return intOrNil(t.PhoneID) }},
+	{"phone_else", func(t *models.TaskRecord) interface{} { return stringOrNil(t.PhoneElse) }},
+	{"system_id", func(t *models.TaskRecord) interface{} { return t.SystemID }},
+	{"issue_type", func(t *models.TaskRecord) interface{} { return t.IssueTypeID }},
+	{"issue_else", func(t *models.TaskRecord) interface{} { return t.IssueElse }},
+	{"department_id", func(t *models.TaskRecord) interface{} { return t.DepartmentID }},
+	{"text", func(t *models.TaskRecord) interface{} { return t.Text }},
+	{"reported_by", func(t *models.TaskRecord) interface{} { return t.ReportedBy }},
+	{"file_paths", func(t *models.TaskRecord) interface{} { return t.FilePaths }},
+	{"assignedto_id", func(t *models.TaskRecord) interface{} { return t.AssignedtoID }},
+	{"assignto", func(t *models.TaskRecord) interface{} { return t.Assignto }},
+}
+
+// assignmentFields are the fields whose changes are recorded as an assignment rather than an edit
+var assignmentFields = map[string]bool{"assignedto_id": true, "assignto": true}
+
+func intOrNil(v *int) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func stringOrNil(v *string) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// DiffTask returns the tracked fields that differ between two versions of a task,
+// split into edits and assignee changes
+func DiffTask(before, after *models.TaskRecord) (edits, assignment []models.FieldChange) {
+	for _, f := range taskChangeFields {
+		old, cur := f.value(before), f.value(after)
+		if old == cur {
+			continue
+		}
+		change := models.FieldChange{Field: f.name, Old: old, New: cur}
+		if assignmentFields[f.name] {
+			assignment = append(assignment, change)
+		} else {
+			edits = append(edits, change)
+		}
+	}
+	return edits, assignment
+}
+
+// DiffEntry returns the changed text and attachments of a progress entry or resolution
+func DiffEntry(oldText, newText string, oldFiles, newFiles *string) []models.FieldChange {
+	var fields []models.FieldChange
+	if oldText != newText {
+		fields = append(fields, models.FieldChange{Field: "text", Old: oldText, New: newText})
+	}
+	if old, cur := stringOrNil(oldFiles), stringOrNil(newFiles); old != cur {
+		fields = append(fields, models.FieldChange{Field: "file_paths", Old: old, New: cur})
+	}
+	return fields
+}
+
+// RecordChange appends one change of a task, progress entry or resolution to the change log.
+// Call it inside the InTx that makes the change.
+func RecordChange(ctx context.Context, r *repository.Repositories, taskID int, entity string, entityID int, action string, actor int, fields ...models.FieldChange) error {
+	change := models.TaskChange{
+		TaskID:   taskID,
+		Entity:   entity,
+		EntityID: entityID,
+		Action:   action,
+		Fields:   fields,
+	}
+	if actor != 0 {
+		change.ChangedBy = &actor
+	}
+	return r.Changes.Record(ctx, change)
+}
+
+// RecordTaskCreated records a new task with the values it was reported with
+func RecordTaskCreated(ctx context.Context, r *repository.Repositories, taskID, actor int) error {
+	task, err := r.Tasks.Get(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	edits, assignment := DiffTask(&models.TaskRecord{}, task)
+	return RecordChange(ctx, r, taskID, models.ChangeEntityTask, taskID, models.ChangeActionCreate, actor, append(edits, assignment...)...)
+}
+
+// RecordTaskChanges compares a task with the version read before it was written and records the
+// changed fields as an edit and, when the assignee changed, as an assignment.
+// Call it inside the InTx that writes the task; nothing is recorded when no tracked field changed.
+func RecordTaskChanges(ctx context.Context, r *repository.Repositories, before *models.TaskRecord, actor int) error {
+	after, err := r.Tasks.Get(ctx, before.ID)
+	if err != nil {
+		return err
+	}
+	edits, assignment := DiffTask(before, after)
+	if len(edits) > 0 {
+		if err := RecordChange(ctx, r, before.ID, models.ChangeEntityTask, before.ID, models.ChangeActionUpdate, actor, edits...); err != nil {
+			return err
+		}
+	}
+	if len(assignment) > 0 {
+		return RecordChange(ctx, r, before.ID, models.ChangeEntityTask, before.ID, models.ChangeActionAssign, actor, assignment...)
+	}
+	return nil
+}
+
+// TrackTasks runs fn, which may rewrite the given live tasks, and records what it changed in each of them
+func TrackTasks(ctx context.Context, r *repository.Repositories, taskIDs []int, actor int, fn func() error) error {
+	before := make([]*models.TaskRecord, 0, len(taskIDs))
+	for _, id := range taskIDs {
+		task, err := r.Tasks.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		before = append(before, task)
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	for _, task := range before {
+		if err := RecordTaskChanges(ctx, r, task, actor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TaskTimeline merges the creation, edits, assignments, status changes, progress entries and
+// resolution events of a task into one list, oldest first.
+// Progress entries and resolutions written before the change log existed are listed from their own rows.
+func TaskTimeline(ctx context.Context, r *repository.Repositories, taskID int) ([]models.TimelineEvent, error) {
+	task, err := r.Tasks.Get(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := r.Changes.ListByTask(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := r.StatusHistory.ListByTask(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := r.Progress.ListByTask(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	var resolution *models.Resolution
+	if task.SolutionID != nil {
+		resolution, err = r.Resolutions.Get(ctx, *task.SolutionID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	var events []models.TimelineEvent
+	type entityKey struct {
+		entity string
+		id     int
+	}
+	created := map[entityKey]bool{} // entities with a recorded create
+	for _, c := range changes {
+		if c.Action == models.ChangeActionCreate {
+			created[entityKey{c.Entity, c.EntityID}] = true
+		}
+	}
+
+	if !created[entityKey{models.ChangeEntityTask, taskID}] {
+		ev := models.TimelineEvent{Type: models.TimelineCreated, At: task.CreatedAt}
+		for _, h := range history {
+			if h.FromStatus == nil {
+				ev.Actor = h.ChangedBy
+				break
+			}
+		}
+		events = append(events, ev)
+	}
+	for _, c := range changes {
+		ev := models.TimelineEvent{
+			Type:   models.TimelineType(c.Entity, c.Action),
+			At:     c.ChangedAt,
+			Actor:  c.ChangedBy,
+			Fields: c.Fields,
+		}
+		if c.Entity != models.ChangeEntityTask {
+			ev.EntityID = c.EntityID
+		}
+		events = append(events, ev)
+	}
+	for i := range history {
+		h := history[i]
+		if h.FromStatus == nil {
+			continue // สถานะเริ่มต้นเป็นส่วนหนึ่งของการแจ้งปัญหา
+		}
+		events = append(events, models.TimelineEvent{Type: models.TimelineStatusChanged, At: h.ChangedAt, Actor: h.ChangedBy, Status: &h})
+	}
+	for _, p := range progress {
+		if !created[entityKey{models.ChangeEntityProgress, p.ID}] {
+			events = append(events, models.TimelineEvent{
+				Type:     models.TimelineType(models.ChangeEntityProgress, models.ChangeActionCreate),
+				At:       p.CreatedAt,
+				EntityID: p.ID,
+				Fields:   DiffEntry("", p.Text, nil, p.FilePaths),
+			})
+		}
+	}
+	if resolution != nil && !created[entityKey{models.ChangeEntityResolution, resolution.ID}] {
+		events = append(events, models.TimelineEvent{
+			Type:     models.TimelineType(models.ChangeEntityResolution, models.ChangeActionCreate),
+			At:       resolution.ResolvedAt,
+			EntityID: resolution.ID,
+			Fields:   DiffEntry("", resolution.Text, nil, resolution.FilePaths),
+		})
+	}
+
+	// เวลาทั้งหมดอยู่ในรูปแบบเดียวกัน (UTC) จึงเรียงตามสตริงได้
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+	return events, nil
+}
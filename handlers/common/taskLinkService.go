@@ -0,0 +1,201 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reports-api/models"
+	"reports-api/repository"
+)
+
+// LinkTasks links taskID to req.TaskID as req.Type and records the link in the change log of both tasks.
+// Invalid requests return models.ErrInvalidTaskLink, a missing task repository.ErrNotFound, and a link
+// that already exists or breaks the link rules models.ErrTaskLinkExists or models.ErrTaskLinkConflict.
+func LinkTasks(ctx context.Context, r *repository.Repositories, taskID int, req models.TaskLinkRequest, actor int) (int64, error) {
+	if !models.ValidTaskLinkType(req.Type) {
+		return 0, fmt.Errorf("%w: unknown type %q", models.ErrInvalidTaskLink, req.Type)
+	}
+	if req.TaskID == taskID {
+		return 0, fmt.Errorf("%w: a task cannot be linked to itself", models.ErrInvalidTaskLink)
+	}
+
+	link := models.TaskLinkRecord{TaskID: taskID, LinkedTaskID: req.TaskID, Type: req.Type, Inherit: req.Inherit, CreatedBy: &actor}
+	if !models.IsStoredTaskLink(link.Type) {
+		link.TaskID, link.LinkedTaskID, link.Type = link.LinkedTaskID, link.TaskID, models.InverseTaskLink(link.Type)
+	}
+	if link.Type == models.TaskLinkRelated && link.TaskID > link.LinkedTaskID {
+		link.TaskID, link.LinkedTaskID = link.LinkedTaskID, link.TaskID
+	}
+	if link.Inherit && !models.TaskLinkInherits(link.Type) {
+		return 0, fmt.Errorf("%w: only parent/child and duplicate links can inherit", models.ErrInvalidTaskLink)
+	}
+
+	var id int64
+	err := r.InTx(ctx, func(tx *repository.Repositories) error {
+		for _, linked := range []int{link.TaskID, link.LinkedTaskID} {
+			if _, err := tx.Tasks.Get(ctx, linked); err != nil {
+				return err
+			}
+		}
+		if err := checkTaskLink(ctx, tx, link); err != nil {
+			return err
+		}
+		var err error
+		id, err = tx.Links.Create(ctx, link)
+		if err != nil {
+			return err
+		}
+		link.ID = int(id)
+		return recordTaskLink(ctx, tx, link, models.ChangeActionCreate, actor)
+	})
+	return id, err
+}
+
+// UnlinkTasks removes a link of taskID and records it in the change log of both tasks
+func UnlinkTasks(ctx context.Context, r *repository.Repositories, taskID, linkID, actor int) error {
+	return r.InTx(ctx, func(tx *repository.Repositories) error {
+		link, err := tx.Links.Get(ctx, linkID)
+		if err != nil {
+			return err
+		}
+		if link.TaskID != taskID && link.LinkedTaskID != taskID {
+			return repository.ErrNotFound
+		}
+		if err := tx.Links.Delete(ctx, linkID); err != nil {
+			return err
+		}
+		return recordTaskLink(ctx, tx, *link, models.ChangeActionDelete, actor)
+	})
+}
+
+// checkTaskLink enforces one original per duplicate and one parent per child, and keeps directed links free of cycles
+func checkTaskLink(ctx context.Context, r *repository.Repositories, link models.TaskLinkRecord) error {
+	switch link.Type {
+	case models.TaskLinkRelated:
+		return nil
+	case models.TaskLinkDuplicateOf:
+		originals, err := r.Links.Targets(ctx, link.TaskID, models.TaskLinkDuplicateOf)
+		if err != nil {
+			return err
+		}
+		if len(originals) > 0 {
+			return fmt.Errorf("%w: task %d is already a duplicate of task %d", models.ErrTaskLinkConflict, link.TaskID, originals[0])
+		}
+	case models.TaskLinkParentOf:
+		parents, err := r.Links.Sources(ctx, link.LinkedTaskID, models.TaskLinkParentOf)
+		if err != nil {
+			return err
+		}
+		if len(parents) > 0 {
+			return fmt.Errorf("%w: task %d already has parent task %d", models.ErrTaskLinkConflict, link.LinkedTaskID, parents[0])
+		}
+	}
+
+	cycle, err := taskLinkReaches(ctx, r, link.LinkedTaskID, link.TaskID, link.Type)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return fmt.Errorf("%w: linking task %d to task %d would create a cycle", models.ErrTaskLinkConflict, link.TaskID, link.LinkedTaskID)
+	}
+	return nil
+}
+
+// taskLinkReaches reports whether to can be reached from from by following stored links of linkType
+func taskLinkReaches(ctx context.Context, r *repository.Repositories, from, to int, linkType string) (bool, error) {
+	seen := map[int]bool{from: true}
+	queue := []int{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == to {
+			return true, nil
+		}
+		next, err := r.Links.Targets(ctx, id, linkType)
+		if err != nil {
+			return false, err
+		}
+		for _, n := range next {
+			if !seen[n] {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	return false, nil
+}
+
+// recordTaskLink records an added or removed link in the change log of both tasks, each seeing the link from its side
+func recordTaskLink(ctx context.Context, r *repository.Repositories, link models.TaskLinkRecord, action string, actor int) error {
+	sides := []struct {
+		taskID, other int
+		linkType      string
+	}{
+		{link.TaskID, link.LinkedTaskID, link.Type},
+		{link.LinkedTaskID, link.TaskID, models.InverseTaskLink(link.Type)},
+	}
+	for _, side := range sides {
+		change := models.FieldChange{Field: side.linkType, New: side.other}
+		if action == models.ChangeActionDelete {
+			change = models.FieldChange{Field: side.linkType, Old: side.other}
+		}
+		if err := RecordChange(ctx, r, side.taskID, models.ChangeEntityLink, link.ID, action, actor, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveFollowers resolves the open children and duplicates that inherit the resolution of taskID, and
+// theirs in turn, with a copy of the resolution text; attachments are not copied. A closed follower is left
+// as it is but its own followers are still resolved.
+// Call it inside the InTx that resolves taskID. It returns the tasks it resolved.
+func ResolveFollowers(ctx context.Context, r *repository.Repositories, taskID int, text string, actor int) ([]int, error) {
+	resolved := []int{}
+	seen := map[int]bool{taskID: true}
+	queue := []int{taskID}
+	for len(queue) > 0 {
+		source := queue[0]
+		queue = queue[1:]
+		followers, err := r.Links.Followers(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range followers {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			task, err := r.Tasks.Get(ctx, id)
+			if errors.Is(err, repository.ErrNotFound) {
+				continue // อยู่ในถังขยะ
+			}
+			if err != nil {
+				return nil, err
+			}
+			// งานที่ปิดไปแล้วอาจยังมีลูกที่เปิดอยู่
+			queue = append(queue, id)
+			if task.Status.IsClosed() {
+				continue
+			}
+
+			resolutionID, err := r.Resolutions.Create(ctx, id, text, task.TelegramID, nil)
+			if err != nil {
+				return nil, err
+			}
+			if err := RecordChange(ctx, r, id, models.ChangeEntityResolution, int(resolutionID), models.ChangeActionCreate, actor,
+				DiffEntry("", text, nil, nil)...); err != nil {
+				return nil, err
+			}
+			if err := r.Tasks.Resolve(ctx, id, resolutionID); err != nil {
+				return nil, fmt.Errorf("failed to update solution_id in tasks: %w", err)
+			}
+			if _, err := TransitionTask(ctx, r, id, task.Status, models.TaskStatusDone, actor, fmt.Sprintf("resolved with linked task %d", source)); err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, id)
+		}
+	}
+	return resolved, nil
+}
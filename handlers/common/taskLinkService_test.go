@@ -0,0 +1,68 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"reports-api/models"
+	"reports-api/repository"
+	"testing"
+)
+
+// fakeLinks serves stored links from memory; the methods it does not override panic when called
+type fakeLinks struct {
+	repository.TaskLinkRepository
+	links []models.TaskLinkRecord
+}
+
+func (f *fakeLinks) Targets(ctx context.Context, taskID int, linkType string) ([]int, error) {
+	var ids []int
+	for _, l := range f.links {
+		if l.TaskID == taskID && l.Type == linkType {
+			ids = append(ids, l.LinkedTaskID)
+		}
+	}
+	return ids, nil
+}
+
+func (f *fakeLinks) Sources(ctx context.Context, taskID int, linkType string) ([]int, error) {
+	var ids []int
+	for _, l := range f.links {
+		if l.LinkedTaskID == taskID && l.Type == linkType {
+			ids = append(ids, l.TaskID)
+		}
+	}
+	return ids, nil
+}
+
+func TestCheckTaskLink(t *testing.T) {
+	r := &repository.Repositories{Links: &fakeLinks{links: []models.TaskLinkRecord{
+		{TaskID: 1, LinkedTaskID: 2, Type: models.TaskLinkParentOf},
+		{TaskID: 2, LinkedTaskID: 3, Type: models.TaskLinkParentOf},
+		{TaskID: 5, LinkedTaskID: 6, Type: models.TaskLinkDuplicateOf},
+		{TaskID: 7, LinkedTaskID: 8, Type: models.TaskLinkBlocks},
+		{TaskID: 8, LinkedTaskID: 9, Type: models.TaskLinkBlocks},
+	}}}
+
+	tests := []struct {
+		name    string
+		link    models.TaskLinkRecord
+		wantErr error
+	}{
+		{"related links are never checked", models.TaskLinkRecord{TaskID: 1, LinkedTaskID: 3, Type: models.TaskLinkRelated}, nil},
+		{"new child below a grandchild", models.TaskLinkRecord{TaskID: 3, LinkedTaskID: 4, Type: models.TaskLinkParentOf}, nil},
+		{"parent cycle through a grandchild", models.TaskLinkRecord{TaskID: 3, LinkedTaskID: 1, Type: models.TaskLinkParentOf}, models.ErrTaskLinkConflict},
+		{"second parent", models.TaskLinkRecord{TaskID: 4, LinkedTaskID: 3, Type: models.TaskLinkParentOf}, models.ErrTaskLinkConflict},
+		{"second original", models.TaskLinkRecord{TaskID: 5, LinkedTaskID: 10, Type: models.TaskLinkDuplicateOf}, models.ErrTaskLinkConflict},
+		{"duplicate cycle", models.TaskLinkRecord{TaskID: 6, LinkedTaskID: 5, Type: models.TaskLinkDuplicateOf}, models.ErrTaskLinkConflict},
+		{"blocking cycle over two links", models.TaskLinkRecord{TaskID: 9, LinkedTaskID: 7, Type: models.TaskLinkBlocks}, models.ErrTaskLinkConflict},
+		{"blocking chain", models.TaskLinkRecord{TaskID: 9, LinkedTaskID: 10, Type: models.TaskLinkBlocks}, nil},
+		{"cycles only follow the same type", models.TaskLinkRecord{TaskID: 3, LinkedTaskID: 1, Type: models.TaskLinkBlocks}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkTaskLink(context.Background(), r, tt.link); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkTaskLink(%+v) error = %v, want %v", tt.link, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reports-api/models"
+	"reports-api/repository"
+)
+
+// TransitionTask moves a task from its current status to next and writes the change to the status history.
+// It returns false without writing anything when the status does not change, and an error wrapping
+// models.ErrInvalidStatusTransition when the state machine does not allow the change.
+// The write only applies while the task still has status current; a concurrent change returns
+// models.ErrVersionConflict, so the transition is never checked against a stale status.
+// A task whose open children or duplicates inherit its resolution only moves to done once it has a resolution,
+// so they are resolved with it (see ResolveFollowers).
+// Call it inside InTx so the status and its history row are committed together.
+func TransitionTask(ctx context.Context, r *repository.Repositories, taskID int, current, next models.TaskStatus, actor int, reason string) (bool, error) {
+	return TransitionTaskVersion(ctx, r, taskID, 0, current, next, actor, reason)
+}
+
+// TransitionTaskVersion is TransitionTask for a client that edited the given version of the task (If-Match):
+// it also returns models.ErrVersionConflict when the task has another version
+func TransitionTaskVersion(ctx context.Context, r *repository.Repositories, taskID, version int, current, next models.TaskStatus, actor int, reason string) (bool, error) {
+	if current == next {
+		return false, nil
+	}
+	if !next.Valid() {
+		return false, fmt.Errorf("%w: unknown status %d", models.ErrInvalidStatusTransition, int(next))
+	}
+	if !current.CanTransitionTo(next) {
+		return false, fmt.Errorf("%w from %s to %s", models.ErrInvalidStatusTransition, current, next)
+	}
+	if next == models.TaskStatusDone {
+		if err := checkFollowersResolved(ctx, r, taskID); err != nil {
+			return false, err
+		}
+	}
+
+	if err := r.Tasks.SetStatus(ctx, taskID, current, next, version); err != nil {
+		return false, fmt.Errorf("failed to set task status: %w", err)
+	}
+	if err := r.StatusHistory.Record(ctx, taskID, &current, next, actor, reason); err != nil {
+		return false, err
+	}
+	// การเปลี่ยนสถานะครั้งแรกออกจากรอดำเนินการถือเป็นการตอบรับงาน (SLA first response)
+	if next != models.TaskStatusPending {
+		if err := r.SLA.MarkFirstResponse(ctx, taskID); err != nil {
+			return false, fmt.Errorf("failed to record first response: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// checkFollowersResolved refuses to close a task without a resolution while open tasks inherit its resolution
+func checkFollowersResolved(ctx context.Context, r *repository.Repositories, taskID int) error {
+	task, err := r.Tasks.Get(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if task.SolutionID != nil {
+		return nil
+	}
+	followers, err := r.Links.Followers(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	for _, id := range followers {
+		follower, err := r.Tasks.Get(ctx, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue // อยู่ในถังขยะ
+		}
+		if err != nil {
+			return err
+		}
+		if !follower.Status.IsClosed() {
+			return fmt.Errorf("%w: task %d inherits the resolution of task %d, add a resolution instead", models.ErrInvalidStatusTransition, id, taskID)
+		}
+	}
+	return nil
+}
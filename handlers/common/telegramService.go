@@ -86,19 +86,34 @@ func FormatRepostMessage(req models.TaskRequest, photoURLs ...string) string {
 	// สร้างข้อความตามสถานะ
 	var statusIcon, statusText, headerColor string
 	switch req.Status {
-	case 0:
+	case models.TaskStatusPending:
 		statusIcon = "🔴"
 		statusText = "รอดำเนินการ"
 		headerColor = "🚨 *แจ้งเตือนปัญหาระบบ* 🚨"
-	case 1:
+	case models.TaskStatusInProgress:
 		statusIcon = "🔵"
 		statusText = "กำลังดำเนินการ"
 		headerColor = "🔄 *กำลังดำเนินการแก้ไข* 🔄"
-	case 2:
+	case models.TaskStatusDone:
 		statusIcon = "✅"
 		statusText = "เสร็จสิ้น"
 		headerColor = "✅ *งานเสร็จสิ้นแล้ว* ✅"
-
+	case models.TaskStatusOnHold:
+		statusIcon = "⏸️"
+		statusText = req.Status.Label()
+		headerColor = "⏸️ *พักงานไว้ชั่วคราว* ⏸️"
+	case models.TaskStatusWaitingRequester:
+		statusIcon = "🟡"
+		statusText = req.Status.Label()
+		headerColor = "⏳ *รอข้อมูลจากผู้แจ้ง* ⏳"
+	case models.TaskStatusCancelled:
+		statusIcon = "⚫"
+		statusText = req.Status.Label()
+		headerColor = "🚫 *ยกเลิกงานแล้ว* 🚫"
+	case models.TaskStatusReopened:
+		statusIcon = "🟠"
+		statusText = req.Status.Label()
+		headerColor = "🔁 *เปิดงานใหม่อีกครั้ง* 🔁"
 	}
 
 	newMessage := headerColor + "\n"
@@ -144,10 +159,10 @@ func FormatRepostMessage(req models.TaskRequest, photoURLs ...string) string {
 		}
 	}
 	newMessage += "\n" + statusIcon + " *สถานะ:* " + EscapeMarkdown(statusText) + "\n"
-	if req.Status == 1 {
+	if req.Status == models.TaskStatusInProgress {
 		newMessage += "📆 *กำลังดำเนินการ:* " + req.UpdatedAt + "\n"
 	}
-	if req.Status == 2 {
+	if req.Status == models.TaskStatusDone {
 		newMessage += "📅 *วันที่แก้ไขเสร็จ:* " + req.ResolvedAt + "\n"
 	}
 
@@ -298,10 +313,8 @@ func UpdateTelegram(req models.TaskRequest, photoURL ...string) (int, error) {
 		}
 
 		var notificationMsg string
-		switch req.Status {
-		case 0:
-			notificationMsg = fmt.Sprintf("🔔 *การแจ้งเตือนมอบหมายงาน* 🔔\n━━━━━━━━━━━━━━\n👋 %s\n📋 คุณได้รับมอบหมายงานใหม่แล้ว\n🎫 *Ticket:* [%s](%s)\n🔗 [ดูรายละเอียดเพิ่มเติม](%s)\n━━━━━━━━━━━━━━", EscapeMarkdown(telegramTag), req.Ticket, req.Url, req.Url)
-		case 1:
+		// งานที่ปิดแล้วไม่ต้องแจ้งผู้รับผิดชอบ
+		if !req.Status.IsClosed() {
 			notificationMsg = fmt.Sprintf("🔔 *การแจ้งเตือนมอบหมายงาน* 🔔\n━━━━━━━━━━━━━━\n👋 %s\n📋 คุณได้รับมอบหมายงานใหม่แล้ว\n🎫 *Ticket:* [%s](%s)\n🔗 [ดูรายละเอียดเพิ่มเติม](%s)\n━━━━━━━━━━━━━━", EscapeMarkdown(telegramTag), req.Ticket, req.Url, req.Url)
 		}
 
@@ -347,10 +360,7 @@ func UpdateAssignedtoMsg(messageID int, req models.TaskRequest) (int, error) {
 	}
 
 	var notificationMsg string
-	switch req.Status {
-	case 0:
-		notificationMsg = fmt.Sprintf("🔔 *การแจ้งเตือนมอบหมายงาน* 🔔\n━━━━━━━━━━━━━━\n👋 %s\n📋 คุณได้รับมอบหมายงานใหม่แล้ว\n🎫 *Ticket:* `%s`\n━━━━━━━━━━━━━━\n🔗 [ดูรายละเอียดเพิ่มเติม](%s)\n━━━━━━━━━━━━━━", EscapeMarkdown(telegramTag), req.Ticket, req.Url)
-	case 1:
+	if !req.Status.IsClosed() {
 		notificationMsg = fmt.Sprintf("🔔 *การแจ้งเตือนมอบหมายงาน* 🔔\n━━━━━━━━━━━━━━\n👋 %s\n📋 คุณได้รับมอบหมายงานใหม่แล้ว\n🎫 *Ticket:* `%s`\n━━━━━━━━━━━━━━\n🔗 [ดูรายละเอียดเพิ่มเติม](%s)\n━━━━━━━━━━━━━━", EscapeMarkdown(telegramTag), req.Ticket, req.Url)
 	}
 
@@ -374,6 +384,25 @@ func UpdateAssignedtoMsg(messageID int, req models.TaskRequest) (int, error) {
 	}
 }
 
+// SendReply sends a Markdown text message in reply to messageID and returns the new message id
+func SendReply(messageID int, text string) (int, error) {
+	chatID, err := strconv.ParseInt(config.AppConfig.ChatID, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	bot, err := tgbotapi.NewBotAPI(config.AppConfig.BotToken)
+	if err != nil {
+		return 0, err
+	}
+
+	sentMsg, err := sendTextMessage(bot, chatID, text, messageID)
+	if err != nil {
+		return 0, err
+	}
+	return sentMsg.MessageID, nil
+}
+
 func DeleteTelegram(messageID int) (bool, error) {
 	if messageID <= 0 {
 		return false, nil
@@ -0,0 +1,50 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"reports-api/config"
+	"reports-api/models"
+	"reports-api/repository"
+	"strings"
+	"time"
+)
+
+// TicketPeriod returns the counter key of the reset period containing now and the period bounds
+func TicketPeriod(cfg models.TicketConfig, now time.Time) (key string, start, end time.Time) {
+	local := now.In(cfg.Location)
+	y, m, d := local.Date()
+	switch cfg.Reset {
+	case models.TicketResetDaily:
+		start = time.Date(y, m, d, 0, 0, 0, 0, cfg.Location)
+		return start.Format("20060102"), start, start.AddDate(0, 0, 1)
+	case models.TicketResetYearly:
+		start = time.Date(y, 1, 1, 0, 0, 0, 0, cfg.Location)
+		return start.Format("2006"), start, start.AddDate(1, 0, 0)
+	case models.TicketResetNever:
+		return "all", time.Unix(0, 0), local.AddDate(100, 0, 0)
+	default:
+		start = time.Date(y, m, 1, 0, 0, 0, 0, cfg.Location)
+		return start.Format("200601"), start, start.AddDate(0, 1, 0)
+	}
+}
+
+// FormatTicketNo renders a ticket number such as TK-20250131-0001 from the configured format
+func FormatTicketNo(cfg models.TicketConfig, now time.Time, seq int) string {
+	return strings.NewReplacer(
+		"{date}", now.In(cfg.Location).Format(cfg.DateLayout),
+		"{seq}", fmt.Sprintf("%0*d", cfg.SeqWidth, seq),
+	).Replace(cfg.Format)
+}
+
+// NextTicketNo allocates the next ticket number from the sequence counter of the current period
+func NextTicketNo(ctx context.Context, tickets repository.TicketSequenceRepository) (string, error) {
+	cfg := config.AppConfig.Ticket
+	now := time.Now()
+	key, start, end := TicketPeriod(cfg, now)
+	seq, err := tickets.Next(ctx, key, start, end)
+	if err != nil {
+		return "", err
+	}
+	return FormatTicketNo(cfg, now, seq), nil
+}
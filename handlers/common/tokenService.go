@@ -0,0 +1,204 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reports-api/config"
+	"reports-api/db"
+	"reports-api/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+	ErrRevokedToken = errors.New("token revoked")
+)
+
+// tokenHeader is the fixed JOSE header for HS256 signed tokens
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// SignToken serialises the claims and signs them with the configured secret (HS256)
+func SignToken(claims models.TokenClaims) (string, error) {
+	secret := config.AppConfig.TokenSecret
+	if secret == "" {
+		return "", errors.New("token secret is not configured")
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode token claims: %w", err)
+	}
+
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + signTokenPart(unsigned, secret), nil
+}
+
+// ParseToken verifies the signature, issuer and expiry of a token and returns its claims
+func ParseToken(token string) (*models.TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, ErrInvalidToken
+	}
+
+	expected := signTokenPart(parts[0]+"."+parts[1], config.AppConfig.TokenSecret)
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims models.TokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.ID == "" || claims.Issuer != config.AppConfig.TokenIssuer {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
+
+// IssueTokenPair creates a new access token and a persisted refresh token for the user
+func IssueTokenPair(userID int, username, role string) (models.TokenPair, error) {
+	now := time.Now()
+	accessTTL := config.AppConfig.AccessTokenTTL
+	refreshTTL := config.AppConfig.RefreshTokenTTL
+
+	access, err := SignToken(newClaims(userID, username, role, TokenTypeAccess, now, accessTTL))
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	refreshClaims := newClaims(userID, username, role, TokenTypeRefresh, now, refreshTTL)
+	refresh, err := SignToken(refreshClaims)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	_, err = db.DB.Exec(
+		`INSERT INTO refresh_tokens (jti, user_id, expires_at) VALUES (?, ?, ?)`,
+		refreshClaims.ID, userID, time.Unix(refreshClaims.ExpiresAt, 0).UTC(),
+	)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return models.TokenPair{
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(accessTTL.Seconds()),
+		RefreshExpiresIn: int64(refreshTTL.Seconds()),
+	}, nil
+}
+
+// RotateRefreshToken exchanges a valid refresh token for a new token pair and revokes the old one
+func RotateRefreshToken(refreshToken string) (models.TokenPair, *models.TokenClaims, error) {
+	claims, err := ParseToken(refreshToken)
+	if err != nil {
+		return models.TokenPair{}, nil, err
+	}
+	if claims.TokenType != TokenTypeRefresh {
+		return models.TokenPair{}, nil, ErrInvalidToken
+	}
+
+	// Revoke the presented token first so that a replayed refresh token can only be used once
+	res, err := db.DB.Exec(
+		`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE jti = ? AND revoked_at IS NULL`,
+		claims.ID,
+	)
+	if err != nil {
+		return models.TokenPair{}, nil, fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return models.TokenPair{}, nil, ErrRevokedToken
+	}
+
+	// Re-read the user so that role changes and deletions take effect on refresh
+	var username, role string
+	err = db.DB.QueryRow(`SELECT username, role FROM users WHERE id = ? AND deleted_at IS NULL`, claims.UserID).Scan(&username, &role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.TokenPair{}, nil, ErrRevokedToken
+		}
+		return models.TokenPair{}, nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	pair, err := IssueTokenPair(claims.UserID, username, role)
+	return pair, claims, err
+}
+
+// RevokeToken blacklists a token until it expires; refresh tokens are also marked revoked
+func RevokeToken(claims *models.TokenClaims) error {
+	if claims.TokenType == TokenTypeRefresh {
+		_, err := db.DB.Exec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE jti = ? AND revoked_at IS NULL`, claims.ID)
+		return err
+	}
+
+	_, err := db.DB.Exec(
+		`INSERT IGNORE INTO revoked_tokens (jti, expires_at) VALUES (?, ?)`,
+		claims.ID, time.Unix(claims.ExpiresAt, 0).UTC(),
+	)
+	return err
+}
+
+// RevokeUserRefreshTokens revokes every outstanding refresh token of a user
+func RevokeUserRefreshTokens(userID int) error {
+	_, err := db.DB.Exec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL`, userID)
+	return err
+}
+
+// IsTokenRevoked reports whether an access token has been revoked through logout
+func IsTokenRevoked(jti string) (bool, error) {
+	var exists bool
+	err := db.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = ?)`, jti).Scan(&exists)
+	return exists, err
+}
+
+// PurgeExpiredTokens removes revocation entries and refresh tokens that can no longer be used
+func PurgeExpiredTokens() error {
+	if _, err := db.DB.Exec(`DELETE FROM revoked_tokens WHERE expires_at < UTC_TIMESTAMP()`); err != nil {
+		return err
+	}
+	_, err := db.DB.Exec(`DELETE FROM refresh_tokens WHERE expires_at < UTC_TIMESTAMP()`)
+	return err
+}
+
+func newClaims(userID int, username, role, tokenType string, now time.Time, ttl time.Duration) models.TokenClaims {
+	return models.TokenClaims{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		TokenType: tokenType,
+		Issuer:    config.AppConfig.TokenIssuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+}
+
+func signTokenPart(unsigned, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,182 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reports-api/config"
+	"reports-api/models"
+	"reports-api/repository"
+	"time"
+)
+
+// trashPurgeBatch caps the records of one kind purged per retention run
+const trashPurgeBatch = 100
+
+// RestoreTrashed moves a record out of the trash. A restored resolution is linked to its task again
+// and the task is marked done, unless the task already has another resolution.
+// Restoring a task, progress entry or resolution is recorded in the task's change log.
+func RestoreTrashed(ctx context.Context, r *repository.Repositories, kind string, id, actor int) error {
+	return r.InTx(ctx, func(tx *repository.Repositories) error {
+		taskID, err := tx.Trash.Restore(ctx, kind, id)
+		if err != nil {
+			return err
+		}
+		if entity, ok := trashChangeEntities[kind]; ok {
+			if err := RecordChange(ctx, tx, taskID, entity, id, models.ChangeActionRestore, actor); err != nil {
+				return err
+			}
+		}
+		if kind != models.TrashResolutions {
+			return nil
+		}
+
+		resolution, err := tx.Resolutions.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		task, err := tx.Tasks.Get(ctx, resolution.TaskID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("%w: task %d is deleted", models.ErrRestoreConflict, resolution.TaskID)
+		}
+		if err != nil {
+			return err
+		}
+		if task.SolutionID != nil {
+			return fmt.Errorf("%w: task %d already has a resolution", models.ErrRestoreConflict, resolution.TaskID)
+		}
+		if err := tx.Tasks.Resolve(ctx, resolution.TaskID, int64(id)); err != nil {
+			return fmt.Errorf("failed to link resolution: %w", err)
+		}
+		_, err = TransitionTask(ctx, tx, resolution.TaskID, task.Status, models.TaskStatusDone, actor, "resolution restored")
+		return err
+	})
+}
+
+// trashChangeEntities maps the trash kinds recorded in the task change log to their entity
+var trashChangeEntities = map[string]string{
+	models.TrashTasks:       models.ChangeEntityTask,
+	models.TrashProgress:    models.ChangeEntityProgress,
+	models.TrashResolutions: models.ChangeEntityResolution,
+}
+
+// PurgeTrashed permanently deletes a trashed record. A task takes its progress, resolutions,
+// status history, change log, incidents, links, SLA events and Telegram chat with it. Files and Telegram messages are
+// removed only after the database changes are committed.
+// A branch, department, IP phone or program is kept while any task, child record or problem record, trashed or not,
+// still references it; its scores, SLA policies, working hours and holidays are purged with it.
+func PurgeTrashed(ctx context.Context, r *repository.Repositories, kind string, id int) error {
+	att, err := r.Trash.Attachments(ctx, kind, id)
+	if err != nil {
+		return err
+	}
+
+	err = r.InTx(ctx, func(tx *repository.Repositories) error {
+		if kind != models.TrashTasks {
+			if err := purgeMasterDataRows(ctx, tx, kind, id); err != nil {
+				return err
+			}
+			return tx.Trash.Purge(ctx, kind, id)
+		}
+		task, err := tx.Tasks.GetDeleted(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Progress.DeleteByTask(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete progress: %w", err)
+		}
+		if err := tx.Resolutions.DeleteByTask(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete resolutions: %w", err)
+		}
+		if err := tx.StatusHistory.DeleteByTask(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete status history: %w", err)
+		}
+		if err := tx.Changes.DeleteByTask(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete change log: %w", err)
+		}
+		if err := tx.Incidents.DeleteByTask(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete incidents: %w", err)
+		}
+		if err := tx.Links.DeleteByTask(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete task links: %w", err)
+		}
+		if err := tx.ProblemRecords.DeleteByTask(ctx, id); err != nil {
+			return fmt.Errorf("failed to detach problem record: %w", err)
+		}
+		if err := tx.SLA.DeleteTaskEvents(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete SLA events: %w", err)
+		}
+		if task.TelegramID > 0 {
+			if err := tx.TelegramChats.Delete(ctx, task.TelegramID); err != nil {
+				return fmt.Errorf("failed to delete telegram chat: %w", err)
+			}
+		}
+		return tx.Trash.Purge(ctx, kind, id)
+	})
+	if err != nil {
+		return err
+	}
+
+	DeleteFileURLs(att.FileURLs)
+	for _, messageID := range att.TelegramMessageIDs {
+		if _, err := DeleteTelegram(messageID); err != nil {
+			log.Printf("Failed to delete Telegram message %d: %v", messageID, err)
+		}
+	}
+	return nil
+}
+
+// purgeMasterDataRows fails with models.ErrHasDependents while tasks, child records or problem records still point at
+// a branch, department, IP phone or program; trashed rows count too, as they may be restored later.
+// Otherwise it deletes the scores, SLA policies, working hours and holidays the record owns.
+func purgeMasterDataRows(ctx context.Context, tx *repository.Repositories, kind string, id int) error {
+	switch kind {
+	case models.TrashBranches, models.TrashDepartments, models.TrashPhones, models.TrashPrograms:
+	default:
+		return nil
+	}
+	n, err := tx.MasterData.References(ctx, kind, id)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: %s %d is referenced by %d records", models.ErrHasDependents, kind, id, n)
+	}
+	return tx.MasterData.PurgeOwned(ctx, kind, id)
+}
+
+// PurgeExpiredTrash permanently deletes records trashed longer than TRASH_RETENTION ago and
+// returns how many were purged. A record that fails is logged and skipped for the rest of the run,
+// so it never holds back the records behind it.
+func PurgeExpiredTrash(ctx context.Context, r *repository.Repositories, now time.Time) (int, error) {
+	before := now.Add(-config.AppConfig.TrashRetention)
+	purged := 0
+	for _, kind := range models.TrashKinds {
+		var failed []int
+		kindPurged := 0
+		for kindPurged < trashPurgeBatch {
+			ids, err := r.Trash.Expired(ctx, kind, before, failed, trashPurgeBatch-kindPurged)
+			if err != nil {
+				return purged, err
+			}
+			if len(ids) == 0 {
+				break
+			}
+			for _, id := range ids {
+				err := PurgeTrashed(ctx, r, kind, id)
+				// รายการอาจถูกลบถาวรจาก API ไปแล้วระหว่างรอบ
+				if err != nil && !errors.Is(err, repository.ErrNotFound) {
+					log.Printf("Failed to purge expired %s %d: %v", kind, id, err)
+					failed = append(failed, id)
+					continue
+				}
+				if err == nil {
+					purged++
+				}
+				kindPurged++
+			}
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reports-api/models"
+	"reports-api/repository"
+	"reports-api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// versionConflict answers 409 with the current state of a record edited from a stale version
+func versionConflict(c *fiber.Ctx, version int, current interface{}) error {
+	utils.SetETag(c, version)
+	return c.Status(409).JSON(fiber.Map{"error": models.ErrVersionConflict.Error(), "current": current})
+}
+
+// taskConflict answers 409 with the current state of a task
+func taskConflict(c *fiber.Ctx, id int) error {
+	current, err := repos.Tasks.GetDetail(c.UserContext(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
+	}
+	if err != nil {
+		log.Printf("Error fetching task %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query task"})
+	}
+	return versionConflict(c, current.Version, current)
+}
+
+// progressConflict answers 409 with the current state of a progress entry of task
+func progressConflict(c *fiber.Ctx, id int, task *models.TaskRecord) error {
+	current, err := repos.Progress.Get(c.UserContext(), id, task.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Progress not found"})
+	}
+	if err != nil {
+		log.Printf("Error fetching progress %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get progress entries"})
+	}
+	return versionConflict(c, current.Version, progressEntry(current, task))
+}
+
+// resolutionConflict answers 409 with the current state of a resolution
+func resolutionConflict(c *fiber.Ctx, id int) error {
+	current, err := repos.Resolutions.Get(c.UserContext(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Resolution not found"})
+	}
+	if err != nil {
+		log.Printf("Error fetching resolution %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update resolution"})
+	}
+	return versionConflict(c, current.Version, resolutionResponse(current))
+}
+
+// resolutionCurrent reports whether a resolution still has the version an update committed,
+// so the Telegram edits that follow the update do not publish a stale version
+func resolutionCurrent(ctx context.Context, id, version int) bool {
+	current, err := repos.Resolutions.Get(ctx, id)
+	if err != nil {
+		log.Printf("Error fetching resolution %d: %v", id, err)
+		return false
+	}
+	if current.Version != version {
+		log.Printf("Resolution %d changed after version %d, skipping its Telegram update", id, version)
+		return false
+	}
+	return true
+}
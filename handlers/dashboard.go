@@ -225,18 +225,26 @@ func GetDashboardDataHandler(c *fiber.Ctx) error {
 		}
 	}
 
+	// ดึงข้อมูล problem records ที่ยังไม่ปิด
+	problemRecords, err := repos.ProblemRecords.List(c.UserContext(), models.ProblemRecordQuery{Active: true})
+	if err != nil {
+		logger.Printf("❌ ERROR: Failed to query problem records: %v", err)
+		problemRecords = []models.ProblemRecord{}
+	}
+
 	chartData := calculateChartData(tasks)
 
 	response := models.DashboardResponse{
-		Success:     true,
-		Message:     "Dashboard data retrieved successfully",
-		ChartData:   chartData,
-		Branches:    branches,
-		Departments: departments,
-		IPPhones:    ipPhones,
-		Programs:    programs,
-		Tasks:       tasks,
-		IssueTypes:  issueTypes,
+		Success:        true,
+		Message:        "Dashboard data retrieved successfully",
+		ChartData:      chartData,
+		Branches:       branches,
+		Departments:    departments,
+		IPPhones:       ipPhones,
+		Programs:       programs,
+		Tasks:          tasks,
+		IssueTypes:     issueTypes,
+		ProblemRecords: problemRecords,
 	}
 	return c.JSON(response)
 }
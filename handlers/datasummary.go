@@ -5,6 +5,7 @@ import (
 	"encoding/csv"
 	"fmt"
 	"reports-api/db"
+	"reports-api/models"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -352,12 +353,7 @@ func TasksExportCsv(c *fiber.Ctx) error {
 			t.reported_by,
 			r.name as assignto_name,
 			res.text as solution_text,
-			CASE 
-				WHEN t.status = 0 THEN 'รอดำเนินการ'
-				WHEN t.status = 1 THEN 'กำลังดำเนินการ'
-				WHEN t.status = 2 THEN 'เสร็จสิ้นแล้ว'
-				ELSE 'ไม่ระบุ'
-			END as status_text,
+			t.status,
 			GROUP_CONCAT(DISTINCT p.progress_text ORDER BY p.created_at SEPARATOR ' , ') as progress_notes,
 			DATE_ADD(t.created_at, INTERVAL 7 HOUR) as created_at,
 			DATE_ADD(t.updated_at, INTERVAL 7 HOUR) as updated_at,
@@ -397,9 +393,10 @@ func TasksExportCsv(c *fiber.Ctx) error {
 
 	for rows.Next() {
 		var id int
-		var ticketNo, phoneName, issueTypeName, systemName, branchName, departmentName, text, reportedBy, assigntoName, solutionText, statusText, progressNotes, createdAt, updatedAt, resolvedAt sql.NullString
+		var status models.TaskStatus
+		var ticketNo, phoneName, issueTypeName, systemName, branchName, departmentName, text, reportedBy, assigntoName, solutionText, progressNotes, createdAt, updatedAt, resolvedAt sql.NullString
 
-		err := rows.Scan(&id, &ticketNo, &phoneName, &issueTypeName, &systemName, &branchName, &departmentName, &text, &reportedBy, &assigntoName, &solutionText, &statusText, &progressNotes, &createdAt, &updatedAt, &resolvedAt)
+		err := rows.Scan(&id, &ticketNo, &phoneName, &issueTypeName, &systemName, &branchName, &departmentName, &text, &reportedBy, &assigntoName, &solutionText, &status, &progressNotes, &createdAt, &updatedAt, &resolvedAt)
 		if err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Failed to scan data"})
 		}
@@ -416,7 +413,7 @@ func TasksExportCsv(c *fiber.Ctx) error {
 			reportedBy.String,
 			assigntoName.String,
 			solutionText.String,
-			statusText.String,
+			status.Label(),
 			progressNotes.String,
 			createdAt.String,
 			updatedAt.String,
@@ -3,8 +3,8 @@ package handlers
 import (
 	"log"
 	"net/url"
-	"reports-api/db"
 	"reports-api/models"
+	"reports-api/repository"
 	"reports-api/utils"
 	"strconv"
 	"strings"
@@ -26,40 +26,11 @@ func ListDepartmentsHandler(c *fiber.Ctx) error {
 	pagination := utils.GetPaginationParams(c)
 	offset := utils.CalculateOffset(pagination.Page, pagination.Limit)
 
-	// Get total count
-	var total int
-	err := db.DB.QueryRow(`
-		SELECT COUNT(*) FROM departments d
-		WHERE d.deleted_at IS NULL
-	`).Scan(&total)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count departments"})
-	}
-
-	// Get paginated data
-	rows, err := db.DB.Query(`
-		SELECT d.id, d.name, d.branch_id, b.name as branch_name, d.created_at, d.updated_at, d.deleted_at
-		FROM departments d
-		LEFT JOIN branches b ON d.branch_id = b.id
-		WHERE d.deleted_at IS NULL
-		ORDER BY d.id DESC
-		LIMIT ? OFFSET ?
-	`, pagination.Limit, offset)
+	departments, total, err := repos.Departments.List(c.UserContext(), repository.ListQuery{Limit: pagination.Limit, Offset: offset})
 	if err != nil {
+		log.Printf("Error listing departments: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query departments"})
 	}
-	defer rows.Close()
-
-	var departments []models.Department
-	for rows.Next() {
-		var d models.Department
-		err := rows.Scan(&d.ID, &d.Name, &d.BranchID, &d.BranchName, &d.CreatedAt, &d.UpdatedAt, &d.DeletedAt)
-		if err != nil {
-			log.Printf("Error scanning department: %v", err)
-			continue
-		}
-		departments = append(departments, d)
-	}
 
 	log.Printf("Getting departments Success")
 	return c.JSON(models.PaginatedResponse{
@@ -90,12 +61,16 @@ func CreateDepartmentHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	res, err := db.DB.Exec(`INSERT INTO departments (name, branch_id) VALUES (?, ?)`, req.Name, req.BranchID)
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	id, err := repos.Departments.Create(c.UserContext(), req, actor)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert department"})
 	}
 
-	id, _ := res.LastInsertId()
 	log.Printf("Inserted new department: %s", req.Name)
 	return c.JSON(fiber.Map{"success": true, "id": id})
 }
@@ -123,8 +98,12 @@ func UpdateDepartmentHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	_, err = db.DB.Exec(`UPDATE departments SET name=?, branch_id=?, updated_at=CURRENT_TIMESTAMP WHERE id=? AND deleted_at IS NULL`, req.Name, req.BranchID, id)
+	actor, err := utils.AuditActor(c)
 	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	if err := repos.Departments.Update(c.UserContext(), id, req, actor); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update department"})
 	}
 
@@ -133,29 +112,37 @@ func UpdateDepartmentHandler(c *fiber.Ctx) error {
 }
 
 // @Summary Delete department
-// @Description Delete a department
+// @Description Move a department to the trash. While live IP phones, tasks and scores depend on it, mode decides what happens:
+// @Description block (default) answers 409 with the dependents, cascade moves them to the trash too,
+// @Description reassign moves them to reassign_to first.
 // @Tags departments
 // @Accept json
 // @Produce json
 // @Param id path string true "Department ID"
+// @Param mode query string false "block, cascade or reassign" default(block)
+// @Param reassign_to query int false "Department ID receiving the dependents in reassign mode"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/department/delete/{id} [delete]
 func DeleteDepartmentHandler(c *fiber.Ctx) error {
-	idStr := c.Params("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
-	}
-
-	_, err = db.DB.Exec(`DELETE FROM departments WHERE id=?`, id)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete department"})
-	}
+	return deleteMasterData(c, models.TrashDepartments, "Department not found", "Failed to delete department")
+}
 
-	log.Printf("Deleted department ID: %d", id)
-	return c.JSON(fiber.Map{"success": true})
+// @Summary Get department dependents
+// @Description Count the live IP phones, tasks and scores that depend on a department
+// @Tags departments
+// @Produce json
+// @Param id path string true "Department ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/department/dependents/{id} [get]
+func GetDepartmentDependentsHandler(c *fiber.Ctx) error {
+	return masterDataDependents(c, models.TrashDepartments, "Department not found")
 }
 
 // @Summary Get department details
@@ -175,46 +162,12 @@ func GetDepartmentDetailHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
 	}
 
-	var departmentDetail models.DepartmentDetail
-	err = db.DB.QueryRow(`
-		SELECT d.id, d.name, IFNULL(d.branch_id, 0) as branch_id, IFNULL(b.name, '') as branch_name, d.created_at, d.updated_at 
-		FROM departments d
-		LEFT JOIN branches b ON d.branch_id = b.id
-		WHERE d.id = ? AND d.deleted_at IS NULL
-	`, id).Scan(
-		&departmentDetail.ID,
-		&departmentDetail.Name,
-		&departmentDetail.BranchID,
-		&departmentDetail.BranchName,
-		&departmentDetail.CreatedAt,
-		&departmentDetail.UpdatedAt,
-	)
-
+	departmentDetail, err := repos.Departments.Get(c.UserContext(), id)
 	if err != nil {
 		log.Printf("Error fetching department details: %v", err)
 		return c.Status(404).JSON(fiber.Map{"error": "Department not found"})
 	}
 
-	err = db.DB.QueryRow(`
-		SELECT COUNT(*) 
-		FROM ip_phones 
-		WHERE department_id = ? AND deleted_at IS NULL
-	`, id).Scan(&departmentDetail.IPPhonesCount)
-
-	if err != nil {
-		log.Printf("Error counting IP phones: %v", err)
-	}
-
-	err = db.DB.QueryRow(`
-		SELECT COUNT(*) FROM tasks t
-		JOIN ip_phones ip ON t.phone_id = ip.id
-		WHERE ip.department_id = ? AND t.deleted_at IS NULL
-	`, id).Scan(&departmentDetail.TasksCount)
-
-	if err != nil {
-		log.Printf("Error counting tasks: %v", err)
-	}
-
 	log.Printf("Getting department details Success for ID: %d", id)
 	return c.JSON(fiber.Map{"success": true, "data": departmentDetail})
 }
@@ -228,39 +181,11 @@ func GetDepartmentDetailHandler(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/department/listall [get]
 func AllDepartmentsHandler(c *fiber.Ctx) error {
-	// Get total count
-	var total int
-	err := db.DB.QueryRow(`
-		SELECT COUNT(*) FROM departments d
-		WHERE d.deleted_at IS NULL
-	`).Scan(&total)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count departments"})
-	}
-
-	// Get paginated data
-	rows, err := db.DB.Query(`
-		SELECT d.id, d.name, d.branch_id, b.name as branch_name, d.created_at, d.updated_at, d.deleted_at
-		FROM departments d
-		LEFT JOIN branches b ON d.branch_id = b.id
-		WHERE d.deleted_at IS NULL
-		ORDER BY d.id DESC
-	`)
+	departments, _, err := repos.Departments.List(c.UserContext(), repository.ListQuery{})
 	if err != nil {
+		log.Printf("Error listing departments: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query departments"})
 	}
-	defer rows.Close()
-
-	var departments []models.Department
-	for rows.Next() {
-		var d models.Department
-		err := rows.Scan(&d.ID, &d.Name, &d.BranchID, &d.BranchName, &d.CreatedAt, &d.UpdatedAt, &d.DeletedAt)
-		if err != nil {
-			log.Printf("Error scanning department: %v", err)
-			continue
-		}
-		departments = append(departments, d)
-	}
 
 	log.Printf("Getting departments Success")
 	return c.JSON(models.PaginatedResponse{
@@ -307,45 +232,11 @@ func ListDepartmentsQueryHandler(c *fiber.Ctx) error {
 	decodedQuery = strings.ReplaceAll(decodedQuery, "'", "")
 	decodedQuery = strings.ReplaceAll(decodedQuery, "\"", "")
 
-	searchPattern := "%" + decodedQuery + "%"
-
-	// Get total count
-	var total int
-	err = db.DB.QueryRow(`
-		SELECT COUNT(*) FROM departments d
-		LEFT JOIN branches b ON d.branch_id = b.id AND b.deleted_at IS NULL
-		WHERE d.deleted_at IS NULL
-		AND (d.name LIKE ? OR b.name LIKE ?)
-	`, searchPattern, searchPattern).Scan(&total)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count search results"})
-	}
-
-	// Get search results with pagination
-	rows, err := db.DB.Query(`
-		SELECT d.id, d.name, d.branch_id, IFNULL(b.name, '') as branch_name, d.created_at, d.updated_at, d.deleted_at
-		FROM departments d
-		LEFT JOIN branches b ON d.branch_id = b.id AND b.deleted_at IS NULL
-		WHERE d.deleted_at IS NULL
-		AND (d.name LIKE ? OR b.name LIKE ?)
-		ORDER BY d.id DESC
-		LIMIT ? OFFSET ?
-	`, searchPattern, searchPattern, pagination.Limit, offset)
+	departments, total, err := repos.Departments.List(c.UserContext(), repository.ListQuery{Search: decodedQuery, Limit: pagination.Limit, Offset: offset})
 	if err != nil {
+		log.Printf("Error searching departments: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to search departments"})
 	}
-	defer rows.Close()
-
-	var departments []models.Department
-	for rows.Next() {
-		var d models.Department
-		err := rows.Scan(&d.ID, &d.Name, &d.BranchID, &d.BranchName, &d.CreatedAt, &d.UpdatedAt, &d.DeletedAt)
-		if err != nil {
-			log.Printf("Error scanning department: %v", err)
-			continue
-		}
-		departments = append(departments, d)
-	}
 
 	log.Printf("Searching departments with query: %s, found %d results", query, len(departments))
 	return c.JSON(models.PaginatedResponse{
@@ -359,3 +250,31 @@ func ListDepartmentsQueryHandler(c *fiber.Ctx) error {
 		},
 	})
 }
+
+// @Summary Merge departments
+// @Description Move the IP phones, tasks and scores (scores of the same month are combined) of the source departments to the survivor, move the sources to the trash and record the merge.
+// @Description With dry_run nothing changes and the response shows what would move.
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param request body models.MergeRequest true "Survivor, sources and dry run"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/department/merge [post]
+func MergeDepartmentsHandler(c *fiber.Ctx) error {
+	return mergeMasterData(c, models.TrashDepartments)
+}
+
+// @Summary Find duplicate departments
+// @Description Suggest merge candidates: pairs of departments of the same branch with similar names
+// @Tags departments
+// @Produce json
+// @Param threshold query number false "Minimum similarity between 0 and 1" default(0.8)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/department/duplicates [get]
+func GetDepartmentDuplicatesHandler(c *fiber.Ctx) error {
+	return masterDataDuplicates(c, models.TrashDepartments)
+}
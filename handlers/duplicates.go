@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"log"
+	"reports-api/config"
+	"reports-api/handlers/common"
+	"reports-api/models"
+	"reports-api/repository"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// duplicateQuery builds the duplicate lookup of a report; a report against a phone belongs to the phone's department
+func duplicateQuery(c *fiber.Ctx, req models.TaskRequest) repository.DuplicateQuery {
+	ctx := c.UserContext()
+	q := repository.DuplicateQuery{SystemID: req.SystemID, IssueTypeID: req.IssueTypeID, DepartmentID: req.DepartmentID}
+	if req.PhoneID != nil && *req.PhoneID > 0 {
+		if departmentID, err := repos.Phones.DepartmentID(ctx, *req.PhoneID); err == nil {
+			q.DepartmentID = departmentID
+		}
+	}
+	if q.SystemID > 0 {
+		q.IssueTypeID, _ = repos.Programs.IssueType(ctx, q.SystemID)
+	}
+	return q
+}
+
+// @Summary Check problem duplicates
+// @Description List the open problems a report would duplicate, so the caller can warn before submitting
+// @Tags problems
+// @Accept json
+// @Produce json
+// @Param request body models.TaskRequest true "phone_id or department_id, system_id or issue_type, and text"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problem/duplicates [post]
+func CheckTaskDuplicatesHandler(c *fiber.Ctx) error {
+	var req models.TaskRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	duplicates, err := common.FindDuplicateTasks(c.UserContext(), repos, duplicateQuery(c, req), req.Text)
+	if err != nil {
+		log.Printf("Error checking duplicate tasks: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check duplicates"})
+	}
+	return c.JSON(fiber.Map{"success": true, "policy": config.AppConfig.Duplicate.Policy, "data": duplicates})
+}
+
+// attachDuplicateReport stores a report as an incident of the task it duplicates instead of opening a ticket.
+// Nothing is posted to Telegram; the duplicated task keeps its message.
+func attachDuplicateReport(c *fiber.Ctx, req models.TaskRequest, duplicates []models.DuplicateCandidate, filePaths *string, actor int) error {
+	target := duplicates[0]
+	incident := models.TaskIncident{
+		TaskID:       target.ID,
+		PhoneID:      req.PhoneID,
+		PhoneElse:    req.PhoneElse,
+		DepartmentID: req.DepartmentID,
+		Text:         req.Text,
+		ReportedBy:   req.ReportedBy,
+		FilePaths:    filePaths,
+		Similarity:   target.Similarity,
+		CreatedBy:    &actor,
+	}
+	incidentID, err := common.AttachIncident(c.UserContext(), repos, incident, actor)
+	if err != nil {
+		log.Printf("Error attaching duplicate report to task %d: %v", target.ID, err)
+		if filePaths != nil {
+			deleteUploadedFiles(*filePaths)
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert task"})
+	}
+
+	log.Printf("Attached duplicate report as incident %d of task %d (%s) by user ID: %d", incidentID, target.ID, target.Ticket, actor)
+	return c.JSON(fiber.Map{
+		"success":     true,
+		"id":          target.ID,
+		"ticket_no":   target.Ticket,
+		"linked":      true,
+		"incident_id": incidentID,
+		"duplicates":  duplicates,
+	})
+}
+
+// @Summary List problem incidents
+// @Description List the duplicate reports attached to a problem, oldest first
+// @Tags problems
+// @Produce json
+// @Param id path string true "Problem ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problem/{id}/incidents [get]
+func GetTaskIncidentsHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	incidents, err := repos.Incidents.ListByTask(c.UserContext(), id)
+	if err != nil {
+		log.Printf("Failed to get incidents of task %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get incidents"})
+	}
+	for i := range incidents {
+		incidents[i].FileURLs = []string{}
+		if incidents[i].FilePaths != nil {
+			incidents[i].FileURLs = append(incidents[i].FileURLs, getPhotoURLs(*incidents[i].FilePaths)...)
+		}
+		incidents[i].CreatedAt = common.Fixtimefeature(incidents[i].CreatedAt)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": incidents, "count": len(incidents)})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"reports-api/config"
+	"reports-api/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header
+const maxIdempotencyKeyLength = 255
+
+// Idempotent runs a create request once per Idempotency-Key: a retry with the same key by the same user
+// on the same path replays the stored response instead of running the handler again. A retry whose body
+// differs from the first request is answered with 422.
+// Only successful responses are stored; a failed request releases its key so it can be retried.
+// Requests without the header run as usual.
+func Idempotent(c *fiber.Ctx) error {
+	key := c.Get("Idempotency-Key")
+	if key == "" {
+		return c.Next()
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return c.Status(400).JSON(fiber.Map{"error": "Idempotency-Key must be at most " + strconv.Itoa(maxIdempotencyKeyLength) + " characters"})
+	}
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	fingerprint, err := requestFingerprint(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid form data"})
+	}
+
+	ctx := c.UserContext()
+	sum := sha256.Sum256([]byte(strconv.Itoa(actor) + "\n" + c.Method() + "\n" + c.Path() + "\n" + key))
+	bucket := hex.EncodeToString(sum[:])
+	cfg := config.AppConfig.Idempotency
+
+	record, reserved, err := repos.Idempotency.Reserve(ctx, bucket, fingerprint, time.Now().UTC(), cfg.Window, cfg.LockTimeout)
+	if err != nil {
+		log.Printf("Error reserving Idempotency-Key for %s: %v", c.Path(), err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check Idempotency-Key"})
+	}
+	if !reserved {
+		// key ที่เก็บก่อนมี fingerprint จะไม่ถูกเทียบ
+		if record.Fingerprint != "" && record.Fingerprint != fingerprint {
+			return c.Status(422).JSON(fiber.Map{"error": "Idempotency-Key was already used for a different request"})
+		}
+		if record.StatusCode == 0 {
+			return c.Status(409).JSON(fiber.Map{"error": "A request with this Idempotency-Key is still in progress"})
+		}
+		log.Printf("Replaying response of Idempotency-Key for %s by user ID: %d", c.Path(), actor)
+		c.Set("Idempotent-Replayed", "true")
+		if record.ContentType != "" {
+			c.Set(fiber.HeaderContentType, record.ContentType)
+		}
+		return c.Status(record.StatusCode).Send(record.Body)
+	}
+
+	if err := c.Next(); err != nil {
+		releaseIdempotencyKey(c, bucket)
+		return err
+	}
+	status := c.Response().StatusCode()
+	if status < 200 || status >= 300 {
+		releaseIdempotencyKey(c, bucket)
+		return nil
+	}
+	body := append([]byte(nil), c.Response().Body()...)
+	if err := repos.Idempotency.Complete(ctx, bucket, status, string(c.Response().Header.ContentType()), body); err != nil {
+		// งานสำเร็จแล้ว ส่งผลลัพธ์ตามปกติ แต่ retry ด้วย key เดิมจะได้ 409 จนกว่า claim จะหมดเวลา
+		log.Printf("Error storing response of Idempotency-Key for %s: %v", c.Path(), err)
+	}
+	return nil
+}
+
+// releaseIdempotencyKey frees the key of a request that did not succeed
+func releaseIdempotencyKey(c *fiber.Ctx, bucket string) {
+	if err := repos.Idempotency.Release(c.UserContext(), bucket); err != nil {
+		log.Printf("Error releasing Idempotency-Key for %s: %v", c.Path(), err)
+	}
+}
+
+// requestFingerprint hashes the request body. A multipart body is hashed by its form fields and the names and
+// sizes of its files, since the boundary changes on every retry.
+func requestFingerprint(c *fiber.Ctx) (string, error) {
+	h := sha256.New()
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		h.Write(c.Body())
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return "", err
+	}
+	fields := make([]string, 0, len(form.Value))
+	for name := range form.Value {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	for _, name := range fields {
+		for _, value := range form.Value[name] {
+			fmt.Fprintf(h, "value %q %q\n", name, value)
+		}
+	}
+	files := make([]string, 0, len(form.File))
+	for name := range form.File {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		for _, file := range form.File[name] {
+			fmt.Fprintf(h, "file %q %q %d\n", name, file.Filename, file.Size)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -3,8 +3,8 @@ package handlers
 import (
 	"log"
 	"net/url"
-	"reports-api/db"
 	"reports-api/models"
+	"reports-api/repository"
 	"reports-api/utils"
 	"strconv"
 	"strings"
@@ -26,44 +26,11 @@ func ListIPPhonesHandler(c *fiber.Ctx) error {
 	pagination := utils.GetPaginationParams(c)
 	offset := utils.CalculateOffset(pagination.Page, pagination.Limit)
 
-	// Get total count
-	var total int
-	err := db.DB.QueryRow(`SELECT COUNT(*) FROM ip_phones WHERE deleted_at IS NULL`).Scan(&total)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count ip_phones"})
-	}
-
-	// Get paginated data
-	rows, err := db.DB.Query(`
-		SELECT ip.id, ip.number, ip.name, IFNULL(ip.department_id,0) as department_id,
-			   IFNULL(d.name, '') as department_name, IFNULL(d.branch_id, 0) as branch_id, IFNULL(b.name, '') as branch_name,
-			   ip.created_at, ip.updated_at, ip.deleted_at, ip.created_by, ip.updated_by, ip.deleted_by
-		FROM ip_phones ip
-		LEFT JOIN departments d ON ip.department_id = d.id
-		LEFT JOIN branches b ON d.branch_id = b.id
-		WHERE ip.deleted_at IS NULL
-		ORDER BY ip.id DESC
-		LIMIT ? OFFSET ?
-	`, pagination.Limit, offset)
+	phones, total, err := repos.Phones.List(c.UserContext(), repository.ListQuery{Limit: pagination.Limit, Offset: offset})
 	if err != nil {
+		log.Printf("Error listing ip_phones: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query ip_phones"})
 	}
-	defer rows.Close()
-
-	var phones []models.IPPhone
-	for rows.Next() {
-		var p models.IPPhone
-		err := rows.Scan(
-			&p.ID, &p.Number, &p.Name, &p.DepartmentID,
-			&p.DepartmentName, &p.BranchID, &p.BranchName,
-			&p.CreatedAt, &p.UpdatedAt, &p.DeletedAt, &p.CreatedBy, &p.UpdatedBy, &p.DeletedBy,
-		)
-		if err != nil {
-			log.Printf("Error scanning ip_phone: %v", err)
-			continue
-		}
-		phones = append(phones, p)
-	}
 
 	log.Printf("Getting IP phones Success")
 	return c.JSON(models.PaginatedResponse{
@@ -94,13 +61,17 @@ func CreateIPPhoneHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	res, err := db.DB.Exec(`INSERT INTO ip_phones (number, name, department_id, created_by) VALUES (?, ?, ?, ?)`, req.Number, req.Name, req.DepartmentID, req.CreatedBy)
+	actor, err := utils.AuditActor(c, req.CreatedBy, req.UpdatedBy)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	id, err := repos.Phones.Create(c.UserContext(), req, actor)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert ip_phone"})
 	}
 
 	log.Printf("Inserted new IP phone: %d", req.Number)
-	id, _ := res.LastInsertId()
 	return c.JSON(fiber.Map{"success": true, "id": id})
 }
 
@@ -127,8 +98,12 @@ func UpdateIPPhoneHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	_, err = db.DB.Exec(`UPDATE ip_phones SET number=?, name=?, department_id=?, updated_by=?, updated_at=CURRENT_TIMESTAMP WHERE id=? AND deleted_at IS NULL`, req.Number, req.Name, req.DepartmentID, req.UpdatedBy, id)
+	actor, err := utils.AuditActor(c, req.UpdatedBy)
 	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	if err := repos.Phones.Update(c.UserContext(), id, req, actor); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update ip_phone"})
 	}
 
@@ -137,29 +112,37 @@ func UpdateIPPhoneHandler(c *fiber.Ctx) error {
 }
 
 // @Summary Delete IP phone
-// @Description Delete an IP phone
+// @Description Move an IP phone to the trash. While live tasks depend on it, mode decides what happens:
+// @Description block (default) answers 409 with the dependents, cascade moves them to the trash too,
+// @Description reassign moves them to reassign_to first.
 // @Tags ip-phones
 // @Accept json
 // @Produce json
 // @Param id path string true "IP phone ID"
+// @Param mode query string false "block, cascade or reassign" default(block)
+// @Param reassign_to query int false "IP phone ID receiving the dependents in reassign mode"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/ipphone/delete/{id} [delete]
 func DeleteIPPhoneHandler(c *fiber.Ctx) error {
-	idStr := c.Params("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
-	}
-
-	_, err = db.DB.Exec(`DELETE FROM ip_phones WHERE id=?`, id)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete ip_phone"})
-	}
+	return deleteMasterData(c, models.TrashPhones, "IP phone not found", "Failed to delete ip_phone")
+}
 
-	log.Printf("Deleted IP phone ID: %d", id)
-	return c.JSON(fiber.Map{"success": true})
+// @Summary Get IP phone dependents
+// @Description Count the live tasks that depend on an IP phone
+// @Tags ip-phones
+// @Produce json
+// @Param id path string true "IP phone ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/ipphone/dependents/{id} [get]
+func GetIPPhoneDependentsHandler(c *fiber.Ctx) error {
+	return masterDataDependents(c, models.TrashPhones, "IP phone not found")
 }
 
 // @Summary Get all IP phones
@@ -172,42 +155,11 @@ func DeleteIPPhoneHandler(c *fiber.Ctx) error {
 // @Router /api/v1/ipphone/listall [get]
 func AllIPPhonesHandler(c *fiber.Ctx) error {
 
-	// Get total count
-	var total int
-	err := db.DB.QueryRow(`SELECT COUNT(*) FROM ip_phones WHERE deleted_at IS NULL`).Scan(&total)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count ip_phones"})
-	}
-
-	// Get paginated data
-	rows, err := db.DB.Query(`
-		SELECT ip.id, ip.number, ip.name, IFNULL(ip.department_id,0) as department_id,
-			   IFNULL(d.name, '') as department_name, IFNULL(d.branch_id, 0) as branch_id, IFNULL(b.name, '') as branch_name,
-			   ip.created_at, ip.updated_at, ip.deleted_at, ip.created_by, ip.updated_by, ip.deleted_by
-		FROM ip_phones ip
-		LEFT JOIN departments d ON ip.department_id = d.id
-		LEFT JOIN branches b ON d.branch_id = b.id
-		WHERE ip.deleted_at IS NULL
-	`)
+	phones, _, err := repos.Phones.List(c.UserContext(), repository.ListQuery{})
 	if err != nil {
+		log.Printf("Error listing ip_phones: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query ip_phones"})
 	}
-	defer rows.Close()
-
-	var phones []models.IPPhone
-	for rows.Next() {
-		var p models.IPPhone
-		err := rows.Scan(
-			&p.ID, &p.Number, &p.Name, &p.DepartmentID,
-			&p.DepartmentName, &p.BranchID, &p.BranchName,
-			&p.CreatedAt, &p.UpdatedAt, &p.DeletedAt, &p.CreatedBy, &p.UpdatedBy, &p.DeletedBy,
-		)
-		if err != nil {
-			log.Printf("Error scanning ip_phone: %v", err)
-			continue
-		}
-		phones = append(phones, p)
-	}
 
 	log.Printf("Getting IP phones Success")
 	return c.JSON(models.PaginatedResponse{
@@ -254,53 +206,11 @@ func ListIPPhonesQueryHandler(c *fiber.Ctx) error {
 	decodedQuery = strings.ReplaceAll(decodedQuery, "'", "")
 	decodedQuery = strings.ReplaceAll(decodedQuery, "\"", "")
 
-	searchPattern := "%" + decodedQuery + "%"
-
-	// Get total count
-	var total int
-	err = db.DB.QueryRow(`
-		SELECT COUNT(*) FROM ip_phones ip
-		LEFT JOIN departments d ON ip.department_id = d.id AND d.deleted_at IS NULL
-		LEFT JOIN branches b ON d.branch_id = b.id AND b.deleted_at IS NULL
-		WHERE ip.deleted_at IS NULL
-		AND (ip.number LIKE ? OR ip.name LIKE ? OR d.name LIKE ? OR b.name LIKE ?)
-	`, searchPattern, searchPattern, searchPattern, searchPattern).Scan(&total)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count search results"})
-	}
-
-	// Get search results with pagination
-	rows, err := db.DB.Query(`
-		SELECT ip.id, ip.number, ip.name, IFNULL(ip.department_id,0) as department_id,
-			   IFNULL(d.name, '') as department_name, IFNULL(d.branch_id, 0) as branch_id, IFNULL(b.name, '') as branch_name,
-			   ip.created_at, ip.updated_at, ip.deleted_at, ip.created_by, ip.updated_by, ip.deleted_by
-		FROM ip_phones ip
-		LEFT JOIN departments d ON ip.department_id = d.id AND d.deleted_at IS NULL
-		LEFT JOIN branches b ON d.branch_id = b.id AND b.deleted_at IS NULL
-		WHERE ip.deleted_at IS NULL
-		AND (ip.number LIKE ? OR ip.name LIKE ? OR d.name LIKE ? OR b.name LIKE ?)
-		ORDER BY ip.id DESC
-		LIMIT ? OFFSET ?
-	`, searchPattern, searchPattern, searchPattern, searchPattern, pagination.Limit, offset)
+	phones, total, err := repos.Phones.List(c.UserContext(), repository.ListQuery{Search: decodedQuery, Limit: pagination.Limit, Offset: offset})
 	if err != nil {
+		log.Printf("Error searching ip_phones: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to search ip_phones"})
 	}
-	defer rows.Close()
-
-	var phones []models.IPPhone
-	for rows.Next() {
-		var p models.IPPhone
-		err := rows.Scan(
-			&p.ID, &p.Number, &p.Name, &p.DepartmentID,
-			&p.DepartmentName, &p.BranchID, &p.BranchName,
-			&p.CreatedAt, &p.UpdatedAt, &p.DeletedAt, &p.CreatedBy, &p.UpdatedBy, &p.DeletedBy,
-		)
-		if err != nil {
-			log.Printf("Error scanning ip_phone: %v", err)
-			continue
-		}
-		phones = append(phones, p)
-	}
 
 	log.Printf("Searching IP phones with query: %s, found %d results", query, len(phones))
 	return c.JSON(models.PaginatedResponse{
@@ -332,22 +242,7 @@ func GetIPPhonesDetailHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
 	}
 
-	var ipPhone models.IPPhone
-	err = db.DB.QueryRow(`
-		SELECT ip.id, ip.number, ip.name, IFNULL(ip.department_id,0) as department_id,
-			   IFNULL(d.name, '') as department_name, IFNULL(d.branch_id, 0) as branch_id, IFNULL(b.name, '') as branch_name,
-			   ip.created_at, ip.updated_at, ip.deleted_at, ip.created_by, ip.updated_by, ip.deleted_by
-		FROM ip_phones ip
-		LEFT JOIN departments d ON ip.department_id = d.id
-		LEFT JOIN branches b ON d.branch_id = b.id
-		WHERE ip.id = ? AND ip.deleted_at IS NULL
-	`, id).Scan(
-		&ipPhone.ID, &ipPhone.Number, &ipPhone.Name, &ipPhone.DepartmentID,
-		&ipPhone.DepartmentName, &ipPhone.BranchID, &ipPhone.BranchName,
-		&ipPhone.CreatedAt, &ipPhone.UpdatedAt, &ipPhone.DeletedAt,
-		&ipPhone.CreatedBy, &ipPhone.UpdatedBy, &ipPhone.DeletedBy,
-	)
-
+	ipPhone, err := repos.Phones.Get(c.UserContext(), id)
 	if err != nil {
 		log.Printf("Error fetching IP phone details: %v", err)
 		return c.Status(404).JSON(fiber.Map{"error": "IP phone not found"})
@@ -356,3 +251,31 @@ func GetIPPhonesDetailHandler(c *fiber.Ctx) error {
 	log.Printf("Getting IP phone details Success for ID: %d", id)
 	return c.JSON(fiber.Map{"success": true, "data": ipPhone})
 }
+
+// @Summary Merge IP phones
+// @Description Move the tasks of the source IP phones to the survivor, move the sources to the trash and record the merge.
+// @Description With dry_run nothing changes and the response shows what would move.
+// @Tags ip-phones
+// @Accept json
+// @Produce json
+// @Param request body models.MergeRequest true "Survivor, sources and dry run"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/ipphone/merge [post]
+func MergeIPPhonesHandler(c *fiber.Ctx) error {
+	return mergeMasterData(c, models.TrashPhones)
+}
+
+// @Summary Find duplicate IP phones
+// @Description Suggest merge candidates: pairs of IP phones with a similar number and name
+// @Tags ip-phones
+// @Produce json
+// @Param threshold query number false "Minimum similarity between 0 and 1" default(0.8)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/ipphone/duplicates [get]
+func GetIPPhoneDuplicatesHandler(c *fiber.Ctx) error {
+	return masterDataDuplicates(c, models.TrashPhones)
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"reports-api/handlers/common"
+	"reports-api/models"
+	"reports-api/repository"
+	"reports-api/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// deleteMasterData handles the delete route of a branch, department, IP phone or program.
+// The mode query parameter (block, cascade or reassign; default block) decides what happens to dependent records.
+func deleteMasterData(c *fiber.Ctx, kind, notFound, failure string) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	mode := c.Query("mode", models.DeleteModeBlock)
+	target := 0
+	switch mode {
+	case models.DeleteModeBlock, models.DeleteModeCascade:
+	case models.DeleteModeReassign:
+		if target, err = strconv.Atoi(c.Query("reassign_to")); err != nil || target <= 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "reassign_to is required in reassign mode"})
+		}
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "mode must be one of block, cascade or reassign"})
+	}
+
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	deps, err := common.DeleteMasterData(c.UserContext(), repos, kind, id, mode, target, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return c.Status(404).JSON(fiber.Map{"error": notFound})
+		case errors.Is(err, models.ErrHasDependents):
+			return c.Status(409).JSON(fiber.Map{"error": err.Error(), "dependents": deps})
+		case errors.Is(err, models.ErrInvalidReassignTarget):
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Printf("Error deleting %s %d: %v", kind, id, err)
+		return c.Status(500).JSON(fiber.Map{"error": failure})
+	}
+
+	log.Printf("Deleted %s ID: %d (%s) by user ID: %d", kind, id, mode, actor)
+	return c.JSON(fiber.Map{"success": true, "mode": mode, "dependents": deps})
+}
+
+// masterDataDependents handles the dependents route of a branch, department, IP phone or program
+func masterDataDependents(c *fiber.Ctx, kind, notFound string) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	deps, err := repos.MasterData.Dependents(c.UserContext(), kind, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": notFound})
+		}
+		log.Printf("Error counting dependents of %s %d: %v", kind, id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query dependents"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": deps, "blocking": deps.Blocking()})
+}
+
+// defaultDuplicateThreshold is the similarity from which two names are reported as merge candidates
+const defaultDuplicateThreshold = 0.8
+
+// mergeMasterData handles the merge route of a branch, department, IP phone or program
+func mergeMasterData(c *fiber.Ctx, kind string) error {
+	var req models.MergeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.SurvivorID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "survivor_id is required"})
+	}
+
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	result, err := common.MergeMasterData(c.UserContext(), repos, kind, req, actor)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidMerge) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Printf("Error merging %s %v into %d: %v", kind, req.SourceIDs, req.SurvivorID, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to merge records"})
+	}
+
+	if !req.DryRun {
+		log.Printf("Merged %s %v into %d by user ID: %d", kind, req.SourceIDs, req.SurvivorID, actor)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": result})
+}
+
+// masterDataDuplicates handles the duplicates route of a branch, department, IP phone or program
+func masterDataDuplicates(c *fiber.Ctx, kind string) error {
+	threshold := defaultDuplicateThreshold
+	if raw := c.Query("threshold"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v <= 0 || v > 1 {
+			return c.Status(400).JSON(fiber.Map{"error": "threshold must be a number between 0 and 1"})
+		}
+		threshold = v
+	}
+
+	names, err := repos.MasterData.Names(c.UserContext(), kind)
+	if err != nil {
+		log.Printf("Error listing %s: %v", kind, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query duplicates"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": common.FindMergeCandidates(names, threshold)})
+}
+
+// @Summary List merges
+// @Description List the recorded merges of branches, departments, IP phones and programs, newest first
+// @Tags master-data
+// @Produce json
+// @Param kind query string false "branches, departments, ipphones or programs"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} models.PaginatedResponse
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/merges [get]
+func ListMergesHandler(c *fiber.Ctx) error {
+	pagination := utils.GetPaginationParams(c)
+	offset := utils.CalculateOffset(pagination.Page, pagination.Limit)
+
+	entries, total, err := repos.MasterData.ListMerges(c.UserContext(), c.Query("kind"), repository.ListQuery{Limit: pagination.Limit, Offset: offset})
+	if err != nil {
+		log.Printf("Error listing merges: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query merges"})
+	}
+
+	return c.JSON(models.PaginatedResponse{
+		Success: true,
+		Data:    entries,
+		Pagination: models.PaginationResponse{
+			Page:       pagination.Page,
+			Limit:      pagination.Limit,
+			Total:      total,
+			TotalPages: utils.CalculateTotalPages(total, pagination.Limit),
+		},
+	})
+}
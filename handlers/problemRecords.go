@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"reports-api/handlers/common"
+	"reports-api/models"
+	"reports-api/repository"
+	"reports-api/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary List problem records
+// @Description List problem records, the underlying defects behind recurring problems, newest first
+// @Tags problemrecords
+// @Produce json
+// @Param status query string false "open, investigating, known_error, resolved or closed"
+// @Param system_id query int false "Program ID"
+// @Param active query bool false "Only records that are not resolved or closed"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problemrecord/list [get]
+func ListProblemRecordsHandler(c *fiber.Ctx) error {
+	q := models.ProblemRecordQuery{
+		Status:   c.Query("status"),
+		SystemID: c.QueryInt("system_id"),
+		Active:   c.QueryBool("active"),
+	}
+	if q.Status != "" && !models.ValidProblemRecordStatus(q.Status) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid status"})
+	}
+
+	records, err := repos.ProblemRecords.List(c.UserContext(), q)
+	if err != nil {
+		log.Printf("Error listing problem records: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query problem records"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": records, "count": len(records)})
+}
+
+// @Summary Get problem record
+// @Description Get a problem record with its root cause, workaround and attached problems
+// @Tags problemrecords
+// @Produce json
+// @Param id path string true "Problem record ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problemrecord/{id} [get]
+func GetProblemRecordHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	record, err := repos.ProblemRecords.Get(c.UserContext(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Problem record not found"})
+	}
+	if err != nil {
+		log.Printf("Error fetching problem record %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query problem record"})
+	}
+	record.Tasks, err = repos.ProblemRecords.Tasks(c.UserContext(), id)
+	if err != nil {
+		log.Printf("Error fetching tasks of problem record %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query problem record"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": record})
+}
+
+// @Summary Create problem record
+// @Description Create a problem record; status defaults to open
+// @Tags problemrecords
+// @Accept json
+// @Produce json
+// @Param request body models.ProblemRecordRequest true "Title, program, root cause, workaround and status"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problemrecord/create [post]
+func CreateProblemRecordHandler(c *fiber.Ctx) error {
+	var req models.ProblemRecordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := common.NormalizeProblemRecord(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	id, err := repos.ProblemRecords.Create(c.UserContext(), req, actor)
+	if err != nil {
+		log.Printf("Error creating problem record: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert problem record"})
+	}
+	log.Printf("Created problem record %d by user ID: %d", id, actor)
+	return c.JSON(fiber.Map{"success": true, "id": id})
+}
+
+// @Summary Update problem record
+// @Description Replace the fields of a problem record; moving it to resolved or closed stops suggesting its workaround
+// @Tags problemrecords
+// @Accept json
+// @Produce json
+// @Param id path string true "Problem record ID"
+// @Param request body models.ProblemRecordRequest true "Title, program, root cause, workaround and status"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problemrecord/update/{id} [put]
+func UpdateProblemRecordHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+	var req models.ProblemRecordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := common.NormalizeProblemRecord(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	err = repos.ProblemRecords.Update(c.UserContext(), id, req, actor)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Problem record not found"})
+	}
+	if err != nil {
+		log.Printf("Error updating problem record %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update problem record"})
+	}
+	log.Printf("Updated problem record %d (%s) by user ID: %d", id, req.Status, actor)
+	return c.JSON(fiber.Map{"success": true, "message": "Problem record updated successfully"})
+}
+
+// @Summary Delete problem record
+// @Description Move a problem record to the trash; its problems are detached, not deleted
+// @Tags problemrecords
+// @Produce json
+// @Param id path string true "Problem record ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problemrecord/delete/{id} [delete]
+func DeleteProblemRecordHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	err = common.DeleteProblemRecord(c.UserContext(), repos, id, actor)
+	if errors.Is(err, repository.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Problem record not found"})
+	}
+	if err != nil {
+		log.Printf("Error deleting problem record %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete problem record"})
+	}
+	log.Printf("Moved problem record %d to trash by user ID: %d", id, actor)
+	return c.JSON(fiber.Map{"success": true, "message": "Problem record deleted successfully"})
+}
+
+// @Summary Attach problems to a problem record
+// @Description Attach problems to a problem record; a problem belongs to at most one problem record
+// @Tags problemrecords
+// @Accept json
+// @Produce json
+// @Param id path string true "Problem record ID"
+// @Param request body models.ProblemRecordTasksRequest true "Problem IDs"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problemrecord/{id}/tasks [post]
+func AttachProblemRecordTasksHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+	var req models.ProblemRecordTasksRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if len(req.TaskIDs) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "task_ids is required"})
+	}
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	if err := common.AttachProblemRecordTasks(c.UserContext(), repos, id, req.TaskIDs, actor); err != nil {
+		return problemRecordTaskError(c, id, err)
+	}
+	log.Printf("Attached tasks %v to problem record %d by user ID: %d", req.TaskIDs, id, actor)
+	return c.JSON(fiber.Map{"success": true, "message": "Problems attached successfully"})
+}
+
+// @Summary Detach a problem from a problem record
+// @Description Detach a problem from a problem record
+// @Tags problemrecords
+// @Produce json
+// @Param id path string true "Problem record ID"
+// @Param taskId path string true "Problem ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problemrecord/{id}/tasks/{taskId} [delete]
+func DetachProblemRecordTaskHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+	taskID, err := strconv.Atoi(c.Params("taskId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid task id"})
+	}
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	if err := common.DetachProblemRecordTask(c.UserContext(), repos, id, taskID, actor); err != nil {
+		return problemRecordTaskError(c, id, err)
+	}
+	log.Printf("Detached task %d from problem record %d by user ID: %d", taskID, id, actor)
+	return c.JSON(fiber.Map{"success": true, "message": "Problem detached successfully"})
+}
+
+// @Summary Suggest workarounds
+// @Description List the workarounds of the open problem records of a program, to show while a problem is reported
+// @Tags problemrecords
+// @Produce json
+// @Param system_id query int true "Program ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problemrecord/workarounds [get]
+func GetWorkaroundsHandler(c *fiber.Ctx) error {
+	systemID := c.QueryInt("system_id")
+	if systemID <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "system_id is required"})
+	}
+
+	suggestions, err := repos.ProblemRecords.Workarounds(c.UserContext(), systemID)
+	if err != nil {
+		log.Printf("Error fetching workarounds of program %d: %v", systemID, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query workarounds"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": suggestions})
+}
+
+// workaroundSuggestions returns the workarounds suggested for a new task of a program.
+// A failed lookup is logged and suggests nothing, so it never fails the report.
+func workaroundSuggestions(c *fiber.Ctx, systemID int) []models.WorkaroundSuggestion {
+	if systemID <= 0 {
+		return []models.WorkaroundSuggestion{}
+	}
+	suggestions, err := repos.ProblemRecords.Workarounds(c.UserContext(), systemID)
+	if err != nil {
+		log.Printf("Error fetching workarounds of program %d: %v", systemID, err)
+		return []models.WorkaroundSuggestion{}
+	}
+	return suggestions
+}
+
+// problemRecordTaskError writes the response for an error returned by common.AttachProblemRecordTasks or common.DetachProblemRecordTask
+func problemRecordTaskError(c *fiber.Ctx, id int, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Problem record or task not found"})
+	case errors.Is(err, models.ErrTaskHasProblemRecord):
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	log.Printf("Failed to change tasks of problem record %d: %v", id, err)
+	return c.Status(500).JSON(fiber.Map{"error": "Failed to change problem record tasks"})
+}
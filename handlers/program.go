@@ -3,8 +3,8 @@ package handlers
 import (
 	"log"
 	"net/url"
-	"reports-api/db"
 	"reports-api/models"
+	"reports-api/repository"
 	"reports-api/utils"
 	"strconv"
 	"strings"
@@ -26,37 +26,11 @@ func ListProgramsHandler(c *fiber.Ctx) error {
 	pagination := utils.GetPaginationParams(c)
 	offset := utils.CalculateOffset(pagination.Page, pagination.Limit)
 
-	// Get total count
-	var total int
-	err := db.DB.QueryRow(`SELECT COUNT(*) FROM systems_program WHERE deleted_at IS NULL`).Scan(&total)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count programs"})
-	}
-
-	// Get paginated data
-	rows, err := db.DB.Query(`
-		SELECT sp.id, sp.name, IFNULL(sp.type, 0) as type_id, IFNULL(it.name, '') as type_name, sp.created_at, sp.updated_at, sp.deleted_at, sp.created_by, sp.updated_by, sp.deleted_by 
-		FROM systems_program sp
-		LEFT JOIN issue_types it ON sp.type = it.id
-		WHERE deleted_at IS NULL
-		ORDER BY sp.id DESC
-		LIMIT ? OFFSET ?
-	`, pagination.Limit, offset)
+	programs, total, err := repos.Programs.List(c.UserContext(), repository.ListQuery{Limit: pagination.Limit, Offset: offset})
 	if err != nil {
+		log.Printf("Error listing programs: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query programs"})
 	}
-	defer rows.Close()
-
-	var programs []models.Program
-	for rows.Next() {
-		var p models.Program
-		err := rows.Scan(&p.ID, &p.Name, &p.TypeID, &p.TypeName, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt, &p.CreatedBy, &p.UpdatedBy, &p.DeletedBy)
-		if err != nil {
-			log.Printf("Error scanning program: %v", err)
-			continue
-		}
-		programs = append(programs, p)
-	}
 
 	log.Printf("Getting programs Success")
 	return c.JSON(models.PaginatedResponse{
@@ -87,17 +61,16 @@ func CreateProgramHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	if req.Priority == nil || *req.Priority == 0 {
-		priority := 2
-		req.Priority = &priority
+	actor, err := utils.AuditActor(c, req.CreatedBy, req.UpdatedBy)
+	if err != nil {
+		return utils.AuditActorError(c, err)
 	}
 
-	res, err := db.DB.Exec(`INSERT INTO systems_program (name, priority, type, created_by) VALUES (?, ?, ?, ?)`, req.Name, req.Priority, req.TypeID, req.CreatedBy)
+	id, err := repos.Programs.Create(c.UserContext(), req, actor)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert program"})
 	}
 
-	id, _ := res.LastInsertId()
 	return c.JSON(fiber.Map{"success": true, "id": id})
 }
 
@@ -124,8 +97,12 @@ func UpdateProgramHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	_, err = db.DB.Exec(`UPDATE systems_program SET name=?, type=?, updated_by=?, updated_at=CURRENT_TIMESTAMP WHERE id=? AND deleted_at IS NULL`, req.Name, req.TypeID, req.UpdatedBy, id)
+	actor, err := utils.AuditActor(c, req.UpdatedBy)
 	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	if err := repos.Programs.Update(c.UserContext(), id, req, actor); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update program"})
 	}
 
@@ -133,29 +110,37 @@ func UpdateProgramHandler(c *fiber.Ctx) error {
 }
 
 // @Summary Delete program
-// @Description Delete a program
+// @Description Move a program to the trash. While live tasks depend on it, mode decides what happens:
+// @Description block (default) answers 409 with the dependents, cascade moves them to the trash too,
+// @Description reassign moves them to reassign_to first.
 // @Tags programs
 // @Accept json
 // @Produce json
 // @Param id path string true "Program ID"
+// @Param mode query string false "block, cascade or reassign" default(block)
+// @Param reassign_to query int false "Program ID receiving the dependents in reassign mode"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/program/delete/{id} [delete]
 func DeleteProgramHandler(c *fiber.Ctx) error {
-	idStr := c.Params("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
-	}
-
-	_, err = db.DB.Exec(`DELETE FROM systems_program WHERE id=?`, id)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete program"})
-	}
+	return deleteMasterData(c, models.TrashPrograms, "Program not found", "Failed to delete program")
+}
 
-	log.Printf("Deleted program ID: %d", id)
-	return c.JSON(fiber.Map{"success": true})
+// @Summary Get program dependents
+// @Description Count the live tasks that depend on a program
+// @Tags programs
+// @Produce json
+// @Param id path string true "Program ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/program/dependents/{id} [get]
+func GetProgramDependentsHandler(c *fiber.Ctx) error {
+	return masterDataDependents(c, models.TrashPrograms, "Program not found")
 }
 
 // @Summary Search programs
@@ -196,42 +181,11 @@ func ListProgramsQueryHandler(c *fiber.Ctx) error {
 	decodedQuery = strings.ReplaceAll(decodedQuery, "'", "")
 	decodedQuery = strings.ReplaceAll(decodedQuery, "\"", "")
 
-	searchPattern := "%" + decodedQuery + "%"
-
-	// Get total count
-	var total int
-	err = db.DB.QueryRow(`
-		SELECT COUNT(*) FROM systems_program
-		WHERE deleted_at IS NULL AND name LIKE ?
-	`, searchPattern).Scan(&total)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count search results"})
-	}
-
-	// Get search results with pagination
-	rows, err := db.DB.Query(`
-		SELECT sp.id, sp.name, IFNULL(sp.type, 0), IFNULL(it.name, ''), sp.created_at, sp.updated_at, sp.deleted_at, sp.created_by, sp.updated_by, sp.deleted_by
-		FROM systems_program sp
-		LEFT JOIN issue_types it ON sp.type = it.id
-		WHERE deleted_at IS NULL AND name LIKE ?
-		ORDER BY id DESC
-		LIMIT ? OFFSET ?
-	`, searchPattern, pagination.Limit, offset)
+	programs, total, err := repos.Programs.List(c.UserContext(), repository.ListQuery{Search: decodedQuery, Limit: pagination.Limit, Offset: offset})
 	if err != nil {
+		log.Printf("Error searching programs: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to search programs"})
 	}
-	defer rows.Close()
-
-	var programs []models.Program
-	for rows.Next() {
-		var p models.Program
-		err := rows.Scan(&p.ID, &p.Name, &p.TypeID, &p.TypeName, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt, &p.CreatedBy, &p.UpdatedBy, &p.DeletedBy)
-		if err != nil {
-			log.Printf("Error scanning program: %v", err)
-			continue
-		}
-		programs = append(programs, p)
-	}
 
 	log.Printf("Searching programs with query: %s, found %d results", query, len(programs))
 	return c.JSON(models.PaginatedResponse{
@@ -263,17 +217,7 @@ func GetProgramDetailHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
 	}
 
-	var program models.Program
-	err = db.DB.QueryRow(`
-		SELECT sp.id, sp.name, IFNULL(sp.type, 0), IFNULL(it.name, ''), sp.created_at, sp.updated_at, sp.deleted_at, sp.created_by, sp.updated_by, sp.deleted_by
-		FROM systems_program sp
-		LEFT JOIN issue_types it ON sp.type = it.id
-		WHERE sp.id=? AND sp.deleted_at IS NULL
-	`, id).Scan(
-		&program.ID, &program.Name, &program.TypeID, &program.TypeName, &program.CreatedAt, &program.UpdatedAt,
-		&program.DeletedAt, &program.CreatedBy, &program.UpdatedBy, &program.DeletedBy,
-	)
-
+	program, err := repos.Programs.Get(c.UserContext(), id)
 	if err != nil {
 		log.Printf("Error fetching program details: %v", err)
 		return c.Status(404).JSON(fiber.Map{"error": "Program not found"})
@@ -292,22 +236,15 @@ func GetProgramDetailHandler(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/program/type/list [get]
 func GETTypeProgramHandler(c *fiber.Ctx) error {
-	rows, err := db.DB.Query(`SELECT id, name FROM issue_types ORDER BY id`)
+	issueTypes, err := repos.IssueTypes.List(c.UserContext(), "")
 	if err != nil {
+		log.Printf("Error listing program types: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query program types"})
 	}
-	defer rows.Close()
 
 	var types []fiber.Map
-	for rows.Next() {
-		var id int
-		var name string
-		err := rows.Scan(&id, &name)
-		if err != nil {
-			log.Printf("Error scanning program type: %v", err)
-			continue
-		}
-		types = append(types, fiber.Map{"id": id, "name": name})
+	for _, t := range issueTypes {
+		types = append(types, fiber.Map{"id": t.ID, "name": t.Name})
 	}
 	log.Printf("Getting issue types Success")
 	return c.JSON(fiber.Map{"success": true, "data": types})
@@ -329,12 +266,11 @@ func AddTypeProgramHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	res, err := db.DB.Exec(`INSERT INTO issue_types (name) VALUES (?)`, req.Name)
+	id, err := repos.IssueTypes.Create(c.UserContext(), req.Name)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert program type"})
 	}
 
-	id, _ := res.LastInsertId()
 	return c.JSON(fiber.Map{"success": true, "id": id})
 }
 
@@ -361,8 +297,7 @@ func UpdateTypeProgramHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	_, err = db.DB.Exec(`UPDATE issue_types SET name=? WHERE id=?`, req.Name, id)
-	if err != nil {
+	if err := repos.IssueTypes.Update(c.UserContext(), id, req.Name); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update program type"})
 	}
 
@@ -386,8 +321,7 @@ func DeleteTypeHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
 	}
 
-	_, err = db.DB.Exec(`DELETE FROM issue_types WHERE id=?`, id)
-	if err != nil {
+	if err := repos.IssueTypes.Delete(c.UserContext(), id); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete program type"})
 	}
 
@@ -427,31 +361,45 @@ func GetTypeWithQueryHandler(c *fiber.Ctx) error {
 	decodedQuery = strings.ReplaceAll(decodedQuery, "'", "")
 	decodedQuery = strings.ReplaceAll(decodedQuery, "\"", "")
 
-	searchPattern := "%" + decodedQuery + "%"
-
-	// Get search results
-	rows, err := db.DB.Query(`
-		SELECT id, name FROM issue_types
-		WHERE name LIKE ?
-		ORDER BY id
-	`, searchPattern)
+	issueTypes, err := repos.IssueTypes.List(c.UserContext(), decodedQuery)
 	if err != nil {
+		log.Printf("Error searching types: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to search types"})
 	}
-	defer rows.Close()
 
 	var types []fiber.Map
-	for rows.Next() {
-		var id int
-		var name string
-		err := rows.Scan(&id, &name)
-		if err != nil {
-			log.Printf("Error scanning type: %v", err)
-			continue
-		}
-		types = append(types, fiber.Map{"id": id, "name": name})
+	for _, t := range issueTypes {
+		types = append(types, fiber.Map{"id": t.ID, "name": t.Name})
 	}
 
 	log.Printf("Searching types with query: %s, found %d results", query, len(types))
 	return c.JSON(fiber.Map{"success": true, "data": types})
 }
+
+// @Summary Merge programs
+// @Description Move the tasks of the source programs to the survivor, move the sources to the trash and record the merge.
+// @Description With dry_run nothing changes and the response shows what would move.
+// @Tags programs
+// @Accept json
+// @Produce json
+// @Param request body models.MergeRequest true "Survivor, sources and dry run"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/program/merge [post]
+func MergeProgramsHandler(c *fiber.Ctx) error {
+	return mergeMasterData(c, models.TrashPrograms)
+}
+
+// @Summary Find duplicate programs
+// @Description Suggest merge candidates: pairs of programs with similar names
+// @Tags programs
+// @Produce json
+// @Param threshold query number false "Minimum similarity between 0 and 1" default(0.8)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/program/duplicates [get]
+func GetProgramDuplicatesHandler(c *fiber.Ctx) error {
+	return masterDataDuplicates(c, models.TrashPrograms)
+}
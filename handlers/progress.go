@@ -1,15 +1,17 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"mime/multipart"
 	"reports-api/config"
-	"reports-api/db"
 	"reports-api/handlers/common"
 	"reports-api/models"
+	"reports-api/repository"
+	"reports-api/utils"
 	"slices"
 	"strconv"
 	"strings"
@@ -46,11 +48,11 @@ func parseProgressText(progressText string, entry *models.ProgressEntry) {
 }
 
 // parseProgressFilePaths parses file_paths JSON array and populates ProgressEntry FilePaths
-func parseProgressFilePaths(filePathsJSON sql.NullString, entry *models.ProgressEntry) {
-	if filePathsJSON.Valid && filePathsJSON.String != "" {
+func parseProgressFilePaths(filePathsJSON *string, entry *models.ProgressEntry) {
+	if filePathsJSON != nil && *filePathsJSON != "" {
 		// Try to parse as array of objects [{"url": "..."}]
 		var fileObjects []map[string]any
-		if err := json.Unmarshal([]byte(filePathsJSON.String), &fileObjects); err == nil {
+		if err := json.Unmarshal([]byte(*filePathsJSON), &fileObjects); err == nil {
 			if entry.FilePaths == nil {
 				entry.FilePaths = make(map[string]string)
 			}
@@ -62,7 +64,7 @@ func parseProgressFilePaths(filePathsJSON sql.NullString, entry *models.Progress
 		} else {
 			// Fallback: try to parse as array of strings (legacy format)
 			var fileURLs []string
-			if err := json.Unmarshal([]byte(filePathsJSON.String), &fileURLs); err == nil {
+			if err := json.Unmarshal([]byte(*filePathsJSON), &fileURLs); err == nil {
 				if entry.FilePaths == nil {
 					entry.FilePaths = make(map[string]string)
 				}
@@ -74,108 +76,6 @@ func parseProgressFilePaths(filePathsJSON sql.NullString, entry *models.Progress
 	}
 }
 
-// parseProgressTextAndDeleteFiles parses progress_text and deletes files from MinIO (legacy format)
-func parseProgressTextAndDeleteFiles(progressText string, entry *models.ProgressEntry) {
-	var progressData map[string]any
-	if err := json.Unmarshal([]byte(progressText), &progressData); err == nil {
-		// Successfully parsed as JSON, extract text and files
-		if text, ok := progressData["text"].(string); ok {
-			entry.Text = text
-		}
-		if fileList, ok := progressData["files"].([]any); ok {
-			// Convert files to map[string]string format expected by FilePaths
-			entry.FilePaths = make(map[string]string)
-
-			// ลบไฟล์จาก MinIO และ populate FilePaths
-			for i, file := range fileList {
-				if fileMap, ok := file.(map[string]any); ok {
-					if url, ok := fileMap["url"].(string); ok {
-						// Add to FilePaths for response
-						entry.FilePaths[strconv.Itoa(i)] = url
-
-						// Delete from MinIO
-						if strings.Contains(url, "prefix=") {
-							parts := strings.Split(url, "prefix=")
-							if len(parts) > 1 {
-								objectName := parts[1]
-								if deleteErr := common.DeleteImage(objectName); deleteErr != nil {
-									log.Printf("Warning: Failed to delete file from MinIO: %s, error: %v", objectName, deleteErr)
-								} else {
-									log.Printf("Successfully deleted file from MinIO: %s", objectName)
-								}
-							}
-						}
-					}
-				}
-			}
-		}
-	} else {
-		// Not JSON, treat as plain text
-		entry.Text = progressText
-	}
-}
-
-// parseFilePathsAndDelete parses file_paths from database and deletes files from MinIO
-func parseFilePathsAndDelete(filePathsJSON sql.NullString, entry *models.ProgressEntry) {
-	if filePathsJSON.Valid && filePathsJSON.String != "" {
-		// Try to parse as array of objects [{"url": "..."}]
-		var fileObjects []map[string]any
-		if err := json.Unmarshal([]byte(filePathsJSON.String), &fileObjects); err == nil {
-			if entry.FilePaths == nil {
-				entry.FilePaths = make(map[string]string)
-			}
-
-			for i, fileObj := range fileObjects {
-				if url, ok := fileObj["url"].(string); ok {
-					// Add to FilePaths for response
-					entry.FilePaths[strconv.Itoa(i)] = url
-
-					// Delete from MinIO
-					if strings.Contains(url, "prefix=") {
-						parts := strings.Split(url, "prefix=")
-						if len(parts) > 1 {
-							objectName := parts[1]
-							if deleteErr := common.DeleteImage(objectName); deleteErr != nil {
-								log.Printf("Warning: Failed to delete file from MinIO: %s, error: %v", objectName, deleteErr)
-							} else {
-								log.Printf("Successfully deleted file from MinIO: %s", objectName)
-							}
-						}
-					}
-				}
-			}
-		} else {
-			// Fallback: try to parse as array of strings (legacy format)
-			var fileURLs []string
-			if err := json.Unmarshal([]byte(filePathsJSON.String), &fileURLs); err == nil {
-				if entry.FilePaths == nil {
-					entry.FilePaths = make(map[string]string)
-				}
-
-				for i, url := range fileURLs {
-					// Add to FilePaths for response
-					entry.FilePaths[strconv.Itoa(i)] = url
-
-					// Delete from MinIO
-					if strings.Contains(url, "prefix=") {
-						parts := strings.Split(url, "prefix=")
-						if len(parts) > 1 {
-							objectName := parts[1]
-							if deleteErr := common.DeleteImage(objectName); deleteErr != nil {
-								log.Printf("Warning: Failed to delete file from MinIO: %s, error: %v", objectName, deleteErr)
-							} else {
-								log.Printf("Successfully deleted file from MinIO: %s", objectName)
-							}
-						}
-					}
-				}
-			} else {
-				log.Printf("Warning: Failed to parse file_paths JSON: %v", err)
-			}
-		}
-	}
-}
-
 // ProgressEntry represents a single progress entry
 
 // @Summary Create progress entry for task
@@ -190,8 +90,11 @@ func parseFilePathsAndDelete(filePathsJSON sql.NullString, entry *models.Progres
 // @Failure 400 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried"
+// @Failure 422 {object} map[string]interface{} "Idempotency-Key reused for a different request"
 // @Router /api/v1/progress/create/{id} [post]
 func CreateProgressHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 	idStr := c.Params("id")
 	taskID, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -199,33 +102,29 @@ func CreateProgressHandler(c *fiber.Ctx) error {
 	}
 
 	// ตรวจสอบว่า task_id มีอยู่ในตาราง tasks หรือไม่ และดึง ticket_no และ status
-	var ticketNo string
-	var status int
 	var Urlenv string
 	env := config.AppConfig.Environment
 
-	err = db.DB.QueryRow("SELECT IFNULL(ticket_no, ''), IFNULL(status, 0) FROM tasks WHERE id = ?", taskID).Scan(&ticketNo, &status)
+	task, err := repos.Tasks.Get(ctx, taskID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, repository.ErrNotFound) {
 			return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
 		}
 		log.Printf("Error checking task existence: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
 	}
+	ticketNo := task.Ticket
 
-	// ตรวจสอบว่า task เสร็จสิ้นแล้วหรือไม่ (status = 2)
-	if status == 2 {
+	// ตรวจสอบว่า task ปิดไปแล้วหรือไม่ (เสร็จสิ้นหรือยกเลิก)
+	if task.Status.IsClosed() {
 		return c.Status(400).JSON(fiber.Map{
 			"error": "Cannot add progress to completed task",
 		})
 	}
 
-	if status != 2 {
-		_, err = db.DB.Exec(`UPDATE tasks SET status = 1 WHERE id = ?`, idStr)
-		if err != nil {
-			log.Printf("Database error: %v", err)
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update status progress"})
-		}
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
 	}
 
 	var uploadedFiles []fiber.Map
@@ -274,144 +173,85 @@ func CreateProgressHandler(c *fiber.Ctx) error {
 	}
 
 	// Prepare file paths JSON if files were uploaded
-	var filePathsJSON string
+	var filePaths *string
 	if len(uploadedFiles) > 0 {
 		// Keep the original format with url objects: [{"url": "..."}]
 		filePathsBytes, _ := json.Marshal(uploadedFiles)
-		filePathsJSON = string(filePathsBytes)
+		filePathsJSON := string(filePathsBytes)
+		filePaths = &filePathsJSON
 	}
 
-	// บันทึกข้อมูลลงในตาราง progress
-	var result sql.Result
-	if filePathsJSON != "" {
-		result, err = db.DB.Exec(
-			"INSERT INTO progress (task_id, progress_text, file_paths, created_at, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)",
-			taskID, progressText, filePathsJSON,
-		)
-	} else {
-		result, err = db.DB.Exec(
-			"INSERT INTO progress (task_id, progress_text, created_at, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)",
-			taskID, progressText,
-		)
-	}
+	// บันทึกข้อมูลลงในตาราง progress และเปลี่ยนสถานะ task เป็นกำลังดำเนินการใน transaction เดียวกัน
+	var progressID int64
+	failure := "Failed to update status progress"
+	err = repos.InTx(ctx, func(tx *repository.Repositories) error {
+		if _, err := common.TransitionTask(ctx, tx, taskID, task.Status, models.TaskStatusInProgress, actor, "progress added"); err != nil {
+			return err
+		}
+		failure = "Failed to create progress entry"
+		var err error
+		progressID, err = tx.Progress.Create(ctx, taskID, progressText, filePaths)
+		if err != nil {
+			return err
+		}
+		return common.RecordChange(ctx, tx, taskID, models.ChangeEntityProgress, int(progressID), models.ChangeActionCreate, actor,
+			common.DiffEntry("", progressText, nil, filePaths)...)
+	})
 	if err != nil {
 		log.Printf("Error inserting progress: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create progress entry"})
-	}
-
-	progressID, err := result.LastInsertId()
-	if err != nil {
-		log.Printf("Error getting last insert ID: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to get progress ID"})
+		if filePaths != nil {
+			deleteUploadedFiles(*filePaths)
+		}
+		if errors.Is(err, models.ErrVersionConflict) {
+			return taskConflict(c, taskID)
+		}
+		return c.Status(500).JSON(fiber.Map{"error": failure})
 	}
 
 	log.Printf("Created progress entry with ID: %d for task ID: %d", progressID, taskID)
 
-	// ดึงข้อมูลที่เพิ่งสร้างเพื่อส่งกลับในรูปแบบ ProgressEntry
-	var createdEntry models.ProgressEntry
-	var createdAt, updatedAt string
-	var retrievedFilePathsJSON sql.NullString
-	err = db.DB.QueryRow(
-		"SELECT id, progress_text, file_paths, created_at, updated_at FROM progress WHERE id = ?",
-		progressID,
-	).Scan(&createdEntry.ID, &createdEntry.Text, &retrievedFilePathsJSON, &createdAt, &updatedAt)
-
-	if err != nil {
-		log.Printf("Error retrieving created progress entry: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to retrieve created progress entry"})
-	}
-
-	createdEntry.CreatedAt = createdAt
-	createdEntry.UpdateAt = updatedAt
-
-	// Parse file_paths จากฐานข้อมูล (ถ้ามี)
-	parseProgressFilePaths(retrievedFilePathsJSON, &createdEntry)
-
 	// ดึงข้อมูล task สำหรับอัพเดต Telegram
-	var ticket, text, issueElse, reportedBy, assignto, branchName, departmentName, programName string
-	var phoneID, systemID, departmentID, messageID, phoneNumber, branchID, telegramID int
-	var phoneElse *string
-	var telegramUser string
-	// Fix SQL: JOINs before WHERE, select tc.report_id as messageID
-	err = db.DB.QueryRow(`
-			SELECT IFNULL(t.ticket_no, ''), IFNULL(t.phone_id, 0), IFNULL(t.phone_else, ''), IFNULL(t.system_id, 0), IFNULL(t.issue_else, ''), IFNULL(t.department_id, 0),
-			IFNULL(t.text, ''), IFNULL(t.status, 0), IFNULL(t.reported_by, ''), IFNULL(t.assignto, ''), IFNULL(rs.telegram_username, ''), 
-			IFNULL(tc.report_id, 0), IFNULL(t.file_paths, '[]'), IFNULL(d.branch_id, 0), IFNULL(t.created_at, ''), IFNULL(t.updated_at, ''),
-			IFNULL(t.telegram_id, 0)
-			FROM tasks t
-			LEFT JOIN telegram_chat tc ON t.telegram_id = tc.id
-			LEFT JOIN departments d ON t.department_id = d.id
-			LEFT JOIN branches b ON d.branch_id = b.id
-			LEFT JOIN systems_program s ON t.system_id = s.id
-			LEFT JOIN responsibilities rs ON t.assignto_id = rs.id
-			WHERE t.id = ?
-		`, idStr).Scan(&ticket, &phoneID, &phoneElse, &systemID, &issueElse, &departmentID, &text, &status, &reportedBy, &assignto, &telegramUser, &messageID, &filePathsJSON, &branchID, &createdAt, &updatedAt, &telegramID)
-	log.Printf("Fetched task for Telegram update, ID: %s, MessageID: %d", telegramUser, messageID)
-	// Query extra info for Telegram
-	db.DB.QueryRow(`SELECT name FROM branches WHERE id = ?`, branchID).Scan(&branchName)
-	db.DB.QueryRow(`SELECT name FROM departments WHERE id = ?`, departmentID).Scan(&departmentName)
-	db.DB.QueryRow(`SELECT name FROM systems_program WHERE id = ?`, systemID).Scan(&programName)
-	db.DB.QueryRow(`SELECT number FROM ip_phones WHERE id = ?`, phoneID).Scan(&phoneNumber)
-
-	if env == "dev" {
-		Urlenv = "http://helpdesk-dev.nopadol.com/tasks/show/" + idStr
-	} else {
-		Urlenv = "http://helpdesk.nopadol.com/tasks/show/" + idStr
-	}
-
-	CreatedAt := common.Fixtimefeature(createdAt)
-	UpdatedAt := common.Fixtimefeature(updatedAt)
-
+	task, err = repos.Tasks.Get(ctx, taskID)
 	if err == nil {
-		// Parse file_paths JSON
-		var photoURLs []string
-		if filePathsJSON != "" && filePathsJSON != "[]" {
-			var filePaths []fiber.Map
-			if err := json.Unmarshal([]byte(filePathsJSON), &filePaths); err == nil && len(filePaths) > 0 {
-				for _, file := range filePaths {
-					if url, ok := file["url"].(string); ok {
-						photoURLs = append(photoURLs, url)
-					}
-				}
-			}
+		log.Printf("Fetched task for Telegram update, ID: %s, MessageID: %d", task.AssigneeTelegram, task.ReportMessageID)
+		phoneID := 0
+		if task.PhoneID != nil {
+			phoneID = *task.PhoneID
+		}
+		phoneNumber, departmentName, branchName, programName := getTelegramData(ctx, task.PhoneID, task.SystemID, task.DepartmentID)
+
+		if env == "dev" {
+			Urlenv = "http://helpdesk-dev.nopadol.com/tasks/show/" + idStr
+		} else {
+			Urlenv = "http://helpdesk.nopadol.com/tasks/show/" + idStr
 		}
+
 		telegramReq := models.TaskRequest{
 			PhoneID:        &phoneID,
-			PhoneElse:      phoneElse,
-			SystemID:       systemID,
-			IssueElse:      issueElse,
-			DepartmentID:   departmentID,
-			Text:           text,
-			Status:         1,
-			ReportedBy:     reportedBy,
-			Assignto:       assignto,
-			TelegramUser:   telegramUser,
-			MessageID:      messageID,
-			Ticket:         ticket,
+			PhoneElse:      task.PhoneElse,
+			SystemID:       task.SystemID,
+			IssueElse:      task.IssueElse,
+			DepartmentID:   task.DepartmentID,
+			Text:           task.Text,
+			Status:         task.Status,
+			ReportedBy:     task.ReportedBy,
+			Assignto:       task.Assignto,
+			TelegramUser:   task.AssigneeTelegram,
+			MessageID:      task.ReportMessageID,
+			Ticket:         task.Ticket,
 			BranchName:     branchName,
 			DepartmentName: departmentName,
 			PhoneNumber:    phoneNumber,
 			ProgramName:    programName,
 			Url:            Urlenv,
-			CreatedAt:      CreatedAt,
-			UpdatedAt:      UpdatedAt,
+			CreatedAt:      common.Fixtimefeature(task.CreatedAt),
+			UpdatedAt:      common.Fixtimefeature(task.UpdatedAt),
 		}
-		if len(photoURLs) > 0 {
-			assigntoID, _ := common.UpdateTelegram(telegramReq, photoURLs...)
-			_, err = db.DB.Exec(`UPDATE telegram_chat SET assignto_id = ? WHERE id = ?`, assigntoID, idStr)
-			if err != nil {
-				log.Printf("Database error: %v", err)
-				return c.Status(500).JSON(fiber.Map{"error": "Failed to update telegram chat"})
-			}
-		} else {
-			assigntoID, _ := common.UpdateTelegram(telegramReq)
-			_, err = db.DB.Exec(`UPDATE telegram_chat SET assignto_id = ? WHERE id = ?`, assigntoID, idStr)
-			if err != nil {
-				log.Printf("Database error: %v", err)
-				return c.Status(500).JSON(fiber.Map{"error": "Failed to update telegram chat"})
-			}
+		assigntoID, _ := common.UpdateTelegram(telegramReq, getPhotoURLs(task.FilePaths)...)
+		if err := repos.TelegramChats.SetAssigneeMessage(ctx, task.TelegramID, assigntoID); err != nil {
+			log.Printf("Database error: %v", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to update telegram chat"})
 		}
-
 	}
 
 	return c.JSON(fiber.Map{
@@ -431,6 +271,7 @@ func CreateProgressHandler(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/progress/{id} [get]
 func GetProgressHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 	idStr := c.Params("id")
 	taskID, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -438,12 +279,9 @@ func GetProgressHandler(c *fiber.Ctx) error {
 	}
 
 	// ตรวจสอบว่า task_id มีอยู่ในตาราง tasks หรือไม่
-	var taskExists string
-	var assignto string
-	var ticketno string
-	err = db.DB.QueryRow("SELECT '1', IFNULL(ticket_no, ''),IFNULL(assignto,'') FROM tasks WHERE id = ? LIMIT 1", taskID).Scan(&taskExists, &ticketno, &assignto)
+	task, err := repos.Tasks.Get(ctx, taskID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, repository.ErrNotFound) {
 			return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
 		}
 		log.Printf("Error checking task existence: %v", err)
@@ -451,47 +289,15 @@ func GetProgressHandler(c *fiber.Ctx) error {
 	}
 
 	// ดึงข้อมูล progress entries สำหรับ task นี้
-	rows, err := db.DB.Query(
-		"SELECT id, progress_text, file_paths, created_at, updated_at FROM progress WHERE task_id = ?",
-		taskID,
-	)
+	records, err := repos.Progress.ListByTask(ctx, taskID)
 	if err != nil {
 		log.Printf("Error querying progress entries: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to get progress entries"})
 	}
-	defer rows.Close()
 
 	var progressEntries []models.ProgressEntry
-
-	for rows.Next() {
-		var entry models.ProgressEntry
-		var progressText string
-		var filePathsJSON sql.NullString
-		var CreatedAt string
-		var UpdateAt string
-
-		err := rows.Scan(&entry.ID, &progressText, &filePathsJSON, &CreatedAt, &UpdateAt)
-		if err != nil {
-			log.Printf("Error scanning progress row: %v", err)
-			continue
-		}
-
-		// Parse progress_text and file_paths
-		parseProgressText(progressText, &entry)
-		parseProgressFilePaths(filePathsJSON, &entry)
-
-		// Set assignto from task
-		entry.CreatedAt = common.Fixtimefeature(CreatedAt)
-		entry.UpdateAt = common.Fixtimefeature(UpdateAt)
-		entry.Ticketno = ticketno
-		entry.AssignTo = assignto
-
-		progressEntries = append(progressEntries, entry)
-	}
-
-	if err := rows.Err(); err != nil {
-		log.Printf("Row error: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to read progress entries"})
+	for i := range records {
+		progressEntries = append(progressEntries, progressEntry(&records[i], task))
 	}
 
 	log.Printf("Retrieved %d progress entries for task ID: %d", len(progressEntries), taskID)
@@ -503,6 +309,43 @@ func GetProgressHandler(c *fiber.Ctx) error {
 	})
 }
 
+// progressEntry builds the response of a progress entry of task
+func progressEntry(record *models.ProgressRecord, task *models.TaskRecord) models.ProgressEntry {
+	entry := models.ProgressEntry{ID: record.ID, Version: record.Version}
+
+	// Parse progress_text and file_paths
+	parseProgressText(record.Text, &entry)
+	parseProgressFilePaths(record.FilePaths, &entry)
+
+	// Set assignto from task
+	entry.CreatedAt = common.Fixtimefeature(record.CreatedAt)
+	entry.UpdateAt = common.Fixtimefeature(record.UpdatedAt)
+	entry.Ticketno = task.Ticket
+	entry.AssignTo = task.Assignto
+	return entry
+}
+
+// progressFileURLs ดึง URLs จาก file_paths ของ progress (รองรับทั้งรูปแบบ object และ string)
+func progressFileURLs(filePathsJSON *string) []string {
+	var urls []string
+	if filePathsJSON == nil || *filePathsJSON == "" {
+		return urls
+	}
+	// Try to parse as array of objects [{"url": "..."}]
+	var fileObjects []map[string]any
+	if err := json.Unmarshal([]byte(*filePathsJSON), &fileObjects); err == nil {
+		for _, fileObj := range fileObjects {
+			if url, ok := fileObj["url"].(string); ok {
+				urls = append(urls, url)
+			}
+		}
+	} else if err := json.Unmarshal([]byte(*filePathsJSON), &urls); err != nil {
+		// Fallback: try to parse as array of strings
+		log.Printf("Error parsing existing file_paths: %v", err)
+	}
+	return urls
+}
+
 // @Summary Update progress entry
 // @Description Update an existing progress entry with new text and/or images
 // @Tags progress
@@ -513,48 +356,58 @@ func GetProgressHandler(c *fiber.Ctx) error {
 // @Param text formData string false "Updated progress text"
 // @Param image_urls formData string false "JSON array of image URLs to keep"
 // @Param image formData file false "New image files"
+// @Param If-Match header string true "Version of the progress entry being edited"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 428 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/progress/update/{id}/{pgid} [put]
 func UpdateProgressHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 	idStr := c.Params("id")
 
-	progressid := c.Params("pgid")
-
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid task ID"})
 	}
+	progressID, err := strconv.Atoi(c.Params("pgid"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Progress not found"})
+	}
 	var req models.UpdateProgress
-	var existingProgressText string
 	var uploadedFiles []fiber.Map
-	var ticketno string
-	var existingFilePathsJSON sql.NullString
-	var taskid int
-	err = db.DB.QueryRow(`
-		SELECT task_id, progress_text, file_paths
-		FROM progress WHERE id = ?
-	`, progressid).Scan(&taskid, &existingProgressText, &existingFilePathsJSON)
 
+	existing, err := repos.Progress.Get(ctx, progressID, id)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Progress not found"})
 	}
 
-	if taskid != id {
-		return c.Status(404).JSON(fiber.Map{"error": "tasks id not match"})
-	}
-
 	log.Printf("Looking for task with ID: %d", id)
-	err = db.DB.QueryRow("SELECT ticket_no FROM tasks WHERE id = ?", id).Scan(&ticketno)
+	task, err := repos.Tasks.Get(ctx, id)
 	if err != nil {
 		log.Printf("Task not found error: %v", err)
 		return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
 	}
+	ticketno := task.Ticket
 	log.Printf("Found task with ticket_no: %s", ticketno)
 
+	version, err := utils.IfMatchVersion(c)
+	if err != nil {
+		return utils.IfMatchError(c, err)
+	}
+	if existing.Version != version {
+		return versionConflict(c, existing.Version, progressEntry(existing, task))
+	}
+
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
 	var keepImageURLs []string
+	var removedURLs []string
 	form, err := c.MultipartForm()
 	if err != nil {
 		// Handle JSON request
@@ -582,28 +435,10 @@ func UpdateProgressHandler(c *fiber.Ctx) error {
 			}
 		}
 
+		existingURLs := progressFileURLs(existing.FilePaths)
+
 		// ตรวจสอบว่า ImageURLs ที่ส่งมาตรงกับที่มีอยู่แล้วหรือไม่
 		if len(allFiles) == 0 && len(keepImageURLs) > 0 {
-			var existingURLs []string
-
-			// ดึง URLs จาก file_paths column
-			if existingFilePathsJSON.Valid && existingFilePathsJSON.String != "" {
-				// Try to parse as array of objects [{"url": "..."}]
-				var fileObjects []map[string]any
-				if err := json.Unmarshal([]byte(existingFilePathsJSON.String), &fileObjects); err == nil {
-					for _, fileObj := range fileObjects {
-						if url, ok := fileObj["url"].(string); ok {
-							existingURLs = append(existingURLs, url)
-						}
-					}
-				} else {
-					// Fallback: try to parse as array of strings
-					if err := json.Unmarshal([]byte(existingFilePathsJSON.String), &existingURLs); err != nil {
-						log.Printf("Error parsing existing file_paths: %v", err)
-					}
-				}
-			}
-
 			// ถ้า URLs ตรงกันทั้งหมด และไม่มีไฟล์ใหม่
 			if len(existingURLs) == len(keepImageURLs) {
 				allMatch := true
@@ -616,60 +451,32 @@ func UpdateProgressHandler(c *fiber.Ctx) error {
 				if allMatch {
 					// URLs ตรงกันทั้งหมด ใช้ text เดิมถ้าไม่ได้ส่งมาใหม่
 					if req.Text == "" {
-						req.Text = existingProgressText
+						req.Text = existing.Text
 					}
 					// อัปเดตเฉพาะ progress_text
-					_, err = db.DB.Exec(`UPDATE progress SET progress_text = ? WHERE id = ?`, req.Text, progressid)
+					err := repos.InTx(ctx, func(tx *repository.Repositories) error {
+						if err := tx.Progress.UpdateText(ctx, progressID, version, req.Text); err != nil {
+							return err
+						}
+						return recordProgressUpdate(ctx, tx, existing, req.Text, existing.FilePaths, actor)
+					})
+					if errors.Is(err, models.ErrVersionConflict) {
+						return progressConflict(c, progressID, task)
+					}
 					if err != nil {
 						return c.Status(500).JSON(fiber.Map{"error": "Failed to update progress"})
 					}
+					utils.SetETag(c, version+1)
 
 					return c.JSON(fiber.Map{"success": true, "message": "Progress updated successfully"})
 				}
 			}
 		}
 
-		// ลบรูปเก่าที่ไม่ต้องการเก็บไว้
-		if existingFilePathsJSON.Valid && existingFilePathsJSON.String != "" {
-			var existingURLs []string
-
-			// Try to parse as array of objects [{"url": "..."}]
-			var fileObjects []map[string]any
-			if err := json.Unmarshal([]byte(existingFilePathsJSON.String), &fileObjects); err == nil {
-				for _, fileObj := range fileObjects {
-					if url, ok := fileObj["url"].(string); ok {
-						existingURLs = append(existingURLs, url)
-					}
-				}
-			} else {
-				// Fallback: try to parse as array of strings
-				if err := json.Unmarshal([]byte(existingFilePathsJSON.String), &existingURLs); err != nil {
-					log.Printf("Error parsing existing file_paths for deletion: %v", err)
-				}
-			}
-
-			for _, url := range existingURLs {
-				// ถ้าไม่ได้ส่ง image_urls มา ให้ลบทั้งหมด
-				if len(keepImageURLs) == 0 {
-					if strings.Contains(url, "prefix=") {
-						parts := strings.Split(url, "prefix=")
-						if len(parts) > 1 {
-							objectName := parts[1]
-							common.DeleteImage(objectName)
-						}
-					}
-				} else {
-					// ตรวจสอบว่า URL นี้อยู่ในรายการที่ต้องการเก็บไว้หรือไม่
-					if !slices.Contains(keepImageURLs, url) {
-						if strings.Contains(url, "prefix=") {
-							parts := strings.Split(url, "prefix=")
-							if len(parts) > 1 {
-								objectName := parts[1]
-								common.DeleteImage(objectName)
-							}
-						}
-					}
-				}
+		// รูปเก่าที่ไม่ต้องการเก็บไว้จะถูกลบหลังบันทึกสำเร็จ (ถ้าไม่ได้ส่ง image_urls มา ให้ลบทั้งหมด)
+		for _, url := range existingURLs {
+			if !slices.Contains(keepImageURLs, url) {
+				removedURLs = append(removedURLs, url)
 			}
 		}
 
@@ -691,25 +498,32 @@ func UpdateProgressHandler(c *fiber.Ctx) error {
 
 	// ใช้ text เดิมถ้าไม่ได้ส่งมาใหม่
 	if req.Text == "" {
-		req.Text = existingProgressText
+		req.Text = existing.Text
 	}
 
 	// เตรียม file paths JSON ใหม่
-	var newFilePathsJSON string
+	var newFilePaths *string
 	if len(uploadedFiles) > 0 {
 		filePathsBytes, _ := json.Marshal(uploadedFiles)
-		newFilePathsJSON = string(filePathsBytes)
+		filePathsJSON := string(filePathsBytes)
+		newFilePaths = &filePathsJSON
 	}
 
 	// อัปเดต progress
-	if newFilePathsJSON != "" {
-		_, err = db.DB.Exec(`UPDATE progress SET progress_text = ?, file_paths = ? WHERE id = ?`, req.Text, newFilePathsJSON, progressid)
-	} else {
-		_, err = db.DB.Exec(`UPDATE progress SET progress_text = ?, file_paths = NULL WHERE id = ?`, req.Text, progressid)
-	}
+	err = repos.InTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Progress.Update(ctx, progressID, version, req.Text, newFilePaths); err != nil {
+			return err
+		}
+		return recordProgressUpdate(ctx, tx, existing, req.Text, newFilePaths, actor)
+	})
 	if err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			return progressConflict(c, progressID, task)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update progress"})
 	}
+	deleteFileURLs(removedURLs)
+	utils.SetETag(c, version+1)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -718,8 +532,17 @@ func UpdateProgressHandler(c *fiber.Ctx) error {
 
 }
 
+// recordProgressUpdate records the changed text and attachments of a progress entry
+func recordProgressUpdate(ctx context.Context, tx *repository.Repositories, existing *models.ProgressRecord, text string, filePaths *string, actor int) error {
+	fields := common.DiffEntry(existing.Text, text, existing.FilePaths, filePaths)
+	if len(fields) == 0 {
+		return nil
+	}
+	return common.RecordChange(ctx, tx, existing.TaskID, models.ChangeEntityProgress, existing.ID, models.ChangeActionUpdate, actor, fields...)
+}
+
 // @Summary Delete progress entry
-// @Description Delete a specific progress entry and its associated files permanently
+// @Description Move a progress entry to the trash; files are kept until it is purged
 // @Tags progress
 // @Accept json
 // @Produce json
@@ -731,6 +554,7 @@ func UpdateProgressHandler(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/progress/delete/{id}/{pgid} [delete]
 func DeleteProgressHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 	id := c.Params("id")
 	progressid := c.Params("pgid")
 
@@ -747,56 +571,35 @@ func DeleteProgressHandler(c *fiber.Ctx) error {
 	}
 
 	// ตรวจสอบว่า task มีอยู่จริงหรือไม่
-	var taskExists string
-	err = db.DB.QueryRow("SELECT '1' FROM tasks WHERE id = ? LIMIT 1", taskID).Scan(&taskExists)
-	if err != nil {
-		if err == sql.ErrNoRows {
+	if _, err := repos.Tasks.Get(ctx, taskID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
 		}
 		log.Printf("Error checking task existence: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
 	}
 
-	// ดึงข้อมูล progress entry ที่จะลบ โดยใช้ models.ProgressEntry
-	var progressEntry models.ProgressEntry
-	var progressText string
-	var filePathsJSON sql.NullString
-	err = db.DB.QueryRow(
-		"SELECT id, progress_text, file_paths, created_at, updated_at FROM progress WHERE id = ? AND task_id = ?",
-		progressID, taskID,
-	).Scan(&progressEntry.ID, &progressText, &filePathsJSON, &progressEntry.CreatedAt, &progressEntry.UpdateAt)
-
+	actor, err := utils.AuditActor(c)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.Status(404).JSON(fiber.Map{"error": "Progress entry not found"})
-		}
-		log.Printf("Error retrieving progress entry: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
+		return utils.AuditActorError(c, err)
 	}
 
-	// Parse progress text เพื่อดึงข้อมูลไฟล์และ populate ProgressEntry
-	parseProgressTextAndDeleteFiles(progressText, &progressEntry)
-
-	// Parse file_paths จากฐานข้อมูล (ถ้ามี)
-	parseFilePathsAndDelete(filePathsJSON, &progressEntry)
-
-	// ทำ hard delete - ลบข้อมูลออกจากฐานข้อมูลจริง
-	_, err = db.DB.Exec(
-		"DELETE FROM progress WHERE id = ? AND task_id = ?",
-		progressID, taskID,
-	)
+	// ย้าย progress ไปถังขยะ ไฟล์แนบจะถูกลบเมื่อ purge ถาวร
+	err = repos.InTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Progress.Delete(ctx, progressID, taskID, actor); err != nil {
+			return err
+		}
+		return common.RecordChange(ctx, tx, taskID, models.ChangeEntityProgress, progressID, models.ChangeActionDelete, actor)
+	})
 	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Progress entry not found"})
+		}
 		log.Printf("Error deleting progress entry: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete progress entry"})
 	}
 
-	// Count deleted files for logging
-	fileCount := 0
-	if progressEntry.FilePaths != nil {
-		fileCount = len(progressEntry.FilePaths)
-	}
-
-	log.Printf("Successfully deleted progress entry ID: %d for task ID: %d with %d associated files", progressID, taskID, fileCount)
+	log.Printf("Moved progress entry ID: %d for task ID: %d to trash by user ID: %d", progressID, taskID, actor)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -1,17 +1,18 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"mime/multipart"
 	"net/url"
 	"os"
 	"reports-api/config"
-	"reports-api/db"
 	"reports-api/handlers/common"
 	"reports-api/models"
+	"reports-api/repository"
 	"reports-api/utils"
 	"strconv"
 	"strings"
@@ -20,22 +21,6 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-// parseFilePaths แปลง JSON file paths เป็น map
-func parseFilePaths(filePathsJSON string) map[string]string {
-	fileMap := make(map[string]string)
-	if filePathsJSON != "" && filePathsJSON != "[]" {
-		var filePaths []fiber.Map
-		if err := json.Unmarshal([]byte(filePathsJSON), &filePaths); err == nil {
-			for i, fp := range filePaths {
-				if url, ok := fp["url"].(string); ok {
-					fileMap[fmt.Sprintf("image_%d", i)] = url
-				}
-			}
-		}
-	}
-	return fileMap
-}
-
 // getPhotoURLs ดึง URLs จาก file paths JSON
 func getPhotoURLs(filePathsJSON string) []string {
 	var photoURLs []string
@@ -52,24 +37,58 @@ func getPhotoURLs(filePathsJSON string) []string {
 	return photoURLs
 }
 
+// uploadTaskFiles uploads the images of a report under ticketno and returns their file paths JSON, "" when none were stored
+func uploadTaskFiles(files []*multipart.FileHeader, ticketno string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	uploadedFiles, _ := common.HandleFileUploads(files, ticketno)
+	if len(uploadedFiles) == 0 {
+		return ""
+	}
+	log.Printf("Uploaded %d files", len(uploadedFiles))
+	filePathsBytes, _ := json.Marshal(uploadedFiles)
+	return string(filePathsBytes)
+}
+
+// deleteUploadedFiles ลบไฟล์ใน MinIO ตาม file paths JSON ([{"url": ...}])
+func deleteUploadedFiles(filePathsJSON string) {
+	deleteFileURLs(getPhotoURLs(filePathsJSON))
+}
+
+// deleteFileURLs ลบไฟล์ใน MinIO ตาม URL ที่มี prefix= ของ object
+func deleteFileURLs(urls []string) {
+	common.DeleteFileURLs(urls)
+}
+
 // getTelegramData ดึงข้อมูลสำหรับ Telegram
-func getTelegramData(phoneID *int, systemID, departmentID int, taskID string) (phoneNumber int, departmentName, branchName, programName string) {
-	if phoneID != nil {
-		db.DB.QueryRow(`SELECT p.number, d.name, b.name FROM ip_phones p JOIN departments d ON p.department_id = d.id JOIN branches b ON d.branch_id = b.id WHERE p.id = ?`, *phoneID).Scan(&phoneNumber, &departmentName, &branchName)
+func getTelegramData(ctx context.Context, phoneID *int, systemID, departmentID int) (phoneNumber int, departmentName, branchName, programName string) {
+	if phoneID != nil && *phoneID > 0 {
+		phoneNumber, departmentName, branchName, _ = repos.Phones.Location(ctx, *phoneID)
 	} else {
-		db.DB.QueryRow(`SELECT d.name, b.name FROM departments d JOIN branches b ON d.branch_id = b.id WHERE d.id = ?`, departmentID).Scan(&departmentName, &branchName)
+		departmentName, branchName, _ = repos.Departments.Location(ctx, departmentID)
 	}
 
 	if systemID != 0 {
-		db.DB.QueryRow(`SELECT name FROM systems_program WHERE id = ?`, systemID).Scan(&programName)
-	} else if taskID != "" {
-		var issueElse string
-		db.DB.QueryRow(`SELECT IFNULL(issue_else, '') FROM tasks WHERE id = ?`, taskID).Scan(&issueElse)
-		programName = issueElse
+		programName, _ = repos.Programs.Name(ctx, systemID)
 	}
 	return
 }
 
+// taskListResponse ส่งรายการ task พร้อมข้อมูล pagination
+func taskListResponse(c *fiber.Ctx, tasks []models.TaskWithDetails, total int, pagination models.PaginationRequest) error {
+	return c.JSON(models.PaginatedResponse{
+		Success: true,
+		Data:    tasks,
+		Pagination: models.PaginationResponse{
+			Page:       pagination.Page,
+			Limit:      pagination.Limit,
+			Total:      total,
+			TotalPages: utils.CalculateTotalPages(total, pagination.Limit),
+		},
+	})
+}
+
 // GetTasksHandler returns a handler for listing all tasks with details and pagination
 // @Summary Get all problems
 // @Description Get list of all problems with pagination
@@ -83,74 +102,14 @@ func GetTasksHandler(c *fiber.Ctx) error {
 	pagination := utils.GetPaginationParams(c)
 	offset := utils.CalculateOffset(pagination.Page, pagination.Limit)
 
-	// Get total count
-	var total int
-	err := db.DB.QueryRow(`SELECT COUNT(*) FROM tasks`).Scan(&total)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count tasks"})
-	}
-
-	// Get paginated data
-	query := `
-		SELECT t.id, IFNULL(t.ticket_no, ''), IFNULL(t.phone_id, 0), IFNULL(t.phone_else, ''), IFNULL(p.number, 0), IFNULL(p.name, ''), t.system_id, IFNULL(s.name, ''), IFNULL(t.issue_type, 0), IFNULL(t.issue_else, ''), IFNULL(it.name, ''), IFNULL(t.department_id, 0), IFNULL(d.name, ''), IFNULL(d.branch_id, 0), IFNULL(b.name, ''), t.text, IFNULL(t.assignto_id, 0), IFNULL(t.assignto, ''), IFNULL(t.reported_by, ''), t.status, t.created_at, t.updated_at, IFNULL(t.file_paths, '[]')
-		FROM tasks t
-		LEFT JOIN ip_phones p ON t.phone_id = p.id
-		LEFT JOIN departments d ON t.department_id = d.id
-		LEFT JOIN branches b ON d.branch_id = b.id
-		LEFT JOIN systems_program s ON t.system_id = s.id
-		LEFT JOIN issue_types it ON t.issue_type = it.id
-		ORDER BY id DESC
-		LIMIT ? OFFSET ?
-	`
-	rows, err := db.DB.Query(query, pagination.Limit, offset)
+	tasks, total, err := repos.Tasks.List(c.UserContext(), repository.TaskQuery{Limit: pagination.Limit, Offset: offset})
 	if err != nil {
 		log.Printf("Error querying tasks with join: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query tasks"})
 	}
-	defer rows.Close()
-
-	// task model Mapping
-	var tasks []models.TaskWithDetails
-	for rows.Next() {
-		var t models.TaskWithDetails
-		var issueTypeName string
-		var filePathsJSON string
-		// Scan row into task model
-		err := rows.Scan(&t.ID, &t.Ticket, &t.PhoneID, &t.PhoneElse, &t.Number, &t.PhoneName, &t.SystemID, &t.SystemName, &t.IssueTypeID, &t.IssueElse, &issueTypeName, &t.DepartmentID, &t.DepartmentName, &t.BranchID, &t.BranchName, &t.Text, &t.AssignedtoID, &t.Assignto, &t.ReportedBy, &t.Status, &t.CreatedAt, &t.UpdatedAt, &filePathsJSON)
-		if err != nil {
-			log.Printf("Error scanning task: %v", err)
-			continue
-		}
-		// Set SystemType based on SystemID
-		if t.SystemID > 0 {
-			t.SystemType = issueTypeName
-		} else {
-			t.SystemType = issueTypeName
-		}
-
-		// Parse file_paths JSON
-		t.FilePaths = parseFilePaths(filePathsJSON)
-
-		tasks = append(tasks, t)
-	}
-
-	if err := rows.Err(); err != nil {
-		log.Printf("Row error: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to read tasks"})
-	}
 
 	log.Printf("Getting tasks Success")
-	// Return paginated response
-	return c.JSON(models.PaginatedResponse{
-		Success: true,
-		Data:    tasks,
-		Pagination: models.PaginationResponse{
-			Page:       pagination.Page,
-			Limit:      pagination.Limit,
-			Total:      total,
-			TotalPages: utils.CalculateTotalPages(total, pagination.Limit),
-		},
-	})
+	return taskListResponse(c, tasks, total, pagination)
 }
 
 // @Summary Get problem details
@@ -160,6 +119,7 @@ func GetTasksHandler(c *fiber.Ctx) error {
 // @Produce json
 // @Param id path string true "Problem ID"
 // @Success 200 {object} map[string]interface{}
+// @Header 200 {string} ETag "Version of the problem"
 // @Router /api/v1/problem/{id} [get]
 func GetTaskDetailHandler(c *fiber.Ctx) error {
 	idStr := c.Params("id")
@@ -167,50 +127,54 @@ func GetTaskDetailHandler(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
 	}
-	var filePathsJSON string
-	var task models.TaskWithDetails
-	var issueTypeName string
-	err = db.DB.QueryRow(`
-		SELECT t.id, IFNULL(t.ticket_no, ''), IFNULL(t.phone_id, 0), IFNULL(t.phone_else, ''), IFNULL(p.number, 0), IFNULL(p.name, ''), IFNULL(t.system_id, 0), IFNULL(s.name, ''), IFNULL(t.issue_type, 0), IFNULL(t.issue_else, ''), IFNULL(it.name, ''), IFNULL(t.department_id, 0), IFNULL(d.name, ''), IFNULL(d.branch_id, 0), IFNULL(b.name, ''), IFNULL(t.text, ''), IFNULL(t.assignto_id, 0), IFNULL(t.assignto, ''), IFNULL(t.reported_by, ''), IFNULL(t.status, 0), IFNULL(t.created_at, ''), IFNULL(t.updated_at, ''), IFNULL(t.file_paths, '[]')
-		FROM tasks t
-		LEFT JOIN ip_phones p ON t.phone_id = p.id
-		LEFT JOIN departments d ON t.department_id = d.id
-		LEFT JOIN branches b ON d.branch_id = b.id
-		LEFT JOIN systems_program s ON t.system_id = s.id
-		LEFT JOIN issue_types it ON t.issue_type = it.id
-		WHERE t.id = ?
-	`, id).Scan(&task.ID, &task.Ticket, &task.PhoneID, &task.PhoneElse, &task.Number, &task.PhoneName, &task.SystemID, &task.SystemName, &task.IssueTypeID, &task.IssueElse, &issueTypeName, &task.DepartmentID, &task.DepartmentName, &task.BranchID, &task.BranchName, &task.Text, &task.AssignedtoID, &task.Assignto, &task.ReportedBy, &task.Status, &task.CreatedAt, &task.UpdatedAt, &filePathsJSON)
-
-	if task.SystemID > 0 {
-		task.SystemType = issueTypeName
-	} else {
-		task.SystemType = issueTypeName
-	}
 
-	if err != nil {
+	task, err := repos.Tasks.GetDetail(c.UserContext(), id)
+	if errors.Is(err, repository.ErrNotFound) {
 		return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
 	}
+	if err != nil {
+		log.Printf("Error fetching task %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query task"})
+	}
 
-	// Parse file_paths JSON and convert to image_{index} format
-	task.FilePaths = parseFilePaths(filePathsJSON)
+	links, err := repos.Links.ListByTask(c.UserContext(), id)
+	if err != nil {
+		log.Printf("Error fetching links of task %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query task"})
+	}
+	problemRecordID, err := repos.ProblemRecords.ProblemOf(c.UserContext(), id)
+	if err != nil {
+		log.Printf("Error fetching problem record of task %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query task"})
+	}
 
 	log.Printf("Getting task ID: %d details", id)
-	return c.JSON(fiber.Map{"success": true, "data": task})
+	utils.SetETag(c, task.Version)
+	return c.JSON(fiber.Map{"success": true, "data": taskDetail{TaskWithDetails: task, Links: links, ProblemRecordID: problemRecordID}})
+}
+
+// taskDetail is a task with its links and problem record (0 when it has none), as returned by GetTaskDetailHandler
+type taskDetail struct {
+	*models.TaskWithDetails
+	Links           []models.TaskLink `json:"links"`
+	ProblemRecordID int               `json:"problem_record_id"`
 }
 
 // CreateTaskHandler เพิ่ม task ใหม่
 // @Summary Create new problem
-// @Description Create a new problem report
+// @Description Create a new problem report; likely duplicates are returned, or the report is attached to one when DUPLICATE_POLICY=link
 // @Tags problems
 // @Accept multipart/form-data
 // @Produce json
 // @Success 200 {object} map[string]interface{}
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried"
+// @Failure 422 {object} map[string]interface{} "Idempotency-Key reused for a different request"
 // @Router /api/v1/problem/create [post]
 func CreateTaskHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 	var req models.TaskRequest
-	var uploadedFiles []fiber.Map
-	// Get latest ID and add 1 for ticket number
-	ticketno := common.Generateticketno()
+	// Handle file uploads if present (support image_{index} format)
+	var allFiles []*multipart.FileHeader
 
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -224,9 +188,6 @@ func CreateTaskHandler(c *fiber.Ctx) error {
 			return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 		}
 
-		// Handle file uploads if present (support image_{index} format)
-		var allFiles []*multipart.FileHeader
-
 		// Check for indexed files (image_0, image_1, image_2, etc.)
 		for key, files := range form.File {
 			if strings.HasPrefix(key, "image_") || key == "image" {
@@ -234,8 +195,6 @@ func CreateTaskHandler(c *fiber.Ctx) error {
 			}
 		}
 
-		uploadedFiles, _ = common.HandleFileUploads(allFiles, ticketno)
-
 		// Convert string form values to int for multipart data
 		if phoneIDStr := c.FormValue("phone_id"); phoneIDStr != "" && phoneIDStr != "0" {
 			if phoneID, err := strconv.Atoi(phoneIDStr); err == nil {
@@ -269,65 +228,117 @@ func CreateTaskHandler(c *fiber.Ctx) error {
 		} else {
 			log.Printf("📥 No telegram field found in form, defaulting to false")
 		}
+		if ignoreStr := c.FormValue("ignore_duplicates"); ignoreStr != "" {
+			req.IgnoreDuplicates = ignoreStr == "true"
+		}
 		if textStr := c.FormValue("text"); textStr != "" {
 			req.Text = textStr
 		}
 
 	}
 
+	// ผู้สร้างต้องเป็นผู้ใช้ที่ล็อกอินอยู่เท่านั้น
+	actor, err := utils.AuditActor(c, &req.CreatedBy, &req.UpdatedBy)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+	req.CreatedBy = actor
+
 	// Get department_id from ip_phones if phone_id is provided
 	if req.PhoneID != nil && *req.PhoneID > 0 {
-		err := db.DB.QueryRow("SELECT department_id FROM ip_phones WHERE id = ?", *req.PhoneID).Scan(&req.DepartmentID)
+		departmentID, err := repos.Phones.DepartmentID(ctx, *req.PhoneID)
 		if err != nil {
 			log.Printf("Warning: Could not get department_id from phone_id %d: %v", *req.PhoneID, err)
+		} else {
+			req.DepartmentID = departmentID
 		}
 	}
 
-	// log.Printf("Request data: PhoneID=%v, SystemID=%d, DepartmentID=%d, Text=%s, CreatedBy=%d", req.PhoneID, req.SystemID, req.DepartmentID, req.Text, req.CreatedBy)
-
-	if len(uploadedFiles) > 0 {
-		log.Printf("Uploaded %d files", len(uploadedFiles))
+	newTask := repository.NewTask{
+		PhoneID:      req.PhoneID,
+		PhoneElse:    req.PhoneElse,
+		SystemID:     req.SystemID,
+		DepartmentID: req.DepartmentID,
+		Text:         req.Text,
+		ReportedBy:   req.ReportedBy,
+		CreatedBy:    req.CreatedBy,
+	}
+	if req.SystemID > 0 {
+		// ดึง typeid จาก systems_program
+		newTask.IssueTypeID, _ = repos.Programs.IssueType(ctx, req.SystemID)
+	} else {
+		// กรณีไม่มีระบบ ให้เก็บ issue_type และ issue_else ที่ส่งมาจาก frontend
+		if req.IssueTypeID == 0 || req.IssueElse == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "issue_type and issue_else must be provided when system_id is 0"})
+		}
+		newTask.IssueTypeID = req.IssueTypeID
+		newTask.IssueElse = &req.IssueElse
 	}
 
-	var res sql.Result
-	if len(uploadedFiles) > 0 {
-		filePathsBytes, _ := json.Marshal(uploadedFiles)
-		if req.SystemID > 0 {
-			// ดึง typeid จาก systems_program
-			var typeid int
-			db.DB.QueryRow(`SELECT type FROM systems_program WHERE id = ?`, req.SystemID).Scan(&typeid)
-			res, err = db.DB.Exec(`INSERT INTO tasks (phone_id, phone_else, ticket_no, system_id, issue_type, department_id, text, reported_by, status, created_by, file_paths) VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?)`, req.PhoneID, req.PhoneElse, ticketno, req.SystemID, typeid, req.DepartmentID, req.Text, req.ReportedBy, req.CreatedBy, string(filePathsBytes))
+	// ตรวจหาการแจ้งปัญหาเดียวกันซ้ำจากแผนกหรือสาขาเดียวกันในช่วงเวลาใกล้กัน
+	duplicates := []models.DuplicateCandidate{}
+	if policy := config.AppConfig.Duplicate.Policy; policy != models.DuplicatePolicyOff && !req.IgnoreDuplicates {
+		q := repository.DuplicateQuery{SystemID: newTask.SystemID, IssueTypeID: newTask.IssueTypeID, DepartmentID: newTask.DepartmentID}
+		found, err := common.FindDuplicateTasks(ctx, repos, q, req.Text)
+		if err != nil {
+			// ตรวจซ้ำไม่สำเร็จก็ยังแจ้งปัญหาได้ตามปกติ
+			log.Printf("Error checking duplicate tasks: %v", err)
 		} else {
-			// กรณีไม่มีระบบ ให้เก็บ issue_type และ issue_else
-			if req.IssueTypeID == 0 || req.IssueElse == "" {
-				return c.Status(400).JSON(fiber.Map{"error": "issue_type and issue_else must be provided when system_id is 0"})
-			}
-			// รับค่า issue_type จาก frontend โดยตรง
-			res, err = db.DB.Exec(`INSERT INTO tasks (phone_id, phone_else, ticket_no, system_id, issue_type, issue_else, department_id, text, reported_by, status, created_by, file_paths) VALUES (?, ?, ?, 0, ?, ?, ?, ?, ?, 0, ?, ?)`, req.PhoneID, req.PhoneElse, ticketno, req.IssueTypeID, req.IssueElse, req.DepartmentID, req.Text, req.ReportedBy, req.CreatedBy, string(filePathsBytes))
+			duplicates = found
 		}
-	} else {
-		if req.SystemID > 0 {
-			var typeid int
-			db.DB.QueryRow(`SELECT type FROM systems_program WHERE id = ?`, req.SystemID).Scan(&typeid)
-			res, err = db.DB.Exec(`INSERT INTO tasks (phone_id, phone_else, ticket_no, system_id, issue_type, department_id, text, reported_by, status, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0, ?)`, req.PhoneID, req.PhoneElse, ticketno, req.SystemID, typeid, req.DepartmentID, req.Text, req.ReportedBy, req.CreatedBy)
-		} else {
-			// กรณีไม่มีระบบ ให้เก็บ issue_type และ issue_else
-			if req.IssueTypeID == 0 || req.IssueElse == "" {
-				return c.Status(400).JSON(fiber.Map{"error": "issue_type and issue_else must be provided when system_id is 0"})
+		if policy == models.DuplicatePolicyLink && len(duplicates) > 0 {
+			// รูปของการแจ้งซ้ำเก็บไว้ใต้ ticket ที่ถูกแจ้งซ้ำ
+			var filePaths *string
+			if filePathsJSON := uploadTaskFiles(allFiles, duplicates[0].Ticket); filePathsJSON != "" {
+				filePaths = &filePathsJSON
 			}
-			// รับค่า issue_type จาก frontend โดยตรง
-			res, err = db.DB.Exec(`INSERT INTO tasks (phone_id, phone_else, ticket_no, system_id, issue_type, issue_else, department_id, text, reported_by, status, created_by) VALUES (?, ?, ?, 0, ?, ?, ?, ?, ?, 0, ?)`, req.PhoneID, req.PhoneElse, ticketno, req.IssueTypeID, req.IssueElse, req.DepartmentID, req.Text, req.ReportedBy, req.CreatedBy)
+			return attachDuplicateReport(c, req, duplicates, filePaths, actor)
 		}
 	}
 
+	// จองเลข ticket ใน transaction เดียวกับการสร้าง task เพื่อไม่ให้เลขที่ไม่ได้ใช้หายไปจากลำดับ
+	var id int64
+	var ticketno string
+	err = repos.InTx(ctx, func(tx *repository.Repositories) error {
+		var err error
+		ticketno, err = common.NextTicketNo(ctx, tx.Tickets)
+		if err != nil {
+			return fmt.Errorf("failed to allocate ticket number: %w", err)
+		}
+		newTask.Ticket = ticketno
+		id, err = tx.Tasks.Create(ctx, newTask)
+		if err != nil {
+			return err
+		}
+		if err := tx.StatusHistory.Record(ctx, int(id), nil, models.TaskStatusPending, req.CreatedBy, ""); err != nil {
+			return err
+		}
+		if err := common.RecordTaskCreated(ctx, tx, int(id), req.CreatedBy); err != nil {
+			return err
+		}
+		if err := common.ApplyTaskSLA(ctx, tx, int(id)); err != nil {
+			log.Printf("Failed to apply SLA to task %d: %v", id, err)
+		}
+
+		// Update department score
+		if err := updateDepartmentScore(ctx, tx, req.DepartmentID); err != nil {
+			log.Printf("Failed to update department score: %v", err)
+		}
+		return nil
+	})
 	if err != nil {
+		log.Printf("Error inserting task: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert task"})
 	}
-	id, _ := res.LastInsertId()
 
-	// Update department score
-	if err := updateDepartmentScore(req.DepartmentID); err != nil {
-		log.Printf("Failed to update department score: %v", err)
+	// อัปโหลดรูปหลังได้เลข ticket แล้ว จากนั้นจึงผูกกับ task
+	filePathsJSON := uploadTaskFiles(allFiles, ticketno)
+	if filePathsJSON != "" {
+		if err := repos.Tasks.SetFilePaths(ctx, int(id), filePathsJSON); err != nil {
+			log.Printf("Failed to store file paths of task %d: %v", id, err)
+			deleteUploadedFiles(filePathsJSON)
+			filePathsJSON = ""
+		}
 	}
 
 	log.Printf("Inserted new task with ID: %d", id)
@@ -341,7 +352,7 @@ func CreateTaskHandler(c *fiber.Ctx) error {
 	}
 	if req.Telegram {
 		// Get additional data for Telegram
-		phoneNumber, departmentName, branchName, programName := getTelegramData(req.PhoneID, req.SystemID, req.DepartmentID, "")
+		phoneNumber, departmentName, branchName, programName := getTelegramData(ctx, req.PhoneID, req.SystemID, req.DepartmentID)
 
 		req.PhoneNumber = phoneNumber
 		req.DepartmentName = departmentName
@@ -350,50 +361,34 @@ func CreateTaskHandler(c *fiber.Ctx) error {
 		req.ProgramName = programName
 		req.Url = Urlenv
 		req.CreatedAt = time.Now().Add(7 * time.Hour).Format("02/01/2006 15:04:05")
-		req.Status = 0
+		req.Status = models.TaskStatusPending
 
-		var messageID int
-		var messageName string
 		// Send with photo if files were uploaded
-		if len(uploadedFiles) > 0 {
-			// Get all image URLs
-			filePathsBytes, _ := json.Marshal(uploadedFiles)
-			photoURLs := getPhotoURLs(string(filePathsBytes))
-			messageID, messageName, err = common.SendTelegram(req, photoURLs...)
-			if err != nil {
-				log.Printf("❌ Error sending Telegram: %v", err)
-			}
+		messageID, messageName, err := common.SendTelegram(req, getPhotoURLs(filePathsJSON)...)
+		if err != nil {
+			log.Printf("❌ Error sending Telegram: %v", err)
+			log.Printf("❌ Failed to send Telegram notification: %v", err)
 		} else {
-			messageID, messageName, err = common.SendTelegram(req)
-			if err != nil {
-				log.Printf("❌ Error sending Telegram: %v", err)
-			}
-		}
-
-		if err == nil {
 			// Update task with message_id
+			// บันทึก telegram_chat และผูกกับ task ใน transaction เดียวกัน
 			chatID, _ := strconv.Atoi(os.Getenv("CHAT_ID"))
-			resTG, errTG := db.DB.Exec(`INSERT INTO telegram_chat (chat_id, chat_name, report_id) VALUES (?, ?, ?)`, chatID, messageName, messageID)
-			var telegramChatID int64
-			if errTG != nil {
-				log.Printf("❌ Failed to Chat telegram: %v", errTG)
-			} else {
-				telegramChatID, _ = resTG.LastInsertId()
-				log.Printf("✅ Message ID Chat telegram successfully in database, telegram_chat.id: %d", telegramChatID)
-			}
-			_, err = db.DB.Exec(`UPDATE tasks SET telegram_id = ? WHERE id = ?`, telegramChatID, id)
+			err := repos.InTx(ctx, func(tx *repository.Repositories) error {
+				telegramChatID, err := tx.TelegramChats.Create(ctx, chatID, messageName, messageID)
+				if err != nil {
+					return err
+				}
+				return tx.Tasks.SetTelegramChat(ctx, int(id), telegramChatID)
+			})
 			if err != nil {
-				log.Printf("❌ Failed to update telegram_id: %v", err)
+				log.Printf("❌ Failed to link telegram chat to task %d: %v", id, err)
 			} else {
-				log.Printf("✅ Telegram ID updated successfully in database")
+				log.Printf("✅ Telegram chat linked to task %d in database", id)
 			}
-		} else {
-			log.Printf("❌ Failed to send Telegram notification: %v", err)
 		}
 	} else {
 		log.Printf("⚠️ Telegram notification skipped - Telegram flag is false")
 	}
-	return c.JSON(fiber.Map{"success": true, "id": id})
+	return c.JSON(fiber.Map{"success": true, "id": id, "duplicates": duplicates, "workarounds": workaroundSuggestions(c, req.SystemID)})
 }
 
 // UpdateTaskHandler แก้ไข task
@@ -403,13 +398,19 @@ func CreateTaskHandler(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Param id path string true "Problem ID"
+// @Param If-Match header string true "Version of the problem being edited"
 // @Success 200 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 428 {object} map[string]interface{}
 // @Router /api/v1/problem/update/{id} [put]
 func UpdateTaskHandler(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var err error
-	var ticketno string
-	var uploadedFiles []fiber.Map
+	ctx := c.UserContext()
+	idStr := c.Params("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+	var allFiles []*multipart.FileHeader
 
 	var req models.TaskRequestUpdate
 	if err := c.BodyParser(&req); err != nil {
@@ -421,14 +422,24 @@ func UpdateTaskHandler(c *fiber.Ctx) error {
 		req.PhoneID = nil
 	}
 
-	log.Printf("Looking for task with ID: %s", id)
-	err = db.DB.QueryRow("SELECT ticket_no FROM tasks WHERE id = ?", id).Scan(&ticketno)
+	log.Printf("Looking for task with ID: %d", id)
+	existing, err := repos.Tasks.Get(ctx, id)
 	if err != nil {
 		log.Printf("Task not found error: %v", err)
 		return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
 	}
+	ticketno := existing.Ticket
 	log.Printf("Found task with ticket_no: %s", ticketno)
 
+	// แก้ไขได้เฉพาะเวอร์ชันที่ผู้ใช้เห็นล่าสุด ป้องกันการเขียนทับกัน
+	version, err := utils.IfMatchVersion(c)
+	if err != nil {
+		return utils.IfMatchError(c, err)
+	}
+	if existing.Version != version {
+		return taskConflict(c, id)
+	}
+
 	form, err := c.MultipartForm()
 	if err != nil {
 		// If multipart parsing fails, try regular body parser
@@ -441,17 +452,13 @@ func UpdateTaskHandler(c *fiber.Ctx) error {
 			return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 		}
 
-		// Handle file uploads if present (support image_{index} format)
-		var allFiles []*multipart.FileHeader
-
+		// Collect file uploads if present (support image_{index} format)
 		// Check for indexed files (image_0, image_1, image_2, etc.)
 		for key, files := range form.File {
 			if strings.HasPrefix(key, "image_") || key == "image" {
 				allFiles = append(allFiles, files...)
 			}
 		}
-
-		uploadedFiles, _ = common.HandleFileUploads(allFiles, ticketno)
 	}
 
 	// Convert string form values to int for multipart data
@@ -485,158 +492,139 @@ func UpdateTaskHandler(c *fiber.Ctx) error {
 		req.ReportedBy = &reportedByStr
 	}
 
-	// เก็บ assignto เดิมก่อนการอัปเดต
-	var previousAssigntoNull sql.NullString
-	err = db.DB.QueryRow(`SELECT assignto FROM tasks WHERE id = ?`, id).Scan(&previousAssigntoNull)
+	actor, err := utils.AuditActor(c, &req.UpdatedBy)
 	if err != nil {
-		log.Println("Error fetching previous_assignto:", err)
-	}
-	var previousAssignto string
-	if previousAssigntoNull.Valid {
-		previousAssignto = previousAssigntoNull.String
+		return utils.AuditActorError(c, err)
 	}
+	req.UpdatedBy = actor
+
+	// เก็บ assignto เดิมก่อนการอัปเดต
+	previousAssignto := existing.Assignto
 
 	// Get department_id from phone_id if phone_id exists and is valid
 	if req.PhoneID != nil {
-		err := db.DB.QueryRow("SELECT department_id FROM ip_phones WHERE id = ?", *req.PhoneID).Scan(&req.DepartmentID)
+		departmentID, err := repos.Phones.DepartmentID(ctx, *req.PhoneID)
 		if err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Invalid phone_id"})
 		}
+		req.DepartmentID = departmentID
 	} else if req.DepartmentID == 0 {
-		// If no phone_id and no department_id provided, get from existing task
-		err := db.DB.QueryRow("SELECT department_id FROM tasks WHERE id = ?", id).Scan(&req.DepartmentID)
-		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Task not found"})
-		}
+		// If no phone_id and no department_id provided, keep the existing department
+		req.DepartmentID = existing.DepartmentID
 	}
 
-	var createdAtStr string
-
-	log.Printf("Updating task ID: %s", id)
-
-	// Handle file uploads
-	if len(uploadedFiles) > 0 {
-		// Delete existing files first
-		var existingFilePathsJSON string
-		db.DB.QueryRow(`SELECT IFNULL(file_paths, '[]') FROM tasks WHERE id = ?`, id).Scan(&existingFilePathsJSON)
-
-		if existingFilePathsJSON != "" && existingFilePathsJSON != "[]" {
-			var existingFiles []fiber.Map
-			if err := json.Unmarshal([]byte(existingFilePathsJSON), &existingFiles); err == nil {
-				for _, fp := range existingFiles {
-					if url, ok := fp["url"].(string); ok {
-						// Extract object name from URL
-						if strings.Contains(url, "prefix=") {
-							parts := strings.Split(url, "prefix=")
-							if len(parts) > 1 {
-								objectName := parts[1]
-								common.DeleteImage(objectName)
-							}
-						}
-					}
-				}
-			}
-		}
+	// อัปโหลดหลังตรวจคำขอครบแล้ว คำขอที่ไม่ผ่านจะไม่ทิ้งไฟล์ค้างใน MinIO
+	var uploadedFiles []fiber.Map
+	if len(allFiles) > 0 {
+		uploadedFiles, _ = common.HandleFileUploads(allFiles, ticketno)
+	}
 
-		// Upload new files
-		filePathsBytes, _ := json.Marshal(uploadedFiles)
-		log.Printf("Updating file_paths: %s", string(filePathsBytes))
+	log.Printf("Updating task ID: %d", id)
 
-		if req.SystemID > 0 {
-			var typeid int
-			db.DB.QueryRow(`SELECT type FROM systems_program WHERE id = ?`, req.SystemID).Scan(&typeid)
-			if req.ReportedBy != nil {
-				_, err = db.DB.Exec(`UPDATE tasks SET phone_id=?, phone_else=?, system_id=?, issue_type=?, issue_else=NULL, department_id=?, assignto_id=?, assignto=?, reported_by=?, text=?, status=?, updated_at=CURRENT_TIMESTAMP, updated_by=?, file_paths=? WHERE id=?`, req.PhoneID, req.PhoneElse, req.SystemID, typeid, req.DepartmentID, req.AssignedtoID, req.Assignto, req.ReportedBy, req.Text, req.Status, req.UpdatedBy, string(filePathsBytes), id)
-			} else {
-				_, err = db.DB.Exec(`UPDATE tasks SET phone_id=?, phone_else=?, system_id=?, issue_type=?, issue_else=NULL, department_id=?, assignto_id=?, assignto=?, text=?, status=?, updated_at=CURRENT_TIMESTAMP, updated_by=?, file_paths=? WHERE id=?`, req.PhoneID, req.PhoneElse, req.SystemID, typeid, req.DepartmentID, req.AssignedtoID, req.Assignto, req.Text, req.Status, req.UpdatedBy, string(filePathsBytes), id)
-			}
-		} else {
-			if req.ReportedBy != nil {
-				_, err = db.DB.Exec(`UPDATE tasks SET phone_id=?, phone_else=?, system_id=0, issue_type=?, issue_else=?, department_id=?, assignto_id=?, assignto=?, reported_by=?, text=?, status=?, updated_at=CURRENT_TIMESTAMP, updated_by=?, file_paths=? WHERE id=?`, req.PhoneID, req.PhoneElse, req.IssueTypeID, req.IssueElse, req.DepartmentID, req.AssignedtoID, req.Assignto, req.ReportedBy, req.Text, req.Status, req.UpdatedBy, string(filePathsBytes), id)
-			} else {
-				_, err = db.DB.Exec(`UPDATE tasks SET phone_id=?, phone_else=?, system_id=0, issue_type=?, issue_else=?, department_id=?, assignto_id=?, assignto=?, text=?, status=?, updated_at=CURRENT_TIMESTAMP, updated_by=?, file_paths=? WHERE id=?`, req.PhoneID, req.PhoneElse, req.IssueTypeID, req.IssueElse, req.DepartmentID, req.AssignedtoID, req.Assignto, req.Text, req.Status, req.UpdatedBy, string(filePathsBytes), id)
-			}
-		}
+	update := repository.TaskUpdate{
+		PhoneID:      req.PhoneID,
+		PhoneElse:    req.PhoneElse,
+		SystemID:     req.SystemID,
+		DepartmentID: req.DepartmentID,
+		AssignedtoID: req.AssignedtoID,
+		Assignto:     req.Assignto,
+		ReportedBy:   req.ReportedBy,
+		Text:         req.Text,
+		UpdatedBy:    req.UpdatedBy,
+		Version:      version,
+	}
+	if req.SystemID > 0 {
+		update.IssueTypeID, _ = repos.Programs.IssueType(ctx, req.SystemID)
 	} else {
-		if req.SystemID > 0 {
-			var typeid int
-			db.DB.QueryRow(`SELECT type FROM systems_program WHERE id = ?`, req.SystemID).Scan(&typeid)
-			if req.ReportedBy != nil {
-				_, err = db.DB.Exec(`UPDATE tasks SET phone_id=?, phone_else=?, system_id=?, issue_type=?, issue_else=NULL, department_id=?, assignto_id=?, assignto=?, reported_by=?, text=?, status=?, updated_at=CURRENT_TIMESTAMP, updated_by=? WHERE id=?`, req.PhoneID, req.PhoneElse, req.SystemID, typeid, req.DepartmentID, req.AssignedtoID, req.Assignto, req.ReportedBy, req.Text, req.Status, req.UpdatedBy, id)
-			} else {
-				_, err = db.DB.Exec(`UPDATE tasks SET phone_id=?, phone_else=?, system_id=?, issue_type=?, issue_else=NULL, department_id=?, assignto_id=?, assignto=?, text=?, status=?, updated_at=CURRENT_TIMESTAMP, updated_by=? WHERE id=?`, req.PhoneID, req.PhoneElse, req.SystemID, typeid, req.DepartmentID, req.AssignedtoID, req.Assignto, req.Text, req.Status, req.UpdatedBy, id)
-			}
-		} else {
-			var assignto string
-			if req.Assignto != nil {
-				assignto = *req.Assignto
-			}
-			if req.ReportedBy != nil {
-				_, err = db.DB.Exec(`UPDATE tasks SET phone_id=?, phone_else=?, system_id=0, issue_type=?, issue_else=?, department_id=?, assignto_id=?, assignto=?, reported_by=?, text=?, status=?, updated_at=CURRENT_TIMESTAMP, updated_by=? WHERE id=?`, req.PhoneID, req.PhoneElse, req.IssueTypeID, req.IssueElse, req.DepartmentID, req.AssignedtoID, assignto, req.ReportedBy, req.Text, req.Status, req.UpdatedBy, id)
-			} else {
-				_, err = db.DB.Exec(`UPDATE tasks SET phone_id=?, phone_else=?, system_id=0, issue_type=?, issue_else=?, department_id=?, assignto_id=?, assignto=?, text=?, status=?, updated_at=CURRENT_TIMESTAMP, updated_by=? WHERE id=?`, req.PhoneID, req.PhoneElse, req.IssueTypeID, req.IssueElse, req.DepartmentID, req.AssignedtoID, assignto, req.Text, req.Status, req.UpdatedBy, id)
-			}
-		}
+		update.IssueTypeID = req.IssueTypeID
+		update.IssueElse = &req.IssueElse
 	}
 
-	if req.Status == 2 {
-		_, err = db.DB.Exec(`UPDATE tasks SET resolved_at=CURRENT_TIMESTAMP WHERE id=?`, id)
-
+	// Handle file uploads
+	var newFilePathsJSON string
+	if len(uploadedFiles) > 0 {
+		filePathsBytes, _ := json.Marshal(uploadedFiles)
+		newFilePathsJSON = string(filePathsBytes)
+		log.Printf("Updating file_paths: %s", newFilePathsJSON)
+		update.FilePaths = &newFilePathsJSON
 	}
 
-	if req.Status == 0 {
-		_, err = db.DB.Exec(`UPDATE tasks SET resolved_at=NULL WHERE id=?`, id)
-
+	// มีผู้รับผิดชอบแล้วแต่ยังไม่เริ่มงาน ถือว่ากำลังดำเนินการ
+	if (req.Status == models.TaskStatusPending || req.Status == models.TaskStatusReopened) &&
+		req.Assignto != nil && *req.Assignto != "" && req.AssignedtoID != 0 {
+		req.Status = models.TaskStatusInProgress
 	}
 
-	var solutionChack int
-	_ = db.DB.QueryRow(`SELECT IFNULL(solution_id, 0) FROM tasks WHERE id = ?`, id).Scan(&solutionChack)
-
-	if req.Status != 2 {
-		if req.Assignto != nil && *req.Assignto != "" && req.AssignedtoID != 0 {
-			_, err = db.DB.Exec(`UPDATE tasks SET status = 1 WHERE id=?`, id)
-			req.Status = 1
+	var committedVersion int
+	err = repos.InTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Tasks.Update(ctx, id, update); err != nil {
+			return err
 		}
-	}
-
+		if err := common.RecordTaskChanges(ctx, tx, existing, req.UpdatedBy); err != nil {
+			return err
+		}
+		// โปรแกรม แผนก หรือประเภทปัญหาอาจเปลี่ยน จึงคำนวณกำหนดเวลา SLA ใหม่
+		if err := common.ApplyTaskSLA(ctx, tx, id); err != nil {
+			log.Printf("Failed to apply SLA to task %d: %v", id, err)
+		}
+		if _, err := common.TransitionTask(ctx, tx, id, existing.Status, req.Status, req.UpdatedBy, req.StatusReason); err != nil {
+			return err
+		}
+		after, err := tx.Tasks.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		committedVersion = after.Version
+		return nil
+	})
 	if err != nil {
+		log.Printf("Error updating task %d: %v", id, err)
+		deleteUploadedFiles(newFilePathsJSON)
+		if errors.Is(err, models.ErrVersionConflict) {
+			return taskConflict(c, id)
+		}
+		if errors.Is(err, models.ErrInvalidStatusTransition) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update task"})
 	}
 
+	// ลบไฟล์เดิมหลังจากบันทึกไฟล์ใหม่สำเร็จแล้วเท่านั้น
+	if newFilePathsJSON != "" {
+		deleteUploadedFiles(existing.FilePaths)
+	}
+
 	var Urlenv string
 	env := config.AppConfig.Environment
 	if env == "dev" {
-		Urlenv = "http://helpdesk-dev.nopadol.com/tasks/show/" + id
+		Urlenv = "http://helpdesk-dev.nopadol.com/tasks/show/" + idStr
 	} else {
-		Urlenv = "http://helpdesk.nopadol.com/tasks/show/" + id
+		Urlenv = "http://helpdesk.nopadol.com/tasks/show/" + idStr
 	}
 
+	utils.SetETag(c, committedVersion)
+
 	// Get message_id and update Telegram if exists
-	var messageID int
-	var reported string
-	var existingFilePathsJSON string
-	var telegramUser string
-	var assigntoID int
-	var ResolvedAt string
-	var telegramID int
-	var updatedAtStr string
-
-	err = db.DB.QueryRow(`
-		SELECT IFNULL(t.ticket_no, ''),IFNULL(tc.report_id, 0), IFNULL(t.reported_by, ''), 
-		IFNULL(t.file_paths, '[]'), IFNULL(rs.telegram_username, ''), IFNULL(tc.assignto_id, 0), t.created_at, IFNULL(t.telegram_id, 0), t.updated_at
-		FROM tasks t
-		LEFT JOIN telegram_chat tc ON t.telegram_id = tc.id
-		LEFT JOIN responsibilities rs ON t.assignto_id = rs.id
-		WHERE t.id = ?
-		`, id).Scan(&ticketno, &messageID, &reported, &existingFilePathsJSON, &telegramUser, &assigntoID, &createdAtStr, &telegramID, &updatedAtStr)
-
-	// Parse created_at string to time
-	CreatedAt := common.Fixtimefeature(createdAtStr)
-	UpdatedAt := common.Fixtimefeature(updatedAtStr)
-
-	log.Printf("Query result - err: %v, messageID: %d, telegramID: %d", err, messageID, telegramID)
-
-	if err == nil && messageID > 0 {
+	// ล็อกข้อความ Telegram ของ task ไว้ และไม่ส่งข้อมูลเวอร์ชันนี้ถ้ามีการแก้ไขที่ใหม่กว่า commit ไปแล้ว
+	unlock := lockTaskTelegram(id)
+	defer unlock()
+	task, err := repos.Tasks.Get(ctx, id)
+	if err != nil {
+		log.Printf("Failed to reload task %d: %v", id, err)
+		log.Printf("Task update completed for ID: %d", id)
+		return c.JSON(fiber.Map{"success": true})
+	}
+	messageID := task.ReportMessageID
+	telegramID := task.TelegramID
+
+	CreatedAt := common.Fixtimefeature(task.CreatedAt)
+	UpdatedAt := common.Fixtimefeature(task.UpdatedAt)
+
+	log.Printf("Query result - messageID: %d, telegramID: %d", messageID, telegramID)
+
+	if task.Version != committedVersion {
+		log.Printf("Task %d changed after version %d, skipping its Telegram update", id, committedVersion)
+	} else if messageID > 0 {
 		// สร้าง currentAssignto
 		var currentAssignto string
 		if req.Assignto != nil {
@@ -651,72 +639,51 @@ func UpdateTaskHandler(c *fiber.Ctx) error {
 			DepartmentID:     req.DepartmentID,
 			Text:             req.Text,
 			Status:           req.Status,
-			ReportedBy:       reported,
-			TelegramUser:     telegramUser,
-			AssigntoID:       assigntoID,
+			ReportedBy:       task.ReportedBy,
+			TelegramUser:     task.AssigneeTelegram,
+			AssigntoID:       task.AssigneeMessageID,
 			Assignto:         currentAssignto,
 			PreviousAssignto: previousAssignto,
 			MessageID:        messageID,
 			IssueElse:        req.IssueElse,
-			Ticket:           ticketno,
+			Ticket:           task.Ticket,
 		}
 
 		// Get additional data for Telegram
-		var phoneNumber int
-		var departmentName, branchName, programName string
-
-		if req.PhoneID != nil {
-			db.DB.QueryRow(`SELECT p.number, d.name, b.name FROM ip_phones p JOIN departments d ON p.department_id = d.id JOIN branches b ON d.branch_id = b.id WHERE p.id = ?`, *req.PhoneID).Scan(&phoneNumber, &departmentName, &branchName)
-		} else {
-			db.DB.QueryRow(`SELECT d.name, b.name FROM departments d JOIN branches b ON d.branch_id = b.id WHERE d.id = ?`, req.DepartmentID).Scan(&departmentName, &branchName)
-		}
-
-		if req.SystemID != 0 {
-			db.DB.QueryRow(`SELECT name FROM systems_program WHERE id = ?`, req.SystemID).Scan(&programName)
-		}
+		phoneNumber, departmentName, branchName, programName := getTelegramData(ctx, req.PhoneID, req.SystemID, req.DepartmentID)
 
-		if req.Status == 2 {
-			db.DB.QueryRow(`SELECT IFNULL(resolved_at, "") FROM tasks WHERE id = ?`, id).Scan(&ResolvedAt)
+		var resolvedAt string
+		if req.Status == models.TaskStatusDone {
+			resolvedAt = task.ResolvedAt
 		}
 
-		resolvedAtnow := common.Fixtimefeature(ResolvedAt)
-
 		telegramReq.PhoneNumber = phoneNumber
 		telegramReq.DepartmentName = departmentName
 		telegramReq.BranchName = branchName
 		telegramReq.ProgramName = programName
 		telegramReq.Url = Urlenv
-		telegramReq.PreviousAssignto = previousAssignto
 		telegramReq.CreatedAt = CreatedAt
-		telegramReq.ResolvedAt = resolvedAtnow
+		telegramReq.ResolvedAt = common.Fixtimefeature(resolvedAt)
 		telegramReq.UpdatedAt = UpdatedAt
 
 		// Get first image URL from existing files for Telegram
-		photoURLs := getPhotoURLs(existingFilePathsJSON)
+		photoURLs := getPhotoURLs(task.FilePaths)
 		log.Printf("Previous assignto: %s, New assignto: %v", previousAssignto, req.Assignto)
 
 		// ตรวจสอบการเปลี่ยนผู้รับผิดชอบเพื่อลบ notification message เก่า
 		if previousAssignto != currentAssignto && previousAssignto != "" {
 			// ลบ notification message เก่าถ้ามีการเปลี่ยนผู้รับผิดชอบ
-			var oldNotificationID int
-			db.DB.QueryRow(`SELECT IFNULL(assignto_id, 0) FROM telegram_chat WHERE id = ?`, telegramID).Scan(&oldNotificationID)
-			if oldNotificationID > 0 {
-				_, _ = common.DeleteTelegram(oldNotificationID)
+			if task.AssigneeMessageID > 0 {
+				_, _ = common.DeleteTelegram(task.AssigneeMessageID)
 			}
 		}
 
-		var assigntoNotificationID int
 		// ส่งหรืออัปเดต main task message
-		if len(photoURLs) > 0 {
-			assigntoNotificationID, err = common.UpdateTelegram(telegramReq, photoURLs...)
-		} else {
-			assigntoNotificationID, err = common.UpdateTelegram(telegramReq)
-		}
+		assigntoNotificationID, err := common.UpdateTelegram(telegramReq, photoURLs...)
 
 		if assigntoNotificationID > 0 {
 			// อัปเดต assignto_id ใน telegram_chat
-			_, err = db.DB.Exec(`UPDATE telegram_chat SET assignto_id = ? WHERE id = ?`, assigntoNotificationID, telegramID)
-			if err != nil {
+			if err := repos.TelegramChats.SetAssigneeMessage(ctx, telegramID, assigntoNotificationID); err != nil {
 				log.Printf("❌ Failed to update assignto_id: %v", err)
 			} else {
 				log.Printf("✅ Assignto notification sent and ID updated: %d", assigntoNotificationID)
@@ -730,27 +697,25 @@ func UpdateTaskHandler(c *fiber.Ctx) error {
 		}
 
 		// อัปเดต solution message (เสมอ ไม่ขึ้นกับการเปลี่ยน assignto)
-		var resolutionID sql.NullInt64
-		var solutionMessageID int
-		var assignedID int
-		db.DB.QueryRow(`SELECT solution_id FROM tasks WHERE id = ?`, id).Scan(&resolutionID)
-		db.DB.QueryRow(`SELECT IFNULL(solution_id, 0), IFNULL(assignto_id, 0) FROM telegram_chat WHERE id = ?`, telegramID).Scan(&solutionMessageID, &assignedID)
+		var solutionMessageID, assignedID int
+		if chat, err := repos.TelegramChats.Get(ctx, telegramID); err == nil {
+			solutionMessageID = chat.SolutionMessageID
+			assignedID = chat.AssigneeMessageID
+		}
 		log.Printf("📊 Debug - telegramID: %d, solutionMessageID: %d, assignedID: %d", telegramID, solutionMessageID, assignedID)
 
-		// เมื่อ Status เป็น 2 (เสร็จสิ้น) ให้ลบการแจ้งเตือนของผู้รับผิดชอบ
-		if req.Status == 2 {
+		// เมื่องานปิดแล้ว (เสร็จสิ้นหรือยกเลิก) ให้ลบการแจ้งเตือนของผู้รับผิดชอบ
+		if req.Status.IsClosed() {
 			if assignedID > 0 {
 				// ลบ telegram message ของผู้รับผิดชอบ
-				_, err = common.DeleteTelegram(assignedID)
-				if err != nil {
+				if _, err := common.DeleteTelegram(assignedID); err != nil {
 					log.Printf("❌ Error deleting assignto telegram message (ID: %d): %v", assignedID, err)
 				} else {
 					log.Printf("✅ Successfully deleted assignto telegram message (ID: %d)", assignedID)
 				}
 
 				// อัปเดต assignto_id ใน telegram_chat ให้เป็น NULL
-				_, err = db.DB.Exec(`UPDATE telegram_chat SET assignto_id = NULL WHERE id = ?`, telegramID)
-				if err != nil {
+				if err := repos.TelegramChats.SetAssigneeMessage(ctx, telegramID, 0); err != nil {
 					log.Printf("❌ Error updating telegram_chat assignto_id: %v", err)
 				} else {
 					log.Printf("✅ Successfully cleared assignto_id in telegram_chat")
@@ -758,44 +723,28 @@ func UpdateTaskHandler(c *fiber.Ctx) error {
 			}
 		}
 
-		log.Printf("Resolution check - resolutionID: %v, solutionMessageID: %d", resolutionID, solutionMessageID)
-
-		if resolutionID.Valid && resolutionID.Int64 > 0 && solutionMessageID > 0 {
-			var resolutionText string
-			var resolutionFilePathsJSON string
-			var resolutionResolvedAt string
-			err = db.DB.QueryRow(`
-				SELECT IFNULL(text, ''), IFNULL(file_paths, '[]'), resolved_at
-				FROM resolutions WHERE id = ?
-			`, resolutionID.Int64).Scan(&resolutionText, &resolutionFilePathsJSON, &resolutionResolvedAt)
-
-			resolvedAt := common.Fixtimefeature(resolutionResolvedAt)
+		log.Printf("Resolution check - resolutionID: %v, solutionMessageID: %d", task.SolutionID, solutionMessageID)
 
+		if task.SolutionID != nil && *task.SolutionID > 0 && solutionMessageID > 0 {
+			resolution, err := repos.Resolutions.Get(ctx, *task.SolutionID)
 			if err == nil {
 				// สร้าง ResolutionReq
 				resolutionReq := models.ResolutionReq{
-					Solution:         resolutionText,
-					TelegramUser:     telegramUser,
+					Solution:         resolution.Text,
+					TelegramUser:     task.AssigneeTelegram,
 					MessageID:        messageID,
 					Url:              Urlenv,
 					Assignto:         currentAssignto,
 					PreviousAssignto: previousAssignto,
-					TicketNo:         ticketno,
+					TicketNo:         task.Ticket,
 					CreatedAt:        CreatedAt,
-					ResolvedAt:       resolvedAt,
+					ResolvedAt:       common.Fixtimefeature(resolution.ResolvedAt),
 				}
 
 				// ดึง photo URLs จาก resolution files
 				var resolutionPhotoURLs []string
-				if resolutionFilePathsJSON != "" && resolutionFilePathsJSON != "[]" {
-					var resolutionFiles []fiber.Map
-					if err := json.Unmarshal([]byte(resolutionFilePathsJSON), &resolutionFiles); err == nil {
-						for _, file := range resolutionFiles {
-							if url, ok := file["url"].(string); ok {
-								resolutionPhotoURLs = append(resolutionPhotoURLs, url)
-							}
-						}
-					}
+				if resolution.FilePaths != nil {
+					resolutionPhotoURLs = getPhotoURLs(*resolution.FilePaths)
 				}
 
 				log.Printf("Attempting to update solution message with ID: %d", solutionMessageID)
@@ -806,8 +755,7 @@ func UpdateTaskHandler(c *fiber.Ctx) error {
 					log.Printf("❌ Failed to update resolution message: %v", err)
 				} else if newSolutionMessageID > 0 {
 					// อัปเดต solution_id ใน telegram_chat
-					_, err = db.DB.Exec(`UPDATE telegram_chat SET solution_id = ? WHERE id = ?`, newSolutionMessageID, telegramID)
-					if err != nil {
+					if err := repos.TelegramChats.SetSolutionMessage(ctx, telegramID, newSolutionMessageID); err != nil {
 						log.Printf("❌ Failed to update solution_id in database: %v", err)
 					} else {
 						log.Printf("✅ Resolution message updated successfully with new ID: %d", newSolutionMessageID)
@@ -821,239 +769,65 @@ func UpdateTaskHandler(c *fiber.Ctx) error {
 		}
 	}
 
-	log.Printf("Task update completed for ID: %s", id)
+	log.Printf("Task update completed for ID: %d", id)
 	return c.JSON(fiber.Map{"success": true})
 }
 
 // DeleteTaskHandler (soft delete)
 // @Summary Delete problem
-// @Description Delete a problem and related data
+// @Description Move a problem to the trash; it can be restored until the retention period ends
 // @Tags problems
 // @Accept json
 // @Produce json
 // @Param id path string true "Problem ID"
 // @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
 // @Router /api/v1/problem/delete/{id} [delete]
 func DeleteTaskHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 	idStr := c.Params("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
 	}
 
-	// Get message_id, file_paths and resolution data before deleting
-	var messageID int
-	var filePathsJSON string
-	var solutionID *int
-	var telegramID int
-	var solutionMessageID int
-	var assigntoID int
-	err = db.DB.QueryRow(`
-		SELECT IFNULL(tc.report_id, 0), IFNULL(t.file_paths, '[]'), t.solution_id, IFNULL(t.telegram_id, 0), IFNULL(tc.assignto_id, 0) FROM tasks t
-		LEFT JOIN telegram_chat tc ON t.telegram_id = tc.id
-		WHERE t.id = ?
-		`, id).Scan(&messageID, &filePathsJSON, &solutionID, &telegramID, &assigntoID)
+	actor, err := utils.AuditActor(c)
 	if err != nil {
-		log.Printf("Failed to get task data: %v", err)
+		return utils.AuditActorError(c, err)
 	}
 
-	// Get solution message ID if exists
-	if telegramID > 0 {
-		db.DB.QueryRow(`SELECT IFNULL(solution_id, 0) FROM telegram_chat WHERE id = ?`, telegramID).Scan(&solutionMessageID)
-	}
-
-	// Delete resolution files from MinIO if they exist
-	if solutionID != nil {
-		var resolutionFilePathsJSON string
-		err = db.DB.QueryRow(`SELECT IFNULL(file_paths, '[]') FROM resolutions WHERE id = ?`, *solutionID).Scan(&resolutionFilePathsJSON)
-		if err == nil && resolutionFilePathsJSON != "" && resolutionFilePathsJSON != "[]" {
-			var resolutionFiles []fiber.Map
-			if err := json.Unmarshal([]byte(resolutionFilePathsJSON), &resolutionFiles); err == nil {
-				for _, fp := range resolutionFiles {
-					if url, ok := fp["url"].(string); ok {
-						if strings.Contains(url, "prefix=") {
-							parts := strings.Split(url, "prefix=")
-							if len(parts) > 1 {
-								objectName := parts[1]
-								common.DeleteImage(objectName)
-								log.Printf("Deleted resolution file: %s", objectName)
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-
-	// Delete task files from MinIO if they exist
-	if filePathsJSON != "" && filePathsJSON != "[]" {
-		var filePaths []fiber.Map
-		if err := json.Unmarshal([]byte(filePathsJSON), &filePaths); err == nil {
-			for _, fp := range filePaths {
-				if url, ok := fp["url"].(string); ok {
-					if strings.Contains(url, "prefix=") {
-						parts := strings.Split(url, "prefix=")
-						if len(parts) > 1 {
-							objectName := parts[1]
-							common.DeleteImage(objectName)
-						}
-					}
-				}
-			}
-		}
-	}
-	log.Printf("Deleting Telegram message ID: %d", assigntoID)
-	if assigntoID > 0 {
-		_, _ = common.DeleteTelegram(assigntoID)
-	}
-	// Delete resolution if exists
-	if solutionID != nil {
-		_, err = db.DB.Exec(`DELETE FROM resolutions WHERE id = ?`, *solutionID)
-		if err != nil {
-			log.Printf("Failed to delete resolution: %v", err)
-		}
-	}
-
-	// Delete telegram_chat
-
-	if telegramID > 0 {
-		_, err = db.DB.Exec(`DELETE FROM telegram_chat WHERE id = ?`, telegramID)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete telegram chat"})
+	// ย้าย task ไปถังขยะ ไฟล์และข้อความ Telegram จะถูกลบเมื่อ purge ถาวร
+	err = repos.InTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Tasks.Delete(ctx, id, actor); err != nil {
+			return err
 		}
-	}
-
-	// Delete progress files from MinIO before deleting progress records
-	progressRows, err := db.DB.Query(`SELECT id, progress_text, file_paths FROM progress WHERE task_id = ?`, id)
+		return common.RecordChange(ctx, tx, id, models.ChangeEntityTask, id, models.ChangeActionDelete, actor)
+	})
 	if err != nil {
-		log.Printf("Failed to get progress data: %v", err)
-	} else {
-		defer progressRows.Close()
-		for progressRows.Next() {
-			var progressID int
-			var progressText string
-			var progressFilePathsJSON sql.NullString
-
-			err := progressRows.Scan(&progressID, &progressText, &progressFilePathsJSON)
-			if err != nil {
-				log.Printf("Error scanning progress row: %v", err)
-				continue
-			}
-
-			// Delete files from progress_text (legacy format)
-			var progressData map[string]any
-			if err := json.Unmarshal([]byte(progressText), &progressData); err == nil {
-				if fileList, ok := progressData["files"].([]any); ok {
-					for _, file := range fileList {
-						if fileMap, ok := file.(map[string]any); ok {
-							if url, ok := fileMap["url"].(string); ok {
-								if strings.Contains(url, "prefix=") {
-									parts := strings.Split(url, "prefix=")
-									if len(parts) > 1 {
-										objectName := parts[1]
-										common.DeleteImage(objectName)
-										log.Printf("Deleted progress file from text: %s", objectName)
-									}
-								}
-							}
-						}
-					}
-				}
-			}
-
-			// Delete files from file_paths column
-			if progressFilePathsJSON.Valid && progressFilePathsJSON.String != "" && progressFilePathsJSON.String != "[]" {
-				// Try to parse as array of objects [{"url": "..."}]
-				var fileObjects []map[string]any
-				if err := json.Unmarshal([]byte(progressFilePathsJSON.String), &fileObjects); err == nil {
-					for _, fileObj := range fileObjects {
-						if url, ok := fileObj["url"].(string); ok {
-							if strings.Contains(url, "prefix=") {
-								parts := strings.Split(url, "prefix=")
-								if len(parts) > 1 {
-									objectName := parts[1]
-									common.DeleteImage(objectName)
-									log.Printf("Deleted progress file from file_paths: %s", objectName)
-								}
-							}
-						}
-					}
-				} else {
-					// Fallback: try to parse as array of strings
-					var fileURLs []string
-					if err := json.Unmarshal([]byte(progressFilePathsJSON.String), &fileURLs); err == nil {
-						for _, url := range fileURLs {
-							if strings.Contains(url, "prefix=") {
-								parts := strings.Split(url, "prefix=")
-								if len(parts) > 1 {
-									objectName := parts[1]
-									common.DeleteImage(objectName)
-									log.Printf("Deleted progress file from file_paths (legacy): %s", objectName)
-								}
-							}
-						}
-					}
-				}
-			}
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
 		}
-	}
-
-	// Now delete progress records
-	_, err = db.DB.Exec(`DELETE FROM progress WHERE task_id = ?`, id)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete progress"})
-	}
-	// Delete task
-	_, err = db.DB.Exec(`DELETE FROM tasks WHERE id = ?`, id)
-	if err != nil {
+		log.Printf("Error deleting task %d: %v", id, err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete task"})
 	}
 
-	// Delete Telegram messages if they exist
-	if messageID > 0 {
-		_, _ = common.DeleteTelegram(messageID)
-	}
-	if solutionMessageID > 0 {
-		_, _ = common.DeleteTelegram(solutionMessageID)
-	}
-
-	log.Printf("Deleted task ID: %d", id)
+	log.Printf("Moved task ID: %d to trash by user ID: %d", id, actor)
 	return c.JSON(fiber.Map{"success": true})
 }
 
 // updateDepartmentScore updates the department score based on problem count
-func updateDepartmentScore(departmentID int) error {
+func updateDepartmentScore(ctx context.Context, r *repository.Repositories, departmentID int) error {
 	now := time.Now()
 	year, month := now.Year(), int(now.Month())
 
-	// 1. Check if record exists for this department/month
-	var exists bool
-	err := db.DB.QueryRow(`
-		SELECT EXISTS(SELECT 1 FROM scores WHERE department_id = ? AND year = ? AND month = ?)
-	`, departmentID, year, month).Scan(&exists)
-	if err != nil {
-		log.Printf("Error checking score record: %v", err)
+	// 1. Make sure the department has a score for this month
+	if err := r.Scores.EnsureMonth(ctx, departmentID, year, month, 100); err != nil {
+		log.Printf("Error creating score record: %v", err)
 		return err
 	}
 
-	// Insert new record if it doesn't exist
-	if !exists {
-		_, err := db.DB.Exec(`
-			INSERT INTO scores (department_id, year, month, score)
-			VALUES (?, ?, ?, 100)
-		`, departmentID, year, month)
-		if err != nil {
-			log.Printf("Error creating score record: %v", err)
-			return err
-		}
-	}
-
 	// 2. Check number of problems in that month
-	var problemCount int
-	err = db.DB.QueryRow(`
-		SELECT COUNT(*) FROM tasks t
-		WHERE t.department_id = ? AND YEAR(t.created_at) = ? AND MONTH(t.created_at) = ?
-	`, departmentID, year, month).Scan(&problemCount)
+	problemCount, err := r.Tasks.CountForDepartmentMonth(ctx, departmentID, year, month)
 	if err != nil {
 		log.Printf("Error counting problems: %v", err)
 		return err
@@ -1063,12 +837,7 @@ func updateDepartmentScore(departmentID int) error {
 
 	// 3. If problem count > 3, deduct score
 	if problemCount > 3 {
-		_, err := db.DB.Exec(`
-			UPDATE scores
-			SET score = GREATEST(score - 1, 0)
-			WHERE department_id = ? AND year = ? AND month = ?
-		`, departmentID, year, month)
-		if err != nil {
+		if err := r.Scores.Deduct(ctx, departmentID, year, month, 1); err != nil {
 			log.Printf("Error updating score: %v", err)
 			return err
 		}
@@ -1089,6 +858,7 @@ func updateDepartmentScore(departmentID int) error {
 // @Success 200 {object} models.PaginatedResponse
 // @Failure 400 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
+// @Deprecated
 // @Router /api/v1/problem/list/{query} [get]
 func GetTasksWithQueryHandler(c *fiber.Ctx) error {
 	query := c.Params("query")
@@ -1128,70 +898,30 @@ func GetTasksWithQueryHandler(c *fiber.Ctx) error {
 	decodedQuery = strings.ReplaceAll(decodedQuery, "/*", "")
 	decodedQuery = strings.ReplaceAll(decodedQuery, "*/", "")
 
-	searchPattern := "%" + decodedQuery + "%"
-
-	// Get total count
-	var total int
-	err = db.DB.QueryRow(`
-		SELECT COUNT(*) FROM tasks t
-		LEFT JOIN issue_types it ON t.issue_type = it.id
-		LEFT JOIN ip_phones p ON t.phone_id = p.id
-		LEFT JOIN departments d ON t.department_id = d.id
-		LEFT JOIN branches b ON d.branch_id = b.id
-		LEFT JOIN systems_program s ON t.system_id = s.id
-		WHERE (t.ticket_no LIKE ? OR p.number LIKE ? OR p.name LIKE ? OR t.phone_else LIKE ? OR d.name LIKE ? OR b.name LIKE ? OR s.name LIKE ? OR t.text LIKE ? OR it.name LIKE ? OR t.status LIKE ?)
-	`, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern).Scan(&total)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count search results"})
-	}
-
-	// Get search results with pagination
-	rows, err := db.DB.Query(`
-		SELECT t.id, IFNULL(t.ticket_no, ''), IFNULL(t.phone_id, 0), IFNULL(t.phone_else, ''), IFNULL(p.number, 0) as number, IFNULL(p.name, ''), t.system_id, IFNULL(s.name, ''), IFNULL(t.issue_type, 0), IFNULL(t.issue_else, ''), IFNULL(it.name, ''), IFNULL(t.department_id, 0), IFNULL(d.name, ''), IFNULL(d.branch_id, 0), IFNULL(b.name, ''), t.text, IFNULL(t.assignto, ''), t.status, t.created_at, t.updated_at
-		FROM tasks t
-		LEFT JOIN ip_phones p ON t.phone_id = p.id
-		LEFT JOIN departments d ON t.department_id = d.id
-		LEFT JOIN branches b ON d.branch_id = b.id
-		LEFT JOIN systems_program s ON t.system_id = s.id
-		LEFT JOIN issue_types it ON t.issue_type = it.id
-		WHERE (t.ticket_no LIKE ? OR p.number LIKE ? OR p.name LIKE ? OR t.phone_else LIKE ? OR d.name LIKE ? OR b.name LIKE ? OR s.name LIKE ? OR t.text LIKE ? OR it.name LIKE ? OR t.assignto LIKE ? OR t.status LIKE ?)
-		ORDER BY t.id DESC
-		LIMIT ? OFFSET ?
-	`, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, pagination.Limit, offset)
+	tasks, total, err := repos.Tasks.List(c.UserContext(), repository.TaskQuery{Search: decodedQuery, Limit: pagination.Limit, Offset: offset})
 	if err != nil {
+		log.Printf("Error searching tasks: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to search tasks"})
 	}
-	defer rows.Close()
 
-	var tasks []models.TaskWithDetails
-	for rows.Next() {
-		var t models.TaskWithDetails
-		var issueTypeName string
-		err := rows.Scan(&t.ID, &t.Ticket, &t.PhoneID, &t.PhoneElse, &t.Number, &t.PhoneName, &t.SystemID, &t.SystemName, &t.IssueTypeID, &t.IssueElse, &issueTypeName, &t.DepartmentID, &t.DepartmentName, &t.BranchID, &t.BranchName, &t.Text, &t.Assignto, &t.Status, &t.CreatedAt, &t.UpdatedAt)
-		if err != nil {
-			log.Printf("Error scanning task: %v", err)
-			continue
-		}
-		if t.SystemID > 0 {
-			t.SystemType = issueTypeName
-		} else {
-			t.SystemType = issueTypeName
-		}
+	log.Printf("Searching tasks with query: %s, found %d results", query, len(tasks))
+	return taskListResponse(c, tasks, total, pagination)
+}
 
-		tasks = append(tasks, t)
+// parseTaskColumnValue ตรวจสอบชื่อคอลัมน์และแปลงค่าตามชนิดของคอลัมน์
+func parseTaskColumnValue(c *fiber.Ctx, column, query string) (interface{}, error) {
+	isInt, ok := repository.TaskFilterColumn(column)
+	if !ok {
+		return nil, c.Status(400).JSON(fiber.Map{"error": "Invalid column name"})
 	}
-
-	log.Printf("Searching tasks with query: %s, found %d results", query, len(tasks))
-	return c.JSON(models.PaginatedResponse{
-		Success: true,
-		Data:    tasks,
-		Pagination: models.PaginationResponse{
-			Page:       pagination.Page,
-			Limit:      pagination.Limit,
-			Total:      total,
-			TotalPages: utils.CalculateTotalPages(total, pagination.Limit),
-		},
-	})
+	if !isInt {
+		return query, nil
+	}
+	intVal, err := strconv.Atoi(query)
+	if err != nil {
+		return nil, c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Invalid value for column %s: must be integer", column)})
+	}
+	return intVal, nil
 }
 
 // @Summary Search problems by column
@@ -1206,6 +936,7 @@ func GetTasksWithQueryHandler(c *fiber.Ctx) error {
 // @Success 200 {object} models.PaginatedResponse
 // @Failure 400 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
+// @Deprecated
 // @Router /api/v1/problem/list/{column}/{query} [get]
 func GetTasksWithColumnQueryHandler(c *fiber.Ctx) error {
 	column := c.Params("column")
@@ -1219,158 +950,18 @@ func GetTasksWithColumnQueryHandler(c *fiber.Ctx) error {
 	pagination := utils.GetPaginationParams(c)
 	offset := utils.CalculateOffset(pagination.Page, pagination.Limit)
 
-	// Define column types and validation
-	intColumns := map[string]bool{
-		"phone_id":      true,
-		"issue_type":    true,
-		"system_id":     true,
-		"department_id": true,
-		"branch_id":     true,
-		"status":        true,
-		"created_by":    true,
-		"updated_by":    true,
-		"telegram_id":   true,
-	}
-
-	stringColumns := map[string]bool{
-		"ticket_no":       true,
-		"phone_else":      true,
-		"number":          true,
-		"phone_name":      true,
-		"system_name":     true,
-		"issue_else":      true,
-		"department_name": true,
-		"branch_name":     true,
-		"text":            true,
-		"assignto":        true,
-		"reported_by":     true,
-		"solution":        true,
-	}
-
-	// Validate column exists
-	if !intColumns[column] && !stringColumns[column] {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid column name"})
-	}
-
-	// Validate query parameter based on column type
-	var queryParam interface{}
-	var err error
-
-	if intColumns[column] {
-		// For integer columns, convert string to int
-		intVal, err := strconv.Atoi(query)
-		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Invalid value for column %s: must be integer", column)})
-		}
-		queryParam = intVal
-	} else {
-		// For string columns, use as is
-		queryParam = query
-	}
-
-	var queryStr string
-	var rows *sql.Rows
-
-	// Declare total variable for pagination
-	var total int
-
-	// Column mapping to prevent SQL injection
-	columnMap := map[string]string{
-		"phone_id":        "t.phone_id",
-		"issue_type":      "t.issue_type",
-		"system_id":       "t.system_id",
-		"department_id":   "t.department_id",
-		"branch_id":       "d.branch_id",
-		"status":          "t.status",
-		"created_by":      "t.created_by",
-		"updated_by":      "t.updated_by",
-		"telegram_id":     "t.telegram_id",
-		"phone_name":      "p.name",
-		"phone_else":      "t.phone_else",
-		"number":          "p.number",
-		"system_name":     "s.name",
-		"department_name": "d.name",
-		"branch_name":     "b.name",
-		"solution":        "t.solution",
-		"reported_by":     "t.reported_by",
-		"ticket_no":       "t.ticket_no",
-		"issue_else":      "t.issue_else",
-		"text":            "t.text",
-		"assignto":        "t.assignto",
-	}
-
-	sqlColumn, exists := columnMap[column]
-	if !exists {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid column name"})
-	}
-
-	baseQuery := `FROM tasks t
-		LEFT JOIN ip_phones p ON t.phone_id = p.id
-		LEFT JOIN departments d ON t.department_id = d.id
-		LEFT JOIN branches b ON d.branch_id = b.id
-		LEFT JOIN systems_program s ON t.system_id = s.id
-		LEFT JOIN issue_types it ON t.issue_type = it.id`
-
-	selectFields := `t.id, IFNULL(t.ticket_no, ''), IFNULL(t.phone_id, 0), IFNULL(t.phone_else, ''), IFNULL(p.number, 0), IFNULL(p.name, ''), 
-		t.system_id, IFNULL(s.name, ''), IFNULL(t.issue_type, 0), IFNULL(t.issue_else, ''), 
-		IFNULL(it.name, ''), IFNULL(t.department_id, 0), IFNULL(d.name, ''), IFNULL(d.branch_id, 0), 
-		IFNULL(b.name, ''), IFNULL(t.reported_by, ''), t.text, IFNULL(t.assignto, ''), t.status, t.created_at, t.updated_at, IFNULL(t.file_paths, '[]')`
-
-	// Build query based on column type
-	if intColumns[column] {
-		// Get total count for pagination
-		countQuery := fmt.Sprintf("SELECT COUNT(*) %s WHERE %s = ?", baseQuery, sqlColumn)
-		db.DB.QueryRow(countQuery, queryParam).Scan(&total)
-
-		queryStr = fmt.Sprintf("SELECT %s %s WHERE %s = ? ORDER BY t.id DESC LIMIT ? OFFSET ?",
-			selectFields, baseQuery, sqlColumn)
-		rows, err = db.DB.Query(queryStr, queryParam, pagination.Limit, offset)
-	} else {
-		// For string columns, use LIKE search
-		searchPattern := "%" + query + "%"
-
-		// Get total count for pagination
-		countQuery := fmt.Sprintf("SELECT COUNT(*) %s WHERE %s LIKE ?", baseQuery, sqlColumn)
-		db.DB.QueryRow(countQuery, searchPattern).Scan(&total)
-
-		queryStr = fmt.Sprintf("SELECT %s %s WHERE %s LIKE ? ORDER BY t.id DESC LIMIT ? OFFSET ?",
-			selectFields, baseQuery, sqlColumn)
-		rows, err = db.DB.Query(queryStr, searchPattern, pagination.Limit, offset)
+	value, errResp := parseTaskColumnValue(c, column, query)
+	if value == nil {
+		return errResp
 	}
 
+	tasks, total, err := repos.Tasks.List(c.UserContext(), repository.TaskQuery{Column: column, Value: value, Limit: pagination.Limit, Offset: offset})
 	if err != nil {
 		log.Printf("Query error: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query tasks"})
 	}
-	defer rows.Close()
-
-	var tasks []models.TaskWithDetails
-	for rows.Next() {
-		var t models.TaskWithDetails
-		var issueTypeName string
-		var filePathsJSON string
-		err := rows.Scan(&t.ID, &t.Ticket, &t.PhoneID, &t.PhoneElse, &t.Number, &t.PhoneName, &t.SystemID, &t.SystemName,
-			&t.IssueTypeID, &t.IssueElse, &issueTypeName, &t.DepartmentID, &t.DepartmentName,
-			&t.BranchID, &t.BranchName, &t.ReportedBy, &t.Text, &t.Assignto, &t.Status, &t.CreatedAt, &t.UpdatedAt, &filePathsJSON)
-		if err != nil {
-			log.Printf("Error scanning task: %v", err)
-			continue
-		}
-		t.FilePaths = parseFilePaths(filePathsJSON)
-		t.SystemType = issueTypeName
-		tasks = append(tasks, t)
-	}
 
-	return c.JSON(models.PaginatedResponse{
-		Success: true,
-		Data:    tasks,
-		Pagination: models.PaginationResponse{
-			Page:       pagination.Page,
-			Limit:      pagination.Limit,
-			Total:      total,
-			TotalPages: utils.CalculateTotalPages(total, pagination.Limit),
-		},
-	})
+	return taskListResponse(c, tasks, total, pagination)
 }
 
 // @Summary Update assigned person
@@ -1385,10 +976,15 @@ func GetTasksWithColumnQueryHandler(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/problem/update/assignto/{id} [put]
 func UpdateAssignedTo(c *fiber.Ctx) error {
-	id := c.Params("id")
-	if id == "" {
+	ctx := c.UserContext()
+	idStr := c.Params("id")
+	if idStr == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "Task ID is required"})
 	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
 	var Urlenv string
 	env := config.AppConfig.Environment
 
@@ -1398,133 +994,102 @@ func UpdateAssignedTo(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	var messageID int
-	var status int
+	actor, err := utils.AuditActor(c, &req.UpdatedBy)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+	req.UpdatedBy = actor
 
-	err := db.DB.QueryRow(`
-		SELECT IFNULL(tc.assignto_id, 0) as assignto_id, IFNULL(status, 0)
-		FROM tasks t
-		LEFT JOIN telegram_chat tc ON t.telegram_id = tc.id
-		WHERE t.id = ?
-		`, id).Scan(&messageID, &status)
+	current, err := repos.Tasks.Get(ctx, id)
 	if err != nil {
 		log.Printf("Failed to get task data: %v", err)
+		return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
 	}
 
-	if messageID > 0 {
-		_, _ = common.DeleteTelegram(messageID)
-	}
-	if status == 2 {
-		_, err = db.DB.Exec(`UPDATE tasks SET assignto_id = ?, assignto = ?, status = 2, updated_by = ?, updated_at = NOW() WHERE id = ?`, req.AssignedtoID, req.Assignto, req.UpdatedBy, id)
-		if err != nil {
-			log.Printf("Database error: %v", err)
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update assigned person"})
+	err = repos.InTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Tasks.Assign(ctx, id, repository.TaskAssignment{
+			AssignedtoID: req.AssignedtoID,
+			Assignto:     req.Assignto,
+			UpdatedBy:    req.UpdatedBy,
+		}); err != nil {
+			return err
 		}
-	} else {
-		_, err = db.DB.Exec(`UPDATE tasks SET assignto_id = ?, assignto = ?, status = 1, updated_by = ?, updated_at = NOW() WHERE id = ?`, req.AssignedtoID, req.Assignto, req.UpdatedBy, id)
-		if err != nil {
-			log.Printf("Database error: %v", err)
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update assigned person"})
+		if err := common.RecordTaskChanges(ctx, tx, current, req.UpdatedBy); err != nil {
+			return err
+		}
+		// งานที่ปิดแล้วหรือพักไว้คงสถานะเดิม งานที่ยังไม่เริ่มถือว่ากำลังดำเนินการ
+		if current.Status == models.TaskStatusPending || current.Status == models.TaskStatusReopened {
+			_, err := common.TransitionTask(ctx, tx, id, current.Status, models.TaskStatusInProgress, req.UpdatedBy, "assigned to "+req.Assignto)
+			return err
 		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		if errors.Is(err, models.ErrVersionConflict) {
+			return taskConflict(c, id)
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update assigned person"})
+	}
+
+	// ลบข้อความแจ้งผู้รับผิดชอบเดิมหลัง commit แล้วเท่านั้น
+	if current.AssigneeMessageID > 0 {
+		_, _ = common.DeleteTelegram(current.AssigneeMessageID)
 	}
 
 	// เฉพาะกรณีที่ต้องการอัพเดต Telegram
 	if req.UpdateTelegram {
 
 		// ดึงข้อมูล task สำหรับอัพเดต Telegram
-		var ticket, text, issueElse, reportedBy, assignto, createdAt, updatedAt, branchName, departmentName, programName string
-		var phoneID, systemID, departmentID, status, messageID, phoneNumber, branchID, telegramID int
-		var filePathsJSON string
-		var telegramUser string
-		// Fix SQL: JOINs before WHERE, select tc.report_id as messageID
-		err := db.DB.QueryRow(`
-			SELECT IFNULL(t.ticket_no, ''), IFNULL(t.phone_id, 0), IFNULL(t.system_id, 0), IFNULL(t.issue_else, ''), IFNULL(t.department_id, 0),
-			IFNULL(t.text, ''), IFNULL(t.status, 0), IFNULL(t.reported_by, ''), IFNULL(t.assignto, ''), IFNULL(rs.telegram_username, ''), 
-			IFNULL(tc.report_id, 0), IFNULL(t.file_paths, '[]'), IFNULL(d.branch_id, 0), IFNULL(t.created_at, ''), IFNULL(t.updated_at, ''),
-			IFNULL(t.telegram_id, 0)
-			FROM tasks t
-			LEFT JOIN telegram_chat tc ON t.telegram_id = tc.id
-			LEFT JOIN departments d ON t.department_id = d.id
-			LEFT JOIN branches b ON d.branch_id = b.id
-			LEFT JOIN systems_program s ON t.system_id = s.id
-			LEFT JOIN responsibilities rs ON t.assignto_id = rs.id
-			WHERE t.id = ?
-		`, id).Scan(&ticket, &phoneID, &systemID, &issueElse, &departmentID, &text, &status, &reportedBy, &assignto, &telegramUser, &messageID, &filePathsJSON, &branchID, &createdAt, &updatedAt, &telegramID)
-		log.Printf("Fetched task for Telegram update, ID: %s, MessageID: %d", telegramUser, messageID)
-		// Query extra info for Telegram
-		db.DB.QueryRow(`SELECT name FROM branches WHERE id = ?`, branchID).Scan(&branchName)
-		db.DB.QueryRow(`SELECT name FROM departments WHERE id = ?`, departmentID).Scan(&departmentName)
-		db.DB.QueryRow(`SELECT name FROM systems_program WHERE id = ?`, systemID).Scan(&programName)
-		db.DB.QueryRow(`SELECT number FROM ip_phones WHERE id = ?`, phoneID).Scan(&phoneNumber)
-
-		if env == "dev" {
-			Urlenv = "http://helpdesk-dev.nopadol.com/tasks/show/" + id
-		} else {
-			Urlenv = "http://helpdesk.nopadol.com/tasks/show/" + id
-		}
+		task, err := repos.Tasks.Get(ctx, id)
+		if err == nil {
+			log.Printf("Fetched task for Telegram update, ID: %s, MessageID: %d", task.AssigneeTelegram, task.ReportMessageID)
 
-		CreatedAt := common.Fixtimefeature(createdAt)
-		UpdatedAt := common.Fixtimefeature(updatedAt)
+			phoneID := 0
+			if task.PhoneID != nil {
+				phoneID = *task.PhoneID
+			}
+			phoneNumber, departmentName, branchName, programName := getTelegramData(ctx, task.PhoneID, task.SystemID, task.DepartmentID)
 
-		if err == nil {
-			// Parse file_paths JSON
-			var photoURLs []string
-			if filePathsJSON != "" && filePathsJSON != "[]" {
-				var filePaths []fiber.Map
-				if err := json.Unmarshal([]byte(filePathsJSON), &filePaths); err == nil && len(filePaths) > 0 {
-					for _, file := range filePaths {
-						if url, ok := file["url"].(string); ok {
-							photoURLs = append(photoURLs, url)
-						}
-					}
-				}
+			if env == "dev" {
+				Urlenv = "http://helpdesk-dev.nopadol.com/tasks/show/" + idStr
+			} else {
+				Urlenv = "http://helpdesk.nopadol.com/tasks/show/" + idStr
 			}
+
 			var phoneElsePtr *string
 			if phoneID == 0 {
-				var phoneElse sql.NullString
-				db.DB.QueryRow(`SELECT IFNULL(phone_else, '') FROM tasks WHERE id = ?`, id).Scan(&phoneElse)
-				if phoneElse.Valid {
-					phoneElsePtr = &phoneElse.String
-				}
+				phoneElsePtr = task.PhoneElse
 			}
 
 			telegramReq := models.TaskRequest{
 				PhoneID:        &phoneID,
 				PhoneElse:      phoneElsePtr,
-				SystemID:       systemID,
-				IssueElse:      issueElse,
-				DepartmentID:   departmentID,
-				Text:           text,
-				Status:         status,
-				ReportedBy:     reportedBy,
-				Assignto:       assignto,
-				TelegramUser:   telegramUser,
-				MessageID:      messageID,
-				Ticket:         ticket,
+				SystemID:       task.SystemID,
+				IssueElse:      task.IssueElse,
+				DepartmentID:   task.DepartmentID,
+				Text:           task.Text,
+				Status:         task.Status,
+				ReportedBy:     task.ReportedBy,
+				Assignto:       task.Assignto,
+				TelegramUser:   task.AssigneeTelegram,
+				MessageID:      task.ReportMessageID,
+				Ticket:         task.Ticket,
 				BranchName:     branchName,
 				DepartmentName: departmentName,
 				PhoneNumber:    phoneNumber,
 				ProgramName:    programName,
 				Url:            Urlenv,
-				CreatedAt:      CreatedAt,
-				UpdatedAt:      UpdatedAt,
+				CreatedAt:      common.Fixtimefeature(task.CreatedAt),
+				UpdatedAt:      common.Fixtimefeature(task.UpdatedAt),
 			}
-			if len(photoURLs) > 0 {
-				assigntoID, _ := common.UpdateTelegram(telegramReq, photoURLs...)
-				_, err = db.DB.Exec(`UPDATE telegram_chat SET assignto_id = ? WHERE id = ?`, assigntoID, id)
-				if err != nil {
-					log.Printf("Database error: %v", err)
-					return c.Status(500).JSON(fiber.Map{"error": "Failed to update telegram chat"})
-				}
-			} else {
-				assigntoID, _ := common.UpdateTelegram(telegramReq)
-				_, err = db.DB.Exec(`UPDATE telegram_chat SET assignto_id = ? WHERE id = ?`, assigntoID, id)
-				if err != nil {
-					log.Printf("Database error: %v", err)
-					return c.Status(500).JSON(fiber.Map{"error": "Failed to update telegram chat"})
-				}
+			assigntoID, _ := common.UpdateTelegram(telegramReq, getPhotoURLs(task.FilePaths)...)
+			if err := repos.TelegramChats.SetAssigneeMessage(ctx, task.TelegramID, assigntoID); err != nil {
+				log.Printf("Database error: %v", err)
+				return c.Status(500).JSON(fiber.Map{"error": "Failed to update telegram chat"})
 			}
-
+		} else {
+			log.Printf("Failed to get task for Telegram update: %v", err)
 		}
 
 	}
@@ -1532,6 +1097,8 @@ func UpdateAssignedTo(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"success": true, "message": "Assigned person updated successfully"})
 }
 
+// GetTaskSort lists every task with the rows matching column first.
+// Deprecated: use GET /api/v1/problems with sort instead.
 func GetTaskSort(c *fiber.Ctx) error {
 	column := c.Params("column")
 	query := c.Params("query")
@@ -1543,123 +1110,17 @@ func GetTaskSort(c *fiber.Ctx) error {
 	pagination := utils.GetPaginationParams(c)
 	offset := utils.CalculateOffset(pagination.Page, pagination.Limit)
 
-	intColumns := map[string]bool{
-		"phone_id": true, "issue_type": true, "system_id": true, "department_id": true,
-		"branch_id": true, "status": true, "created_by": true, "updated_by": true, "telegram_id": true,
+	value, errResp := parseTaskColumnValue(c, column, query)
+	if value == nil {
+		return errResp
 	}
 
-	stringColumns := map[string]bool{
-		"ticket_no": true, "number": true, "phone_else": true, "phone_name": true, "system_name": true,
-		"issue_else": true, "department_name": true, "branch_name": true, "text": true,
-		"assignto": true, "reported_by": true, "solution": true,
-	}
-
-	if !intColumns[column] && !stringColumns[column] {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid column name"})
-	}
-
-	var queryParam interface{}
-	if intColumns[column] {
-		intVal, err := strconv.Atoi(query)
-		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Invalid value for column %s: must be integer", column)})
-		}
-		queryParam = intVal
-	} else {
-		queryParam = query
-	}
-
-	columnMap := map[string]string{
-		"phone_id": "t.phone_id", "phone_else": "t.phone_else", "issue_type": "t.issue_type", "system_id": "t.system_id",
-		"department_id": "t.department_id", "branch_id": "d.branch_id", "status": "t.status",
-		"created_by": "t.created_by", "updated_by": "t.updated_by", "telegram_id": "t.telegram_id",
-		"phone_name": "p.name", "number": "p.number", "system_name": "s.name",
-		"department_name": "d.name", "branch_name": "b.name", "solution": "t.solution",
-		"reported_by": "t.reported_by", "ticket_no": "t.ticket_no", "issue_else": "t.issue_else",
-		"text": "t.text", "assignto": "t.assignto",
-	}
-
-	sqlColumn, exists := columnMap[column]
-	if !exists {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid column name"})
-	}
-
-	baseQuery := `FROM tasks t
-		LEFT JOIN ip_phones p ON t.phone_id = p.id
-		LEFT JOIN departments d ON t.department_id = d.id
-		LEFT JOIN branches b ON d.branch_id = b.id
-		LEFT JOIN systems_program s ON t.system_id = s.id
-		LEFT JOIN issue_types it ON t.issue_type = it.id`
-
-	selectFields := `t.id, IFNULL(t.ticket_no, ''), IFNULL(t.phone_id, 0), IFNULL(t.phone_else, ''), IFNULL(p.number, 0), IFNULL(p.name, ''),
-		t.system_id, IFNULL(s.name, ''), IFNULL(t.issue_type, 0), IFNULL(t.issue_else, ''),
-		IFNULL(it.name, ''), IFNULL(t.department_id, 0), IFNULL(d.name, ''), IFNULL(d.branch_id, 0),
-		IFNULL(b.name, ''), t.text, IFNULL(t.assignto_id, 0), IFNULL(t.assignto, ''), IFNULL(t.reported_by, ''), t.status, t.created_at, t.updated_at, IFNULL(t.file_paths, '[]')`
-
-	var total int
-	db.DB.QueryRow(fmt.Sprintf("SELECT COUNT(*) %s", baseQuery)).Scan(&total)
-
-	var orderBy string
-	var rows *sql.Rows
-	var err error
-
-	if column == "status" {
-		// Custom ordering for status: selected status first, then others
-		statusVal := queryParam.(int)
-		switch statusVal {
-		case 0:
-			orderBy = "ORDER BY CASE WHEN t.status = 0 THEN 0 WHEN t.status = 1 THEN 1 WHEN t.status = 2 THEN 2 ELSE 3 END, t.id DESC"
-		case 1:
-			orderBy = "ORDER BY CASE WHEN t.status = 1 THEN 0 WHEN t.status = 0 THEN 1 WHEN t.status = 2 THEN 2 ELSE 3 END, t.id DESC"
-		case 2:
-			orderBy = "ORDER BY CASE WHEN t.status = 2 THEN 0 WHEN t.status = 0 THEN 1 WHEN t.status = 1 THEN 2 ELSE 3 END, t.id DESC"
-		default:
-			orderBy = "ORDER BY t.status, t.id DESC"
-		}
-		queryStr := fmt.Sprintf("SELECT %s %s %s LIMIT ? OFFSET ?", selectFields, baseQuery, orderBy)
-		rows, err = db.DB.Query(queryStr, pagination.Limit, offset)
-	} else {
-		orderBy = fmt.Sprintf("ORDER BY CASE WHEN %s = ? THEN 0 ELSE 1 END, t.id DESC", sqlColumn)
-		queryStr := fmt.Sprintf("SELECT %s %s %s LIMIT ? OFFSET ?", selectFields, baseQuery, orderBy)
-		rows, err = db.DB.Query(queryStr, queryParam, pagination.Limit, offset)
-	}
+	// แสดงรายการที่ตรงกับค่าที่เลือกก่อน แล้วตามด้วยรายการอื่นทั้งหมด
+	tasks, total, err := repos.Tasks.List(c.UserContext(), repository.TaskQuery{PreferColumn: column, PreferValue: value, Limit: pagination.Limit, Offset: offset})
 	if err != nil {
+		log.Printf("Query error: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to query tasks"})
 	}
-	defer rows.Close()
-
-	var tasks []models.TaskWithDetails
-	for rows.Next() {
-		var t models.TaskWithDetails
-		var issueTypeName string
-		var filePathsJSON string
-		err = rows.Scan(&t.ID, &t.Ticket, &t.PhoneID, &t.PhoneElse, &t.Number, &t.PhoneName, &t.SystemID, &t.SystemName, &t.IssueTypeID, &t.IssueElse, &issueTypeName, &t.DepartmentID, &t.DepartmentName, &t.BranchID, &t.BranchName, &t.Text, &t.AssignedtoID, &t.Assignto, &t.ReportedBy, &t.Status, &t.CreatedAt, &t.UpdatedAt, &filePathsJSON)
-		if err != nil {
-			log.Printf("Error scanning task: %v", err)
-			continue
-		}
-
-		// Set SystemType based on SystemID
-		if t.SystemID > 0 {
-			t.SystemType = issueTypeName
-		} else {
-			t.SystemType = issueTypeName
-		}
-
-		// Parse file_paths JSON
-		t.FilePaths = parseFilePaths(filePathsJSON)
-
-		tasks = append(tasks, t)
-	}
 
-	return c.JSON(models.PaginatedResponse{
-		Success: true,
-		Data:    tasks,
-		Pagination: models.PaginationResponse{
-			Page:       pagination.Page,
-			Limit:      pagination.Limit,
-			Total:      total,
-			TotalPages: utils.CalculateTotalPages(total, pagination.Limit),
-		},
-	})
+	return taskListResponse(c, tasks, total, pagination)
 }
@@ -0,0 +1,12 @@
+package handlers
+
+import "reports-api/repository"
+
+// repos holds the data access used by the handlers; main wires the MySQL implementation
+// and tests can substitute fakes through SetRepositories
+var repos *repository.Repositories
+
+// SetRepositories sets the repositories the handlers read and write through
+func SetRepositories(r *repository.Repositories) {
+	repos = r
+}
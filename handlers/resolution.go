@@ -1,14 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"mime/multipart"
 	"reports-api/config"
-	"reports-api/db"
 	"reports-api/handlers/common"
 	"reports-api/models"
+	"reports-api/repository"
+	"reports-api/utils"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -23,57 +27,79 @@ import (
 // @Produce json
 // @Param id path string true "Task ID"
 // @Success 200 {object} map[string]interface{}
+// @Header 200 {string} ETag "Version of the resolution"
 // @Failure 404 {object} map[string]interface{}
 // @Router /api/v1/resolution/{id} [get]
 func GetResolutionHandler(c *fiber.Ctx) error {
-	id := c.Params("id")
-
-	var solution, filePaths string
-	var telegramID int
-	var SolutionID int
-
-	err := db.DB.QueryRow(`
-		SELECT IFNULL(t.solution_id, 0) as solution_id
-		FROM tasks t
-		WHERE t.id = ?
-	`, id).Scan(&SolutionID)
+	ctx := c.UserContext()
+	id, _ := strconv.Atoi(c.Params("id"))
 
+	task, err := repos.Tasks.Get(ctx, id)
 	if err != nil {
 		log.Printf("Failed to retrieve resolution: %v", err)
 		return c.Status(404).JSON(fiber.Map{"error": "Resolution not found SolutionID"})
 	}
 
-	err = db.DB.QueryRow(`
-		SELECT IFNULL(r.text, '') as text, IFNULL(r.telegram_id, 0) as telegram_id, 
-		IFNULL(r.file_paths, '[]') as file_paths
-		FROM resolutions r
-		WHERE r.id = ?
-	`, SolutionID).Scan(&solution, &telegramID, &filePaths)
-
+	solutionID := 0
+	if task.SolutionID != nil {
+		solutionID = *task.SolutionID
+	}
+	resolution, err := repos.Resolutions.Get(ctx, solutionID)
 	if err != nil {
 		log.Printf("Failed to retrieve resolution: %v", err)
 		return c.Status(404).JSON(fiber.Map{"error": "Resolution not found"})
 	}
 
+	utils.SetETag(c, resolution.Version)
+	return c.JSON(fiber.Map{"success": true, "data": resolutionResponse(resolution)})
+}
+
+// resolutionResponse builds the response of a resolution
+func resolutionResponse(resolution *models.Resolution) fiber.Map {
 	fileMap := make(map[string]string)
-	if filePaths != "" && filePaths != "[]" {
-		var filePathsArray []fiber.Map
-		if err := json.Unmarshal([]byte(filePaths), &filePathsArray); err == nil {
-			for i, fp := range filePathsArray {
-				if url, ok := fp["url"].(string); ok {
-					fileMap[fmt.Sprintf("image_%d", i)] = url
-				}
-			}
+	if resolution.FilePaths != nil {
+		for i, url := range getPhotoURLs(*resolution.FilePaths) {
+			fileMap[fmt.Sprintf("image_%d", i)] = url
 		}
 	}
 
-	response := fiber.Map{
-		"solution":    solution,
-		"telegram_id": telegramID,
+	return fiber.Map{
+		"solution":    resolution.Text,
+		"telegram_id": resolution.TelegramID,
 		"file_paths":  fileMap,
+		"version":     resolution.Version,
 	}
+}
 
-	return c.JSON(fiber.Map{"success": true, "data": response})
+// resolutionTaskURL สร้างลิงก์หน้า task สำหรับข้อความ Telegram
+func resolutionTaskURL(taskID int) string {
+	if config.AppConfig.Environment == "dev" {
+		return "http://helpdesk-dev.nopadol.com/tasks/show/" + strconv.Itoa(taskID)
+	}
+	return "http://helpdesk.nopadol.com/tasks/show/" + strconv.Itoa(taskID)
+}
+
+// resolutionTelegramRequest เตรียมข้อมูล TaskRequest สำหรับอัปเดตข้อความหลักของ task ใน Telegram
+func resolutionTelegramRequest(ctx context.Context, task *models.TaskRecord, status models.TaskStatus) models.TaskRequest {
+	phoneNumber, departmentName, branchName, programName := getTelegramData(ctx, task.PhoneID, task.SystemID, task.DepartmentID)
+	return models.TaskRequest{
+		PhoneID:        task.PhoneID,
+		PhoneElse:      task.PhoneElse,
+		SystemID:       task.SystemID,
+		DepartmentID:   task.DepartmentID,
+		Text:           task.Text,
+		MessageID:      task.ReportMessageID,
+		Ticket:         task.Ticket,
+		Assignto:       task.Assignto,
+		ReportedBy:     task.ReportedBy,
+		CreatedAt:      common.Fixtimefeature(task.CreatedAt),
+		Status:         status,
+		Url:            resolutionTaskURL(task.ID),
+		PhoneNumber:    phoneNumber,
+		DepartmentName: departmentName,
+		BranchName:     branchName,
+		ProgramName:    programName,
+	}
 }
 
 // @Summary Create resolution for task
@@ -88,20 +114,17 @@ func GetResolutionHandler(c *fiber.Ctx) error {
 // @Failure 400 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
+// @Param Idempotency-Key header string false "Replays the first response when the request is retried"
+// @Failure 422 {object} map[string]interface{} "Idempotency-Key reused for a different request"
 // @Router /api/v1/resolution/create/{id} [post]
 func CreateResolutionHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 	id := c.Params("id")
+	taskID, _ := strconv.Atoi(id)
 
 	var req models.ResolutionReq
 	var uploadedFiles []fiber.Map
 
-	// ดึงข้อมูล task ก่อน
-	var ticketno string
-	var assignto string
-	var reportedby string
-	var telegramID int
-	var AssignedtoID int
-
 	if solutionByStr := c.FormValue("solution"); solutionByStr != "" {
 		req.Solution = solutionByStr
 	}
@@ -111,41 +134,20 @@ func CreateResolutionHandler(c *fiber.Ctx) error {
 	if assignedtoIDStr := c.FormValue("assignedto_id"); assignedtoIDStr != "" {
 		req.AssignedtoID, _ = strconv.Atoi(assignedtoIDStr)
 	}
-	var createdAtStr string
-	err := db.DB.QueryRow(`
-			SELECT ticket_no, IFNULL(assignto_id, 0),IFNULL(assignto, '') AS assignto, IFNULL(reported_by, '') AS reported_by, IFNULL(telegram_id, 0), IFNULL(created_at, '')
-			FROM tasks
-			WHERE id = ?
-		`, id).Scan(&ticketno, &AssignedtoID, &assignto, &reportedby, &telegramID, &createdAtStr)
+
+	// ดึงข้อมูล task ก่อน
+	task, err := repos.Tasks.Get(ctx, taskID)
 	if err != nil {
 		log.Printf("Failed to retrieve task data for task ID %s: %v", id, err)
 		return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
 	}
 
-	if req.AssignedtoID == 0 && assignto != "" {
-		req.AssignedtoID = AssignedtoID
-		req.Assignto = assignto
-	}
-
-	if req.Assignto != "" || req.AssignedtoID != 0 {
-		_, err := db.DB.Exec(`UPDATE tasks SET assignto_id = ?, assignto = ? WHERE id = ?`, req.AssignedtoID, req.Assignto, id)
-		if err != nil {
-			log.Printf("Failed to update task assignto: %v", err)
-		}
+	if req.AssignedtoID == 0 && task.Assignto != "" {
+		req.AssignedtoID = task.AssignedtoID
+		req.Assignto = task.Assignto
 	}
 
-	var reportID int
-	err = db.DB.QueryRow(`
-			SELECT report_id
-			FROM telegram_chat
-			WHERE id = ?
-		`, telegramID).Scan(&reportID)
-
-	if err != nil {
-		log.Printf("Failed to retrieve report ID: %v", err)
-	}
-
-	CreatedAt := common.Fixtimefeature(createdAtStr)
+	CreatedAt := common.Fixtimefeature(task.CreatedAt)
 
 	// ลองแยกการ parse ข้อมูล
 	form, err := c.MultipartForm()
@@ -165,163 +167,115 @@ func CreateResolutionHandler(c *fiber.Ctx) error {
 		}
 
 		if len(allFiles) > 0 {
-			uploadedFiles, _ = common.HandleFileUploadsResolution(allFiles, ticketno)
+			uploadedFiles, _ = common.HandleFileUploadsResolution(allFiles, task.Ticket)
 		}
 	}
 
-	// ตรวจสอบว่ามี solution text หรือไฟล์รูป อย่างน้อยอย่างใดอย่างหนึ่ง
-
 	// เตรียม file paths JSON
-	var filePathsJSON interface{}
+	var filePaths *string
 	if len(uploadedFiles) > 0 {
-
 		filePathsBytes, _ := json.Marshal(uploadedFiles)
-		filePathsJSON = string(filePathsBytes)
-	} else {
-		filePathsJSON = nil
+		filePathsJSON := string(filePathsBytes)
+		filePaths = &filePathsJSON
 	}
 
-	// บันทึก resolution ลงฐานข้อมูล
-	res, err := db.DB.Exec(`INSERT INTO resolutions (tasks_id, text, telegram_id, file_paths) VALUES (?, ?, ?, ?)`, id, req.Solution, telegramID, filePathsJSON)
+	actor, err := utils.AuditActor(c)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert resolution"})
+		return utils.AuditActorError(c, err)
 	}
 
-	resolutionID, _ := res.LastInsertId()
+	// บันทึก resolution, ผู้รับผิดชอบ และปิด task ใน transaction เดียวกัน
+	// task ลูกและ task ซ้ำที่ตั้งให้สืบทอดจะถูกปิดด้วยข้อความ resolution เดียวกัน
+	resolvedAt := time.Now() // Fallback to current time
+	var followers []int
+	err = repos.InTx(ctx, func(tx *repository.Repositories) error {
+		if req.Assignto != "" || req.AssignedtoID != 0 {
+			if err := tx.Tasks.Assign(ctx, taskID, repository.TaskAssignment{AssignedtoID: req.AssignedtoID, Assignto: req.Assignto}); err != nil {
+				return fmt.Errorf("failed to update task assignto: %w", err)
+			}
+		}
 
-	// อัพเดต solution_id ใน tasks
-	_, err = db.DB.Exec(`UPDATE tasks SET solution_id = ?, status = 2, resolved_at=CURRENT_TIMESTAMP WHERE id = ?`, resolutionID, id)
-	if err != nil {
-		log.Printf("Failed to update solution_id in tasks: %q", err)
-	}
+		if err := common.RecordTaskChanges(ctx, tx, task, actor); err != nil {
+			return err
+		}
 
-	// ส่ง solution ไปยัง Telegram ถ้ามี reportID
-	// ดึง resolved_at จากฐานข้อมูล resolutions
-	resolvedAt, err := common.GetResolvedAtSafely(db.DB, int(resolutionID))
+		resolutionID, err := tx.Resolutions.Create(ctx, taskID, req.Solution, task.TelegramID, filePaths)
+		if err != nil {
+			return err
+		}
+		if err := common.RecordChange(ctx, tx, taskID, models.ChangeEntityResolution, int(resolutionID), models.ChangeActionCreate, actor,
+			common.DiffEntry("", req.Solution, nil, filePaths)...); err != nil {
+			return err
+		}
+
+		// อัพเดต solution_id ใน tasks และปิดงาน
+		if err := tx.Tasks.Resolve(ctx, taskID, resolutionID); err != nil {
+			return fmt.Errorf("failed to update solution_id in tasks: %w", err)
+		}
+		if _, err := common.TransitionTask(ctx, tx, taskID, task.Status, models.TaskStatusDone, actor, "resolution added"); err != nil {
+			return err
+		}
+		if followers, err = common.ResolveFollowers(ctx, tx, taskID, req.Solution, actor); err != nil {
+			return err
+		}
+
+		// ดึง resolved_at จากฐานข้อมูล resolutions
+		if resolution, err := tx.Resolutions.Get(ctx, int(resolutionID)); err != nil {
+			log.Printf("Failed to get resolved_at: %v", err)
+		} else if parsed, err := time.Parse("2006-01-02 15:04:05", resolution.ResolvedAt); err == nil {
+			resolvedAt = parsed
+		}
+		return nil
+	})
 	if err != nil {
-		log.Printf("Failed to get resolved_at: %v", err)
-		resolvedAt = time.Now() // Fallback to current time
-	}
-	var Urlenv string
-	env := config.AppConfig.Environment
-	if env == "dev" {
-		Urlenv = "http://helpdesk-dev.nopadol.com/tasks/show/" + id
-	} else {
-		Urlenv = "http://helpdesk.nopadol.com/tasks/show/" + id
+		log.Printf("Failed to resolve task %d: %v", taskID, err)
+		if filePaths != nil {
+			deleteUploadedFiles(*filePaths)
+		}
+		if errors.Is(err, models.ErrInvalidStatusTransition) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
+		if errors.Is(err, models.ErrVersionConflict) {
+			return taskConflict(c, taskID)
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert resolution"})
 	}
+	queueTaskTelegram(false, followers...)
 
 	// เตรียมข้อมูล response
-	req.TicketNo = ticketno
+	req.TicketNo = task.Ticket
 	req.CreatedAt = CreatedAt
-	req.Url = Urlenv
+	req.Url = resolutionTaskURL(taskID)
 	req.ResolvedAt = resolvedAt.Add(7 * time.Hour).Format("2006/01/02/ 15:04:05")
 
-	var assignmsgID int
 	// ส่ง solution ไปยัง Telegram ถ้ามี reportID
-	if reportID > 0 {
-		// ดึง MessageID จาก telegram_chat
-		err = db.DB.QueryRow(`SELECT IFNULL(report_id, 0), IFNULL(assignto_id, 0) FROM telegram_chat WHERE id = ?`, telegramID).Scan(&req.MessageID, &assignmsgID)
-		if err != nil {
-			log.Printf("Failed to get message ID: %v", err)
-		}
-
-		// ดึงข้อมูลเพิ่มเติมสำหรับ UpdateTelegram
-		var phoneNumber int
-		var departmentName, branchName, programName string
-		var phoneID *int
-		var phoneElse *string
-		var systemID, departmentID int
-		var text string
-		var telegramUser string
-
-		err = db.DB.QueryRow(`
-			SELECT phone_id, IFNULL(phone_else, ''), system_id, department_id, text
-			FROM tasks WHERE id = ?
-		`, id).Scan(&phoneID, &phoneElse, &systemID, &departmentID, &text)
-		if err != nil {
-			log.Printf("Failed to get task details: %v", err)
-		}
-		if phoneID != nil && *phoneID > 0 {
-			db.DB.QueryRow(`
-				SELECT p.number, d.name, b.name 
-				FROM ip_phones p 
-				JOIN departments d ON p.department_id = d.id 
-				JOIN branches b ON d.branch_id = b.id 
-				WHERE p.id = ?
-			`, *phoneID).Scan(&phoneNumber, &departmentName, &branchName)
-		} else {
-			db.DB.QueryRow(`
-				SELECT d.name, b.name 
-				FROM departments d 
-				JOIN branches b ON d.branch_id = b.id 
-				WHERE d.id = ?
-			`, departmentID).Scan(&departmentName, &branchName)
-		}
-
-		if systemID > 0 {
-			db.DB.QueryRow(`SELECT name FROM systems_program WHERE id = ?`, systemID).Scan(&programName)
-		}
+	if task.ReportMessageID > 0 {
+		req.MessageID = task.ReportMessageID
 
 		// ดึง telegram_user สำหรับ UpdateAssignedtoMsg
+		var telegramUser string
 		if req.AssignedtoID > 0 {
-			db.DB.QueryRow(`SELECT IFNULL(telegram_username, '') FROM responsibilities WHERE id = ?`, req.AssignedtoID).Scan(&telegramUser)
+			if person, err := repos.Responsibilities.Get(ctx, req.AssignedtoID); err == nil {
+				telegramUser = person.TelegramUsername
+			}
 		}
 
 		// อัปเดตสถานะใน Telegram message
-		taskReq := models.TaskRequest{
-			PhoneID:        phoneID,
-			PhoneElse:      phoneElse,
-			SystemID:       systemID,
-			DepartmentID:   departmentID,
-			Text:           text,
-			MessageID:      req.MessageID,
-			Ticket:         ticketno,
-			Assignto:       req.Assignto,
-			ReportedBy:     reportedby,
-			CreatedAt:      CreatedAt,
-			ResolvedAt:     req.ResolvedAt,
-			Status:         2,
-			Url:            req.Url,
-			PhoneNumber:    phoneNumber,
-			DepartmentName: departmentName,
-			BranchName:     branchName,
-			ProgramName:    programName,
-			TelegramUser:   telegramUser,
-		}
-
-		// ดึง file paths จาก task เดิม
-		var existingFilePathsJSON string
-		db.DB.QueryRow(`SELECT IFNULL(file_paths, '[]') FROM tasks WHERE id = ?`, id).Scan(&existingFilePathsJSON)
-
-		var photoURLs []string
-		if existingFilePathsJSON != "" && existingFilePathsJSON != "[]" {
-			var existingFiles []fiber.Map
-			if err := json.Unmarshal([]byte(existingFilePathsJSON), &existingFiles); err == nil {
-				for _, file := range existingFiles {
-					if url, ok := file["url"].(string); ok {
-						photoURLs = append(photoURLs, url)
-					}
-				}
-			}
-		}
+		taskReq := resolutionTelegramRequest(ctx, task, models.TaskStatusDone)
+		taskReq.Assignto = req.Assignto
+		taskReq.ResolvedAt = req.ResolvedAt
+		taskReq.TelegramUser = telegramUser
 
 		// อัปเดตสถานะใน Telegram
-		if len(photoURLs) > 0 {
-			_, err = common.UpdateTelegram(taskReq, photoURLs...)
-		} else {
-			_, err = common.UpdateTelegram(taskReq)
-		}
-		if err != nil {
+		if _, err := common.UpdateTelegram(taskReq, getPhotoURLs(task.FilePaths)...); err != nil {
 			log.Printf("Failed to update Telegram status: %q", err)
 		}
 
-		if assignmsgID > 0 {
-			_, err = db.DB.Exec(`UPDATE telegram_chat SET assignto_id = 0 WHERE id = ?`, telegramID)
-			if err != nil {
+		if task.AssigneeMessageID > 0 {
+			if err := repos.TelegramChats.SetAssigneeMessage(ctx, task.TelegramID, 0); err != nil {
 				log.Printf("Failed to update telegram_chat with message ID: %v", err)
 			}
-			_, err = common.DeleteTelegram(assignmsgID)
-			if err != nil {
+			if _, err := common.DeleteTelegram(task.AssigneeMessageID); err != nil {
 				log.Printf("Failed to Delete assign message!")
 			}
 		}
@@ -341,15 +295,15 @@ func CreateResolutionHandler(c *fiber.Ctx) error {
 			log.Printf("Failed to send solution to Telegram: %v", err)
 		}
 
-		_, err = db.DB.Exec(`UPDATE telegram_chat SET solution_id = ? WHERE id = ?`, replyMessageID, telegramID)
-		if err != nil {
+		if err := repos.TelegramChats.SetSolutionMessage(ctx, task.TelegramID, replyMessageID); err != nil {
 			log.Printf("Failed to update telegram_chat with message ID: %v", err)
 		}
 	}
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Resolution created successfully",
+		"success":  true,
+		"message":  "Resolution created successfully",
+		"resolved": followers,
 	})
 }
 
@@ -364,36 +318,22 @@ func CreateResolutionHandler(c *fiber.Ctx) error {
 // @Param assignedto_id formData int false "User ID to assign the task"
 // @Param image formData file false "Updated resolution image files"
 // @Param image_urls formData string false "Existing image URLs to keep (JSON array)"
+// @Param If-Match header string true "Version of the resolution being edited"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 428 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/resolution/update/{id} [put]
 func UpdateResolutionHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 	id := c.Params("id")
+	taskID, _ := strconv.Atoi(id)
 
 	var req models.ResolutionReq
 	var uploadedFiles []fiber.Map
 
-	// ดึงข้อมูล resolution เดิม
-	var existingResolution models.ResolutionReq
-	var telegramID int
-	var existingFilePathsJSON string
-	var resolutions int
-	var Assignto string
-
-	// ดึงข้อมูลเพิ่มเติมสำหรับ UpdateTelegram
-	var phoneNumber int
-	var departmentName, branchName, programName string
-	var phoneID *int
-	var phoneElse *string
-	var systemID, departmentID int
-	var text string
-	var reportID int
-	var ticketno, assignto, reportedby, resolvedat string
-	var taskID, assigntoID int
-	var createdAtStr string
-
 	req.Solution = c.FormValue("solution")
 
 	if assigntoStr := c.FormValue("assignto"); assigntoStr != "" {
@@ -403,77 +343,45 @@ func UpdateResolutionHandler(c *fiber.Ctx) error {
 		req.AssignedtoID, _ = strconv.Atoi(assigntoIDStr)
 	}
 	// ดึงข้อมูล task ทั้งหมดที่จำเป็น
-	err := db.DB.QueryRow(`
-		SELECT IFNULL(phone_id, 0), IFNULL(phone_else, ''), IFNULL(system_id, 0), IFNULL(department_id, 0), 
-		       IFNULL(text, ''), IFNULL(telegram_id, 0), IFNULL(ticket_no, ''), 
-		       IFNULL(assignto, ''), IFNULL(reported_by, ''), IFNULL(assignto_id, 0)
-		FROM tasks WHERE id = ?
-	`, id).Scan(&phoneID, &phoneElse, &systemID, &departmentID, &text, &telegramID, &ticketno, &assignto, &reportedby, &assigntoID)
+	task, err := repos.Tasks.Get(ctx, taskID)
 	if err != nil {
 		log.Printf("Failed to get task details: %v", err)
 		return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
 	}
 
-	if phoneID != nil && *phoneID > 0 {
-		db.DB.QueryRow(`
-			SELECT p.number, d.name, b.name 
-			FROM ip_phones p 
-			JOIN departments d ON p.department_id = d.id 
-			JOIN branches b ON d.branch_id = b.id 
-			WHERE p.id = ?
-		`, *phoneID).Scan(&phoneNumber, &departmentName, &branchName)
-	} else {
-		db.DB.QueryRow(`
-			SELECT d.name, b.name 
-			FROM departments d 
-			JOIN branches b ON d.branch_id = b.id 
-			WHERE d.id = ?
-		`, departmentID).Scan(&departmentName, &branchName)
-	}
-
-	if systemID > 0 {
-		db.DB.QueryRow(`SELECT name FROM systems_program WHERE id = ?`, systemID).Scan(&programName)
-	}
-
-	// ดึง report_id สำหรับ UpdateTelegram
-	db.DB.QueryRow(`SELECT report_id FROM telegram_chat WHERE id = ?`, telegramID).Scan(&reportID)
-
-	err = db.DB.QueryRow(`
-		SELECT solution_id
-		FROM tasks WHERE id = ?
-	`, id).Scan(&resolutions)
-	if err != nil {
+	if task.SolutionID == nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Solution not found"})
 	}
+	resolutionID := *task.SolutionID
 
-	err = db.DB.QueryRow(`
-		SELECT text, telegram_id, IFNULL(file_paths, '[]')
-		FROM resolutions WHERE id = ?
-	`, resolutions).Scan(&existingResolution.Solution, &telegramID, &existingFilePathsJSON)
-
+	existing, err := repos.Resolutions.Get(ctx, resolutionID)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Resolution not found"})
 	}
+	version, err := utils.IfMatchVersion(c)
+	if err != nil {
+		return utils.IfMatchError(c, err)
+	}
+	if existing.Version != version {
+		return versionConflict(c, existing.Version, resolutionResponse(existing))
+	}
+	var existingURLs []string
+	if existing.FilePaths != nil {
+		existingURLs = getPhotoURLs(*existing.FilePaths)
+	}
 
-	// ดึงข้อมูล task
-
-	err = db.DB.QueryRow(`
-		SELECT r.tasks_id, t.ticket_no, IFNULL(t.assignto_id, 0), IFNULL(t.assignto, ''), IFNULL(t.reported_by, ''), t.created_at, t.resolved_at
-		FROM resolutions r
-		JOIN tasks t ON r.tasks_id = t.id
-		WHERE r.id = ?
-	`, resolutions).Scan(&taskID, &ticketno, &assigntoID, &assignto, &reportedby, &createdAtStr, &resolvedat)
-
+	actor, err := utils.AuditActor(c)
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Resolutions not found"})
+		return utils.AuditActorError(c, err)
 	}
 
-	// Parse created_at string to time
-	CreatedAt := common.Fixtimefeature(createdAtStr)
-	ResolvedAt := common.Fixtimefeature(resolvedat)
+	CreatedAt := common.Fixtimefeature(task.CreatedAt)
+	ResolvedAt := common.Fixtimefeature(task.ResolvedAt)
+	Urlenv := resolutionTaskURL(taskID)
 
 	// Parse ข้อมูลจาก request
 	var keepImageURLs []string
+	var removedURLs []string
 	form, err := c.MultipartForm()
 	if err != nil {
 		if err := c.BodyParser(&req); err != nil {
@@ -501,29 +409,11 @@ func UpdateResolutionHandler(c *fiber.Ctx) error {
 
 		// ตรวจสอบว่า ImageURLs ที่ส่งมาตรงกับที่มีอยู่แล้วหรือไม่
 		if len(allFiles) == 0 && len(keepImageURLs) > 0 {
-			var existingURLs []string
-			if existingFilePathsJSON != "" && existingFilePathsJSON != "[]" {
-				var existingFiles []fiber.Map
-				if err := json.Unmarshal([]byte(existingFilePathsJSON), &existingFiles); err == nil {
-					for _, file := range existingFiles {
-						if url, ok := file["url"].(string); ok {
-							existingURLs = append(existingURLs, url)
-						}
-					}
-				}
-			}
 			// ถ้า URLs ตรงกันทั้งหมด ไม่ต้องทำอะไร
 			if len(existingURLs) == len(keepImageURLs) {
 				allMatch := true
 				for _, keepURL := range keepImageURLs {
-					found := false
-					for _, existingURL := range existingURLs {
-						if keepURL == existingURL {
-							found = true
-							break
-						}
-					}
-					if !found {
+					if !slices.Contains(existingURLs, keepURL) {
 						allMatch = false
 						break
 					}
@@ -531,39 +421,43 @@ func UpdateResolutionHandler(c *fiber.Ctx) error {
 				if allMatch {
 					// URLs ตรงกันทั้งหมด ใช้ solution เดิมถ้าไม่ได้ส่งมาใหม่
 					if req.Solution == "" {
-						req.Solution = existingResolution.Solution
+						req.Solution = existing.Text
 					}
 					// อัปเดตเฉพาะ solution
-					_, err = db.DB.Exec(`UPDATE resolutions SET text = ? WHERE id = ?`, req.Solution, resolutions)
+					err := repos.InTx(ctx, func(tx *repository.Repositories) error {
+						if err := tx.Resolutions.UpdateText(ctx, resolutionID, version, req.Solution); err != nil {
+							return err
+						}
+						return recordResolutionUpdate(ctx, tx, existing, req.Solution, existing.FilePaths, actor)
+					})
+					if errors.Is(err, models.ErrVersionConflict) {
+						return resolutionConflict(c, resolutionID)
+					}
 					if err != nil {
 						return c.Status(500).JSON(fiber.Map{"error": "Failed to update resolution"})
 					}
+					utils.SetETag(c, version+1)
 
 					// อัปเดต Telegram
-					var solutionMessageID int
-					err = db.DB.QueryRow(`SELECT solution_id FROM telegram_chat WHERE id = ?`, telegramID).Scan(&solutionMessageID)
-					if err == nil && solutionMessageID > 0 {
-						req.TicketNo = ticketno
+					unlock := lockTaskTelegram(taskID)
+					defer unlock()
+					if task.SolutionMessageID > 0 && resolutionCurrent(ctx, resolutionID, version+1) {
+						req.TicketNo = task.Ticket
 						if req.Assignto == "" {
-							req.Assignto = assignto
+							req.Assignto = task.Assignto
 						}
 						req.CreatedAt = CreatedAt
 						req.ResolvedAt = ResolvedAt
-						var Urlenv string
-						env := config.AppConfig.Environment
-						if env == "dev" {
-							Urlenv = "http://helpdesk-dev.nopadol.com/tasks/show/" + fmt.Sprintf("%d", taskID)
-						} else {
-							Urlenv = "http://helpdesk.nopadol.com/tasks/show/" + fmt.Sprintf("%d", taskID)
-						}
 						req.Url = Urlenv
-						req.MessageID = reportID
+						req.MessageID = task.ReportMessageID
 
-						messageID, err := common.UpdatereplyToSpecificMessage(solutionMessageID, req, keepImageURLs...)
+						messageID, err := common.UpdatereplyToSpecificMessage(task.SolutionMessageID, req, keepImageURLs...)
 						if err != nil {
 							log.Printf("Failed to update Telegram reply: %v", err)
 						}
-						db.DB.Exec(`UPDATE telegram_chat SET solution_id = ? WHERE id = ?`, messageID, telegramID)
+						if err := repos.TelegramChats.SetSolutionMessage(ctx, task.TelegramID, messageID); err != nil {
+							log.Printf("Failed to update telegram_chat with message ID: %v", err)
+						}
 					}
 
 					return c.JSON(fiber.Map{"success": true, "message": "Resolution updated successfully"})
@@ -571,49 +465,16 @@ func UpdateResolutionHandler(c *fiber.Ctx) error {
 			}
 		}
 
-		// ลบรูปเก่าทั้งหมดถ้าไม่ได้ส่ง image_urls มา หรือลบเฉพาะที่ไม่อยู่ในรายการ
-		if existingFilePathsJSON != "" && existingFilePathsJSON != "[]" {
-			var existingFiles []fiber.Map
-			if err := json.Unmarshal([]byte(existingFilePathsJSON), &existingFiles); err == nil {
-				for _, file := range existingFiles {
-					if url, ok := file["url"].(string); ok {
-						// ถ้าไม่ได้ส่ง image_urls มา ให้ลบทั้งหมด
-						if len(keepImageURLs) == 0 {
-							if strings.Contains(url, "prefix=") {
-								parts := strings.Split(url, "prefix=")
-								if len(parts) > 1 {
-									objectName := parts[1]
-									common.DeleteImage(objectName)
-								}
-							}
-						} else {
-							// ตรวจสอบว่า URL นี้อยู่ในรายการที่ต้องการเก็บไว้หรือไม่
-							keepImage := false
-							for _, keepURL := range keepImageURLs {
-								if url == keepURL {
-									keepImage = true
-									break
-								}
-							}
-							// ถ้าไม่ต้องการเก็บ ให้ลบออกจาก MinIO
-							if !keepImage {
-								if strings.Contains(url, "prefix=") {
-									parts := strings.Split(url, "prefix=")
-									if len(parts) > 1 {
-										objectName := parts[1]
-										common.DeleteImage(objectName)
-									}
-								}
-							}
-						}
-					}
-				}
+		// รูปเก่าที่ไม่อยู่ในรายการจะถูกลบหลังบันทึกสำเร็จ (ถ้าไม่ได้ส่ง image_urls มา ให้ลบทั้งหมด)
+		for _, url := range existingURLs {
+			if !slices.Contains(keepImageURLs, url) {
+				removedURLs = append(removedURLs, url)
 			}
 		}
 
 		// อัปโหลดไฟล์ใหม่ถ้ามี
 		if len(allFiles) > 0 {
-			uploadedFiles, _ = common.HandleFileUploadsResolution(allFiles, ticketno)
+			uploadedFiles, _ = common.HandleFileUploadsResolution(allFiles, task.Ticket)
 		}
 
 		// รวมรูปเก่าที่เก็บไว้กับรูปใหม่
@@ -624,14 +485,15 @@ func UpdateResolutionHandler(c *fiber.Ctx) error {
 
 	// ใช้ solution เดิมถ้าไม่ได้ส่งมาใหม่
 	if req.Solution == "" {
-		req.Solution = existingResolution.Solution
+		req.Solution = existing.Text
 	}
 
 	// เตรียม file paths JSON
-	var filePathsJSON interface{}
+	var filePaths *string
 	if len(uploadedFiles) > 0 {
 		filePathsBytes, _ := json.Marshal(uploadedFiles)
-		filePathsJSON = string(filePathsBytes)
+		filePathsJSON := string(filePathsBytes)
+		filePaths = &filePathsJSON
 	} else if len(keepImageURLs) > 0 {
 		// ใช้เฉพาะรูปเก่าที่เก็บไว้
 		var keepFiles []fiber.Map
@@ -639,45 +501,57 @@ func UpdateResolutionHandler(c *fiber.Ctx) error {
 			keepFiles = append(keepFiles, fiber.Map{"url": url})
 		}
 		filePathsBytes, _ := json.Marshal(keepFiles)
-		filePathsJSON = string(filePathsBytes)
-	} else {
-		// ไม่มีไฟล์ใดๆ
-		filePathsJSON = nil
+		filePathsJSON := string(filePathsBytes)
+		filePaths = &filePathsJSON
 	}
 
-	// อัปเดต tasks ถ้ามีการส่ง assignto มา
-	if req.Assignto != "" || req.AssignedtoID != 0 {
-		_, err = db.DB.Exec(`UPDATE tasks SET assignto_id = ?, assignto = ? WHERE id = ?`, req.AssignedtoID, req.Assignto, id)
-		if err != nil {
-			log.Printf("Failed to update task assignto: %q", err)
+	// อัปเดต resolution และผู้รับผิดชอบใน transaction เดียวกัน
+	err = repos.InTx(ctx, func(tx *repository.Repositories) error {
+		// อัปเดต tasks ถ้ามีการส่ง assignto มา
+		if req.Assignto != "" || req.AssignedtoID != 0 {
+			if err := tx.Tasks.Assign(ctx, taskID, repository.TaskAssignment{AssignedtoID: req.AssignedtoID, Assignto: req.Assignto}); err != nil {
+				return fmt.Errorf("failed to update task assignto: %w", err)
+			}
+			if err := common.RecordTaskChanges(ctx, tx, task, actor); err != nil {
+				return err
+			}
 		}
+		if err := tx.Resolutions.Update(ctx, resolutionID, version, req.Solution, filePaths); err != nil {
+			return err
+		}
+		return recordResolutionUpdate(ctx, tx, existing, req.Solution, filePaths, actor)
+	})
+	if errors.Is(err, models.ErrVersionConflict) {
+		return resolutionConflict(c, resolutionID)
 	}
-
-	// อัปเดต resolution
-	_, err = db.DB.Exec(`UPDATE resolutions SET text = ?, file_paths = ? WHERE id = ?`, req.Solution, filePathsJSON, resolutions)
 	if err != nil {
+		log.Printf("Failed to update resolution %d: %v", resolutionID, err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update resolution"})
 	}
 
-	// ดึง resolved_at
-	var Urlenv string
-	env := config.AppConfig.Environment
-	if env == "dev" {
-		Urlenv = "http://helpdesk-dev.nopadol.com/tasks/show/" + id
-	} else {
-		Urlenv = "http://helpdesk.nopadol.com/tasks/show/" + id
+	// ลบรูปเก่าที่ไม่ได้ใช้แล้วออกจาก MinIO หลัง commit
+	deleteFileURLs(removedURLs)
+	utils.SetETag(c, version+1)
+
+	// ไม่แก้ข้อความ Telegram ถ้ามีการแก้ไข resolution ที่ใหม่กว่านี้แล้ว
+	unlock := lockTaskTelegram(taskID)
+	defer unlock()
+	if !resolutionCurrent(ctx, resolutionID, version+1) {
+		return c.JSON(fiber.Map{"success": true, "message": "Resolution updated successfully"})
 	}
+
 	// เตรียมข้อมูลสำหรับ Telegram
-	req.TicketNo = ticketno
+	req.TicketNo = task.Ticket
+	Assignto := req.Assignto
 	var telegramUser string
 	if req.AssignedtoID > 0 {
-		err = db.DB.QueryRow(`SELECT IFNULL(name, ''), IFNULL(telegram_username, '') FROM responsibilities WHERE id = ?`, req.AssignedtoID).Scan(&Assignto, &telegramUser)
+		person, err := repos.Responsibilities.Get(ctx, req.AssignedtoID)
 		if err != nil {
 			log.Printf("Failed to get assignto name: %v", err)
-			Assignto = req.Assignto // fallback to existing assignto
+		} else {
+			Assignto = person.Name
+			telegramUser = person.TelegramUsername
 		}
-	} else {
-		Assignto = req.Assignto
 	}
 	req.CreatedAt = CreatedAt
 	req.Url = Urlenv
@@ -685,96 +559,45 @@ func UpdateResolutionHandler(c *fiber.Ctx) error {
 	req.TelegramUser = telegramUser
 
 	// อัปเดตสถานะใน Telegram message ด้วยข้อมูลที่ครบ
-	taskReq := models.TaskRequest{
-		PhoneID:        phoneID,
-		PhoneElse:      phoneElse,
-		SystemID:       systemID,
-		DepartmentID:   departmentID,
-		Text:           text,
-		MessageID:      reportID,
-		Ticket:         ticketno,
-		Assignto:       req.Assignto,
-		ReportedBy:     reportedby,
-		CreatedAt:      req.CreatedAt,
-		ResolvedAt:     req.ResolvedAt,
-		Status:         2,
-		Url:            req.Url,
-		PhoneNumber:    phoneNumber,
-		DepartmentName: departmentName,
-		BranchName:     branchName,
-		ProgramName:    programName,
-		TelegramUser:   telegramUser,
-	}
-
-	// ดึง file paths จาก task เดิม
-	var existingFilePaths string
-	db.DB.QueryRow(`SELECT IFNULL(file_paths, '[]') FROM tasks WHERE id = ?`, id).Scan(&existingFilePaths)
-
-	var photoURLs []string
-	if existingFilePaths != "" && existingFilePaths != "[]" {
-		var existingFiles []fiber.Map
-		if err := json.Unmarshal([]byte(existingFilePaths), &existingFiles); err == nil {
-			for _, file := range existingFiles {
-				if url, ok := file["url"].(string); ok {
-					photoURLs = append(photoURLs, url)
-				}
-			}
-		}
-	}
+	taskReq := resolutionTelegramRequest(ctx, task, models.TaskStatusDone)
+	taskReq.Assignto = req.Assignto
+	taskReq.ResolvedAt = req.ResolvedAt
+	taskReq.TelegramUser = telegramUser
 
 	// อัปเดตสถานะใน Telegram
-	if len(photoURLs) > 0 {
-		_, err = common.UpdateTelegram(taskReq, photoURLs...)
-	} else {
-		_, err = common.UpdateTelegram(taskReq)
-	}
-	if err != nil {
+	if _, err := common.UpdateTelegram(taskReq, getPhotoURLs(task.FilePaths)...); err != nil {
 		log.Printf("Failed to update Telegram status: %q", err)
 	}
 
 	// อัปเดต Telegram reply message ถ้ามี solution_id
-	var solutionMessageID int
-	err = db.DB.QueryRow(`SELECT solution_id FROM telegram_chat WHERE id = ?`, telegramID).Scan(&solutionMessageID)
-	if err == nil && solutionMessageID > 0 {
+	if solutionMessageID := task.SolutionMessageID; solutionMessageID > 0 {
 		// เตรียม photo URLs จากไฟล์ทั้งหมด (เก่าและใหม่)
 		var solutionPhotoURLs []string
-		if filePathsJSON != nil {
-			var allFiles []fiber.Map
-			if err := json.Unmarshal([]byte(filePathsJSON.(string)), &allFiles); err == nil {
-				for _, file := range allFiles {
-					if url, ok := file["url"].(string); ok {
-						solutionPhotoURLs = append(solutionPhotoURLs, url)
-					}
-				}
-			}
+		if filePaths != nil {
+			solutionPhotoURLs = getPhotoURLs(*filePaths)
 		}
 
 		// ตั้งค่าข้อมูลให้ครบถ้วนสำหรับ reply
-		req.MessageID = reportID
-		req.TicketNo = ticketno
+		req.MessageID = task.ReportMessageID
 		if req.Assignto == "" {
 			req.Assignto = Assignto
 		}
-		req.CreatedAt = CreatedAt
-		req.Url = Urlenv
 
-		var messageID int
 		// อัปเดต reply message
 		log.Printf("Updating Telegram reply - SolutionMessageID: %d, MessageID: %d, TelegramUser: %s, PhotoURLs count: %d", solutionMessageID, req.MessageID, req.TelegramUser, len(solutionPhotoURLs))
-		messageID, err = common.UpdatereplyToSpecificMessage(solutionMessageID, req, solutionPhotoURLs...)
+		messageID, err := common.UpdatereplyToSpecificMessage(solutionMessageID, req, solutionPhotoURLs...)
 		if err != nil {
 			log.Printf("Failed to update Telegram reply: %v", err)
 		} else {
 			log.Printf("Successfully updated Telegram reply message with new ID: %d", messageID)
 		}
 
-		_, err = db.DB.Exec(`UPDATE telegram_chat SET solution_id = ? WHERE id = ?`, messageID, telegramID)
-		if err != nil {
+		if err := repos.TelegramChats.SetSolutionMessage(ctx, task.TelegramID, messageID); err != nil {
 			log.Printf("Failed to update telegram_chat with message ID: %v", err)
 		}
 	}
 
-	log.Printf("Updated resolution ID: %d", resolutions)
+	log.Printf("Updated resolution ID: %d", resolutionID)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -782,8 +605,17 @@ func UpdateResolutionHandler(c *fiber.Ctx) error {
 	})
 }
 
+// recordResolutionUpdate records the changed text and attachments of a resolution
+func recordResolutionUpdate(ctx context.Context, tx *repository.Repositories, existing *models.Resolution, text string, filePaths *string, actor int) error {
+	fields := common.DiffEntry(existing.Text, text, existing.FilePaths, filePaths)
+	if len(fields) == 0 {
+		return nil
+	}
+	return common.RecordChange(ctx, tx, existing.TaskID, models.ChangeEntityResolution, existing.ID, models.ChangeActionUpdate, actor, fields...)
+}
+
 // @Summary Delete resolution
-// @Description Delete a resolution and reset task status
+// @Description Move the resolution of a task to the trash and reopen the task
 // @Tags resolutions
 // @Accept json
 // @Produce json
@@ -794,6 +626,7 @@ func UpdateResolutionHandler(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/resolution/delete/{id} [delete]
 func DeleteResolutionHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 	idStr := c.Params("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -803,117 +636,81 @@ func DeleteResolutionHandler(c *fiber.Ctx) error {
 
 	log.Printf("Starting deletion process for task ID: %d", id)
 
-	// Get message_id and file_paths before deleting
-	var resolutions int
-	var existingResolution models.ResolutionReq
-	var telegramID int
-	var existingFilePathsJSON string
-	var messageID int
-
 	// ดึง solution_id จาก tasks
-	err = db.DB.QueryRow(`
-		SELECT solution_id
-		FROM tasks WHERE id = ?
-	`, id).Scan(&resolutions)
-	if err != nil {
+	task, err := repos.Tasks.Get(ctx, id)
+	if err != nil || task.SolutionID == nil {
 		log.Printf("Task not found for ID %d: %v", id, err)
 		return c.Status(404).JSON(fiber.Map{"error": "task not found"})
 	}
-	log.Printf("Found solution_id: %d for task ID: %d", resolutions, id)
+	resolutionID := *task.SolutionID
+	log.Printf("Found solution_id: %d for task ID: %d", resolutionID, id)
 
 	// ดึงข้อมูล resolution
-	err = db.DB.QueryRow(`
-		SELECT text, telegram_id, IFNULL(file_paths, '[]')
-		FROM resolutions 
-		WHERE id = ?
-	`, resolutions).Scan(&existingResolution.Solution, &telegramID, &existingFilePathsJSON)
-
+	resolution, err := repos.Resolutions.Get(ctx, resolutionID)
 	if err != nil {
-		log.Printf("Resolution not found for ID %d: %v", resolutions, err)
+		log.Printf("Resolution not found for ID %d: %v", resolutionID, err)
 		return c.Status(404).JSON(fiber.Map{"error": "Resolution not found"})
 	}
-	log.Printf("Found resolution - telegramID: %d, file_paths: %s", telegramID, existingFilePathsJSON)
+	telegramID := resolution.TelegramID
+	log.Printf("Found resolution - telegramID: %d", telegramID)
 
 	// ดึง solution_id จาก telegram_chat (message ID สำหรับลบใน Telegram)
-	err = db.DB.QueryRow(`
-		SELECT solution_id
-		FROM telegram_chat
-		WHERE id = ?
-	`, telegramID).Scan(&messageID)
-
+	chat, err := repos.TelegramChats.Get(ctx, telegramID)
 	if err != nil {
 		log.Printf("Telegram chat not found for ID %d: %v", telegramID, err)
 		return c.Status(404).JSON(fiber.Map{"error": "Telegram chat not found"})
 	}
+	messageID := chat.SolutionMessageID
 	log.Printf("Found solution messageID: %d for telegramID: %d", messageID, telegramID)
 
-	// Delete files from MinIO if they exist
-	if existingFilePathsJSON != "" && existingFilePathsJSON != "[]" {
-		var filePaths []fiber.Map
-		if err := json.Unmarshal([]byte(existingFilePathsJSON), &filePaths); err == nil {
-			log.Printf("Deleting %d files from MinIO", len(filePaths))
-			for _, fp := range filePaths {
-				if url, ok := fp["url"].(string); ok {
-					if strings.Contains(url, "prefix=") {
-						parts := strings.Split(url, "prefix=")
-						if len(parts) > 1 {
-							objectName := parts[1]
-							log.Printf("Deleting file from MinIO: %s", objectName)
-							common.DeleteImage(objectName)
-						}
-					}
-				}
-			}
-		} else {
-			log.Printf("Failed to unmarshal file paths JSON: %v", err)
-		}
-	}
-
-	// Delete resolution from database
-	_, err = db.DB.Exec(`DELETE FROM resolutions WHERE id=?`, resolutions)
+	actor, err := utils.AuditActor(c)
 	if err != nil {
-		log.Printf("Failed to delete resolution ID %d: %v", resolutions, err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete resolutions"})
-	}
-	log.Printf("Successfully deleted resolution ID: %d", resolutions)
-
-	// อัปเดต solution_id เป็น NULL ใน telegram_chat
-	_, err = db.DB.Exec(`UPDATE telegram_chat SET solution_id = NULL WHERE id = ?`, telegramID)
-	if err != nil {
-		log.Printf("Failed to update telegram_chat solution_id to NULL for ID %d: %v", telegramID, err)
-	} else {
-		log.Printf("Successfully updated telegram_chat solution_id to NULL for ID: %d", telegramID)
+		return utils.AuditActorError(c, err)
 	}
 
-	// อัปเดต solution_id และ status ใน tasks
-	_, err = db.DB.Exec(`UPDATE tasks SET solution_id = NULL, status = 0, resolved_at=NULL WHERE id = ?`, id)
+	// ย้าย resolution ไปถังขยะและเปิด task ใหม่ใน transaction เดียวกัน ไฟล์แนบจะถูกลบเมื่อ purge ถาวร
+	err = repos.InTx(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Resolutions.Delete(ctx, resolutionID, actor); err != nil {
+			return err
+		}
+		if err := common.RecordChange(ctx, tx, id, models.ChangeEntityResolution, resolutionID, models.ChangeActionDelete, actor); err != nil {
+			return err
+		}
+		// อัปเดต solution_id เป็น NULL ใน telegram_chat
+		if err := tx.TelegramChats.SetSolutionMessage(ctx, telegramID, 0); err != nil {
+			return fmt.Errorf("failed to clear telegram_chat solution_id: %w", err)
+		}
+		// อัปเดต solution_id และ status ใน tasks
+		if err := tx.Tasks.Unresolve(ctx, id); err != nil {
+			return fmt.Errorf("failed to reopen task: %w", err)
+		}
+		_, err := common.TransitionTask(ctx, tx, id, task.Status, models.TaskStatusReopened, actor, "resolution deleted")
+		return err
+	})
 	if err != nil {
-		log.Printf("Failed to update tasks solution_id to NULL for ID %d: %v", id, err)
-	} else {
-		log.Printf("Successfully updated task ID %d: solution_id=NULL, status=0, resolved_at=NULL", id)
+		log.Printf("Failed to delete resolution ID %d: %v", resolutionID, err)
+		if errors.Is(err, models.ErrInvalidStatusTransition) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
+		if errors.Is(err, models.ErrVersionConflict) {
+			return taskConflict(c, id)
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete resolutions"})
 	}
+	log.Printf("Successfully deleted resolution ID: %d and reopened task ID: %d", resolutionID, id)
 
 	// ลบ solution message จาก Telegram ก่อน
 	if messageID > 0 {
 		log.Printf("Deleting solution message from Telegram, messageID: %d", messageID)
-		_, err = common.DeleteTelegram(messageID)
-		if err != nil {
+		if _, err := common.DeleteTelegram(messageID); err != nil {
 			log.Printf("Failed to delete solution message from Telegram (messageID: %d): %v", messageID, err)
 		} else {
 			log.Printf("Successfully deleted solution message from Telegram (messageID: %d)", messageID)
 		}
 	}
 
-	// อัปเดตสถานะใน Telegram message กลับเป็น "รอดำเนินการ"
-	var reportID int
-	err = db.DB.QueryRow(`SELECT report_id FROM telegram_chat WHERE id = ?`, telegramID).Scan(&reportID)
-	log.Printf("Debug - telegramID: %d, reportID: %d, query error: %v", telegramID, reportID, err)
-
-	if err != nil {
-		log.Printf("Failed to get reportID for telegramID %d: %v", telegramID, err)
-		return c.JSON(fiber.Map{"success": true, "warning": "Resolution deleted but failed to update Telegram status"})
-	}
-
+	// อัปเดตสถานะใน Telegram message เป็น "เปิดงานใหม่"
+	reportID := chat.ReportMessageID
 	if reportID <= 0 {
 		log.Printf("Invalid reportID: %d for telegramID: %d", reportID, telegramID)
 		return c.JSON(fiber.Map{"success": true, "warning": "Resolution deleted but invalid reportID"})
@@ -922,157 +719,33 @@ func DeleteResolutionHandler(c *fiber.Ctx) error {
 	log.Printf("Processing Telegram update for reportID: %d", reportID)
 
 	// ดึงข้อมูล task สำหรับอัปเดต Telegram
-	var ticketno, assignto, reportedby string
-	var phoneID *int
-	var phoneElse *string
-	var systemID, departmentID int
-	var text string
-	var assigntoID int
-	var taskCreatedAtStr string
-
-	err = db.DB.QueryRow(`
-		SELECT ticket_no, IFNULL(assignto, ''), IFNULL(reported_by, ''), phone_id, IFNULL(phone_else, ''), system_id, department_id, text, created_at, IFNULL(assignto_id, 0)
-		FROM tasks WHERE id = ?
-	`, id).Scan(&ticketno, &assignto, &reportedby, &phoneID, &phoneElse, &systemID, &departmentID, &text, &taskCreatedAtStr, &assigntoID)
-
-	log.Printf("Debug - Task query error: %v", err)
+	task, err = repos.Tasks.Get(ctx, id)
 	if err != nil {
 		log.Printf("Failed to get task details for ID %d: %v", id, err)
 		return c.JSON(fiber.Map{"success": true, "warning": "Resolution deleted but failed to get task details"})
 	}
 
-	// แปลง string เป็น time.Time
-	CreatedAt := common.Fixtimefeature(taskCreatedAtStr)
-	log.Printf("Task details: ticket=%s, assignto=%s, reportedby=%s, phoneID=%v, systemID=%d, departmentID=%d, assigntoID=%d, createdAt=%s",
-		ticketno, assignto, reportedby, phoneID, systemID, departmentID, assigntoID, taskCreatedAtStr)
-
-	// ดึงข้อมูลเพิ่มเติม
-	var phoneNumber int
-	var departmentName, branchName, programName string
-
-	if phoneID != nil && *phoneID > 0 {
-		err = db.DB.QueryRow(`
-			SELECT p.number, d.name, b.name 
-			FROM ip_phones p 
-			JOIN departments d ON p.department_id = d.id 
-			JOIN branches b ON d.branch_id = b.id 
-			WHERE p.id = ?
-		`, *phoneID).Scan(&phoneNumber, &departmentName, &branchName)
-		if err != nil {
-			log.Printf("Phone query error for phoneID %d: %v", *phoneID, err)
-		} else {
-			log.Printf("Phone details: number=%d, department=%s, branch=%s", phoneNumber, departmentName, branchName)
-		}
-	} else {
-		err = db.DB.QueryRow(`
-			SELECT d.name, b.name 
-			FROM departments d 
-			JOIN branches b ON d.branch_id = b.id 
-			WHERE d.id = ?
-		`, departmentID).Scan(&departmentName, &branchName)
-		if err != nil {
-			log.Printf("Department query error for departmentID %d: %v", departmentID, err)
-		} else {
-			log.Printf("Department details: department=%s, branch=%s", departmentName, branchName)
-		}
-	}
-
-	if systemID > 0 {
-		err = db.DB.QueryRow(`SELECT name FROM systems_program WHERE id = ?`, systemID).Scan(&programName)
-		if err != nil {
-			log.Printf("System query error for systemID %d: %v", systemID, err)
-		} else {
-			log.Printf("Program name: %s", programName)
-		}
-	}
-
-	// ดึง telegram_user สำหรับ UpdateAssignedtoMsg
-	var telegramUser string
-	if assigntoID > 0 {
-		err = db.DB.QueryRow(`SELECT IFNULL(telegram_user, '') FROM responsibilities WHERE id = ?`, assigntoID).Scan(&telegramUser)
-		if err != nil {
-			log.Printf("Telegram user query error for assigntoID %d: %v", assigntoID, err)
-		} else {
-			log.Printf("Telegram user: %s", telegramUser)
-		}
-	}
-
-	// เตรียมข้อมูลสำหรับ UpdateTelegram
-	var Urlenv string
-	env := config.AppConfig.Environment
-	if env == "dev" {
-		Urlenv = "http://helpdesk-dev.nopadol.com/tasks/show/" + idStr
-	} else {
-		Urlenv = "http://helpdesk.nopadol.com/tasks/show/" + idStr
-	}
-
-	taskReq := models.TaskRequest{
-		PhoneID:        phoneID,
-		PhoneElse:      phoneElse,
-		SystemID:       systemID,
-		DepartmentID:   departmentID,
-		Text:           text,
-		MessageID:      reportID,
-		Ticket:         ticketno,
-		Assignto:       assignto,
-		ReportedBy:     reportedby,
-		CreatedAt:      CreatedAt,
-		UpdatedAt:      "",
-		Status:         0, // เปลี่ยนกลับเป็น "รอดำเนินการ"
-		Url:            Urlenv,
-		PhoneNumber:    phoneNumber,
-		DepartmentName: departmentName,
-		BranchName:     branchName,
-		ProgramName:    programName,
-		TelegramUser:   telegramUser,
-	}
+	taskReq := resolutionTelegramRequest(ctx, task, task.Status) // เปลี่ยนเป็น "เปิดงานใหม่"
+	taskReq.MessageID = reportID
+	taskReq.TelegramUser = task.AssigneeTelegram
 
 	log.Printf("TaskRequest prepared: MessageID=%d, Status=%d, Url=%s", taskReq.MessageID, taskReq.Status, taskReq.Url)
 
-	// ดึง file paths จาก task เดิม (ไม่ใช่จาก resolution)
-	var taskFilePathsJSON string
-	err = db.DB.QueryRow(`SELECT IFNULL(file_paths, '[]') FROM tasks WHERE id = ?`, id).Scan(&taskFilePathsJSON)
-	if err != nil {
-		log.Printf("Task file paths query error for ID %d: %v", id, err)
-		taskFilePathsJSON = "[]"
-	}
-	log.Printf("Task file paths: %s", taskFilePathsJSON)
-
-	var photoURLs []string
-	if taskFilePathsJSON != "" && taskFilePathsJSON != "[]" {
-		var taskFiles []fiber.Map
-		if err := json.Unmarshal([]byte(taskFilePathsJSON), &taskFiles); err == nil {
-			for _, file := range taskFiles {
-				if url, ok := file["url"].(string); ok {
-					photoURLs = append(photoURLs, url)
-				}
-			}
-			log.Printf("Extracted %d photo URLs from task files", len(photoURLs))
-		} else {
-			log.Printf("Failed to unmarshal task file paths JSON: %v", err)
-		}
-	}
+	// ใช้ไฟล์จาก task เดิม (ไม่ใช่จาก resolution)
+	photoURLs := getPhotoURLs(task.FilePaths)
 
 	// อัปเดตสถานะใน Telegram
 	log.Printf("Updating Telegram message (reportID: %d) with %d photos", reportID, len(photoURLs))
 
-	var telegramUpdateErr error
-	if len(photoURLs) > 0 {
-		_, telegramUpdateErr = common.UpdateTelegram(taskReq, photoURLs...)
-	} else {
-		_, telegramUpdateErr = common.UpdateTelegram(taskReq)
-	}
-
-	if telegramUpdateErr != nil {
-		log.Printf("Failed to update Telegram status for reportID %d: %v", reportID, telegramUpdateErr)
+	if _, err := common.UpdateTelegram(taskReq, photoURLs...); err != nil {
+		log.Printf("Failed to update Telegram status for reportID %d: %v", reportID, err)
 		return c.JSON(fiber.Map{
 			"success":        true,
 			"warning":        "Resolution deleted but failed to update Telegram status",
-			"telegram_error": telegramUpdateErr.Error(),
+			"telegram_error": err.Error(),
 		})
-	} else {
-		log.Printf("Successfully updated Telegram message to pending status for reportID: %d", reportID)
 	}
+	log.Printf("Successfully updated Telegram message to pending status for reportID: %d", reportID)
 
 	log.Printf("Completed deletion process for task ID: %d", id)
 	return c.JSON(fiber.Map{"success": true})
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"log"
+	"reports-api/db"
+	"reports-api/handlers/common"
+	"reports-api/models"
+	"slices"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary List roles
+// @Description Get all roles with their granted permissions
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/authEntry/roles [get]
+func ListRolesHandler(c *fiber.Ctx) error {
+	rows, err := db.DB.Query(`
+		SELECT r.name, IFNULL(r.description, ''), IFNULL(rp.permission, '')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role = r.name
+		ORDER BY r.name, rp.permission
+	`)
+	if err != nil {
+		log.Printf("Error querying roles: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query roles"})
+	}
+	defer rows.Close()
+
+	var roles []models.Role
+	index := map[string]int{}
+	for rows.Next() {
+		var name, description, permission string
+		if err := rows.Scan(&name, &description, &permission); err != nil {
+			log.Printf("Error scanning role: %v", err)
+			continue
+		}
+		i, ok := index[name]
+		if !ok {
+			roles = append(roles, models.Role{Name: name, Description: description, Permissions: []string{}})
+			i = len(roles) - 1
+			index[name] = i
+		}
+		if permission != "" {
+			roles[i].Permissions = append(roles[i].Permissions, permission)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success":     true,
+		"data":        roles,
+		"permissions": models.AllPermissions,
+	})
+}
+
+// @Summary Update role permissions
+// @Description Create a role or replace the permissions granted to it
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Param role path string true "Role name"
+// @Param request body models.RolePermissionsRequest true "Role permissions"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/authEntry/roles/{role} [put]
+func UpdateRolePermissionsHandler(c *fiber.Ctx) error {
+	role := c.Params("role")
+	if role == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Role is required"})
+	}
+
+	var req models.RolePermissionsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	for _, permission := range req.Permissions {
+		if !slices.Contains(models.AllPermissions, permission) {
+			return c.Status(400).JSON(fiber.Map{"error": "Unknown permission: " + permission})
+		}
+	}
+	// Never let the admin role lose the ability to manage roles
+	if role == models.RoleAdmin && !slices.Contains(req.Permissions, models.PermRolesManage) {
+		return c.Status(400).JSON(fiber.Map{"error": "The admin role must keep " + models.PermRolesManage})
+	}
+	sort.Strings(req.Permissions)
+	req.Permissions = slices.Compact(req.Permissions)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO roles (name, description) VALUES (?, ?) ON DUPLICATE KEY UPDATE description = IFNULL(?, description)`, role, req.Description, req.Description); err != nil {
+		log.Printf("Error upserting role %s: %v", role, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update role"})
+	}
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role = ?`, role); err != nil {
+		log.Printf("Error clearing permissions for role %s: %v", role, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update role"})
+	}
+	for _, permission := range req.Permissions {
+		if _, err := tx.Exec(`INSERT INTO role_permissions (role, permission) VALUES (?, ?)`, role, permission); err != nil {
+			log.Printf("Error granting %s to role %s: %v", permission, role, err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to update role"})
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update role"})
+	}
+
+	common.InvalidatePermissionCache()
+	log.Printf("Role %s updated with %d permissions", role, len(req.Permissions))
+	return c.JSON(fiber.Map{"success": true, "data": models.Role{Name: role, Permissions: req.Permissions}})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"log"
+	"reports-api/handlers/common"
+	"reports-api/models"
+	"reports-api/utils"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary Full-text search
+// @Description Search task descriptions, progress entries and resolutions ranked by relevance.
+// @Description Each hit points to the matching record (source, source_id) and carries an HTML snippet with <mark> highlights.
+// @Tags search
+// @Produce json
+// @Param q query string true "Search text (at least 2 characters)"
+// @Param source query string false "Comma-separated sources: task, progress, resolution"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} models.PaginatedResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/search [get]
+func SearchHandler(c *fiber.Ctx) error {
+	text := strings.TrimSpace(c.Query("q"))
+	// ngram ตัดคำทีละ 2 ตัวอักษร คำค้นที่สั้นกว่านั้นจึงไม่ตรงกับดัชนี
+	if utf8.RuneCountInString(text) < 2 {
+		return c.Status(400).JSON(fiber.Map{"error": "q must be at least 2 characters"})
+	}
+	if utf8.RuneCountInString(text) > 200 {
+		return c.Status(400).JSON(fiber.Map{"error": "q is too long"})
+	}
+
+	var sources []string
+	for _, s := range strings.Split(c.Query("source"), ",") {
+		switch s = strings.TrimSpace(s); s {
+		case "":
+		case models.SearchSourceTask, models.SearchSourceProgress, models.SearchSourceResolution:
+			sources = append(sources, s)
+		default:
+			return c.Status(400).JSON(fiber.Map{"error": "source must be task, progress or resolution"})
+		}
+	}
+
+	pagination := utils.GetPaginationParams(c)
+	hits, total, err := repos.Search.Search(c.UserContext(), models.SearchQuery{
+		Text:    text,
+		Sources: sources,
+		Limit:   pagination.Limit,
+		Offset:  utils.CalculateOffset(pagination.Page, pagination.Limit),
+	})
+	if err != nil {
+		log.Printf("Error searching: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to search"})
+	}
+	for i := range hits {
+		hits[i].Snippet = common.SearchSnippet(hits[i].Body, text)
+	}
+
+	return c.JSON(models.PaginatedResponse{
+		Success: true,
+		Data:    hits,
+		Pagination: models.PaginationResponse{
+			Page:       pagination.Page,
+			Limit:      pagination.Limit,
+			Total:      total,
+			TotalPages: utils.CalculateTotalPages(total, pagination.Limit),
+		},
+	})
+}
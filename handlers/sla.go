@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"reports-api/models"
+	"reports-api/repository"
+	"reports-api/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// validateSLAPolicy checks the targets of an SLA policy request
+func validateSLAPolicy(req models.SLAPolicyRequest) string {
+	if req.Priority <= 0 {
+		return "priority must be greater than 0"
+	}
+	if req.ResponseMinutes <= 0 || req.ResolutionMinutes <= 0 {
+		return "response_minutes and resolution_minutes must be greater than 0"
+	}
+	if req.ResponseMinutes > req.ResolutionMinutes {
+		return "response_minutes must not exceed resolution_minutes"
+	}
+	return ""
+}
+
+// @Summary List SLA policies
+// @Description List every SLA policy ordered by priority
+// @Tags sla
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/sla/list [get]
+func ListSLAPoliciesHandler(c *fiber.Ctx) error {
+	policies, err := repos.SLA.ListPolicies(c.UserContext())
+	if err != nil {
+		log.Printf("Error listing SLA policies: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query SLA policies"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": policies})
+}
+
+// @Summary Get SLA policy
+// @Description Get an SLA policy by ID
+// @Tags sla
+// @Produce json
+// @Param id path string true "SLA policy ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/v1/sla/{id} [get]
+func GetSLAPolicyHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	policy, err := repos.SLA.GetPolicy(c.UserContext(), id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "SLA policy not found"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": policy})
+}
+
+// @Summary Create SLA policy
+// @Description Create an SLA policy for a program priority, optionally limited to a branch or issue type
+// @Tags sla
+// @Accept json
+// @Produce json
+// @Param policy body models.SLAPolicyRequest true "SLA policy data"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/sla/create [post]
+func CreateSLAPolicyHandler(c *fiber.Ctx) error {
+	var req models.SLAPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if msg := validateSLAPolicy(req); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
+	}
+
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	id, err := repos.SLA.CreatePolicy(c.UserContext(), req, actor)
+	if err != nil {
+		log.Printf("Error creating SLA policy: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to insert SLA policy"})
+	}
+	return c.JSON(fiber.Map{"success": true, "id": id})
+}
+
+// @Summary Update SLA policy
+// @Description Update an SLA policy; due dates of existing tasks are recomputed when the task is next edited
+// @Tags sla
+// @Accept json
+// @Produce json
+// @Param id path string true "SLA policy ID"
+// @Param policy body models.SLAPolicyRequest true "SLA policy data"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/sla/update/{id} [put]
+func UpdateSLAPolicyHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	var req models.SLAPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if msg := validateSLAPolicy(req); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
+	}
+
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	if err := repos.SLA.UpdatePolicy(c.UserContext(), id, req, actor); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "SLA policy not found"})
+		}
+		log.Printf("Error updating SLA policy %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update SLA policy"})
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// @Summary Delete SLA policy
+// @Description Delete an SLA policy
+// @Tags sla
+// @Produce json
+// @Param id path string true "SLA policy ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/sla/delete/{id} [delete]
+func DeleteSLAPolicyHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	if err := repos.SLA.DeletePolicy(c.UserContext(), id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "SLA policy not found"})
+		}
+		log.Printf("Error deleting SLA policy %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete SLA policy"})
+	}
+
+	log.Printf("Deleted SLA policy ID: %d by user ID: %d", id, actor)
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// @Summary List SLA events
+// @Description List warnings and breaches raised by the SLA checker, newest first
+// @Tags sla
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Param search query string false "Event kind or ticket number"
+// @Success 200 {object} models.PaginatedResponse
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/sla/events [get]
+func ListSLAEventsHandler(c *fiber.Ctx) error {
+	pagination := utils.GetPaginationParams(c)
+	offset := utils.CalculateOffset(pagination.Page, pagination.Limit)
+
+	events, total, err := repos.SLA.ListEvents(c.UserContext(), repository.ListQuery{
+		Search: c.Query("search"),
+		Limit:  pagination.Limit,
+		Offset: offset,
+	})
+	if err != nil {
+		log.Printf("Error listing SLA events: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query SLA events"})
+	}
+
+	return c.JSON(models.PaginatedResponse{
+		Success: true,
+		Data:    events,
+		Pagination: models.PaginationResponse{
+			Page:       pagination.Page,
+			Limit:      pagination.Limit,
+			Total:      total,
+			TotalPages: utils.CalculateTotalPages(total, pagination.Limit),
+		},
+	})
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"reports-api/handlers/common"
+	"reports-api/models"
+	"reports-api/repository"
+	"reports-api/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary List task links
+// @Description List the duplicate, parent/child, blocking and related links of a task as seen from it
+// @Tags problems
+// @Produce json
+// @Param id path string true "Problem ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problem/{id}/links [get]
+func GetTaskLinksHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	links, err := repos.Links.ListByTask(c.UserContext(), id)
+	if err != nil {
+		log.Printf("Failed to get links of task %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get links"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": links})
+}
+
+// @Summary Link tasks
+// @Description Link a task to another one; with inherit, resolving the parent or original also resolves the child or duplicate
+// @Tags problems
+// @Accept json
+// @Produce json
+// @Param id path string true "Problem ID"
+// @Param request body models.TaskLinkRequest true "Link type, linked task and inherit"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problem/{id}/links [post]
+func CreateTaskLinkHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+	var req models.TaskLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	linkID, err := common.LinkTasks(c.UserContext(), repos, id, req, actor)
+	if err != nil {
+		return taskLinkError(c, id, err)
+	}
+	log.Printf("Linked task %d as %s of task %d by user ID: %d", id, req.Type, req.TaskID, actor)
+	return c.JSON(fiber.Map{"success": true, "id": linkID})
+}
+
+// @Summary Unlink tasks
+// @Description Remove a link of a task
+// @Tags problems
+// @Produce json
+// @Param id path string true "Problem ID"
+// @Param linkId path string true "Link ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problem/{id}/links/{linkId} [delete]
+func DeleteTaskLinkHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+	linkID, err := strconv.Atoi(c.Params("linkId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid link id"})
+	}
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	if err := common.UnlinkTasks(c.UserContext(), repos, id, linkID, actor); err != nil {
+		return taskLinkError(c, id, err)
+	}
+	log.Printf("Removed link %d of task %d by user ID: %d", linkID, id, actor)
+	return c.JSON(fiber.Map{"success": true, "message": "Link removed successfully"})
+}
+
+// taskLinkError writes the response for an error returned by common.LinkTasks or common.UnlinkTasks
+func taskLinkError(c *fiber.Ctx, id int, err error) error {
+	switch {
+	case errors.Is(err, models.ErrInvalidTaskLink):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, repository.ErrNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Task or link not found"})
+	case errors.Is(err, models.ErrTaskLinkExists), errors.Is(err, models.ErrTaskLinkConflict):
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	log.Printf("Failed to change links of task %d: %v", id, err)
+	return c.Status(500).JSON(fiber.Map{"error": "Failed to change links"})
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"reports-api/config"
+	"reports-api/models"
+	"reports-api/repository"
+	"reports-api/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultTaskSort is the order of /api/v1/problems when sort is omitted
+const defaultTaskSort = "-created_at"
+
+// taskCursor is the decoded form of the opaque cursor returned as next_cursor
+type taskCursor struct {
+	Sort string   `json:"s"`
+	Keys []string `json:"k"`
+}
+
+func encodeTaskCursor(cur taskCursor) string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeTaskCursor(s string) (taskCursor, error) {
+	var cur taskCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, err
+	}
+	err = json.Unmarshal(b, &cur)
+	return cur, err
+}
+
+// queryFunc reads a query parameter; it matches (*fiber.Ctx).Query
+type queryFunc func(key string, defaultValue ...string) string
+
+// valuesQuery reads query parameters from a parsed query string
+func valuesQuery(values url.Values) queryFunc {
+	return func(key string, defaultValue ...string) string {
+		if v := values.Get(key); v != "" || len(defaultValue) == 0 {
+			return v
+		}
+		return defaultValue[0]
+	}
+}
+
+// parseIntList parses a comma-separated list of integers such as "1,2,3"
+func parseIntList(query queryFunc, key string) ([]int, error) {
+	raw := strings.TrimSpace(query(key))
+	if raw == "" {
+		return nil, nil
+	}
+	var values []int
+	for _, part := range strings.Split(raw, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a comma-separated list of integers", key)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// parseTaskSort parses "field,-field" into sort keys; a leading "-" sorts descending
+func parseTaskSort(raw string) ([]repository.TaskSort, error) {
+	var sort []repository.TaskSort
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		s := repository.TaskSort{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if _, ok := repository.TaskSortFields[s.Field]; !ok {
+			return nil, fmt.Errorf("invalid sort field %q", s.Field)
+		}
+		sort = append(sort, s)
+	}
+	return sort, nil
+}
+
+// parseTimeBound parses a date (YYYY-MM-DD, in the business time zone) or an RFC3339 timestamp.
+// An upper bound given as a date includes the whole day.
+func parseTimeBound(query queryFunc, key string, upper bool) (*time.Time, error) {
+	raw := strings.TrimSpace(query(key))
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", raw, config.AppConfig.BusinessLocation)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a date (YYYY-MM-DD) or an RFC3339 timestamp", key)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// parseTaskSearch reads the filters, sort and cursor of /api/v1/problems; the caller sets the limit
+func parseTaskSearch(query queryFunc) (repository.TaskSearch, string, error) {
+	var s repository.TaskSearch
+	var err error
+
+	for key, dst := range map[string]*[]int{
+		"status":        &s.Statuses,
+		"branch_id":     &s.BranchIDs,
+		"department_id": &s.DepartmentIDs,
+		"program_id":    &s.ProgramIDs,
+		"issue_type_id": &s.IssueTypeIDs,
+		"assignee_id":   &s.AssigneeIDs,
+		"created_by":    &s.CreatedByIDs,
+	} {
+		if *dst, err = parseIntList(query, key); err != nil {
+			return s, "", err
+		}
+	}
+
+	for key, dst := range map[string]**time.Time{
+		"created_from":  &s.CreatedFrom,
+		"created_to":    &s.CreatedTo,
+		"updated_from":  &s.UpdatedFrom,
+		"updated_to":    &s.UpdatedTo,
+		"resolved_from": &s.ResolvedFrom,
+		"resolved_to":   &s.ResolvedTo,
+	} {
+		if *dst, err = parseTimeBound(query, key, strings.HasSuffix(key, "_to")); err != nil {
+			return s, "", err
+		}
+	}
+
+	s.Text = strings.TrimSpace(query("q"))
+	if len(s.Text) > 100 {
+		return s, "", fmt.Errorf("q is too long")
+	}
+	s.ReportedBy = strings.TrimSpace(query("reported_by"))
+
+	switch sla := query("sla"); sla {
+	case "", repository.TaskSLANone, repository.TaskSLAOnTrack, repository.TaskSLAAtRisk, repository.TaskSLABreached:
+		s.SLAState = sla
+	default:
+		return s, "", fmt.Errorf("sla must be one of none, on_track, at_risk or breached")
+	}
+	s.Now = time.Now()
+	s.SLAWarnUntil = s.Now.Add(config.AppConfig.SLA.WarnBefore)
+
+	sortParam := strings.TrimSpace(query("sort", defaultTaskSort))
+	if s.Sort, err = parseTaskSort(sortParam); err != nil {
+		return s, "", err
+	}
+
+	if raw := query("cursor"); raw != "" {
+		cur, err := decodeTaskCursor(raw)
+		if err != nil || cur.Sort != sortParam || len(cur.Keys) == 0 {
+			return s, "", fmt.Errorf("invalid cursor for this sort order")
+		}
+		s.After = cur.Keys
+	}
+	return s, sortParam, nil
+}
+
+// @Summary Query problems
+// @Description List problems matching every given filter, ordered by sort and paged with next_cursor.
+// @Description List filters take comma-separated IDs. Date bounds take YYYY-MM-DD (whole day, business time zone) or RFC3339.
+// @Description Sort takes comma-separated fields, "-" for descending: id, created_at, updated_at, status, ticket_no,
+// @Description response_due_at, resolution_due_at, resolved_at, branch_name, department_name, program_name, assignto.
+// @Tags problems
+// @Produce json
+// @Param status query string false "Statuses, e.g. 0,1"
+// @Param branch_id query string false "Branch IDs"
+// @Param department_id query string false "Department IDs"
+// @Param program_id query string false "Program IDs"
+// @Param issue_type_id query string false "Issue type IDs"
+// @Param assignee_id query string false "Assignee IDs"
+// @Param created_by query string false "Creator user IDs"
+// @Param reported_by query string false "Reporter name (partial match)"
+// @Param q query string false "Free text"
+// @Param created_from query string false "Created at or after"
+// @Param created_to query string false "Created before (date: through the end of the day)"
+// @Param updated_from query string false "Updated at or after"
+// @Param updated_to query string false "Updated before"
+// @Param resolved_from query string false "Resolved at or after"
+// @Param resolved_to query string false "Resolved before"
+// @Param sla query string false "SLA state: none, on_track, at_risk, breached"
+// @Param sort query string false "Sort fields" default(-created_at)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} models.CursorPaginatedResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problems [get]
+func QueryTasksHandler(c *fiber.Ctx) error {
+	search, sortParam, err := parseTaskSearch(c.Query)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	search.Limit = utils.GetPaginationParams(c).Limit
+
+	result, err := repos.Tasks.Search(c.UserContext(), search)
+	if err != nil {
+		log.Printf("Error searching tasks: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to query tasks"})
+	}
+
+	page := models.CursorPaginationResponse{
+		Limit:   search.Limit,
+		Total:   result.Total,
+		HasMore: result.NextKeys != nil,
+	}
+	if result.NextKeys != nil {
+		page.NextCursor = encodeTaskCursor(taskCursor{Sort: sortParam, Keys: result.NextKeys})
+	}
+	return c.JSON(models.CursorPaginatedResponse{Success: true, Data: result.Tasks, Pagination: page})
+}
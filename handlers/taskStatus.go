@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"reports-api/handlers/common"
+	"reports-api/models"
+	"reports-api/repository"
+	"reports-api/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary List task statuses
+// @Description List every task status with its Thai label and the statuses it may move to
+// @Tags problems
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/problem/statuses [get]
+func ListTaskStatusesHandler(c *fiber.Ctx) error {
+	statuses := make([]models.TaskStatusInfo, 0, len(models.TaskStatuses))
+	for _, s := range models.TaskStatuses {
+		statuses = append(statuses, models.TaskStatusInfo{
+			Value:       s,
+			Name:        s.String(),
+			Label:       s.Label(),
+			Transitions: s.Transitions(),
+		})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": statuses})
+}
+
+// @Summary Change task status
+// @Description Move a task to another status; illegal transitions and changes to another version are rejected with 409
+// @Tags problems
+// @Accept json
+// @Produce json
+// @Param id path string true "Problem ID"
+// @Param If-Match header string true "Version of the problem being edited"
+// @Param request body models.TaskStatusUpdateRequest true "New status and reason"
+// @Success 200 {object} map[string]interface{}
+// @Header 200 {string} ETag "Version of the problem after the change"
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 428 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problem/status/{id} [put]
+func UpdateTaskStatusHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	var req models.TaskStatusUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if !req.Status.Valid() {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid status"})
+	}
+
+	actor, err := utils.AuditActor(c, &req.UpdatedBy)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	task, err := repos.Tasks.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
+		}
+		log.Printf("Failed to get task %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	// เปลี่ยนสถานะได้เฉพาะเวอร์ชันที่ผู้ใช้เห็นล่าสุด
+	version, err := utils.IfMatchVersion(c)
+	if err != nil {
+		return utils.IfMatchError(c, err)
+	}
+	if task.Version != version {
+		return taskConflict(c, id)
+	}
+
+	var changed bool
+	committedVersion := version
+	err = repos.InTx(ctx, func(tx *repository.Repositories) error {
+		var err error
+		changed, err = common.TransitionTaskVersion(ctx, tx, id, version, task.Status, req.Status, actor, req.Reason)
+		if err != nil || !changed {
+			return err
+		}
+		after, err := tx.Tasks.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		committedVersion = after.Version
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidStatusTransition) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
+		if errors.Is(err, models.ErrVersionConflict) {
+			return taskConflict(c, id)
+		}
+		log.Printf("Failed to change status of task %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update status"})
+	}
+	utils.SetETag(c, committedVersion)
+	if !changed {
+		return c.JSON(fiber.Map{"success": true, "message": "Status unchanged"})
+	}
+
+	// อัปเดตสถานะในข้อความหลักของ Telegram หลัง commit
+	// updateTaskTelegram ล็อกข้อความของ task และวาดจากสถานะล่าสุดที่ commit แล้ว จึงไม่ทับการแก้ไขที่ใหม่กว่า
+	if err := updateTaskTelegram(ctx, id, false); err != nil {
+		log.Printf("Failed to update Telegram status for task %d: %v", id, err)
+	}
+
+	return c.JSON(fiber.Map{"success": true, "message": "Status updated successfully"})
+}
+
+// @Summary Get task status history
+// @Description List every status change of a task, oldest first
+// @Tags problems
+// @Produce json
+// @Param id path string true "Problem ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problem/status/history/{id} [get]
+func GetTaskStatusHistoryHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	history, err := repos.StatusHistory.ListByTask(c.UserContext(), id)
+	if err != nil {
+		log.Printf("Failed to get status history of task %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get status history"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": history})
+}
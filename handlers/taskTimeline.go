@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"reports-api/handlers/common"
+	"reports-api/repository"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary Get task timeline
+// @Description Merge the creation, edits, assignments, status changes, progress entries and resolution events of a task, oldest first
+// @Tags problems
+// @Produce json
+// @Param id path string true "Problem ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problem/{id}/timeline [get]
+func GetTaskTimelineHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	events, err := common.TaskTimeline(c.UserContext(), repos, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Task not found"})
+		}
+		log.Printf("Failed to get timeline of task %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get timeline"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": events})
+}
+
+// @Summary Get task change log
+// @Description List the field-level changes of a task, its progress entries and its resolution, oldest first
+// @Tags problems
+// @Produce json
+// @Param id path string true "Problem ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/problem/{id}/changes [get]
+func GetTaskChangesHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+
+	changes, err := repos.Changes.ListByTask(c.UserContext(), id)
+	if err != nil {
+		log.Printf("Failed to get changes of task %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get changes"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": changes})
+}
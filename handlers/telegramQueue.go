@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reports-api/handlers/common"
+	"reports-api/models"
+	"reports-api/repository"
+	"sync"
+	"time"
+)
+
+// telegramQueue holds the tasks whose Telegram report message must be redrawn.
+// A task queued several times is redrawn once; the value asks to notify its assignee.
+var telegramQueue = struct {
+	sync.Mutex
+	pending map[int]bool
+}{pending: map[int]bool{}}
+
+// taskTelegramLocks serialise the Telegram edits of a task, so an edit built from an older version of the
+// task cannot land after one built from a newer version; tasks share the stripes by id
+var taskTelegramLocks [64]sync.Mutex
+
+// lockTaskTelegram locks the Telegram messages of a task and returns the unlock function.
+// Read the task after locking and skip the edit when it is newer than the version being published.
+func lockTaskTelegram(id int) func() {
+	l := &taskTelegramLocks[id%len(taskTelegramLocks)]
+	l.Lock()
+	return l.Unlock
+}
+
+// queueTaskTelegram queues a redraw of the report message of each task
+func queueTaskTelegram(notifyAssignee bool, ids ...int) {
+	telegramQueue.Lock()
+	defer telegramQueue.Unlock()
+	for _, id := range ids {
+		telegramQueue.pending[id] = telegramQueue.pending[id] || notifyAssignee
+	}
+}
+
+// nextQueuedTask removes and returns the queued task with the lowest id
+func nextQueuedTask() (id int, notifyAssignee, ok bool) {
+	telegramQueue.Lock()
+	defer telegramQueue.Unlock()
+	for queued := range telegramQueue.pending {
+		if !ok || queued < id {
+			id, ok = queued, true
+		}
+	}
+	if ok {
+		notifyAssignee = telegramQueue.pending[id]
+		delete(telegramQueue.pending, id)
+	}
+	return id, notifyAssignee, ok
+}
+
+// RunTelegramQueue redraws one queued task every interval until ctx is done, so bulk changes
+// stay under the Telegram rate limit
+func RunTelegramQueue(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			id, notify, ok := nextQueuedTask()
+			if !ok {
+				continue
+			}
+			if err := updateTaskTelegram(ctx, id, notify); err != nil {
+				log.Printf("Failed to update Telegram for task %d: %v", id, err)
+			}
+		}
+	}
+}
+
+// updateTaskTelegram redraws the report message of a task from its stored state; a trashed task is skipped.
+// With notifyAssignee the previous assignment notification is replaced by a new one.
+func updateTaskTelegram(ctx context.Context, id int, notifyAssignee bool) error {
+	unlock := lockTaskTelegram(id)
+	defer unlock()
+
+	task, err := repos.Tasks.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if task.ReportMessageID <= 0 {
+		return nil
+	}
+	taskReq := resolutionTelegramRequest(ctx, task, task.Status)
+	taskReq.IssueElse = task.IssueElse
+	taskReq.TelegramUser = task.AssigneeTelegram
+	taskReq.PreviousAssignto = task.Assignto // ไม่ส่งแจ้งเตือนมอบหมายงานซ้ำ
+	taskReq.UpdatedAt = common.Fixtimefeature(task.UpdatedAt)
+	if task.Status == models.TaskStatusDone {
+		taskReq.ResolvedAt = common.Fixtimefeature(task.ResolvedAt)
+	}
+	if notifyAssignee {
+		if task.AssigneeMessageID > 0 {
+			_, _ = common.DeleteTelegram(task.AssigneeMessageID)
+		}
+		taskReq.PreviousAssignto = ""
+	}
+
+	assigneeMessageID, err := common.UpdateTelegram(taskReq, getPhotoURLs(task.FilePaths)...)
+	if err != nil {
+		return err
+	}
+	if notifyAssignee {
+		return repos.TelegramChats.SetAssigneeMessage(ctx, task.TelegramID, assigneeMessageID)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"reports-api/handlers/common"
+	"reports-api/models"
+	"reports-api/repository"
+	"reports-api/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary List trash
+// @Description List soft-deleted records of one kind, most recently deleted first.
+// @Description Kinds: tasks, resolutions, progress, branches, departments, ipphones, programs, users.
+// @Tags trash
+// @Produce json
+// @Param kind path string true "Trash kind"
+// @Param q query string false "Label contains"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} models.PaginatedResponse
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/trash/{kind} [get]
+func ListTrashHandler(kind string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pagination := utils.GetPaginationParams(c)
+		offset := utils.CalculateOffset(pagination.Page, pagination.Limit)
+
+		items, total, err := repos.Trash.List(c.UserContext(), kind, repository.ListQuery{
+			Search: strings.TrimSpace(c.Query("q")),
+			Limit:  pagination.Limit,
+			Offset: offset,
+		})
+		if err != nil {
+			log.Printf("Error listing trashed %s: %v", kind, err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to query trash"})
+		}
+
+		return c.JSON(models.PaginatedResponse{
+			Success: true,
+			Data:    items,
+			Pagination: models.PaginationResponse{
+				Page:       pagination.Page,
+				Limit:      pagination.Limit,
+				Total:      total,
+				TotalPages: utils.CalculateTotalPages(total, pagination.Limit),
+			},
+		})
+	}
+}
+
+// @Summary Restore from trash
+// @Description Restore a soft-deleted record. A restored resolution is linked to its task again and the task is marked done;
+// @Description this fails with 409 when the task already has another resolution or is itself in the trash.
+// @Tags trash
+// @Produce json
+// @Param kind path string true "Trash kind"
+// @Param id path int true "Record ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/trash/{kind}/restore/{id} [put]
+func RestoreTrashHandler(kind string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+		}
+
+		actor, err := utils.AuditActor(c)
+		if err != nil {
+			return utils.AuditActorError(c, err)
+		}
+
+		if err := common.RestoreTrashed(c.UserContext(), repos, kind, id, actor); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				return c.Status(404).JSON(fiber.Map{"error": "Record not found in trash"})
+			case errors.Is(err, models.ErrRestoreConflict), errors.Is(err, models.ErrInvalidStatusTransition):
+				return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+			}
+			log.Printf("Error restoring %s %d: %v", kind, id, err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to restore record"})
+		}
+
+		log.Printf("Restored %s ID: %d by user ID: %d", kind, id, actor)
+		return c.JSON(fiber.Map{"success": true})
+	}
+}
+
+// @Summary Purge from trash
+// @Description Permanently delete a soft-deleted record with its attachments; a task also loses its progress,
+// @Description resolutions, history and Telegram messages
+// @Tags trash
+// @Produce json
+// @Param kind path string true "Trash kind"
+// @Param id path int true "Record ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/trash/{kind}/purge/{id} [delete]
+func PurgeTrashHandler(kind string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+		}
+
+		actor, err := utils.AuditActor(c)
+		if err != nil {
+			return utils.AuditActorError(c, err)
+		}
+
+		if err := common.PurgeTrashed(c.UserContext(), repos, kind, id); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				return c.Status(404).JSON(fiber.Map{"error": "Record not found in trash"})
+			case errors.Is(err, models.ErrHasDependents):
+				return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+			}
+			log.Printf("Error purging %s %d: %v", kind, id, err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to purge record"})
+		}
+
+		log.Printf("Purged %s ID: %d by user ID: %d", kind, id, actor)
+		return c.JSON(fiber.Map{"success": true})
+	}
+}
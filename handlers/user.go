@@ -1,34 +1,69 @@
 package handlers
 
 import (
+	"errors"
 	"log"
-	"math/rand"
+	"math"
+	"reports-api/config"
 	"reports-api/db"
+	"reports-api/handlers/common"
 	"reports-api/models"
+	"reports-api/utils"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var sessions = map[string]string{}
+// setTokenCookies stores the issued tokens as HTTP-only cookies for browser clients
+func setTokenCookies(c *fiber.Ctx, tokens models.TokenPair) {
+	secure := config.AppConfig.Environment == "prod"
+	c.Cookie(&fiber.Cookie{
+		Name:     utils.AccessTokenCookie,
+		Value:    tokens.AccessToken,
+		Path:     "/",
+		HTTPOnly: true,
+		Secure:   secure,
+		SameSite: "Lax",
+		MaxAge:   int(tokens.ExpiresIn),
+	})
+	c.Cookie(&fiber.Cookie{
+		Name:     utils.RefreshTokenCookie,
+		Value:    tokens.RefreshToken,
+		Path:     "/api/authEntry",
+		HTTPOnly: true,
+		Secure:   secure,
+		SameSite: "Lax",
+		MaxAge:   int(tokens.RefreshExpiresIn),
+	})
+}
 
-func generateSessionID() string {
-	// amazonq-ignore-next-line
-	return strconv.FormatInt(rand.Int63(), 20)
+// clearTokenCookies expires the token cookies
+func clearTokenCookies(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{Name: utils.AccessTokenCookie, Value: "", Path: "/", MaxAge: -1, HTTPOnly: true, Expires: time.Unix(0, 0)})
+	c.Cookie(&fiber.Cookie{Name: utils.RefreshTokenCookie, Value: "", Path: "/api/authEntry", MaxAge: -1, HTTPOnly: true, Expires: time.Unix(0, 0)})
 }
 
-func generateDummyToken() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	token := make([]byte, 32)
-	for i := range token {
-		token[i] = charset[rand.Intn(len(charset))]
+// recordLogin writes a login attempt to the audit trail; failures are logged but never block the login
+func recordLogin(c *fiber.Ctx, userID *int, username string, success bool, reason string) {
+	if err := common.RecordLoginHistory(userID, username, c.IP(), c.Get(fiber.HeaderUserAgent), success, reason); err != nil {
+		log.Printf("Error recording login history for %s: %v", username, err)
 	}
-	return string(token)
+}
+
+// lockedResponse tells the client how long the account stays locked
+func lockedResponse(c *fiber.Ctx, remaining time.Duration) error {
+	seconds := int(math.Ceil(remaining.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return c.Status(423).JSON(fiber.Map{
+		"error":       "Account temporarily locked due to too many failed login attempts",
+		"retry_after": seconds,
+	})
 }
 
 // @Summary User login
-// @Description Authenticate user and create session
+// @Description Authenticate user and issue signed access and refresh tokens
 // @Tags authentication
 // @Accept json
 // @Produce json
@@ -36,6 +71,7 @@ func generateDummyToken() string {
 // @Success 200 {object} models.LoginResponse
 // @Failure 400 {object} map[string]interface{}
 // @Failure 401 {object} map[string]interface{}
+// @Failure 423 {object} map[string]interface{}
 // @Router /api/authEntry/login [post]
 func LoginHandler(c *fiber.Ctx) error {
 	var credentials models.Credentials
@@ -43,41 +79,110 @@ func LoginHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
+	// ตรวจสอบว่าบัญชีถูกล็อกจากการล็อกอินผิดหลายครั้งหรือไม่
+	remaining, err := common.LoginLockRemaining(credentials.Username)
+	if err != nil {
+		log.Printf("Error checking login lockout for %s: %v", credentials.Username, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Login temporarily unavailable"})
+	}
+	if remaining > 0 {
+		recordLogin(c, nil, credentials.Username, false, common.LoginFailureLocked)
+		return lockedResponse(c, remaining)
+	}
+
 	var id int
 	var username, password string
 	var role string
-	err := db.DB.QueryRow("SELECT id, username, password, role FROM users WHERE username = ? AND deleted_at IS NULL", credentials.Username).Scan(&id, &username, &password, &role)
+	err = db.DB.QueryRow("SELECT id, username, password, role FROM users WHERE username = ? AND deleted_at IS NULL", credentials.Username).Scan(&id, &username, &password, &role)
+	if err == nil {
+		err = bcrypt.CompareHashAndPassword([]byte(password), []byte(credentials.Password))
+	}
 	if err != nil {
+		var userID *int
+		if id > 0 {
+			userID = &id
+		}
+		recordLogin(c, userID, credentials.Username, false, common.LoginFailureBadCredentials)
+
+		lockFor, lockErr := common.RecordLoginFailure(credentials.Username)
+		if lockErr != nil {
+			log.Printf("Error recording login failure for %s: %v", credentials.Username, lockErr)
+		}
+		if lockFor > 0 {
+			log.Printf("Login for %s locked for %s after repeated failures", credentials.Username, lockFor)
+			return lockedResponse(c, lockFor)
+		}
 		return c.Status(401).JSON(fiber.Map{"error": "Invalid username or password"})
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(password), []byte(credentials.Password)); err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Invalid username or password"})
+	if err := common.ResetLoginFailures(username); err != nil {
+		log.Printf("Error resetting login failures for %s: %v", username, err)
 	}
-	sessionID := generateSessionID()
-	sessions[sessionID] = username
+	recordLogin(c, &id, username, true, "")
 
-	c.Set("role", role)
-	c.Set("token", generateDummyToken())
+	tokens, err := common.IssueTokenPair(id, username, role)
+	if err != nil {
+		log.Printf("Error issuing tokens for user %s: %v", username, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to issue tokens"})
+	}
 
-	c.Cookie(&fiber.Cookie{
-		Name:     "session_cookie",
-		Value:    sessionID,
-		Path:     "/",
-		HTTPOnly: true,
-		MaxAge:   3600 * 24,
-	})
+	c.Set("role", role)
+	c.Set("token", tokens.AccessToken)
+	setTokenCookies(c, tokens)
 
 	log.Printf("User %s logged in successfully", username)
 	return c.JSON(models.LoginResponse{
 		Success: true,
 		Message: "Login successful",
+		Token:   tokens.AccessToken,
+		Tokens:  &tokens,
 		Data:    &models.Data{ID: id, Username: username, Role: role},
 	})
 }
 
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access and refresh token pair
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshTokenRequest false "Refresh token (falls back to the refresh_token cookie)"
+// @Success 200 {object} models.LoginResponse
+// @Failure 401 {object} map[string]interface{}
+// @Router /api/authEntry/refresh [post]
+func RefreshTokenHandler(c *fiber.Ctx) error {
+	var req models.RefreshTokenRequest
+	_ = c.BodyParser(&req)
+	if req.RefreshToken == "" {
+		req.RefreshToken = c.Cookies(utils.RefreshTokenCookie)
+	}
+	if req.RefreshToken == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Missing refresh token"})
+	}
+
+	tokens, claims, err := common.RotateRefreshToken(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, common.ErrInvalidToken) || errors.Is(err, common.ErrExpiredToken) || errors.Is(err, common.ErrRevokedToken) {
+			clearTokenCookies(c)
+			return c.Status(401).JSON(fiber.Map{"error": "Invalid refresh token"})
+		}
+		log.Printf("Error refreshing tokens: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to refresh tokens"})
+	}
+
+	c.Set("token", tokens.AccessToken)
+	setTokenCookies(c, tokens)
+
+	log.Printf("Tokens refreshed for user ID: %d", claims.UserID)
+	return c.JSON(models.LoginResponse{
+		Success: true,
+		Message: "Token refreshed",
+		Token:   tokens.AccessToken,
+		Tokens:  &tokens,
+	})
+}
+
 // @Summary Register user
-// @Description Register a new user or admin
+// @Description Register a new user, technician or admin
 // @Tags authentication
 // @Accept json
 // @Produce json
@@ -93,24 +198,34 @@ func RegisterHandler(role string) fiber.Handler {
 		if err := c.BodyParser(&req); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 		}
+		if !common.RoleExists(role) {
+			return c.Status(400).JSON(fiber.Map{"error": "Unknown role: " + role})
+		}
+		actor, err := utils.AuditActor(c, &req.CreatedBy)
+		if err != nil {
+			return utils.AuditActorError(c, err)
+		}
 
 		var count int
-		err := db.DB.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", req.Username).Scan(&count)
+		err = db.DB.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", req.Username).Scan(&count)
 		if err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Database error"})
 		}
 		if count > 0 {
 			return c.Status(409).JSON(fiber.Map{"error": "Username already exists"})
 		}
+		if err := common.ValidatePassword(req.Password); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
 
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		hashedPassword, err := common.HashPassword(req.Password)
 		if err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Failed to hash password"})
 		}
 
 		_, err = db.DB.Exec(
-			"INSERT INTO users (username, password, plain_password, role) VALUES (?, ?, ?, ?)",
-			req.Username, string(hashedPassword), req.Password, role,
+			"INSERT INTO users (username, password, role) VALUES (?, ?, ?)",
+			req.Username, hashedPassword, role,
 		)
 
 		if err != nil {
@@ -118,7 +233,7 @@ func RegisterHandler(role string) fiber.Handler {
 			return c.Status(500).JSON(fiber.Map{"error": "Failed to register user"})
 		}
 
-		log.Printf("User %s registered successfully as %s", req.Username, role)
+		log.Printf("User %s registered successfully as %s by user ID: %d", req.Username, role, actor)
 		return c.JSON(fiber.Map{
 			"message":  "Registered as " + role,
 			"username": req.Username,
@@ -128,7 +243,7 @@ func RegisterHandler(role string) fiber.Handler {
 }
 
 // @Summary Update user
-// @Description Update user information
+// @Description Update username and role; the password is only changed when a new one is sent
 // @Tags users
 // @Accept json
 // @Produce json
@@ -152,22 +267,32 @@ func UpdateUserHandler(c *fiber.Ctx) error {
 	if count == 0 {
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
 	}
-
-	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to hash password"})
+	if !common.RoleExists(req.Role) {
+		return c.Status(400).JSON(fiber.Map{"error": "Unknown role: " + req.Role})
+	}
+	if req.Password != "" {
+		if err := common.ValidatePassword(req.Password); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
 	}
 
 	_, err = db.DB.Exec(
-		"UPDATE users SET username = ?, password = ?, plain_password = ?, role = ?, updated_at=CURRENT_TIMESTAMP WHERE id = ?",
-		req.Username, string(hashedPassword), req.Password, req.Role, req.ID,
+		"UPDATE users SET username = ?, role = ?, updated_at=CURRENT_TIMESTAMP WHERE id = ?",
+		req.Username, req.Role, req.ID,
 	)
 	if err != nil {
 		log.Printf("Error updating user: %v", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update user"})
 	}
 
+	// Rehash only when the admin actually sets a new password
+	if req.Password != "" {
+		if err := common.SetUserPassword(req.ID, req.Password); err != nil {
+			log.Printf("Error updating password for user ID %d: %v", req.ID, err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to update password"})
+		}
+	}
+
 	log.Printf("Updating user ID: %d with username: %s", req.ID, req.Username)
 	return c.JSON(fiber.Map{
 		"message":  "User updated",
@@ -183,6 +308,7 @@ func UpdateUserHandler(c *fiber.Ctx) error {
 // @Param user body models.DeleteUserRequest true "User delete data"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/authEntry/deleteUser [delete]
 func DeleteUserHandler(c *fiber.Ctx) error {
@@ -191,38 +317,60 @@ func DeleteUserHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// Hard delete - remove user completely from database
-	_, err := db.DB.Exec(
-		"DELETE FROM users WHERE id = ?",
-		req.ID,
+	actor, err := utils.AuditActor(c, &req.DeletedBy)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	// Soft delete - ผู้ใช้จะอยู่ในถังขยะจนกว่าจะ restore หรือครบกำหนด purge
+	result, err := db.DB.Exec(
+		"UPDATE users SET deleted_at = CURRENT_TIMESTAMP, deleted_by = ? WHERE id = ? AND deleted_at IS NULL",
+		actor, req.ID,
 	)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete user"})
 	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	if err := common.RevokeUserRefreshTokens(req.ID); err != nil {
+		log.Printf("Error revoking refresh tokens for user ID %d: %v", req.ID, err)
+	}
 
-	log.Printf("User ID: %d permanently deleted by user ID: %d", req.ID, req.DeletedBy)
-	return c.JSON(fiber.Map{"message": "User permanently deleted"})
+	log.Printf("User ID: %d moved to trash by user ID: %d", req.ID, actor)
+	return c.JSON(fiber.Map{"message": "User deleted"})
 }
 
 // @Summary User logout
-// @Description Log out user and clear session
+// @Description Log out user and revoke the presented access and refresh tokens
 // @Tags authentication
 // @Accept json
 // @Produce json
+// @Param request body models.RefreshTokenRequest false "Refresh token to revoke (falls back to the refresh_token cookie)"
 // @Success 200 {object} map[string]interface{}
 // @Router /api/authEntry/logout [post]
 func LogoutHandler(c *fiber.Ctx) error {
-	sessionID := c.Cookies("session_cookie")
-	if sessionID != "" {
-		delete(sessions, sessionID)
+	if claims, err := common.ParseToken(utils.BearerToken(c)); err == nil {
+		if err := common.RevokeToken(claims); err != nil {
+			log.Printf("Error revoking access token: %v", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke token"})
+		}
 	}
-	c.Cookie(&fiber.Cookie{
-		Name:     "session_cookie",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HTTPOnly: true,
-	})
+
+	var req models.RefreshTokenRequest
+	_ = c.BodyParser(&req)
+	if req.RefreshToken == "" {
+		req.RefreshToken = c.Cookies(utils.RefreshTokenCookie)
+	}
+	if claims, err := common.ParseToken(req.RefreshToken); err == nil && claims.TokenType == common.TokenTypeRefresh {
+		if err := common.RevokeToken(claims); err != nil {
+			log.Printf("Error revoking refresh token: %v", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke token"})
+		}
+	}
+
+	clearTokenCookies(c)
 
 	log.Printf("User logged out successfully")
 	return c.JSON(fiber.Map{"message": "Logged out"})
@@ -370,7 +518,7 @@ func GetResponsDetailHandler(c *fiber.Ctx) error {
 }
 
 // @Summary Get all users
-// @Description Get all users with username, plain_password and role
+// @Description Get all users with username and role
 // @Tags users
 // @Accept json
 // @Produce json
@@ -378,7 +526,7 @@ func GetResponsDetailHandler(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/authEntry/users [get]
 func GetAllUsersHandler(c *fiber.Ctx) error {
-	rows, err := db.DB.Query("SELECT id, username, IFNULL(plain_password, '') as plain_password, role FROM users WHERE deleted_at IS NULL")
+	rows, err := db.DB.Query("SELECT id, username, role FROM users WHERE deleted_at IS NULL")
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
 	}
@@ -387,7 +535,7 @@ func GetAllUsersHandler(c *fiber.Ctx) error {
 	var users []models.UsernameResponse
 	for rows.Next() {
 		var user models.UsernameResponse
-		if err := rows.Scan(&user.ID, &user.Username, &user.PlainPassword, &user.Role); err != nil {
+		if err := rows.Scan(&user.ID, &user.Username, &user.Role); err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Database error"})
 		}
 		users = append(users, user)
@@ -400,7 +548,7 @@ func GetAllUsersHandler(c *fiber.Ctx) error {
 }
 
 // @Summary Get user details
-// @Description Get detailed information about a specific user including username, plain_password and role
+// @Description Get detailed information about a specific user including username and role
 // @Tags users
 // @Accept json
 // @Produce json
@@ -417,7 +565,7 @@ func GetUserDetailHandler(c *fiber.Ctx) error {
 	}
 
 	var user models.UsernameResponse
-	err = db.DB.QueryRow("SELECT id, username, IFNULL(plain_password, '') as plain_password, role FROM users WHERE id = ? AND deleted_at IS NULL", id).Scan(&user.ID, &user.Username, &user.PlainPassword, &user.Role)
+	err = db.DB.QueryRow("SELECT id, username, role FROM users WHERE id = ? AND deleted_at IS NULL", id).Scan(&user.ID, &user.Username, &user.Role)
 
 	if err != nil {
 		log.Printf("Error fetching user details: %v", err)
@@ -428,10 +576,205 @@ func GetUserDetailHandler(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data": fiber.Map{
-			"id":             user.ID,
-			"username":       user.Username,
-			"plain_password": user.PlainPassword,
-			"role":           user.Role,
+			"id":       user.ID,
+			"username": user.Username,
+			"role":     user.Role,
+		},
+	})
+}
+
+// @Summary Change password
+// @Description Change the caller's own password; the current password is required
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Router /api/authEntry/password [put]
+func ChangePasswordHandler(c *fiber.Ctx) error {
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	var req models.ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := common.ValidatePassword(req.NewPassword); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := common.ChangePassword(actor, req.OldPassword, req.NewPassword); err != nil {
+		if errors.Is(err, common.ErrWrongPassword) {
+			return c.Status(401).JSON(fiber.Map{"error": "Current password is incorrect"})
+		}
+		log.Printf("Error changing password for user ID %d: %v", actor, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to change password"})
+	}
+
+	log.Printf("User ID: %d changed their password", actor)
+	return c.JSON(fiber.Map{"message": "Password changed, please log in again on other devices"})
+}
+
+// @Summary Issue password reset token
+// @Description Issue a one-time password reset token for a user (admin only)
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} models.PasswordResetResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/authEntry/user/{id}/password-reset [post]
+func CreatePasswordResetHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid id"})
+	}
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	var count int
+	if err := db.DB.QueryRow("SELECT COUNT(*) FROM users WHERE id = ? AND deleted_at IS NULL", id).Scan(&count); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
+	}
+	if count == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	token, expiresAt, err := common.CreatePasswordResetToken(id, actor)
+	if err != nil {
+		log.Printf("Error creating password reset token for user ID %d: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create reset token"})
+	}
+
+	log.Printf("Password reset token issued for user ID: %d by user ID: %d", id, actor)
+	return c.JSON(models.PasswordResetResponse{
+		UserID:     id,
+		ResetToken: token,
+		ExpiresAt:  expiresAt.Format(time.RFC3339),
+	})
+}
+
+// @Summary Reset password
+// @Description Set a new password using a one-time reset token
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /api/authEntry/password/reset [post]
+func ResetPasswordHandler(c *fiber.Ctx) error {
+	var req models.ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil || req.ResetToken == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := common.ValidatePassword(req.NewPassword); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	userID, err := common.ResetPasswordWithToken(req.ResetToken, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, common.ErrInvalidResetToken) {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid or expired reset token"})
+		}
+		log.Printf("Error resetting password: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reset password"})
+	}
+
+	log.Printf("Password reset completed for user ID: %d", userID)
+	return c.JSON(fiber.Map{"message": "Password has been reset"})
+}
+
+// @Summary Unlock user login
+// @Description Clear the failed login counter and lockout of a username (admin only)
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body models.UnlockUserRequest true "Username to unlock"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Router /api/authEntry/users/unlock [post]
+func UnlockUserHandler(c *fiber.Ctx) error {
+	var req models.UnlockUserRequest
+	if err := c.BodyParser(&req); err != nil || req.Username == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	actor, err := utils.AuditActor(c)
+	if err != nil {
+		return utils.AuditActorError(c, err)
+	}
+
+	unlocked, err := common.UnlockLogin(req.Username)
+	if err != nil {
+		log.Printf("Error unlocking login for %s: %v", req.Username, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to unlock user"})
+	}
+
+	log.Printf("Login for %s unlocked by user ID: %d", req.Username, actor)
+	return c.JSON(fiber.Map{"success": true, "username": req.Username, "unlocked": unlocked})
+}
+
+// @Summary Get login history
+// @Description Get the login audit trail (timestamp, IP, user agent, result), newest first
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param username query string false "Filter by username"
+// @Param user_id query int false "Filter by user ID"
+// @Param success query bool false "Filter by result"
+// @Param from query string false "From date (YYYY-MM-DD)"
+// @Param to query string false "To date inclusive (YYYY-MM-DD)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} models.PaginatedResponse
+// @Failure 400 {object} map[string]interface{}
+// @Router /api/authEntry/login-history [get]
+func GetLoginHistoryHandler(c *fiber.Ctx) error {
+	pagination := utils.GetPaginationParams(c)
+	filter := common.LoginHistoryFilter{
+		Username: c.Query("username"),
+		UserID:   c.QueryInt("user_id"),
+		From:     c.Query("from"),
+		To:       c.Query("to"),
+		Limit:    pagination.Limit,
+		Offset:   utils.CalculateOffset(pagination.Page, pagination.Limit),
+	}
+	if s := c.Query("success"); s != "" {
+		success, err := strconv.ParseBool(s)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid success filter"})
+		}
+		filter.Success = &success
+	}
+	for _, date := range []string{filter.From, filter.To} {
+		if date != "" {
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "Dates must be formatted as YYYY-MM-DD"})
+			}
+		}
+	}
+
+	history, total, err := common.ListLoginHistory(filter)
+	if err != nil {
+		log.Printf("Error fetching login history: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch login history"})
+	}
+
+	return c.JSON(models.PaginatedResponse{
+		Success: true,
+		Data:    history,
+		Pagination: models.PaginationResponse{
+			Page:       pagination.Page,
+			Limit:      pagination.Limit,
+			Total:      total,
+			TotalPages: utils.CalculateTotalPages(total, pagination.Limit),
 		},
 	})
 }
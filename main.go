@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"reports-api/db"
+	"reports-api/handlers"
+	"reports-api/handlers/common"
+	"reports-api/models"
+	"reports-api/repository"
+	"strconv"
 	"time"
 
 	_ "reports-api/docs"
@@ -84,16 +91,18 @@ func main() {
 
 	// Check flags and positional arguments
 	selectedEnv := *env
+	args := flag.Args()
+	if len(args) > 0 && (args[0] == "dev" || args[0] == "prod") {
+		// Check if environment is passed as a positional argument
+		if selectedEnv == "" {
+			selectedEnv = args[0]
+		}
+		args = args[1:]
+	}
 	if *devFlag {
 		selectedEnv = "dev"
 	} else if *prodFlag {
 		selectedEnv = "prod"
-	} else if len(flag.Args()) > 0 && selectedEnv == "" {
-		// Check if environment is passed as a positional argument
-		arg := flag.Args()[0]
-		if arg == "dev" || arg == "prod" {
-			selectedEnv = arg
-		}
 	}
 
 	// Load environment variables based on environment
@@ -117,6 +126,13 @@ func main() {
 
 	// Load environment and initialize config
 	initConfig(envFile)
+	migrateCommand := len(args) > 0 && args[0] == "migrate"
+	ticketsCommand := len(args) > 0 && args[0] == "tickets"
+	searchCommand := len(args) > 0 && args[0] == "search"
+	if config.AppConfig.TokenSecret == "" && !migrateCommand && !ticketsCommand && !searchCommand {
+		logger.Error.Println("❌ TOKEN_SECRET is required to sign access tokens")
+		log.Fatal("TOKEN_SECRET environment variable is required")
+	}
 
 	// Initialize database connection
 	logger.Info.Println("🔌 Initializing database connection...")
@@ -132,19 +148,111 @@ func main() {
 		}
 	}()
 
+	// `reports-api [dev|prod] migrate ...` manages the schema and exits without starting the server
+	if migrateCommand {
+		if err := runMigrate(args[1:]); err != nil {
+			logger.Error.Printf("❌ Migration failed: %v", err)
+			db.DB.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// `reports-api [dev|prod] tickets duplicates` reports ticket numbers used by more than one task
+	if ticketsCommand {
+		if err := runTickets(args[1:]); err != nil {
+			logger.Error.Printf("❌ Ticket check failed: %v", err)
+			db.DB.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// `reports-api [dev|prod] search reindex` rebuilds the full-text search index
+	if searchCommand {
+		if err := runSearch(args[1:]); err != nil {
+			logger.Error.Printf("❌ Search command failed: %v", err)
+			db.DB.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if config.AppConfig.AutoMigrate {
+		applied, err := db.MigrateUp()
+		if err != nil {
+			logger.Error.Printf("❌ Migration failed: %v", err)
+			log.Fatalf("Migration failed: %v", err)
+		}
+		logger.Info.Printf("🗄️ Database schema up to date (%d migration(s) applied)", applied)
+	}
+
+	repos := repository.New(db.DB)
+	handlers.SetRepositories(repos)
+
+	// Periodically purge expired token revocations, refresh tokens, password reset tokens, trash and idempotency keys
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := common.PurgeExpiredTokens(); err != nil {
+				logger.Warn.Printf("⚠️ Failed to purge expired tokens: %v", err)
+			}
+			if err := common.PurgeExpiredResetTokens(); err != nil {
+				logger.Warn.Printf("⚠️ Failed to purge expired password reset tokens: %v", err)
+			}
+			if err := middleware.PurgeRateLimitCounters(); err != nil {
+				logger.Warn.Printf("⚠️ Failed to purge expired rate limit counters: %v", err)
+			}
+			if purged, err := common.PurgeExpiredTrash(context.Background(), repos, time.Now()); err != nil {
+				logger.Warn.Printf("⚠️ Failed to purge expired trash: %v", err)
+			} else if purged > 0 {
+				logger.Info.Printf("🗑️ Purged %d expired trash record(s)", purged)
+			}
+			if _, err := repos.Idempotency.PurgeExpired(context.Background(), time.Now().UTC()); err != nil {
+				logger.Warn.Printf("⚠️ Failed to purge expired idempotency keys: %v", err)
+			}
+		}
+	}()
+
+	// Raise SLA warnings and breaches for open tasks
+	go func() {
+		ticker := time.NewTicker(config.AppConfig.SLA.CheckInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if raised, err := common.CheckSLA(context.Background(), repos, now); err != nil {
+				logger.Warn.Printf("⚠️ SLA check failed: %v", err)
+			} else if raised > 0 {
+				logger.Info.Printf("⏰ SLA check raised %d event(s)", raised)
+			}
+		}
+	}()
+
+	// Redraw the Telegram messages of tasks changed in bulk, one per interval
+	go handlers.RunTelegramQueue(context.Background(), config.AppConfig.Bulk.TelegramInterval)
+
 	// Create Fiber app
-	app := fiber.New(fiber.Config{
+	fiberConfig := fiber.Config{
 		AppName:      "Reports API",
 		BodyLimit:    50 * 1024 * 1024, // 50MB limit for file uploads
 		ReadTimeout:  time.Second * 30,
 		WriteTimeout: time.Second * 30,
-	})
+	}
+	// c.IP() อ่าน PROXY_HEADER เฉพาะ request ที่มาจาก TRUSTED_PROXIES เท่านั้น
+	if len(config.AppConfig.TrustedProxies) > 0 {
+		fiberConfig.ProxyHeader = config.AppConfig.ProxyHeader
+		fiberConfig.EnableTrustedProxyCheck = true
+		fiberConfig.TrustedProxies = config.AppConfig.TrustedProxies
+		fiberConfig.EnableIPValidation = true
+	}
+	app := fiber.New(fiberConfig)
 
 	// Add CORS middleware
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:5000,http://localhost:3000,http://helpdesk.nopadol.com,http://helpdesk-dev.nopadol.com,http://10.0.2.94:3000,http://192.168.1.81:3000",
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS,PATCH",
-		AllowHeaders:     "Content-Type,Authorization,X-Requested-With",
+		AllowHeaders:     "Content-Type,Authorization,X-Requested-With,If-Match,Idempotency-Key",
+		ExposeHeaders:    "RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,Retry-After,ETag,Idempotent-Replayed",
 		AllowCredentials: true,
 	}))
 	logger.Info.Println("🌐 CORS enabled using Fiber built-in middleware")
@@ -166,7 +274,7 @@ func main() {
 	logger.Info.Println("🍪 Session middleware configured")
 
 	// Add middleware (order matters!)
-	app.Use(middleware.RateLimiter()) // Rate limiting first to prevent abuse
+	app.Use(middleware.RateLimiter(models.RateLimitDefault)) // Rate limiting first to prevent abuse
 	app.Use(middleware.LoggingMiddleware())
 	app.Use(middleware.CompressionMiddleware())
 	app.Use(middleware.ResponseStandardizationMiddleware())
@@ -236,3 +344,113 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// runMigrate handles the migrate subcommand: up, down [steps], status, force <version>
+func runMigrate(args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := db.MigrateUp()
+		if err != nil {
+			return err
+		}
+		logger.Info.Printf("✅ %d migration(s) applied", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+			steps = n
+		}
+		reverted, err := db.MigrateDown(steps)
+		if err != nil {
+			return err
+		}
+		logger.Info.Printf("✅ %d migration(s) reverted", reverted)
+	case "status":
+		states, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, s := range states {
+			status := "pending"
+			if s.Dirty {
+				status = "DIRTY"
+			} else if s.Applied {
+				status = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, status)
+		}
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: migrate force <version>")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+		return db.MigrateForce(version)
+	default:
+		return fmt.Errorf("unknown migrate command %q (use up, down [steps], status or force <version>)", command)
+	}
+	return nil
+}
+
+// runSearch executes the `search` subcommand
+func runSearch(args []string) error {
+	command := "reindex"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "reindex":
+		repos := repository.New(db.DB)
+		var indexed int
+		err := repos.InTx(context.Background(), func(tx *repository.Repositories) error {
+			var err error
+			indexed, err = tx.Search.Reindex(context.Background())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		logger.Info.Printf("🔎 Search index rebuilt with %d document(s)", indexed)
+	default:
+		return fmt.Errorf("unknown search command %q (use reindex)", command)
+	}
+	return nil
+}
+
+// runTickets executes the `tickets` subcommand
+func runTickets(args []string) error {
+	command := "duplicates"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "duplicates":
+		duplicates, err := repository.New(db.DB).Tickets.Duplicates(context.Background())
+		if err != nil {
+			return err
+		}
+		if len(duplicates) == 0 {
+			logger.Info.Println("✅ No duplicate ticket numbers found")
+			return nil
+		}
+		for _, d := range duplicates {
+			fmt.Printf("%-32s %3d task(s): %s\n", d.TicketNo, d.Count, d.TaskIDs)
+		}
+		logger.Warn.Printf("⚠️ %d ticket number(s) are shared by more than one task", len(duplicates))
+	default:
+		return fmt.Errorf("unknown tickets command %q (use duplicates)", command)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"reports-api/handlers/common"
+	"reports-api/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// authExemptPrefixes lists paths under a protected prefix that stay public
+var authExemptPrefixes = []string{
+	"/api/v1/swagger",
+}
+
+// AuthMiddleware validates the signed access token on every request and rejects revoked or expired tokens
+func AuthMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() == fiber.MethodOptions {
+			return c.Next()
+		}
+		for _, prefix := range authExemptPrefixes {
+			if strings.HasPrefix(c.Path(), prefix) {
+				return c.Next()
+			}
+		}
+
+		token := utils.BearerToken(c)
+		if token == "" {
+			return c.Status(401).JSON(fiber.Map{"error": "Missing access token"})
+		}
+
+		claims, err := common.ParseToken(token)
+		if err != nil {
+			if errors.Is(err, common.ErrExpiredToken) {
+				return c.Status(401).JSON(fiber.Map{"error": "Access token expired"})
+			}
+			return c.Status(401).JSON(fiber.Map{"error": "Invalid access token"})
+		}
+		if claims.TokenType != common.TokenTypeAccess {
+			return c.Status(401).JSON(fiber.Map{"error": "Invalid access token"})
+		}
+
+		revoked, err := common.IsTokenRevoked(claims.ID)
+		if err != nil {
+			log.Printf("Error checking token revocation: %v", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to validate access token"})
+		}
+		if revoked {
+			return c.Status(401).JSON(fiber.Map{"error": "Access token revoked"})
+		}
+
+		utils.SetCurrentUser(c, claims)
+		return c.Next()
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"log"
+	"reports-api/handlers/common"
+	"reports-api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequirePermission allows the request only when the caller's role holds every listed permission
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := utils.CurrentUser(c)
+		if user == nil {
+			return c.Status(401).JSON(fiber.Map{"error": "Authentication required"})
+		}
+
+		for _, permission := range permissions {
+			if !common.HasPermission(user.Role, permission) {
+				log.Printf("Permission denied: user %s (%s) lacks %s for %s %s", user.Username, user.Role, permission, c.Method(), c.Path())
+				return c.Status(403).JSON(fiber.Map{"error": "Permission denied", "required": permission})
+			}
+		}
+		return c.Next()
+	}
+}
@@ -4,12 +4,10 @@ import (
 	"encoding/json"
 	"log"
 	"reports-api/models"
-	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
-	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/google/uuid"
 )
 
@@ -220,49 +218,3 @@ func getStatusMessage(status int) string {
 		return "Unknown Status"
 	}
 }
-
-// RateLimiterConfig holds configuration for different endpoints
-type RateLimiterConfig struct {
-	Max        int
-	Expiration time.Duration
-}
-
-// getEndpointLimits returns rate limit configuration for different endpoints
-func getEndpointLimits(path string) RateLimiterConfig {
-	switch {
-	case strings.Contains(path, "/api/v1/problem/create"):
-		return RateLimiterConfig{Max: 10, Expiration: 1 * time.Minute}
-	case strings.Contains(path, "/api/v1/problem/update"):
-		return RateLimiterConfig{Max: 20, Expiration: 1 * time.Minute}
-	case strings.Contains(path, "/api/v1/problem/delete"):
-		return RateLimiterConfig{Max: 5, Expiration: 1 * time.Minute}
-	case strings.Contains(path, "/api/v1/problem/list"):
-		return RateLimiterConfig{Max: 200, Expiration: 1 * time.Minute}
-	default:
-		return RateLimiterConfig{Max: 100, Expiration: 1 * time.Minute}
-	}
-}
-
-// RateLimiter creates an endpoint-specific rate limiting middleware
-func RateLimiter() fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		config := getEndpointLimits(c.Path())
-
-		limiterMiddleware := limiter.New(limiter.Config{
-			Max:        config.Max,
-			Expiration: config.Expiration,
-			KeyGenerator: func(c *fiber.Ctx) string {
-				return c.IP() + ":" + c.Path()
-			},
-			LimitReached: func(c *fiber.Ctx) error {
-				return c.Status(429).JSON(fiber.Map{
-					"error":  "Too many requests for this endpoint",
-					"limit":  config.Max,
-					"window": config.Expiration.String(),
-				})
-			},
-		})
-
-		return limiterMiddleware(c)
-	}
-}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"fmt"
+	"reports-api/db"
+	"sync"
+	"time"
+)
+
+// RateLimitStore keeps fixed-window request counters.
+// Increment adds one hit to key and returns the hit count in the current window and when the window resets.
+type RateLimitStore interface {
+	Increment(key string, window time.Duration) (hits int, resetAt time.Time, err error)
+}
+
+// MemoryRateLimitStore keeps counters in process memory; counts are per replica
+type MemoryRateLimitStore struct {
+	mu       sync.Mutex
+	counters map[string]*rateLimitCounter
+}
+
+type rateLimitCounter struct {
+	hits    int
+	resetAt time.Time
+}
+
+// NewMemoryRateLimitStore creates an in-memory store and starts a janitor that drops expired windows
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{counters: make(map[string]*rateLimitCounter)}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.purgeExpired()
+		}
+	}()
+	return s
+}
+
+// Increment implements RateLimitStore
+func (s *MemoryRateLimitStore) Increment(key string, window time.Duration) (int, time.Time, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counter, ok := s.counters[key]
+	if !ok || !now.Before(counter.resetAt) {
+		counter = &rateLimitCounter{resetAt: now.Add(window)}
+		s.counters[key] = counter
+	}
+	counter.hits++
+	return counter.hits, counter.resetAt, nil
+}
+
+func (s *MemoryRateLimitStore) purgeExpired() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, counter := range s.counters {
+		if !now.Before(counter.resetAt) {
+			delete(s.counters, key)
+		}
+	}
+}
+
+// MySQLRateLimitStore keeps counters in the rate_limit_counters table so every API replica shares them
+type MySQLRateLimitStore struct{}
+
+// NewMySQLRateLimitStore creates a store backed by the shared database
+func NewMySQLRateLimitStore() *MySQLRateLimitStore {
+	return &MySQLRateLimitStore{}
+}
+
+// Increment implements RateLimitStore
+func (s *MySQLRateLimitStore) Increment(key string, window time.Duration) (int, time.Time, error) {
+	now := time.Now().UTC()
+	newReset := now.Add(window)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	// Start a new window when the stored one has elapsed, otherwise count the hit.
+	// hits is assigned before reset_at, so both conditions still see the old reset_at.
+	_, err = tx.Exec(`
+		INSERT INTO rate_limit_counters (bucket, hits, reset_at) VALUES (?, 1, ?)
+		ON DUPLICATE KEY UPDATE
+			hits = IF(reset_at <= ?, 1, hits + 1),
+			reset_at = IF(reset_at <= ?, VALUES(reset_at), reset_at)
+	`, key, newReset, now, now)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to increment rate limit counter: %w", err)
+	}
+
+	var hits int
+	var resetAt time.Time
+	if err := tx.QueryRow(`SELECT hits, reset_at FROM rate_limit_counters WHERE bucket = ?`, key).Scan(&hits, &resetAt); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to read rate limit counter: %w", err)
+	}
+	return hits, resetAt, tx.Commit()
+}
+
+// PurgeExpired removes counters whose window has elapsed
+func (s *MySQLRateLimitStore) PurgeExpired() error {
+	_, err := db.DB.Exec(`DELETE FROM rate_limit_counters WHERE reset_at < UTC_TIMESTAMP()`)
+	return err
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"reports-api/config"
+	"reports-api/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	rateLimitStore     RateLimitStore
+	rateLimitStoreOnce sync.Once
+)
+
+// sharedRateLimitStore returns the store selected by RATE_LIMIT_STORE, created once for the process
+func sharedRateLimitStore() RateLimitStore {
+	rateLimitStoreOnce.Do(func() {
+		switch config.AppConfig.RateLimitStore {
+		case "mysql":
+			rateLimitStore = NewMySQLRateLimitStore()
+			log.Println("🚦 Rate limit counters stored in MySQL (shared across replicas)")
+		default:
+			rateLimitStore = NewMemoryRateLimitStore()
+			log.Println("🚦 Rate limit counters stored in memory")
+		}
+	})
+	return rateLimitStore
+}
+
+// PurgeRateLimitCounters removes expired counters from a shared store; the memory store cleans itself
+func PurgeRateLimitCounters() error {
+	if store, ok := sharedRateLimitStore().(*MySQLRateLimitStore); ok {
+		return store.PurgeExpired()
+	}
+	return nil
+}
+
+// RateLimiter creates the rate limiting middleware for an endpoint class (models.RateLimit*).
+// Build it once per route group: the counters live in the shared store and are keyed by class and client IP.
+func RateLimiter(class string) fiber.Handler {
+	rule, ok := config.AppConfig.RateLimits[class]
+	if !ok {
+		log.Printf("⚠️ Unknown rate limit class %q, using %q", class, models.RateLimitDefault)
+		class = models.RateLimitDefault
+		rule = config.AppConfig.RateLimits[class]
+	}
+	store := sharedRateLimitStore()
+
+	return func(c *fiber.Ctx) error {
+		if c.Method() == fiber.MethodOptions {
+			return c.Next()
+		}
+
+		hits, resetAt, err := store.Increment("rl:"+class+":"+c.IP(), rule.Window)
+		if err != nil {
+			// Fail open: a broken counter store must not take the API down
+			log.Printf("Rate limiter store error (%s): %v", class, err)
+			return c.Next()
+		}
+
+		resetSeconds := int(math.Ceil(time.Until(resetAt).Seconds()))
+		if resetSeconds < 0 {
+			resetSeconds = 0
+		}
+		remaining := rule.Max - hits
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		c.Set("RateLimit-Limit", strconv.Itoa(rule.Max))
+		c.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(resetSeconds))
+
+		if hits > rule.Max {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(resetSeconds))
+			return c.Status(429).JSON(fiber.Map{
+				"error":  "Too many requests for this endpoint",
+				"limit":  rule.Max,
+				"window": rule.Window.String(),
+			})
+		}
+
+		return c.Next()
+	}
+}
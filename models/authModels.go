@@ -0,0 +1,27 @@
+package models
+
+// TokenClaims represents the payload carried inside a signed access or refresh token
+type TokenClaims struct {
+	ID        string `json:"jti"`
+	UserID    int    `json:"uid"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	TokenType string `json:"typ"`
+	Issuer    string `json:"iss,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// TokenPair represents the access and refresh tokens issued on login
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+}
+
+// RefreshTokenRequest represents the structure of the request to refresh an access token
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
@@ -15,8 +15,8 @@ type Branch struct {
 // BranchRequest model for receiving branch data
 type BranchRequest struct {
 	Name      *string `json:"name"`
-	CreatedBy *int    `json:"created_by"`
-	UpdatedBy *int    `json:"updated_by"`
+	CreatedBy *int    `json:"created_by"` // ignored unless it matches the authenticated user
+	UpdatedBy *int    `json:"updated_by"` // ignored unless it matches the authenticated user
 }
 
 // BranchDetail model for displaying branch details
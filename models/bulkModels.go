@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrTaskClosed is returned when progress is added to a done or cancelled task
+	ErrTaskClosed = errors.New("task is closed")
+	// ErrBulkAborted is returned when an item of an atomic bulk call fails and every change is rolled back
+	ErrBulkAborted = errors.New("bulk operation rolled back")
+)
+
+// BulkConfig limits bulk task operations
+type BulkConfig struct {
+	// MaxTasks is the most tasks one bulk call may change
+	MaxTasks int
+	// TelegramInterval spaces the queued Telegram edits of changed tasks
+	TelegramInterval time.Duration
+}
+
+// BulkTaskRequest selects tasks by ids or by filter, the query string of GET /api/v1/problems
+// (e.g. "status=0&branch_id=2"), and carries the fields of every bulk action.
+// With atomic, one failed task rolls back the whole call.
+type BulkTaskRequest struct {
+	IDs    []int  `json:"ids"`
+	Filter string `json:"filter"`
+	Atomic bool   `json:"atomic"`
+
+	AssignedtoID int         `json:"assignedto_id"` // assign
+	Assignto     string      `json:"assign_to"`     // assign
+	Status       *TaskStatus `json:"status"`        // status
+	Reason       string      `json:"reason"`        // status
+	DepartmentID int         `json:"department_id"` // department
+	ProgramID    int         `json:"program_id"`    // program
+	Text         string      `json:"text"`          // progress
+}
+
+// BulkItemResult is the outcome of one task of a bulk call
+type BulkItemResult struct {
+	ID      int    `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BulkResult reports every task of a bulk call; RolledBack is set when an atomic call was undone
+type BulkResult struct {
+	Total      int              `json:"total"`
+	Succeeded  int              `json:"succeeded"`
+	Failed     int              `json:"failed"`
+	RolledBack bool             `json:"rolled_back"`
+	Items      []BulkItemResult `json:"items"`
+}
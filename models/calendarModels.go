@@ -0,0 +1,45 @@
+package models
+
+// Holiday sources
+const (
+	HolidaySourceManual = "manual"
+	HolidaySourceICS    = "ics"
+)
+
+// WorkingPeriod is one opening period of a weekday; times are "15:04" in the business time zone
+type WorkingPeriod struct {
+	Weekday   int    `json:"weekday"` // 0 = Sunday
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// WorkingHours is the opening schedule of a branch; BranchID nil is the default schedule
+type WorkingHours struct {
+	BranchID *int            `json:"branch_id"`
+	Periods  []WorkingPeriod `json:"periods"`
+}
+
+// Holiday closes a branch, or every branch when BranchID is nil, for a whole day
+type Holiday struct {
+	ID        int     `json:"id"`
+	BranchID  *int    `json:"branch_id"`
+	Date      string  `json:"date"` // 2006-01-02
+	Name      string  `json:"name"`
+	Source    string  `json:"source"`
+	CreatedAt *string `json:"created_at"`
+	CreatedBy *int    `json:"created_by"`
+}
+
+// HolidayRequest creates a holiday
+type HolidayRequest struct {
+	BranchID *int   `json:"branch_id"`
+	Date     string `json:"date"`
+	Name     string `json:"name"`
+}
+
+// HolidayQuery filters the holiday list; empty fields match everything
+type HolidayQuery struct {
+	BranchID *int
+	From     string
+	To       string
+}
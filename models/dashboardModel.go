@@ -13,6 +13,8 @@ type DashboardResponse struct {
 	IssueTypes  []IssueTypeDb       `json:"issue_types"`
 	Timestamp   string              `json:"timestamp,omitempty"`
 	RequestID   string              `json:"request_id,omitempty"`
+	// ProblemRecords lists the problem records that are not resolved or closed
+	ProblemRecords []ProblemRecord `json:"problem_records"`
 }
 
 // TaskWithDetailsDb model for task with details
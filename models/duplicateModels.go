@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Duplicate report policies (DUPLICATE_POLICY)
+const (
+	// DuplicatePolicyOff creates every report as a new task
+	DuplicatePolicyOff = "off"
+	// DuplicatePolicyWarn creates the task and returns the likely duplicates with it
+	DuplicatePolicyWarn = "warn"
+	// DuplicatePolicyLink attaches the report to the most similar open task instead of creating a task
+	DuplicatePolicyLink = "link"
+)
+
+// DuplicateConfig controls duplicate detection when a problem is reported
+type DuplicateConfig struct {
+	Policy string
+	// Window is how far back open tasks are compared with a new report
+	Window time.Duration
+	// Similarity is the text similarity (0-1) from which a task counts as a duplicate
+	Similarity float64
+}
+
+// DuplicateCandidate is an open task that a new report likely duplicates.
+// SameDepartment is false when the task only shares the branch.
+type DuplicateCandidate struct {
+	ID             int        `json:"id"`
+	Ticket         string     `json:"ticket_no"`
+	Text           string     `json:"text"`
+	DepartmentID   int        `json:"department_id"`
+	SameDepartment bool       `json:"same_department"`
+	Status         TaskStatus `json:"status"`
+	CreatedAt      string     `json:"created_at"`
+	Similarity     float64    `json:"similarity"`
+}
+
+// TaskIncident is a report attached to an existing task as a duplicate instead of opening its own ticket
+type TaskIncident struct {
+	ID           int      `json:"id"`
+	TaskID       int      `json:"task_id"`
+	PhoneID      *int     `json:"phone_id"`
+	PhoneElse    *string  `json:"phone_else"`
+	DepartmentID int      `json:"department_id"`
+	Text         string   `json:"text"`
+	ReportedBy   string   `json:"reported_by"`
+	FilePaths    *string  `json:"-"` // JSON array of {"url": ...}
+	FileURLs     []string `json:"file_paths"`
+	Similarity   float64  `json:"similarity"`
+	CreatedBy    *int     `json:"created_by"`
+	CreatedAt    string   `json:"created_at"`
+}
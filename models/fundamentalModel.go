@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Models Middleware
 type StandardResponse struct {
 	Success   bool   `json:"success"`
@@ -12,13 +14,46 @@ type StandardResponse struct {
 
 // Models Config
 type Config struct {
-	EndPoint        string
-	AccessKey       string
-	SecretAccessKey string
-	BucketName      string
-	Environment     string
-	ChatID          string
-	BotToken        string
+	EndPoint         string
+	AccessKey        string
+	SecretAccessKey  string
+	BucketName       string
+	Environment      string
+	ChatID           string
+	BotToken         string
+	TokenSecret      string
+	TokenIssuer      string
+	AccessTokenTTL   time.Duration
+	RefreshTokenTTL  time.Duration
+	PasswordPolicy   PasswordPolicy
+	PasswordResetTTL time.Duration
+	LoginMaxAttempts int
+	LoginLockoutBase time.Duration
+	LoginLockoutMax  time.Duration
+	RateLimits       map[string]RateLimitRule
+	RateLimitStore   string
+	// TrustedProxies lists the reverse proxies (IPs or CIDRs) whose ProxyHeader is believed for the client IP;
+	// when empty the client IP is always the address of the connection
+	TrustedProxies   []string
+	ProxyHeader      string
+	AutoMigrate      bool
+	Ticket           TicketConfig
+	SLA              SLAConfig
+	BusinessLocation *time.Location
+	// TrashRetention is how long soft-deleted records stay restorable before they are purged
+	TrashRetention time.Duration
+	Bulk           BulkConfig
+	Idempotency    IdempotencyConfig
+	Duplicate      DuplicateConfig
+}
+
+// PasswordPolicy describes the rules a new password must satisfy
+type PasswordPolicy struct {
+	MinLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
 }
 
 // Models ImageProcessor
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// IdempotencyConfig controls how long the response of a request sent with an Idempotency-Key is replayed
+type IdempotencyConfig struct {
+	// Window is how long a key replays its first response
+	Window time.Duration
+	// LockTimeout is how long a key stays claimed by a request that never finished (e.g. the API restarted)
+	LockTimeout time.Duration
+}
+
+// IdempotencyRecord is the stored response of a request sent with an Idempotency-Key.
+// StatusCode is 0 while the first request is still running; Fingerprint identifies its body.
+type IdempotencyRecord struct {
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
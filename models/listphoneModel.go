@@ -22,6 +22,6 @@ type IPPhoneRequest struct {
 	Number       *int    `json:"number"`
 	Name         *string `json:"name"`
 	DepartmentID int     `json:"department_id"`
-	CreatedBy    *int    `json:"created_by"`
-	UpdatedBy    *int    `json:"uodated_by"`
+	CreatedBy    *int    `json:"created_by"` // ignored unless it matches the authenticated user
+	UpdatedBy    *int    `json:"uodated_by"` // ignored unless it matches the authenticated user
 }
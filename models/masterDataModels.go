@@ -0,0 +1,82 @@
+package models
+
+import "errors"
+
+// Delete modes of branches, departments, IP phones and programs that still have dependent records
+const (
+	DeleteModeBlock    = "block"    // refuse to delete while live records depend on it
+	DeleteModeCascade  = "cascade"  // move the dependent departments, IP phones and tasks to the trash too
+	DeleteModeReassign = "reassign" // re-point the dependent records to another record first
+)
+
+var (
+	// ErrHasDependents is returned by a blocked delete
+	ErrHasDependents = errors.New("record has dependent records")
+	// ErrInvalidReassignTarget is returned when the reassign target is missing, trashed or the deleted record itself
+	ErrInvalidReassignTarget = errors.New("invalid reassign target")
+	// ErrInvalidMerge is returned when no source is given, a source is the survivor or a record is missing
+	ErrInvalidMerge = errors.New("invalid merge")
+)
+
+// Dependents counts the live records referencing a branch, department, IP phone or program.
+// Scores are monthly history: they are reported but never block a delete and stay with the deleted department.
+type Dependents struct {
+	Departments int `json:"departments"`
+	Phones      int `json:"ip_phones"`
+	Tasks       int `json:"tasks"`
+	Scores      int `json:"scores"`
+}
+
+// Blocking reports whether any dependent prevents a delete in block mode
+func (d Dependents) Blocking() bool {
+	return d.Departments > 0 || d.Phones > 0 || d.Tasks > 0
+}
+
+// MergeRequest merges the source records into the survivor; a dry run only reports what would change
+type MergeRequest struct {
+	SurvivorID int   `json:"survivor_id"`
+	SourceIDs  []int `json:"source_ids"`
+	DryRun     bool  `json:"dry_run"`
+}
+
+// MergeSource is what moves from one source record to the survivor.
+// ScoresCombined counts the months both departments have a score; their deductions are added up.
+type MergeSource struct {
+	ID             int        `json:"id"`
+	Dependents     Dependents `json:"dependents"`
+	ScoresCombined int        `json:"scores_combined"`
+}
+
+// MergeResult describes a merge; ID is the audit log entry and is omitted in a dry run
+type MergeResult struct {
+	ID         int64         `json:"id,omitempty"`
+	Kind       string        `json:"kind"`
+	SurvivorID int           `json:"survivor_id"`
+	DryRun     bool          `json:"dry_run"`
+	Sources    []MergeSource `json:"sources"`
+}
+
+// MergeLogEntry is one recorded merge
+type MergeLogEntry struct {
+	ID         int64         `json:"id"`
+	Kind       string        `json:"kind"`
+	SurvivorID int           `json:"survivor_id"`
+	SourceIDs  []int         `json:"source_ids"`
+	Sources    []MergeSource `json:"sources"`
+	MergedBy   *int          `json:"merged_by"`
+	MergedAt   string        `json:"merged_at"`
+}
+
+// MasterDataName is a live record compared by the duplicate detector; only records of the same group are compared
+type MasterDataName struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Group int    `json:"-"`
+}
+
+// MergeCandidate is a pair of records whose names look alike, with a similarity between 0 and 1
+type MergeCandidate struct {
+	A          MasterDataName `json:"a"`
+	B          MasterDataName `json:"b"`
+	Similarity float64        `json:"similarity"`
+}
@@ -22,4 +22,19 @@ type PaginatedResponse struct {
 	Pagination PaginationResponse `json:"pagination"`
 	Timestamp  string             `json:"timestamp,omitempty"`
 	RequestID  string             `json:"request_id,omitempty"`
-}
\ No newline at end of file
+}
+
+// CursorPaginationResponse represents keyset pagination metadata; NextCursor is empty on the last page
+type CursorPaginationResponse struct {
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// CursorPaginatedResponse represents an API response paged with a cursor
+type CursorPaginatedResponse struct {
+	Success    bool                     `json:"success"`
+	Data       interface{}              `json:"data"`
+	Pagination CursorPaginationResponse `json:"pagination"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import "errors"
+
+// Problem record statuses. A problem record is the underlying defect behind recurring tasks;
+// its workaround is suggested for new tasks until it is resolved or closed.
+const (
+	ProblemRecordOpen          = "open"
+	ProblemRecordInvestigating = "investigating"
+	ProblemRecordKnownError    = "known_error" // root cause found, workaround documented
+	ProblemRecordResolved      = "resolved"
+	ProblemRecordClosed        = "closed"
+)
+
+// problemRecordStatuses lists the statuses the API accepts, in lifecycle order
+var problemRecordStatuses = []string{
+	ProblemRecordOpen, ProblemRecordInvestigating, ProblemRecordKnownError, ProblemRecordResolved, ProblemRecordClosed,
+}
+
+var (
+	// ErrInvalidProblemRecord is returned for a problem record without a title or with an unknown status
+	ErrInvalidProblemRecord = errors.New("invalid problem record")
+	// ErrTaskHasProblemRecord is returned when a task is attached while it already belongs to another problem record
+	ErrTaskHasProblemRecord = errors.New("task already belongs to a problem record")
+)
+
+// ValidProblemRecordStatus reports whether s is a problem record status the API accepts
+func ValidProblemRecordStatus(s string) bool {
+	for _, status := range problemRecordStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ProblemRecordActive reports whether a problem record with status s is still open, so its workaround is suggested
+func ProblemRecordActive(s string) bool {
+	return s != ProblemRecordResolved && s != ProblemRecordClosed
+}
+
+// ProblemRecord is one row of problem_records with the number of live tasks attached to it.
+// Tasks is only filled by GET /api/v1/problemrecord/:id.
+type ProblemRecord struct {
+	ID            int                 `json:"id"`
+	Title         string              `json:"title"`
+	SystemID      *int                `json:"system_id"`
+	SystemName    string              `json:"system_name"`
+	Description   string              `json:"description"`
+	RootCause     string              `json:"root_cause"`
+	Workaround    string              `json:"workaround"`
+	Status        string              `json:"status"`
+	TaskCount     int                 `json:"task_count"`
+	OpenTaskCount int                 `json:"open_task_count"`
+	CreatedBy     *int                `json:"created_by"`
+	UpdatedBy     *int                `json:"updated_by"`
+	CreatedAt     string              `json:"created_at"`
+	UpdatedAt     string              `json:"updated_at"`
+	ResolvedAt    string              `json:"resolved_at"`
+	Tasks         []ProblemRecordTask `json:"tasks,omitempty"`
+}
+
+// ProblemRecordTask is a task attached to a problem record
+type ProblemRecordTask struct {
+	TaskID     int        `json:"task_id"`
+	Ticket     string     `json:"ticket_no"`
+	Text       string     `json:"text"`
+	Status     TaskStatus `json:"status"`
+	AttachedBy *int       `json:"attached_by"`
+	AttachedAt string     `json:"attached_at"`
+}
+
+// ProblemRecordRequest creates or updates a problem record; Status defaults to open on create
+type ProblemRecordRequest struct {
+	Title       string `json:"title"`
+	SystemID    *int   `json:"system_id"`
+	Description string `json:"description"`
+	RootCause   string `json:"root_cause"`
+	Workaround  string `json:"workaround"`
+	Status      string `json:"status"`
+}
+
+// ProblemRecordQuery filters GET /api/v1/problemrecord/list; Active keeps only records that are not resolved or closed
+type ProblemRecordQuery struct {
+	Status   string
+	SystemID int
+	Active   bool
+}
+
+// ProblemRecordTasksRequest attaches tasks to a problem record
+type ProblemRecordTasksRequest struct {
+	TaskIDs []int `json:"task_ids"`
+}
+
+// WorkaroundSuggestion is the known-error workaround of an active problem record, suggested for a new task of the same program
+type WorkaroundSuggestion struct {
+	ProblemRecordID int    `json:"problem_record_id"`
+	Title           string `json:"title"`
+	Status          string `json:"status"`
+	Workaround      string `json:"workaround"`
+}
@@ -4,6 +4,7 @@ package models
 type Program struct {
 	ID        int     `json:"id"`
 	Name      *string `json:"name"`
+	Priority  int     `json:"priority"`
 	TypeID    *int    `json:"type_id"`
 	TypeName  string  `json:"type_name"`
 	CreatedAt *string `json:"created_at"`
@@ -19,8 +20,8 @@ type ProgramRequest struct {
 	Name      *string `json:"name"`
 	Priority  *int    `json:"priority"`
 	TypeID    *int    `json:"type_id"`
-	CreatedBy *int    `json:"created_by"`
-	UpdatedBy *int    `json:"updated_by"`
+	CreatedBy *int    `json:"created_by"` // ignored unless it matches the authenticated user
+	UpdatedBy *int    `json:"updated_by"` // ignored unless it matches the authenticated user
 }
 
 type Type struct {
@@ -9,6 +9,7 @@ type ProgressEntry struct {
 	ImageURLs []string          `json:"-"`
 	UpdateAt  string            `json:"updated_at"`
 	CreatedAt string            `json:"created_at"`
+	Version   int               `json:"version"`
 }
 
 type UpdateProgress struct {
@@ -19,3 +20,14 @@ type UpdateProgress struct {
 	UpdateAt  string            `json:"updated_at"`
 	CreatedAt string            `json:"created_at"`
 }
+
+// ProgressRecord is a row of the progress table
+type ProgressRecord struct {
+	ID        int     `json:"id"`
+	TaskID    int     `json:"task_id"`
+	Text      string  `json:"text"`
+	FilePaths *string `json:"-"` // JSON array of {"url": ...} or, for old rows, of URLs
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+	Version   int     `json:"version"`
+}
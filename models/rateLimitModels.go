@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Rate limit endpoint classes; each class has its own counters and limits
+const (
+	RateLimitDefault = "default"
+	RateLimitAuth    = "auth"
+	RateLimitList    = "list"
+	RateLimitCreate  = "create"
+	RateLimitUpdate  = "update"
+	RateLimitDelete  = "delete"
+)
+
+// RateLimitRule allows Max requests per client within Window
+type RateLimitRule struct {
+	Max    int
+	Window time.Duration
+}
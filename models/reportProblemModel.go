@@ -2,55 +2,51 @@ package models
 
 // TaskRequest model for receiving task data
 type TaskRequest struct {
-	PhoneID          *int    `json:"phone_id" db:"phone_id"`
-	PhoneElse        *string `json:"phone_else" db:"phone_else"`
-	Ticket           string  `json:"ticket_no" db:"ticket_no"`
-	SystemID         int     `json:"system_id"`
-	IssueElse        string  `json:"issue_else" db:"issue_else"`
-	IssueTypeID      int     `json:"issue_type" db:"issue_type"`
-	DepartmentID     int     `json:"department_id"`
-	Text             string  `json:"text"`
-	ReportedBy       string  `json:"reported_by"`
-	AssignedtoID     int     `json:"assignedto_id" db:"assignedto_id"`
-	Assignto         string  `json:"assign_to"`
-	Status           int     `json:"status"`
-	CreatedBy        int     `json:"created_by"`
-	UpdatedBy        int     `json:"updated_by"`
-	ResolvedAt       string  `json:"resolved_at"`
-	Telegram         bool    `json:"telegram"`
-	TelegramUser     string  `json:"telegram_user"`
-	MessageID        int     `json:"message_id"`
-	UpdatedAt        string  `json:"updated_at"`
-	PreviousAssignto string  `json:"previous_assignto"`
-	AssigntoID       int     `json:"-"`
-	PhoneNumber      int     `json:"-"`
-	DepartmentName   string  `json:"-"`
-	BranchName       string  `json:"-"`
-	ProgramName      string  `json:"-"`
-	Url              string  `json:"-"`
-	CreatedAt        string  `json:"-"`
+	PhoneID          *int       `json:"phone_id" db:"phone_id"`
+	PhoneElse        *string    `json:"phone_else" db:"phone_else"`
+	Ticket           string     `json:"ticket_no" db:"ticket_no"`
+	SystemID         int        `json:"system_id"`
+	IssueElse        string     `json:"issue_else" db:"issue_else"`
+	IssueTypeID      int        `json:"issue_type" db:"issue_type"`
+	DepartmentID     int        `json:"department_id"`
+	Text             string     `json:"text"`
+	ReportedBy       string     `json:"reported_by"`
+	AssignedtoID     int        `json:"assignedto_id" db:"assignedto_id"`
+	Assignto         string     `json:"assign_to"`
+	Status           TaskStatus `json:"status"`
+	CreatedBy        int        `json:"created_by"` // ignored unless it matches the authenticated user
+	UpdatedBy        int        `json:"updated_by"` // ignored unless it matches the authenticated user
+	ResolvedAt       string     `json:"resolved_at"`
+	Telegram         bool       `json:"telegram"`
+	IgnoreDuplicates bool       `json:"ignore_duplicates"` // create the task even when it looks like a duplicate
+	TelegramUser     string     `json:"telegram_user"`
+	MessageID        int        `json:"message_id"`
+	UpdatedAt        string     `json:"updated_at"`
+	PreviousAssignto string     `json:"previous_assignto"`
+	AssigntoID       int        `json:"-"`
+	PhoneNumber      int        `json:"-"`
+	DepartmentName   string     `json:"-"`
+	BranchName       string     `json:"-"`
+	ProgramName      string     `json:"-"`
+	Url              string     `json:"-"`
+	CreatedAt        string     `json:"-"`
 }
 
 type TaskRequestUpdate struct {
-	PhoneID      *int    `json:"phone_id" db:"phone_id"`
-	PhoneElse    *string `json:"phone_else" db:"phone_else"`
-	SystemID     int     `json:"system_id"`
-	IssueElse    string  `json:"issue_else" db:"issue_else"`
-	IssueTypeID  int     `json:"issue_type" db:"issue_type"`
-	AssignedtoID int     `json:"assignedto_id" db:"assignedto_id"`
-	Assignto     *string `json:"assign_to"`
-	ReportedBy   *string `json:"reported_by"`
-	DepartmentID int     `json:"department_id"`
-	Status       int     `json:"status"`
-	Text         string  `json:"text"`
-	Solution     string  `json:"solution"`
-	UpdatedBy    int     `json:"updated_by"`
-}
-
-type TaskStatusUpdateRequest struct {
-	ID        int `json:"id" db:"id"`
-	Status    int `json:"status"`
-	UpdatedBy int `json:"updated_by"`
+	PhoneID      *int       `json:"phone_id" db:"phone_id"`
+	PhoneElse    *string    `json:"phone_else" db:"phone_else"`
+	SystemID     int        `json:"system_id"`
+	IssueElse    string     `json:"issue_else" db:"issue_else"`
+	IssueTypeID  int        `json:"issue_type" db:"issue_type"`
+	AssignedtoID int        `json:"assignedto_id" db:"assignedto_id"`
+	Assignto     *string    `json:"assign_to"`
+	ReportedBy   *string    `json:"reported_by"`
+	DepartmentID int        `json:"department_id"`
+	Status       TaskStatus `json:"status"`
+	StatusReason string     `json:"status_reason"`
+	Text         string     `json:"text"`
+	Solution     string     `json:"solution"`
+	UpdatedBy    int        `json:"updated_by"` // ignored unless it matches the authenticated user
 }
 
 // TaskWithDetailsDb model for task with details in the database
@@ -74,16 +70,47 @@ type TaskWithDetails struct {
 	AssignedtoID   int               `json:"assignedto_id" db:"assignedto_id"`
 	Assignto       *string           `json:"assign_to"`
 	ReportedBy     *string           `json:"reported_by"`
-	Status         int               `json:"status"`
+	Status         TaskStatus        `json:"status"`
 	FilePaths      map[string]string `json:"file_paths"`
 	ResolvedAt     string            `json:"resolved_at"`
 	CreatedAt      string            `json:"created_at"`
 	UpdatedAt      string            `json:"updated_at"`
+	SLA            *TaskSLA          `json:"sla"`
+	Version        int               `json:"version"`
 }
 
 type AssignRequest struct {
 	AssignedtoID   int    `json:"assignedto_id"`
 	Assignto       string `json:"assign_to"`
-	UpdatedBy      int    `json:"updated_by"`
+	UpdatedBy      int    `json:"updated_by"` // ignored unless it matches the authenticated user
 	UpdateTelegram bool   `json:"update_telegram"`
 }
+
+// TaskRecord is a raw row of the tasks table together with its Telegram message ids.
+// Handlers use it when they need the stored values rather than the display joins of TaskWithDetails.
+type TaskRecord struct {
+	ID                int        `json:"id"`
+	Ticket            string     `json:"ticket_no"`
+	PhoneID           *int       `json:"phone_id"`
+	PhoneElse         *string    `json:"phone_else"`
+	SystemID          int        `json:"system_id"`
+	IssueTypeID       int        `json:"issue_type"`
+	IssueElse         string     `json:"issue_else"`
+	DepartmentID      int        `json:"department_id"`
+	Text              string     `json:"text"`
+	ReportedBy        string     `json:"reported_by"`
+	AssignedtoID      int        `json:"assignedto_id"`
+	Assignto          string     `json:"assign_to"`
+	AssigneeTelegram  string     `json:"-"`
+	Status            TaskStatus `json:"status"`
+	SolutionID        *int       `json:"solution_id"`
+	TelegramID        int        `json:"telegram_id"`
+	ReportMessageID   int        `json:"-"`
+	AssigneeMessageID int        `json:"-"`
+	SolutionMessageID int        `json:"-"`
+	FilePaths         string     `json:"-"` // JSON array of {"url": ...}, "[]" when empty
+	CreatedAt         string     `json:"created_at"`
+	UpdatedAt         string     `json:"updated_at"`
+	ResolvedAt        string     `json:"resolved_at"`
+	Version           int        `json:"version"`
+}
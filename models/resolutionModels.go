@@ -23,3 +23,14 @@ type UpdateResolutionReq struct {
 	TelegramID int               `json:"telegram_id"`
 	ResolvedAt string            `json:"resolved_at"`
 }
+
+// Resolution is a row of the resolutions table
+type Resolution struct {
+	ID         int     `json:"id"`
+	TaskID     int     `json:"task_id"`
+	Text       string  `json:"solution"`
+	TelegramID int     `json:"telegram_id"`
+	FilePaths  *string `json:"-"` // JSON array of {"url": ...}
+	ResolvedAt string  `json:"resolved_at"`
+	Version    int     `json:"version"`
+}
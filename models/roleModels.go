@@ -0,0 +1,60 @@
+package models
+
+// Role names stored in users.role
+const (
+	RoleAdmin      = "admin"
+	RoleTechnician = "technician"
+	RoleUser       = "user"
+)
+
+// Permissions checked by the route authorization layer
+const (
+	PermTasksRead           = "tasks:read"
+	PermTasksCreate         = "tasks:create"
+	PermTasksWrite          = "tasks:write"
+	PermTasksAssign         = "tasks:assign"
+	PermTasksDelete         = "tasks:delete"
+	PermProgressWrite       = "progress:write"
+	PermResolutionsWrite    = "resolutions:write"
+	PermProblemRecordsWrite = "problemrecords:write"
+	PermMasterDataRead      = "masterdata:read"
+	PermMasterDataWrite     = "masterdata:write"
+	PermScoresWrite         = "scores:write"
+	PermReportsExport       = "reports:export"
+	PermUsersManage         = "users:manage"
+	PermRolesManage         = "roles:manage"
+)
+
+// AllPermissions lists every permission known to the API
+var AllPermissions = []string{
+	PermTasksRead, PermTasksCreate, PermTasksWrite, PermTasksAssign, PermTasksDelete,
+	PermProgressWrite, PermResolutionsWrite, PermProblemRecordsWrite,
+	PermMasterDataRead, PermMasterDataWrite, PermScoresWrite,
+	PermReportsExport, PermUsersManage, PermRolesManage,
+}
+
+// DefaultRolePermissions is used when the role_permissions table is empty or unavailable
+var DefaultRolePermissions = map[string][]string{
+	RoleAdmin: AllPermissions,
+	RoleTechnician: {
+		PermTasksRead, PermTasksCreate, PermTasksWrite, PermTasksAssign,
+		PermProgressWrite, PermResolutionsWrite, PermProblemRecordsWrite,
+		PermMasterDataRead, PermReportsExport,
+	},
+	RoleUser: {
+		PermTasksRead, PermTasksCreate, PermMasterDataRead,
+	},
+}
+
+// Role represents a role and the permissions granted to it
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// RolePermissionsRequest represents the structure of the request to replace a role's permissions
+type RolePermissionsRequest struct {
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"`
+}
@@ -0,0 +1,30 @@
+package models
+
+// Search document sources
+const (
+	SearchSourceTask       = "task"
+	SearchSourceProgress   = "progress"
+	SearchSourceResolution = "resolution"
+)
+
+// SearchHit is one matching task, progress entry or resolution ranked by relevance.
+// SourceID is the id of the progress entry or resolution (the task id for task hits).
+type SearchHit struct {
+	TaskID    int        `json:"task_id"`
+	Ticket    string     `json:"ticket_no"`
+	Status    TaskStatus `json:"status"`
+	Source    string     `json:"source"`
+	SourceID  int        `json:"source_id"`
+	Score     float64    `json:"score"`
+	Snippet   string     `json:"snippet"`
+	Body      string     `json:"-"`
+	UpdatedAt string     `json:"updated_at"`
+}
+
+// SearchQuery is a full-text search request; empty Sources searches every source
+type SearchQuery struct {
+	Text    string
+	Sources []string
+	Limit   int
+	Offset  int
+}
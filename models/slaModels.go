@@ -0,0 +1,91 @@
+package models
+
+import "time"
+
+// SLA event kinds raised by the SLA checker
+const (
+	SLAEventResponseWarning    = "response_warning"
+	SLAEventResponseBreached   = "response_breached"
+	SLAEventResolutionWarning  = "resolution_warning"
+	SLAEventResolutionBreached = "resolution_breached"
+)
+
+// SLAConfig controls how due dates are assigned and how often they are checked
+type SLAConfig struct {
+	// DefaultPriority is used for tasks without a program (system_id 0)
+	DefaultPriority int
+	// CheckInterval is how often the background checker runs
+	CheckInterval time.Duration
+	// WarnBefore raises a warning event this long before a due date
+	WarnBefore time.Duration
+	// NotifyTelegram replies to the task's Telegram message when an event is raised
+	NotifyTelegram bool
+	// BusinessHours counts targets in working hours of the task's branch instead of wall-clock time
+	BusinessHours bool
+}
+
+// SLAPolicy sets the response and resolution targets for a program priority.
+// BranchID and IssueTypeID narrow the policy; nil matches every branch or issue type.
+type SLAPolicy struct {
+	ID                int     `json:"id"`
+	Priority          int     `json:"priority"`
+	BranchID          *int    `json:"branch_id"`
+	IssueTypeID       *int    `json:"issue_type_id"`
+	ResponseMinutes   int     `json:"response_minutes"`
+	ResolutionMinutes int     `json:"resolution_minutes"`
+	CreatedAt         *string `json:"created_at"`
+	UpdatedAt         *string `json:"updated_at"`
+	CreatedBy         *int    `json:"created_by"`
+	UpdatedBy         *int    `json:"updated_by"`
+}
+
+// SLAPolicyRequest creates or updates an SLA policy
+type SLAPolicyRequest struct {
+	Priority          int  `json:"priority"`
+	BranchID          *int `json:"branch_id"`
+	IssueTypeID       *int `json:"issue_type_id"`
+	ResponseMinutes   int  `json:"response_minutes"`
+	ResolutionMinutes int  `json:"resolution_minutes"`
+}
+
+// SLATarget holds what a task's SLA policy is matched on
+type SLATarget struct {
+	TaskID      int
+	Priority    int
+	BranchID    int
+	IssueTypeID int
+	CreatedAt   time.Time
+}
+
+// TaskSLA is the SLA state of a task returned by the task list and detail APIs.
+// Remaining seconds are nil once the target has been met and negative after a breach.
+type TaskSLA struct {
+	PolicyID                   *int   `json:"policy_id"`
+	ResponseDueAt              string `json:"response_due_at"`
+	ResolutionDueAt            string `json:"resolution_due_at"`
+	FirstResponseAt            string `json:"first_response_at"`
+	ResponseRemainingSeconds   *int64 `json:"response_remaining_seconds"`
+	ResolutionRemainingSeconds *int64 `json:"resolution_remaining_seconds"`
+	ResponseBreached           bool   `json:"response_breached"`
+	ResolutionBreached         bool   `json:"resolution_breached"`
+}
+
+// SLADueTask is an open task whose response or resolution target is near or past
+type SLADueTask struct {
+	TaskID          int
+	Ticket          string
+	ReportMessageID int
+	ResponseDueAt   *time.Time
+	ResolutionDueAt *time.Time
+	FirstResponseAt *time.Time
+}
+
+// SLAEvent is a warning or breach raised for a task
+type SLAEvent struct {
+	ID       int    `json:"id"`
+	TaskID   int    `json:"task_id"`
+	Ticket   string `json:"ticket_no"`
+	Kind     string `json:"kind"`
+	DueAt    string `json:"due_at"`
+	RaisedAt string `json:"raised_at"`
+}
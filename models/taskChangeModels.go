@@ -0,0 +1,93 @@
+package models
+
+// Entities and actions of task_changes
+const (
+	ChangeEntityTask       = "task"
+	ChangeEntityProgress   = "progress"
+	ChangeEntityResolution = "resolution"
+	ChangeEntityIncident   = "incident"
+	ChangeEntityLink       = "link"
+	// ChangeEntityProblemRecord records a task attached to (create) or detached from (delete) a problem record
+	ChangeEntityProblemRecord = "problem_record"
+
+	ChangeActionCreate  = "create"
+	ChangeActionUpdate  = "update"
+	ChangeActionAssign  = "assign"
+	ChangeActionDelete  = "delete"
+	ChangeActionRestore = "restore"
+)
+
+// FieldChange is the old and new value of one column; nil stands for NULL
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// TaskChange is one row of task_changes: a change to a task, one of its progress entries or its resolution.
+// EntityID is the id of the progress entry or resolution, or the task id.
+type TaskChange struct {
+	ID        int           `json:"id"`
+	TaskID    int           `json:"task_id"`
+	Entity    string        `json:"entity"`
+	EntityID  int           `json:"entity_id"`
+	Action    string        `json:"action"`
+	Fields    []FieldChange `json:"fields"`
+	ChangedBy *int          `json:"changed_by"`
+	ChangedAt string        `json:"changed_at"`
+}
+
+// Timeline event types
+const (
+	TimelineCreated       = "created"
+	TimelineEdited        = "edited"
+	TimelineAssigned      = "assigned"
+	TimelineStatusChanged = "status_changed"
+	TimelineDeleted       = "deleted"
+	TimelineRestored      = "restored"
+	// progress_added, progress_edited, progress_deleted, progress_restored, the same for resolution_
+	// incident_added, link_added, link_deleted, problem_record_added and problem_record_deleted are built
+	// from the entity and action by TimelineType
+)
+
+// TimelineEvent is one entry of GET /api/v1/problem/:id/timeline.
+// Fields holds the diff of an edit or assignment and the values of an added progress entry or resolution;
+// Status is set for status changes.
+type TimelineEvent struct {
+	Type     string            `json:"type"`
+	At       string            `json:"at"`
+	Actor    *int              `json:"actor"`
+	EntityID int               `json:"entity_id,omitempty"`
+	Fields   []FieldChange     `json:"fields,omitempty"`
+	Status   *TaskStatusChange `json:"status,omitempty"`
+}
+
+// TimelineType returns the timeline event type of a change
+func TimelineType(entity, action string) string {
+	if entity == ChangeEntityTask {
+		switch action {
+		case ChangeActionCreate:
+			return TimelineCreated
+		case ChangeActionUpdate:
+			return TimelineEdited
+		case ChangeActionAssign:
+			return TimelineAssigned
+		case ChangeActionDelete:
+			return TimelineDeleted
+		case ChangeActionRestore:
+			return TimelineRestored
+		}
+		return action
+	}
+	switch action {
+	case ChangeActionCreate:
+		return entity + "_added"
+	case ChangeActionUpdate:
+		return entity + "_edited"
+	case ChangeActionDelete:
+		return entity + "_deleted"
+	case ChangeActionRestore:
+		return entity + "_restored"
+	}
+	return entity + "_" + action
+}
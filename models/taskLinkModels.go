@@ -0,0 +1,97 @@
+package models
+
+import "errors"
+
+// Task link types as sent and returned by the API. A link is stored once, as the first type of its pair;
+// the other task sees it as the second. related links are stored with the lower task id first.
+const (
+	TaskLinkDuplicateOf  = "duplicate_of"
+	TaskLinkDuplicatedBy = "duplicated_by"
+	TaskLinkParentOf     = "parent_of"
+	TaskLinkChildOf      = "child_of"
+	TaskLinkBlocks       = "blocks"
+	TaskLinkBlockedBy    = "blocked_by"
+	TaskLinkRelated      = "related"
+)
+
+// taskLinkInverse maps each link type to the type the linked task sees
+var taskLinkInverse = map[string]string{
+	TaskLinkDuplicateOf:  TaskLinkDuplicatedBy,
+	TaskLinkDuplicatedBy: TaskLinkDuplicateOf,
+	TaskLinkParentOf:     TaskLinkChildOf,
+	TaskLinkChildOf:      TaskLinkParentOf,
+	TaskLinkBlocks:       TaskLinkBlockedBy,
+	TaskLinkBlockedBy:    TaskLinkBlocks,
+	TaskLinkRelated:      TaskLinkRelated,
+}
+
+// taskLinkStored lists the types links are stored as
+var taskLinkStored = map[string]bool{
+	TaskLinkDuplicateOf: true,
+	TaskLinkParentOf:    true,
+	TaskLinkBlocks:      true,
+	TaskLinkRelated:     true,
+}
+
+var (
+	// ErrInvalidTaskLink is returned for an unknown link type, a link of a task to itself or inherit on a link that cannot inherit
+	ErrInvalidTaskLink = errors.New("invalid task link")
+	// ErrTaskLinkExists is returned when the same link is added twice
+	ErrTaskLinkExists = errors.New("task link already exists")
+	// ErrTaskLinkConflict is returned when a link would give a task a second parent or original, or close a cycle
+	ErrTaskLinkConflict = errors.New("task link conflicts with existing links")
+)
+
+// ValidTaskLinkType reports whether t is a link type the API accepts
+func ValidTaskLinkType(t string) bool {
+	_, ok := taskLinkInverse[t]
+	return ok
+}
+
+// InverseTaskLink returns the type the linked task sees for a link of type t
+func InverseTaskLink(t string) string {
+	return taskLinkInverse[t]
+}
+
+// IsStoredTaskLink reports whether links of type t are stored as t rather than as its inverse
+func IsStoredTaskLink(t string) bool {
+	return taskLinkStored[t]
+}
+
+// TaskLinkInherits reports whether a link of type t may pass a resolution on:
+// a parent resolves its children and an original resolves its duplicates
+func TaskLinkInherits(t string) bool {
+	return t == TaskLinkParentOf || t == TaskLinkDuplicateOf
+}
+
+// TaskLinkRecord is one row of task_links, stored as one of the types accepted by IsStoredTaskLink
+type TaskLinkRecord struct {
+	ID           int
+	TaskID       int
+	LinkedTaskID int
+	Type         string
+	Inherit      bool
+	CreatedBy    *int
+	CreatedAt    string
+}
+
+// TaskLink is a link as seen from one of its tasks: Type is read from that task to the linked task
+type TaskLink struct {
+	ID        int        `json:"id"`
+	Type      string     `json:"type"`
+	TaskID    int        `json:"task_id"`
+	Ticket    string     `json:"ticket_no"`
+	Status    TaskStatus `json:"status"`
+	Inherit   bool       `json:"inherit"`
+	CreatedBy *int       `json:"created_by"`
+	CreatedAt string     `json:"created_at"`
+}
+
+// TaskLinkRequest links the task in the path to TaskID.
+// With Inherit on a parent_of/child_of or duplicate_of/duplicated_by link, resolving the parent or the
+// original also resolves the child or the duplicate with a copy of the resolution text.
+type TaskLinkRequest struct {
+	Type    string `json:"type"`
+	TaskID  int    `json:"task_id"`
+	Inherit bool   `json:"inherit"`
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TaskStatus is the workflow state of a task (tasks.status).
+// 0-2 keep the values stored before the state machine existed.
+type TaskStatus int
+
+const (
+	TaskStatusPending          TaskStatus = 0 // รอดำเนินการ
+	TaskStatusInProgress       TaskStatus = 1 // กำลังดำเนินการ
+	TaskStatusDone             TaskStatus = 2 // เสร็จสิ้นแล้ว
+	TaskStatusOnHold           TaskStatus = 3 // พักไว้ชั่วคราว
+	TaskStatusWaitingRequester TaskStatus = 4 // รอข้อมูลจากผู้แจ้ง
+	TaskStatusCancelled        TaskStatus = 5 // ยกเลิก
+	TaskStatusReopened         TaskStatus = 6 // เปิดงานใหม่
+)
+
+// TaskStatuses lists every status in workflow order
+var TaskStatuses = []TaskStatus{
+	TaskStatusPending,
+	TaskStatusReopened,
+	TaskStatusInProgress,
+	TaskStatusOnHold,
+	TaskStatusWaitingRequester,
+	TaskStatusDone,
+	TaskStatusCancelled,
+}
+
+// ErrInvalidStatusTransition is returned when a status change is not allowed by taskStatusTransitions
+var ErrInvalidStatusTransition = errors.New("invalid status transition")
+
+// ErrVersionConflict is returned when a task, progress entry or resolution changed since the version the client read
+var ErrVersionConflict = errors.New("record was changed by another user")
+
+var taskStatusNames = map[TaskStatus]string{
+	TaskStatusPending:          "pending",
+	TaskStatusInProgress:       "in_progress",
+	TaskStatusDone:             "done",
+	TaskStatusOnHold:           "on_hold",
+	TaskStatusWaitingRequester: "waiting_for_requester",
+	TaskStatusCancelled:        "cancelled",
+	TaskStatusReopened:         "reopened",
+}
+
+var taskStatusLabels = map[TaskStatus]string{
+	TaskStatusPending:          "รอดำเนินการ",
+	TaskStatusInProgress:       "กำลังดำเนินการ",
+	TaskStatusDone:             "เสร็จสิ้นแล้ว",
+	TaskStatusOnHold:           "พักไว้ชั่วคราว",
+	TaskStatusWaitingRequester: "รอข้อมูลจากผู้แจ้ง",
+	TaskStatusCancelled:        "ยกเลิก",
+	TaskStatusReopened:         "เปิดงานใหม่",
+}
+
+// taskStatusTransitions lists the statuses each status may move to.
+// Done and cancelled tasks can only be reopened.
+var taskStatusTransitions = map[TaskStatus][]TaskStatus{
+	TaskStatusPending:          {TaskStatusInProgress, TaskStatusOnHold, TaskStatusWaitingRequester, TaskStatusDone, TaskStatusCancelled},
+	TaskStatusReopened:         {TaskStatusPending, TaskStatusInProgress, TaskStatusOnHold, TaskStatusWaitingRequester, TaskStatusDone, TaskStatusCancelled},
+	TaskStatusInProgress:       {TaskStatusPending, TaskStatusOnHold, TaskStatusWaitingRequester, TaskStatusDone, TaskStatusCancelled},
+	TaskStatusOnHold:           {TaskStatusPending, TaskStatusInProgress, TaskStatusWaitingRequester, TaskStatusDone, TaskStatusCancelled},
+	TaskStatusWaitingRequester: {TaskStatusPending, TaskStatusInProgress, TaskStatusOnHold, TaskStatusDone, TaskStatusCancelled},
+	TaskStatusDone:             {TaskStatusReopened},
+	TaskStatusCancelled:        {TaskStatusReopened},
+}
+
+// Valid reports whether s is a known status
+func (s TaskStatus) Valid() bool {
+	_, ok := taskStatusNames[s]
+	return ok
+}
+
+// String returns the API name of the status, e.g. "in_progress"
+func (s TaskStatus) String() string {
+	if name, ok := taskStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// Label returns the Thai label shown in Telegram messages and exports
+func (s TaskStatus) Label() string {
+	if label, ok := taskStatusLabels[s]; ok {
+		return label
+	}
+	return "ไม่ระบุ"
+}
+
+// IsClosed reports whether the task is finished (done or cancelled)
+func (s TaskStatus) IsClosed() bool {
+	return s == TaskStatusDone || s == TaskStatusCancelled
+}
+
+// CanTransitionTo reports whether a task may move from s to next
+func (s TaskStatus) CanTransitionTo(next TaskStatus) bool {
+	for _, allowed := range taskStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Transitions returns the statuses s may move to
+func (s TaskStatus) Transitions() []TaskStatus {
+	return append([]TaskStatus(nil), taskStatusTransitions[s]...)
+}
+
+// TaskStatusInfo describes a status for API clients
+type TaskStatusInfo struct {
+	Value       TaskStatus   `json:"value"`
+	Name        string       `json:"name"`
+	Label       string       `json:"label"`
+	Transitions []TaskStatus `json:"transitions"`
+}
+
+// TaskStatusChange is one row of task_status_history; FromStatus is nil for the initial status
+type TaskStatusChange struct {
+	ID         int         `json:"id"`
+	TaskID     int         `json:"task_id"`
+	FromStatus *TaskStatus `json:"from_status"`
+	ToStatus   TaskStatus  `json:"to_status"`
+	FromLabel  string      `json:"from_label,omitempty"`
+	ToLabel    string      `json:"to_label"`
+	ChangedBy  *int        `json:"changed_by"`
+	Reason     string      `json:"reason"`
+	ChangedAt  string      `json:"changed_at"`
+}
+
+// TaskStatusUpdateRequest changes the status of a task
+type TaskStatusUpdateRequest struct {
+	Status    TaskStatus `json:"status"`
+	Reason    string     `json:"reason"`
+	UpdatedBy int        `json:"updated_by"` // ignored unless it matches the authenticated user
+}
@@ -0,0 +1,48 @@
+package models
+
+import "testing"
+
+func TestTaskStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to TaskStatus
+		want     bool
+	}{
+		{TaskStatusPending, TaskStatusInProgress, true},
+		{TaskStatusPending, TaskStatusDone, true},
+		{TaskStatusPending, TaskStatusReopened, false},
+		{TaskStatusPending, TaskStatusPending, false},
+		{TaskStatusInProgress, TaskStatusOnHold, true},
+		{TaskStatusOnHold, TaskStatusWaitingRequester, true},
+		{TaskStatusWaitingRequester, TaskStatusCancelled, true},
+		{TaskStatusReopened, TaskStatusPending, true},
+		{TaskStatusReopened, TaskStatusDone, true},
+		{TaskStatusDone, TaskStatusReopened, true},
+		{TaskStatusDone, TaskStatusInProgress, false},
+		{TaskStatusDone, TaskStatusCancelled, false},
+		{TaskStatusCancelled, TaskStatusReopened, true},
+		{TaskStatusCancelled, TaskStatusPending, false},
+		{TaskStatus(99), TaskStatusPending, false},
+		{TaskStatusPending, TaskStatus(99), false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestTaskStatusTransitionsAreValid(t *testing.T) {
+	for _, from := range TaskStatuses {
+		for _, to := range from.Transitions() {
+			if !to.Valid() {
+				t.Errorf("%s may move to unknown status %d", from, int(to))
+			}
+			if to == from {
+				t.Errorf("%s may move to itself", from)
+			}
+		}
+		if from.IsClosed() && (len(from.Transitions()) != 1 || from.Transitions()[0] != TaskStatusReopened) {
+			t.Errorf("closed status %s may only be reopened, got %v", from, from.Transitions())
+		}
+	}
+}
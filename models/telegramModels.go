@@ -26,3 +26,13 @@ type TelegramPayload struct {
 	ReplyToMessageID int    `json:"reply_to_message_id"`
 	ParseMode        string `json:"parse_mode,omitempty"`
 }
+
+// TelegramChat links a task to its Telegram messages
+type TelegramChat struct {
+	ID                int    `json:"id"`
+	ChatID            string `json:"chat_id"`
+	ChatName          string `json:"chat_name"`
+	ReportMessageID   int    `json:"report_id"`
+	AssigneeMessageID int    `json:"assignto_id"`
+	SolutionMessageID int    `json:"solution_id"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Ticket sequence reset periods; the running number starts again from 1 in every period
+const (
+	TicketResetDaily   = "daily"
+	TicketResetMonthly = "monthly"
+	TicketResetYearly  = "yearly"
+	TicketResetNever   = "never"
+)
+
+// TicketConfig describes how ticket numbers are rendered and when the running number resets.
+// Format may contain {date} (rendered with DateLayout) and {seq} (zero padded to SeqWidth).
+type TicketConfig struct {
+	Format     string
+	DateLayout string
+	SeqWidth   int
+	Reset      string
+	Location   *time.Location
+}
+
+// DuplicateTicket is a ticket number used by more than one task
+type DuplicateTicket struct {
+	TicketNo string `json:"ticket_no"`
+	Count    int    `json:"count"`
+	TaskIDs  string `json:"task_ids"`
+}
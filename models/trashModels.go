@@ -0,0 +1,41 @@
+package models
+
+import "errors"
+
+// Trash kinds; each names the soft-deleted records listed under /api/v1/trash/{kind}
+const (
+	TrashTasks          = "tasks"
+	TrashResolutions    = "resolutions"
+	TrashProgress       = "progress"
+	TrashBranches       = "branches"
+	TrashDepartments    = "departments"
+	TrashPhones         = "ipphones"
+	TrashPrograms       = "programs"
+	TrashUsers          = "users"
+	TrashProblemRecords = "problemrecords"
+)
+
+// TrashKinds lists every trash kind in the order the retention job purges them
+var TrashKinds = []string{
+	TrashProgress, TrashResolutions, TrashTasks, TrashProblemRecords,
+	TrashPhones, TrashDepartments, TrashBranches, TrashPrograms, TrashUsers,
+}
+
+// ErrRestoreConflict is returned when a trashed record cannot be restored next to the current data
+var ErrRestoreConflict = errors.New("record cannot be restored")
+
+// TrashItem is one soft-deleted record
+type TrashItem struct {
+	Kind      string `json:"kind"`
+	ID        int    `json:"id"`
+	Label     string `json:"label"`
+	TaskID    *int   `json:"task_id,omitempty"` // parent task of progress entries and resolutions
+	DeletedAt string `json:"deleted_at"`
+	DeletedBy *int   `json:"deleted_by"`
+}
+
+// TrashAttachments are the files and Telegram messages removed when a trashed record is purged
+type TrashAttachments struct {
+	FileURLs           []string
+	TelegramMessageIDs []int
+}
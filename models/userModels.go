@@ -15,34 +15,54 @@ type Credentials struct {
 
 // LoginResponse represents the structure of the response after a user logs in
 type LoginResponse struct {
-	Success   bool   `json:"success"`
-	Message   string `json:"message"`
-	Token     string `json:"token,omitempty"`
-	Data      *Data  `json:"data,omitempty"`
-	Timestamp string `json:"timestamp,omitempty"`
-	RequestID string `json:"request_id,omitempty"`
+	Success   bool       `json:"success"`
+	Message   string     `json:"message"`
+	Token     string     `json:"token,omitempty"`
+	Tokens    *TokenPair `json:"tokens,omitempty"`
+	Data      *Data      `json:"data,omitempty"`
+	Timestamp string     `json:"timestamp,omitempty"`
+	RequestID string     `json:"request_id,omitempty"`
 }
 
 // RegisterUserRequest represents the structure of the request to register a new user
 type RegisterUserRequest struct {
 	Username  string `json:"username"`
 	Password  string `json:"password"`
-	CreatedBy int    `json:"created_by"`
+	CreatedBy int    `json:"created_by"` // ignored unless it matches the authenticated user
 }
 
-// UserDetailResponse represents the structure of user details response
+// UpdateUserRequest represents the structure of the request to update a user profile
+// Password is optional; the stored hash is only replaced when a new password is sent
 type UpdateUserRequest struct {
-	ID            int     `json:"id"`
-	Username      string  `json:"username"`
-	Password      string  `json:"password"`
-	PlainPassword *string `json:"plain_password"`
-	Role          string  `json:"role"`
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+	Role     string `json:"role"`
+}
+
+// ChangePasswordRequest represents a self-service password change
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+// PasswordResetResponse carries a one-time reset token issued by an admin
+type PasswordResetResponse struct {
+	UserID     int    `json:"user_id"`
+	ResetToken string `json:"reset_token"`
+	ExpiresAt  string `json:"expires_at"`
+}
+
+// ResetPasswordRequest represents the redemption of a one-time reset token
+type ResetPasswordRequest struct {
+	ResetToken  string `json:"reset_token"`
+	NewPassword string `json:"new_password"`
 }
 
 // UserDetailResponse represents the structure of user details response
 type DeleteUserRequest struct {
 	ID        int `json:"id"`
-	DeletedBy int `json:"deleted_by"`
+	DeletedBy int `json:"deleted_by"` // ignored unless it matches the authenticated user
 }
 
 type ResponseRequest struct {
@@ -53,11 +73,25 @@ type ResponseRequest struct {
 
 // UserResponse represents the structure of user response (without password for security)
 type UsernameResponse struct {
-	ID             int    `json:"id"`
-	Username       string `json:"username"`
-	Role           string `json:"role"`
-	CreatedAt      string `json:"created_at"`
-	HashedPassword string `json:"hashed_password,omitempty"`
-	PlainPassword  string `json:"plain_password,omitempty"`
-	PasswordNote   string `json:"password_note,omitempty"`
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// UnlockUserRequest represents an admin request to clear a login lockout
+type UnlockUserRequest struct {
+	Username string `json:"username"`
+}
+
+// LoginHistory represents a single login attempt in the audit trail
+type LoginHistory struct {
+	ID            int     `json:"id"`
+	UserID        *int    `json:"user_id"`
+	Username      string  `json:"username"`
+	IPAddress     string  `json:"ip_address"`
+	UserAgent     string  `json:"user_agent"`
+	Success       bool    `json:"success"`
+	FailureReason *string `json:"failure_reason"`
+	CreatedAt     string  `json:"created_at"`
 }
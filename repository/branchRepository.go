@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reports-api/models"
+)
+
+// BranchRepository reads and writes branches
+type BranchRepository interface {
+	List(ctx context.Context, q ListQuery) ([]models.Branch, int, error)
+	// Get returns a branch with the number of departments and IP phones in it
+	Get(ctx context.Context, id int) (*models.BranchDetail, error)
+	Create(ctx context.Context, name *string, actor int) (int64, error)
+	Update(ctx context.Context, id int, name *string, actor int) error
+	// Delete moves the row to the trash; it returns ErrNotFound when it is missing or already trashed
+	Delete(ctx context.Context, id, actor int) error
+}
+
+type mysqlBranchRepository struct {
+	db DBTX
+}
+
+func (r *mysqlBranchRepository) List(ctx context.Context, q ListQuery) ([]models.Branch, int, error) {
+	where := " WHERE deleted_at IS NULL"
+	var args []interface{}
+	if q.Search != "" {
+		where += " AND name LIKE ?"
+		args = append(args, likePattern(q.Search))
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM branches"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count branches: %w", err)
+	}
+
+	query := "SELECT id, name, created_at, updated_at, deleted_at, created_by, updated_by, deleted_by FROM branches" + where + " ORDER BY id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query branches: %w", err)
+	}
+	defer rows.Close()
+
+	var branches []models.Branch
+	for rows.Next() {
+		var b models.Branch
+		if err := rows.Scan(&b.ID, &b.Name, &b.CreatedAt, &b.UpdatedAt, &b.DeletedAt, &b.CreatedBy, &b.UpdatedBy, &b.DeletedBy); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan branch: %w", err)
+		}
+		branches = append(branches, b)
+	}
+	return branches, total, rows.Err()
+}
+
+func (r *mysqlBranchRepository) Get(ctx context.Context, id int) (*models.BranchDetail, error) {
+	var b models.BranchDetail
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, name, created_at, updated_at
+		FROM branches
+		WHERE id = ? AND deleted_at IS NULL
+	`, id).Scan(&b.ID, &b.Name, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM departments
+		WHERE branch_id = ? AND deleted_at IS NULL
+	`, id).Scan(&b.DepartmentsCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count departments: %w", err)
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM ip_phones ip
+		JOIN departments d ON ip.department_id = d.id
+		WHERE d.branch_id = ? AND ip.deleted_at IS NULL
+	`, id).Scan(&b.IPPhonesCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count IP phones: %w", err)
+	}
+	return &b, nil
+}
+
+func (r *mysqlBranchRepository) Create(ctx context.Context, name *string, actor int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO branches (name, created_by, updated_by) VALUES (?, ?, ?)`, name, actor, actor)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (r *mysqlBranchRepository) Update(ctx context.Context, id int, name *string, actor int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE branches SET name = ?, updated_by = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`, name, actor, id)
+	return err
+}
+
+func (r *mysqlBranchRepository) Delete(ctx context.Context, id, actor int) error {
+	return softDelete(ctx, r.db, "branches", id, actor)
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reports-api/models"
+	"strings"
+)
+
+// CalendarRepository reads and writes working hours and holidays
+type CalendarRepository interface {
+	// WorkingHours returns the periods stored for the branch; nil branchID reads the default schedule
+	WorkingHours(ctx context.Context, branchID *int) ([]models.WorkingPeriod, error)
+	// EffectiveWorkingHours returns the branch's own periods, or the default schedule when it has none
+	EffectiveWorkingHours(ctx context.Context, branchID int) ([]models.WorkingPeriod, error)
+	// ReplaceWorkingHours replaces every period of the branch (or of the default schedule)
+	ReplaceWorkingHours(ctx context.Context, branchID *int, periods []models.WorkingPeriod, actor int) error
+
+	ListHolidays(ctx context.Context, q models.HolidayQuery) ([]models.Holiday, error)
+	// HolidayDates returns the dates (2006-01-02) closed for the branch, including company-wide holidays
+	HolidayDates(ctx context.Context, branchID int) ([]string, error)
+	// CreateHoliday inserts a holiday unless the branch already has one on that date; it reports whether a row was added
+	CreateHoliday(ctx context.Context, req models.HolidayRequest, source string, actor int) (bool, error)
+	DeleteHoliday(ctx context.Context, id int) error
+}
+
+type mysqlCalendarRepository struct {
+	db DBTX
+}
+
+// timeOfDay trims the seconds of a TIME column ("08:00:00" → "08:00")
+func timeOfDay(s string) string {
+	if len(s) == len("15:04:05") && strings.Count(s, ":") == 2 {
+		return s[:5]
+	}
+	return s
+}
+
+func (r *mysqlCalendarRepository) queryPeriods(ctx context.Context, where string, args ...interface{}) ([]models.WorkingPeriod, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT weekday, CAST(start_time AS CHAR), CAST(end_time AS CHAR) FROM working_hours WHERE `+where+` ORDER BY weekday, start_time`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query working hours: %w", err)
+	}
+	defer rows.Close()
+
+	periods := []models.WorkingPeriod{}
+	for rows.Next() {
+		var p models.WorkingPeriod
+		if err := rows.Scan(&p.Weekday, &p.StartTime, &p.EndTime); err != nil {
+			return nil, fmt.Errorf("failed to scan working hours: %w", err)
+		}
+		p.StartTime = timeOfDay(p.StartTime)
+		p.EndTime = timeOfDay(p.EndTime)
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
+
+func (r *mysqlCalendarRepository) WorkingHours(ctx context.Context, branchID *int) ([]models.WorkingPeriod, error) {
+	if branchID == nil {
+		return r.queryPeriods(ctx, "branch_id IS NULL")
+	}
+	return r.queryPeriods(ctx, "branch_id = ?", *branchID)
+}
+
+func (r *mysqlCalendarRepository) EffectiveWorkingHours(ctx context.Context, branchID int) ([]models.WorkingPeriod, error) {
+	if branchID > 0 {
+		periods, err := r.queryPeriods(ctx, "branch_id = ?", branchID)
+		if err != nil || len(periods) > 0 {
+			return periods, err
+		}
+	}
+	return r.queryPeriods(ctx, "branch_id IS NULL")
+}
+
+func (r *mysqlCalendarRepository) ReplaceWorkingHours(ctx context.Context, branchID *int, periods []models.WorkingPeriod, actor int) error {
+	var err error
+	if branchID == nil {
+		_, err = r.db.ExecContext(ctx, `DELETE FROM working_hours WHERE branch_id IS NULL`)
+	} else {
+		_, err = r.db.ExecContext(ctx, `DELETE FROM working_hours WHERE branch_id = ?`, *branchID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to clear working hours: %w", err)
+	}
+
+	for _, p := range periods {
+		_, err := r.db.ExecContext(ctx, `INSERT INTO working_hours (branch_id, weekday, start_time, end_time, created_by) VALUES (?, ?, ?, ?, ?)`,
+			branchID, p.Weekday, p.StartTime, p.EndTime, nullIfZero(actor))
+		if err != nil {
+			return fmt.Errorf("failed to insert working hours: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *mysqlCalendarRepository) ListHolidays(ctx context.Context, q models.HolidayQuery) ([]models.Holiday, error) {
+	var where []string
+	var args []interface{}
+	if q.BranchID != nil {
+		where = append(where, "(branch_id IS NULL OR branch_id = ?)")
+		args = append(args, *q.BranchID)
+	}
+	if q.From != "" {
+		where = append(where, "holiday_date >= ?")
+		args = append(args, q.From)
+	}
+	if q.To != "" {
+		where = append(where, "holiday_date <= ?")
+		args = append(args, q.To)
+	}
+	query := `SELECT id, branch_id, holiday_date, name, source, created_at, created_by FROM holidays`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := r.db.QueryContext(ctx, query+" ORDER BY holiday_date, id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holidays: %w", err)
+	}
+	defer rows.Close()
+
+	holidays := []models.Holiday{}
+	for rows.Next() {
+		var h models.Holiday
+		var date sql.NullTime
+		if err := rows.Scan(&h.ID, &h.BranchID, &date, &h.Name, &h.Source, &h.CreatedAt, &h.CreatedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan holiday: %w", err)
+		}
+		if date.Valid {
+			h.Date = date.Time.Format("2006-01-02")
+		}
+		holidays = append(holidays, h)
+	}
+	return holidays, rows.Err()
+}
+
+func (r *mysqlCalendarRepository) HolidayDates(ctx context.Context, branchID int) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT DATE_FORMAT(holiday_date, '%Y-%m-%d') FROM holidays WHERE branch_id IS NULL OR branch_id = ?`, branchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holidays: %w", err)
+	}
+	defer rows.Close()
+
+	var dates []string
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, fmt.Errorf("failed to scan holiday: %w", err)
+		}
+		dates = append(dates, d)
+	}
+	return dates, rows.Err()
+}
+
+func (r *mysqlCalendarRepository) CreateHoliday(ctx context.Context, req models.HolidayRequest, source string, actor int) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO holidays (branch_id, holiday_date, name, source, created_by)
+		SELECT ?, ?, ?, ?, ?
+		FROM DUAL
+		WHERE NOT EXISTS (SELECT 1 FROM holidays WHERE holiday_date = ? AND branch_id <=> ?)
+	`, req.BranchID, req.Date, req.Name, source, nullIfZero(actor), req.Date, req.BranchID)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert holiday: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *mysqlCalendarRepository) DeleteHoliday(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM holidays WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reports-api/models"
+)
+
+// DepartmentRepository reads and writes departments
+type DepartmentRepository interface {
+	// List returns departments with their branch name; Search matches the department or branch name
+	List(ctx context.Context, q ListQuery) ([]models.Department, int, error)
+	// Get returns a department with the number of IP phones and tasks in it
+	Get(ctx context.Context, id int) (*models.DepartmentDetail, error)
+	Create(ctx context.Context, req models.DepartmentRequest, actor int) (int64, error)
+	Update(ctx context.Context, id int, req models.DepartmentRequest, actor int) error
+	// Delete moves the row to the trash; it returns ErrNotFound when it is missing or already trashed
+	Delete(ctx context.Context, id, actor int) error
+	// Location returns the department and branch names used in notifications
+	Location(ctx context.Context, id int) (departmentName, branchName string, err error)
+}
+
+type mysqlDepartmentRepository struct {
+	db DBTX
+}
+
+func (r *mysqlDepartmentRepository) List(ctx context.Context, q ListQuery) ([]models.Department, int, error) {
+	where := " WHERE d.deleted_at IS NULL"
+	var args []interface{}
+	if q.Search != "" {
+		where += " AND (d.name LIKE ? OR b.name LIKE ?)"
+		pattern := likePattern(q.Search)
+		args = append(args, pattern, pattern)
+	}
+	from := " FROM departments d LEFT JOIN branches b ON d.branch_id = b.id AND b.deleted_at IS NULL"
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*)"+from+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count departments: %w", err)
+	}
+
+	query := "SELECT d.id, d.name, d.branch_id, b.name, d.created_at, d.updated_at, d.deleted_at" + from + where + " ORDER BY d.id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query departments: %w", err)
+	}
+	defer rows.Close()
+
+	var departments []models.Department
+	for rows.Next() {
+		var d models.Department
+		if err := rows.Scan(&d.ID, &d.Name, &d.BranchID, &d.BranchName, &d.CreatedAt, &d.UpdatedAt, &d.DeletedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan department: %w", err)
+		}
+		departments = append(departments, d)
+	}
+	return departments, total, rows.Err()
+}
+
+func (r *mysqlDepartmentRepository) Get(ctx context.Context, id int) (*models.DepartmentDetail, error) {
+	var d models.DepartmentDetail
+	err := r.db.QueryRowContext(ctx, `
+		SELECT d.id, d.name, IFNULL(d.branch_id, 0), IFNULL(b.name, ''), d.created_at, d.updated_at
+		FROM departments d
+		LEFT JOIN branches b ON d.branch_id = b.id
+		WHERE d.id = ? AND d.deleted_at IS NULL
+	`, id).Scan(&d.ID, &d.Name, &d.BranchID, &d.BranchName, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM ip_phones
+		WHERE department_id = ? AND deleted_at IS NULL
+	`, id).Scan(&d.IPPhonesCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count IP phones: %w", err)
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM tasks t
+		JOIN ip_phones ip ON t.phone_id = ip.id
+		WHERE ip.department_id = ? AND t.deleted_at IS NULL
+	`, id).Scan(&d.TasksCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tasks: %w", err)
+	}
+	return &d, nil
+}
+
+func (r *mysqlDepartmentRepository) Create(ctx context.Context, req models.DepartmentRequest, actor int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO departments (name, branch_id, created_by, updated_by) VALUES (?, ?, ?, ?)`, req.Name, req.BranchID, actor, actor)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (r *mysqlDepartmentRepository) Update(ctx context.Context, id int, req models.DepartmentRequest, actor int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE departments SET name = ?, branch_id = ?, updated_by = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`, req.Name, req.BranchID, actor, id)
+	return err
+}
+
+func (r *mysqlDepartmentRepository) Delete(ctx context.Context, id, actor int) error {
+	return softDelete(ctx, r.db, "departments", id, actor)
+}
+
+func (r *mysqlDepartmentRepository) Location(ctx context.Context, id int) (departmentName, branchName string, err error) {
+	err = r.db.QueryRowContext(ctx, `
+		SELECT IFNULL(d.name, ''), IFNULL(b.name, '')
+		FROM departments d
+		JOIN branches b ON d.branch_id = b.id
+		WHERE d.id = ?
+	`, id).Scan(&departmentName, &branchName)
+	return departmentName, branchName, notFound(err)
+}
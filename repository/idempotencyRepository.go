@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reports-api/models"
+	"time"
+)
+
+// IdempotencyRepository stores the responses of requests sent with an Idempotency-Key.
+// Keys are the opaque buckets built by the caller.
+type IdempotencyRepository interface {
+	// Reserve claims bucket for window and stores the fingerprint of the request body. When another
+	// request holds a live claim it returns that request's record and false; expired claims and claims
+	// older than lockTimeout that never completed are taken over.
+	Reserve(ctx context.Context, bucket, fingerprint string, now time.Time, window, lockTimeout time.Duration) (*models.IdempotencyRecord, bool, error)
+	// Complete stores the response of the request holding bucket
+	Complete(ctx context.Context, bucket string, statusCode int, contentType string, body []byte) error
+	// Release drops an uncompleted claim so the request can be retried
+	Release(ctx context.Context, bucket string) error
+	// PurgeExpired removes the keys whose window has elapsed
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type mysqlIdempotencyRepository struct {
+	db DBTX
+}
+
+func (r *mysqlIdempotencyRepository) Reserve(ctx context.Context, bucket, fingerprint string, now time.Time, window, lockTimeout time.Duration) (*models.IdempotencyRecord, bool, error) {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE bucket = ? AND (expires_at <= ? OR (status_code IS NULL AND created_at <= ?))
+	`, bucket, now, now.Add(-lockTimeout))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to release expired idempotency key: %w", err)
+	}
+
+	// rows affected is 1 when the key was inserted and 0 when it already existed
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (bucket, fingerprint, created_at, expires_at) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE bucket = bucket
+	`, bucket, fingerprint, now, now.Add(window))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 1 {
+		return nil, true, nil
+	}
+
+	var record models.IdempotencyRecord
+	var statusCode sql.NullInt64
+	err = r.db.QueryRowContext(ctx, `
+		SELECT fingerprint, status_code, content_type, body, created_at FROM idempotency_keys WHERE bucket = ?
+	`, bucket).Scan(&record.Fingerprint, &statusCode, &record.ContentType, &record.Body, &record.CreatedAt)
+	if err != nil {
+		return nil, false, notFound(err)
+	}
+	record.StatusCode = int(statusCode.Int64)
+	return &record, false, nil
+}
+
+func (r *mysqlIdempotencyRepository) Complete(ctx context.Context, bucket string, statusCode int, contentType string, body []byte) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE idempotency_keys SET status_code = ?, content_type = ?, body = ? WHERE bucket = ?
+	`, statusCode, contentType, body, bucket)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (r *mysqlIdempotencyRepository) Release(ctx context.Context, bucket string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE bucket = ? AND status_code IS NULL`, bucket)
+	return err
+}
+
+func (r *mysqlIdempotencyRepository) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
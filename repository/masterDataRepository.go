@@ -0,0 +1,426 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reports-api/models"
+)
+
+// MasterDataRepository follows the references to branches, departments, IP phones and programs.
+// Kinds are the matching models.Trash* kinds.
+type MasterDataRepository interface {
+	// Dependents counts the live records referencing a live record; it returns ErrNotFound for a missing or trashed record.
+	// Inside a transaction the record stays locked and no dependent can be added until it ends.
+	Dependents(ctx context.Context, kind string, id int) (*models.Dependents, error)
+	// DependentTasks returns the live tasks referencing the record, directly or through its departments
+	DependentTasks(ctx context.Context, kind string, id int) ([]int, error)
+	// References counts the tasks, child records and problem records, live or trashed, that still point at a record;
+	// a record is only purged when it has none
+	References(ctx context.Context, kind string, id int) (int, error)
+	// PurgeOwned deletes the rows a record owns before it is purged: the scores of a department and the SLA policies,
+	// working hours and holidays of a branch. Task incidents are history of their task and only lose the reference.
+	PurgeOwned(ctx context.Context, kind string, id int) error
+	// Reassign re-points the records referencing from to to, incidents and the calendar of a branch included; trashed
+	// records move too so a later restore stays consistent. Scores are moved separately with MergeScores.
+	Reassign(ctx context.Context, kind string, from, to, actor int) error
+	// DeleteChildren moves the live departments and IP phones below a branch or department to the trash
+	DeleteChildren(ctx context.Context, kind string, id, actor int) error
+	// ScoreConflicts counts the months both departments have a score
+	ScoreConflicts(ctx context.Context, from, to int) (int, error)
+	// MergeScores moves the scores of department from to department to; for months both have,
+	// the deductions from the initial 100 are added up
+	MergeScores(ctx context.Context, from, to int) error
+	// Names returns the live records of a kind; departments are grouped by branch
+	Names(ctx context.Context, kind string) ([]models.MasterDataName, error)
+	RecordMerge(ctx context.Context, entry models.MergeLogEntry) (int64, error)
+	// ListMerges returns recorded merges, newest first; an empty kind lists every kind
+	ListMerges(ctx context.Context, kind string, q ListQuery) ([]models.MergeLogEntry, int, error)
+}
+
+type mysqlMasterDataRepository struct {
+	db DBTX
+}
+
+func (r *mysqlMasterDataRepository) count(ctx context.Context, dst *int, query string, args ...interface{}) error {
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(dst); err != nil {
+		return fmt.Errorf("failed to count dependents: %w", err)
+	}
+	return nil
+}
+
+// lockedCount counts with a locking read, so inside a transaction it sees the latest rows and
+// holds back concurrent inserts into the counted range until the transaction ends
+func (r *mysqlMasterDataRepository) lockedCount(ctx context.Context, dst *int, query string, args ...interface{}) error {
+	return r.count(ctx, dst, query+" LOCK IN SHARE MODE", args...)
+}
+
+func (r *mysqlMasterDataRepository) Dependents(ctx context.Context, kind string, id int) (*models.Dependents, error) {
+	t, err := trashTableOf(kind)
+	if err != nil {
+		return nil, err
+	}
+	var exists int
+	if err := r.db.QueryRowContext(ctx, "SELECT 1 FROM "+t.table+" WHERE id = ? AND deleted_at IS NULL FOR UPDATE", id).Scan(&exists); err != nil {
+		return nil, notFound(err)
+	}
+
+	var d models.Dependents
+	switch kind {
+	case models.TrashBranches:
+		if err := r.lockedCount(ctx, &d.Departments, `SELECT COUNT(*) FROM departments WHERE branch_id = ? AND deleted_at IS NULL`, id); err != nil {
+			return nil, err
+		}
+		if err := r.lockedCount(ctx, &d.Phones, `
+			SELECT COUNT(*) FROM ip_phones p JOIN departments d ON p.department_id = d.id
+			WHERE d.branch_id = ? AND d.deleted_at IS NULL AND p.deleted_at IS NULL
+		`, id); err != nil {
+			return nil, err
+		}
+		if err := r.lockedCount(ctx, &d.Tasks, `
+			SELECT COUNT(*) FROM tasks t JOIN departments d ON t.department_id = d.id
+			WHERE d.branch_id = ? AND d.deleted_at IS NULL AND t.deleted_at IS NULL
+		`, id); err != nil {
+			return nil, err
+		}
+		if err := r.lockedCount(ctx, &d.Scores, `
+			SELECT COUNT(*) FROM scores s JOIN departments d ON s.department_id = d.id
+			WHERE d.branch_id = ? AND d.deleted_at IS NULL
+		`, id); err != nil {
+			return nil, err
+		}
+	case models.TrashDepartments:
+		if err := r.lockedCount(ctx, &d.Phones, `SELECT COUNT(*) FROM ip_phones WHERE department_id = ? AND deleted_at IS NULL`, id); err != nil {
+			return nil, err
+		}
+		if err := r.lockedCount(ctx, &d.Tasks, `SELECT COUNT(*) FROM tasks WHERE department_id = ? AND deleted_at IS NULL`, id); err != nil {
+			return nil, err
+		}
+		if err := r.lockedCount(ctx, &d.Scores, `SELECT COUNT(*) FROM scores WHERE department_id = ?`, id); err != nil {
+			return nil, err
+		}
+	case models.TrashPhones:
+		if err := r.lockedCount(ctx, &d.Tasks, `SELECT COUNT(*) FROM tasks WHERE phone_id = ? AND deleted_at IS NULL`, id); err != nil {
+			return nil, err
+		}
+	case models.TrashPrograms:
+		if err := r.lockedCount(ctx, &d.Tasks, `SELECT COUNT(*) FROM tasks WHERE system_id = ? AND deleted_at IS NULL`, id); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("kind %q has no dependents", kind)
+	}
+	return &d, nil
+}
+
+func (r *mysqlMasterDataRepository) DependentTasks(ctx context.Context, kind string, id int) ([]int, error) {
+	var query string
+	switch kind {
+	case models.TrashBranches:
+		query = `SELECT t.id FROM tasks t JOIN departments d ON t.department_id = d.id
+			WHERE d.branch_id = ? AND d.deleted_at IS NULL AND t.deleted_at IS NULL`
+	case models.TrashDepartments:
+		query = `SELECT id FROM tasks WHERE department_id = ? AND deleted_at IS NULL`
+	case models.TrashPhones:
+		query = `SELECT id FROM tasks WHERE phone_id = ? AND deleted_at IS NULL`
+	case models.TrashPrograms:
+		query = `SELECT id FROM tasks WHERE system_id = ? AND deleted_at IS NULL`
+	default:
+		return nil, fmt.Errorf("kind %q has no dependents", kind)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dependent tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var taskID int
+		if err := rows.Scan(&taskID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, taskID)
+	}
+	return ids, rows.Err()
+}
+
+func (r *mysqlMasterDataRepository) References(ctx context.Context, kind string, id int) (int, error) {
+	var queries []string
+	switch kind {
+	case models.TrashBranches:
+		queries = []string{`SELECT COUNT(*) FROM departments WHERE branch_id = ?`}
+	case models.TrashDepartments:
+		queries = []string{
+			`SELECT COUNT(*) FROM ip_phones WHERE department_id = ?`,
+			`SELECT COUNT(*) FROM tasks WHERE department_id = ?`,
+		}
+	case models.TrashPhones:
+		queries = []string{`SELECT COUNT(*) FROM tasks WHERE phone_id = ?`}
+	case models.TrashPrograms:
+		queries = []string{
+			`SELECT COUNT(*) FROM tasks WHERE system_id = ?`,
+			`SELECT COUNT(*) FROM problem_records WHERE system_id = ?`,
+		}
+	default:
+		return 0, fmt.Errorf("kind %q has no dependents", kind)
+	}
+
+	total := 0
+	for _, query := range queries {
+		var n int
+		if err := r.lockedCount(ctx, &n, query, id); err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (r *mysqlMasterDataRepository) PurgeOwned(ctx context.Context, kind string, id int) error {
+	var queries []string
+	switch kind {
+	case models.TrashBranches:
+		queries = []string{
+			`DELETE FROM sla_policies WHERE branch_id = ?`,
+			`DELETE FROM working_hours WHERE branch_id = ?`,
+			`DELETE FROM holidays WHERE branch_id = ?`,
+		}
+	case models.TrashDepartments:
+		queries = []string{
+			`DELETE FROM scores WHERE department_id = ?`,
+			`UPDATE task_incidents SET department_id = NULL WHERE department_id = ?`,
+		}
+	case models.TrashPhones:
+		queries = []string{`UPDATE task_incidents SET phone_id = NULL WHERE phone_id = ?`}
+	}
+
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("failed to purge rows owned by %s %d: %w", kind, id, err)
+		}
+	}
+	return nil
+}
+
+func (r *mysqlMasterDataRepository) Reassign(ctx context.Context, kind string, from, to, actor int) error {
+	var queries []string
+	var columns [][2]string // table and column of references without audit columns
+	switch kind {
+	case models.TrashBranches:
+		// task กับ IP phone อ้างอิงสาขาผ่านแผนก จึงย้ายตามแผนกไปเอง
+		queries = []string{`UPDATE departments SET branch_id = ?, updated_by = ? WHERE branch_id = ?`}
+	case models.TrashDepartments:
+		queries = []string{
+			`UPDATE ip_phones SET department_id = ?, updated_by = ? WHERE department_id = ?`,
+			`UPDATE tasks SET department_id = ?, updated_by = ?, version = version + 1 WHERE department_id = ?`,
+		}
+		columns = [][2]string{{"task_incidents", "department_id"}}
+	case models.TrashPhones:
+		queries = []string{`UPDATE tasks SET phone_id = ?, updated_by = ?, version = version + 1 WHERE phone_id = ?`}
+		columns = [][2]string{{"task_incidents", "phone_id"}}
+	case models.TrashPrograms:
+		queries = []string{
+			`UPDATE tasks SET system_id = ?, updated_by = ?, version = version + 1 WHERE system_id = ?`,
+			`UPDATE problem_records SET system_id = ?, updated_by = ? WHERE system_id = ?`,
+		}
+	default:
+		return fmt.Errorf("kind %q has no dependents", kind)
+	}
+
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query, to, nullIfZero(actor), from); err != nil {
+			return fmt.Errorf("failed to reassign %s %d: %w", kind, from, err)
+		}
+	}
+	for _, c := range columns {
+		query := "UPDATE " + c[0] + " SET " + c[1] + " = ? WHERE " + c[1] + " = ?"
+		if _, err := r.db.ExecContext(ctx, query, to, from); err != nil {
+			return fmt.Errorf("failed to reassign %s %d: %w", kind, from, err)
+		}
+	}
+	if kind == models.TrashBranches {
+		return r.reassignCalendar(ctx, from, to, actor)
+	}
+	return nil
+}
+
+// reassignCalendar moves the holidays of branch from to branch to, and the SLA policies and working hours
+// to does not set itself. Working hours are replaced as a whole set, so they only move when to has none;
+// what stays behind is deleted when from is purged.
+func (r *mysqlMasterDataRepository) reassignCalendar(ctx context.Context, from, to, actor int) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE holidays SET branch_id = ? WHERE branch_id = ?`, to, from); err != nil {
+		return fmt.Errorf("failed to reassign holidays of branch %d: %w", from, err)
+	}
+	// MySQL อัปเดตตารางที่อ่านใน subquery ตรง ๆ ไม่ได้ จึงห่อด้วย derived table
+	if _, err := r.db.ExecContext(ctx, `
+		UPDATE sla_policies SET branch_id = ?, updated_by = ?
+		WHERE branch_id = ? AND (priority, COALESCE(issue_type_id, 0)) NOT IN (
+			SELECT priority, issue_type FROM (
+				SELECT priority, COALESCE(issue_type_id, 0) AS issue_type FROM sla_policies WHERE branch_id = ?
+			) own
+		)
+	`, to, nullIfZero(actor), from, to); err != nil {
+		return fmt.Errorf("failed to reassign SLA policies of branch %d: %w", from, err)
+	}
+
+	var own int
+	if err := r.lockedCount(ctx, &own, `SELECT COUNT(*) FROM working_hours WHERE branch_id = ?`, to); err != nil {
+		return err
+	}
+	if own > 0 {
+		return nil
+	}
+	if _, err := r.db.ExecContext(ctx, `UPDATE working_hours SET branch_id = ? WHERE branch_id = ?`, to, from); err != nil {
+		return fmt.Errorf("failed to reassign working hours of branch %d: %w", from, err)
+	}
+	return nil
+}
+
+func (r *mysqlMasterDataRepository) DeleteChildren(ctx context.Context, kind string, id, actor int) error {
+	var queries []string
+	switch kind {
+	case models.TrashBranches:
+		queries = []string{
+			`UPDATE ip_phones p JOIN departments d ON p.department_id = d.id
+				SET p.deleted_at = CURRENT_TIMESTAMP, p.deleted_by = ?
+				WHERE d.branch_id = ? AND d.deleted_at IS NULL AND p.deleted_at IS NULL`,
+			`UPDATE departments SET deleted_at = CURRENT_TIMESTAMP, deleted_by = ? WHERE branch_id = ? AND deleted_at IS NULL`,
+		}
+	case models.TrashDepartments:
+		queries = []string{
+			`UPDATE ip_phones SET deleted_at = CURRENT_TIMESTAMP, deleted_by = ? WHERE department_id = ? AND deleted_at IS NULL`,
+		}
+	}
+
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query, nullIfZero(actor), id); err != nil {
+			return fmt.Errorf("failed to delete children of %s %d: %w", kind, id, err)
+		}
+	}
+	return nil
+}
+
+func (r *mysqlMasterDataRepository) ScoreConflicts(ctx context.Context, from, to int) (int, error) {
+	var n int
+	err := r.count(ctx, &n, `
+		SELECT COUNT(*) FROM scores s JOIN scores t ON t.year = s.year AND t.month = s.month
+		WHERE s.department_id = ? AND t.department_id = ?
+	`, from, to)
+	return n, err
+}
+
+func (r *mysqlMasterDataRepository) MergeScores(ctx context.Context, from, to int) error {
+	queries := []string{
+		// คะแนนเริ่มที่ 100 และถูกหักตามจำนวนปัญหา จึงรวมยอดที่ถูกหักของทั้งสองแผนก
+		`UPDATE scores t JOIN scores s ON s.year = t.year AND s.month = t.month
+			SET t.score = GREATEST(t.score + s.score - 100, 0)
+			WHERE t.department_id = ? AND s.department_id = ?`,
+		`DELETE s FROM scores s JOIN scores t ON t.year = s.year AND t.month = s.month
+			WHERE t.department_id = ? AND s.department_id = ?`,
+		`UPDATE scores SET department_id = ? WHERE department_id = ?`,
+	}
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query, to, from); err != nil {
+			return fmt.Errorf("failed to merge scores of department %d: %w", from, err)
+		}
+	}
+	return nil
+}
+
+func (r *mysqlMasterDataRepository) Names(ctx context.Context, kind string) ([]models.MasterDataName, error) {
+	var query string
+	switch kind {
+	case models.TrashBranches:
+		query = `SELECT id, IFNULL(name, ''), 0 FROM branches WHERE deleted_at IS NULL`
+	case models.TrashDepartments:
+		query = `SELECT id, IFNULL(name, ''), IFNULL(branch_id, 0) FROM departments WHERE deleted_at IS NULL`
+	case models.TrashPhones:
+		query = `SELECT id, CONCAT_WS(' ', number, name), 0 FROM ip_phones WHERE deleted_at IS NULL`
+	case models.TrashPrograms:
+		query = `SELECT id, IFNULL(name, ''), 0 FROM systems_program WHERE deleted_at IS NULL`
+	default:
+		return nil, fmt.Errorf("kind %q cannot be merged", kind)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query+" ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", kind, err)
+	}
+	defer rows.Close()
+
+	var names []models.MasterDataName
+	for rows.Next() {
+		var n models.MasterDataName
+		if err := rows.Scan(&n.ID, &n.Name, &n.Group); err != nil {
+			return nil, err
+		}
+		names = append(names, n)
+	}
+	return names, rows.Err()
+}
+
+func (r *mysqlMasterDataRepository) RecordMerge(ctx context.Context, entry models.MergeLogEntry) (int64, error) {
+	sourceIDs, err := json.Marshal(entry.SourceIDs)
+	if err != nil {
+		return 0, err
+	}
+	summary, err := json.Marshal(entry.Sources)
+	if err != nil {
+		return 0, err
+	}
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO master_data_merges (kind, survivor_id, source_ids, summary, merged_by)
+		VALUES (?, ?, ?, ?, ?)
+	`, entry.Kind, entry.SurvivorID, string(sourceIDs), string(summary), entry.MergedBy)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record merge: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+func (r *mysqlMasterDataRepository) ListMerges(ctx context.Context, kind string, q ListQuery) ([]models.MergeLogEntry, int, error) {
+	where := ""
+	var args []interface{}
+	if kind != "" {
+		where = " WHERE kind = ?"
+		args = append(args, kind)
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM master_data_merges"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count merges: %w", err)
+	}
+
+	query := "SELECT id, kind, survivor_id, source_ids, summary, merged_by, merged_at FROM master_data_merges" + where + " ORDER BY merged_at DESC, id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query merges: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.MergeLogEntry{}
+	for rows.Next() {
+		var e models.MergeLogEntry
+		var sourceIDs, summary string
+		var mergedAt sql.NullTime
+		if err := rows.Scan(&e.ID, &e.Kind, &e.SurvivorID, &sourceIDs, &summary, &e.MergedBy, &mergedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan merge: %w", err)
+		}
+		if err := json.Unmarshal([]byte(sourceIDs), &e.SourceIDs); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode merge %d: %w", e.ID, err)
+		}
+		if err := json.Unmarshal([]byte(summary), &e.Sources); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode merge %d: %w", e.ID, err)
+		}
+		e.MergedAt = formatTimeRFC3339(mergedAt)
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reports-api/models"
+)
+
+// PhoneRepository reads and writes IP phones
+type PhoneRepository interface {
+	// List returns phones with their department and branch; Search matches number, name, department or branch
+	List(ctx context.Context, q ListQuery) ([]models.IPPhone, int, error)
+	Get(ctx context.Context, id int) (*models.IPPhone, error)
+	Create(ctx context.Context, req models.IPPhoneRequest, actor int) (int64, error)
+	Update(ctx context.Context, id int, req models.IPPhoneRequest, actor int) error
+	// Delete moves the row to the trash; it returns ErrNotFound when it is missing or already trashed
+	Delete(ctx context.Context, id, actor int) error
+	// DepartmentID returns the department a phone belongs to
+	DepartmentID(ctx context.Context, id int) (int, error)
+	// Location returns the phone number with its department and branch names used in notifications
+	Location(ctx context.Context, id int) (number int, departmentName, branchName string, err error)
+}
+
+const phoneSelect = `
+	SELECT ip.id, ip.number, ip.name, IFNULL(ip.department_id, 0),
+		IFNULL(d.name, ''), IFNULL(d.branch_id, 0), IFNULL(b.name, ''),
+		ip.created_at, ip.updated_at, ip.deleted_at, ip.created_by, ip.updated_by, ip.deleted_by`
+
+type mysqlPhoneRepository struct {
+	db DBTX
+}
+
+func scanPhone(row interface{ Scan(...interface{}) error }) (models.IPPhone, error) {
+	var p models.IPPhone
+	err := row.Scan(
+		&p.ID, &p.Number, &p.Name, &p.DepartmentID,
+		&p.DepartmentName, &p.BranchID, &p.BranchName,
+		&p.CreatedAt, &p.UpdatedAt, &p.DeletedAt, &p.CreatedBy, &p.UpdatedBy, &p.DeletedBy,
+	)
+	return p, err
+}
+
+func (r *mysqlPhoneRepository) List(ctx context.Context, q ListQuery) ([]models.IPPhone, int, error) {
+	from := `
+	FROM ip_phones ip
+	LEFT JOIN departments d ON ip.department_id = d.id AND d.deleted_at IS NULL
+	LEFT JOIN branches b ON d.branch_id = b.id AND b.deleted_at IS NULL
+	WHERE ip.deleted_at IS NULL`
+	var args []interface{}
+	if q.Search != "" {
+		from += " AND (ip.number LIKE ? OR ip.name LIKE ? OR d.name LIKE ? OR b.name LIKE ?)"
+		pattern := likePattern(q.Search)
+		args = append(args, pattern, pattern, pattern, pattern)
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count ip_phones: %w", err)
+	}
+
+	query := phoneSelect + from + " ORDER BY ip.id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query ip_phones: %w", err)
+	}
+	defer rows.Close()
+
+	var phones []models.IPPhone
+	for rows.Next() {
+		p, err := scanPhone(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan ip_phone: %w", err)
+		}
+		phones = append(phones, p)
+	}
+	return phones, total, rows.Err()
+}
+
+func (r *mysqlPhoneRepository) Get(ctx context.Context, id int) (*models.IPPhone, error) {
+	p, err := scanPhone(r.db.QueryRowContext(ctx, phoneSelect+`
+		FROM ip_phones ip
+		LEFT JOIN departments d ON ip.department_id = d.id
+		LEFT JOIN branches b ON d.branch_id = b.id
+		WHERE ip.id = ? AND ip.deleted_at IS NULL
+	`, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &p, nil
+}
+
+func (r *mysqlPhoneRepository) Create(ctx context.Context, req models.IPPhoneRequest, actor int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO ip_phones (number, name, department_id, created_by) VALUES (?, ?, ?, ?)`, req.Number, req.Name, req.DepartmentID, actor)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (r *mysqlPhoneRepository) Update(ctx context.Context, id int, req models.IPPhoneRequest, actor int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE ip_phones SET number = ?, name = ?, department_id = ?, updated_by = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`, req.Number, req.Name, req.DepartmentID, actor, id)
+	return err
+}
+
+func (r *mysqlPhoneRepository) Delete(ctx context.Context, id, actor int) error {
+	return softDelete(ctx, r.db, "ip_phones", id, actor)
+}
+
+func (r *mysqlPhoneRepository) DepartmentID(ctx context.Context, id int) (int, error) {
+	var departmentID int
+	err := r.db.QueryRowContext(ctx, `SELECT IFNULL(department_id, 0) FROM ip_phones WHERE id = ?`, id).Scan(&departmentID)
+	return departmentID, notFound(err)
+}
+
+func (r *mysqlPhoneRepository) Location(ctx context.Context, id int) (number int, departmentName, branchName string, err error) {
+	err = r.db.QueryRowContext(ctx, `
+		SELECT IFNULL(p.number, 0), IFNULL(d.name, ''), IFNULL(b.name, '')
+		FROM ip_phones p
+		JOIN departments d ON p.department_id = d.id
+		JOIN branches b ON d.branch_id = b.id
+		WHERE p.id = ?
+	`, id).Scan(&number, &departmentName, &branchName)
+	return number, departmentName, branchName, notFound(err)
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reports-api/models"
+	"strings"
+)
+
+// ProblemRecordRepository stores problem records and the tasks attached to them (see models.ProblemRecord)
+type ProblemRecordRepository interface {
+	// List returns the live problem records matching q, newest first.
+	// Every read skips trashed problem records.
+	List(ctx context.Context, q models.ProblemRecordQuery) ([]models.ProblemRecord, error)
+	Get(ctx context.Context, id int) (*models.ProblemRecord, error)
+	Create(ctx context.Context, req models.ProblemRecordRequest, actor int) (int64, error)
+	// Update replaces every field of a problem record and stamps resolved_at when it moves to resolved or closed
+	Update(ctx context.Context, id int, req models.ProblemRecordRequest, actor int) error
+	// Delete moves a problem record to the trash; its tasks must be detached first (see common.DeleteProblemRecord)
+	Delete(ctx context.Context, id, actor int) error
+	// TaskIDs returns every task attached to a problem record, trashed tasks included
+	TaskIDs(ctx context.Context, id int) ([]int, error)
+	// Tasks returns the live tasks attached to a problem record, oldest attachment first
+	Tasks(ctx context.Context, id int) ([]models.ProblemRecordTask, error)
+	// AttachTask returns models.ErrTaskHasProblemRecord when the task already belongs to a problem record
+	AttachTask(ctx context.Context, id, taskID, actor int) error
+	DetachTask(ctx context.Context, id, taskID int) error
+	// ProblemOf returns the problem record a task belongs to, 0 when it has none
+	ProblemOf(ctx context.Context, taskID int) (int, error)
+	DeleteByTask(ctx context.Context, taskID int) error
+	// Workarounds returns the workarounds of the active problem records of a program
+	Workarounds(ctx context.Context, systemID int) ([]models.WorkaroundSuggestion, error)
+}
+
+const problemRecordSelect = `
+	SELECT pr.id, pr.title, pr.system_id, IFNULL(sp.name, ''), IFNULL(pr.description, ''), IFNULL(pr.root_cause, ''),
+	       IFNULL(pr.workaround, ''), pr.status, pr.created_by, pr.updated_by, pr.created_at, pr.updated_at, pr.resolved_at,
+	       (SELECT COUNT(*) FROM problem_record_tasks prt JOIN tasks t ON t.id = prt.task_id
+	        WHERE prt.problem_record_id = pr.id AND t.deleted_at IS NULL),
+	       (SELECT COUNT(*) FROM problem_record_tasks prt JOIN tasks t ON t.id = prt.task_id
+	        WHERE prt.problem_record_id = pr.id AND t.deleted_at IS NULL AND t.status NOT IN (?, ?))
+	FROM problem_records pr
+	LEFT JOIN systems_program sp ON sp.id = pr.system_id`
+
+type mysqlProblemRecordRepository struct {
+	db DBTX
+}
+
+func scanProblemRecord(row interface{ Scan(...interface{}) error }) (models.ProblemRecord, error) {
+	var p models.ProblemRecord
+	var systemID, createdBy, updatedBy sql.NullInt64
+	var createdAt, updatedAt, resolvedAt sql.NullTime
+	err := row.Scan(&p.ID, &p.Title, &systemID, &p.SystemName, &p.Description, &p.RootCause, &p.Workaround, &p.Status,
+		&createdBy, &updatedBy, &createdAt, &updatedAt, &resolvedAt, &p.TaskCount, &p.OpenTaskCount)
+	if err != nil {
+		return p, err
+	}
+	p.SystemID = nullableInt(systemID)
+	p.CreatedBy = nullableInt(createdBy)
+	p.UpdatedBy = nullableInt(updatedBy)
+	p.CreatedAt = formatTime(createdAt)
+	p.UpdatedAt = formatTime(updatedAt)
+	p.ResolvedAt = formatTime(resolvedAt)
+	return p, nil
+}
+
+func (r *mysqlProblemRecordRepository) List(ctx context.Context, q models.ProblemRecordQuery) ([]models.ProblemRecord, error) {
+	where := []string{"pr.deleted_at IS NULL"}
+	args := []interface{}{models.TaskStatusDone, models.TaskStatusCancelled}
+	if q.Status != "" {
+		where = append(where, "pr.status = ?")
+		args = append(args, q.Status)
+	}
+	if q.SystemID > 0 {
+		where = append(where, "pr.system_id = ?")
+		args = append(args, q.SystemID)
+	}
+	if q.Active {
+		where = append(where, "pr.status NOT IN (?, ?)")
+		args = append(args, models.ProblemRecordResolved, models.ProblemRecordClosed)
+	}
+	query := problemRecordSelect + " WHERE " + strings.Join(where, " AND ") + " ORDER BY pr.id DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query problem records: %w", err)
+	}
+	defer rows.Close()
+
+	records := []models.ProblemRecord{}
+	for rows.Next() {
+		p, err := scanProblemRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan problem record: %w", err)
+		}
+		records = append(records, p)
+	}
+	return records, rows.Err()
+}
+
+func (r *mysqlProblemRecordRepository) Get(ctx context.Context, id int) (*models.ProblemRecord, error) {
+	row := r.db.QueryRowContext(ctx, problemRecordSelect+" WHERE pr.id = ? AND pr.deleted_at IS NULL", models.TaskStatusDone, models.TaskStatusCancelled, id)
+	p, err := scanProblemRecord(row)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &p, nil
+}
+
+func (r *mysqlProblemRecordRepository) Create(ctx context.Context, req models.ProblemRecordRequest, actor int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO problem_records (title, system_id, description, root_cause, workaround, status, created_by, updated_by, resolved_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, IF(? IN (?, ?), CURRENT_TIMESTAMP, NULL))
+	`, req.Title, req.SystemID, req.Description, req.RootCause, req.Workaround, req.Status, nullIfZero(actor), nullIfZero(actor),
+		req.Status, models.ProblemRecordResolved, models.ProblemRecordClosed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create problem record: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+func (r *mysqlProblemRecordRepository) Update(ctx context.Context, id int, req models.ProblemRecordRequest, actor int) error {
+	// resolved_at keeps the first time the record left the active statuses and is cleared when it is reopened
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE problem_records
+		SET title = ?, system_id = ?, description = ?, root_cause = ?, workaround = ?, status = ?, updated_by = ?,
+		    resolved_at = IF(? IN (?, ?), IFNULL(resolved_at, CURRENT_TIMESTAMP), NULL)
+		WHERE id = ? AND deleted_at IS NULL
+	`, req.Title, req.SystemID, req.Description, req.RootCause, req.Workaround, req.Status, nullIfZero(actor),
+		req.Status, models.ProblemRecordResolved, models.ProblemRecordClosed, id)
+	if err != nil {
+		return fmt.Errorf("failed to update problem record: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		// rows affected is 0 for an unchanged row too
+		if _, err := r.Get(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *mysqlProblemRecordRepository) Delete(ctx context.Context, id, actor int) error {
+	return softDelete(ctx, r.db, "problem_records", id, actor)
+}
+
+func (r *mysqlProblemRecordRepository) TaskIDs(ctx context.Context, id int) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT task_id FROM problem_record_tasks WHERE problem_record_id = ? ORDER BY task_id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query problem record tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var taskID int
+		if err := rows.Scan(&taskID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, taskID)
+	}
+	return ids, rows.Err()
+}
+
+func (r *mysqlProblemRecordRepository) Tasks(ctx context.Context, id int) ([]models.ProblemRecordTask, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, IFNULL(t.ticket_no, ''), IFNULL(t.text, ''), t.status, prt.attached_by, prt.attached_at
+		FROM problem_record_tasks prt
+		JOIN tasks t ON t.id = prt.task_id
+		WHERE prt.problem_record_id = ? AND t.deleted_at IS NULL
+		ORDER BY prt.attached_at, t.id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query problem record tasks: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := []models.ProblemRecordTask{}
+	for rows.Next() {
+		var t models.ProblemRecordTask
+		var attachedBy sql.NullInt64
+		var attachedAt sql.NullTime
+		if err := rows.Scan(&t.TaskID, &t.Ticket, &t.Text, &t.Status, &attachedBy, &attachedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan problem record task: %w", err)
+		}
+		t.AttachedBy = nullableInt(attachedBy)
+		t.AttachedAt = formatTime(attachedAt)
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+func (r *mysqlProblemRecordRepository) AttachTask(ctx context.Context, id, taskID, actor int) error {
+	// rows affected is 0 when the task already belongs to a problem record, this one included
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO problem_record_tasks (problem_record_id, task_id, attached_by) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE task_id = task_id
+	`, id, taskID, nullIfZero(actor))
+	if err != nil {
+		return fmt.Errorf("failed to attach task to problem record: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return models.ErrTaskHasProblemRecord
+	}
+	return nil
+}
+
+func (r *mysqlProblemRecordRepository) DetachTask(ctx context.Context, id, taskID int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM problem_record_tasks WHERE problem_record_id = ? AND task_id = ?`, id, taskID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mysqlProblemRecordRepository) ProblemOf(ctx context.Context, taskID int) (int, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, `SELECT problem_record_id FROM problem_record_tasks WHERE task_id = ?`, taskID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+func (r *mysqlProblemRecordRepository) DeleteByTask(ctx context.Context, taskID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM problem_record_tasks WHERE task_id = ?`, taskID)
+	return err
+}
+
+func (r *mysqlProblemRecordRepository) Workarounds(ctx context.Context, systemID int) ([]models.WorkaroundSuggestion, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, title, status, workaround
+		FROM problem_records
+		WHERE system_id = ? AND deleted_at IS NULL AND status NOT IN (?, ?) AND TRIM(IFNULL(workaround, '')) <> ''
+		ORDER BY status = ? DESC, id DESC
+	`, systemID, models.ProblemRecordResolved, models.ProblemRecordClosed, models.ProblemRecordKnownError)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workarounds: %w", err)
+	}
+	defer rows.Close()
+
+	suggestions := []models.WorkaroundSuggestion{}
+	for rows.Next() {
+		var s models.WorkaroundSuggestion
+		if err := rows.Scan(&s.ProblemRecordID, &s.Title, &s.Status, &s.Workaround); err != nil {
+			return nil, fmt.Errorf("failed to scan workaround: %w", err)
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
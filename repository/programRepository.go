@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reports-api/models"
+)
+
+// ProgramRepository reads and writes programs (systems_program)
+type ProgramRepository interface {
+	List(ctx context.Context, q ListQuery) ([]models.Program, int, error)
+	Get(ctx context.Context, id int) (*models.Program, error)
+	Create(ctx context.Context, req models.ProgramRequest, actor int) (int64, error)
+	Update(ctx context.Context, id int, req models.ProgramRequest, actor int) error
+	// Delete moves the row to the trash; it returns ErrNotFound when it is missing or already trashed
+	Delete(ctx context.Context, id, actor int) error
+	// IssueType returns the issue type a program belongs to, 0 when it has none
+	IssueType(ctx context.Context, id int) (int, error)
+	Name(ctx context.Context, id int) (string, error)
+}
+
+// IssueTypeRepository reads and writes issue types (program types)
+type IssueTypeRepository interface {
+	List(ctx context.Context, search string) ([]models.Type, error)
+	Create(ctx context.Context, name *string) (int64, error)
+	Update(ctx context.Context, id int, name *string) error
+	Delete(ctx context.Context, id int) error
+}
+
+const programSelect = `
+	SELECT sp.id, sp.name, IFNULL(sp.priority, 2), IFNULL(sp.type, 0), IFNULL(it.name, ''), sp.created_at, sp.updated_at, sp.deleted_at, sp.created_by, sp.updated_by, sp.deleted_by
+	FROM systems_program sp
+	LEFT JOIN issue_types it ON sp.type = it.id`
+
+type mysqlProgramRepository struct {
+	db DBTX
+}
+
+func scanProgram(row interface{ Scan(...interface{}) error }) (models.Program, error) {
+	var p models.Program
+	err := row.Scan(&p.ID, &p.Name, &p.Priority, &p.TypeID, &p.TypeName, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt, &p.CreatedBy, &p.UpdatedBy, &p.DeletedBy)
+	return p, err
+}
+
+func (r *mysqlProgramRepository) List(ctx context.Context, q ListQuery) ([]models.Program, int, error) {
+	where := " WHERE sp.deleted_at IS NULL"
+	var args []interface{}
+	if q.Search != "" {
+		where += " AND sp.name LIKE ?"
+		args = append(args, likePattern(q.Search))
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM systems_program sp"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count programs: %w", err)
+	}
+
+	query := programSelect + where + " ORDER BY sp.id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query programs: %w", err)
+	}
+	defer rows.Close()
+
+	var programs []models.Program
+	for rows.Next() {
+		p, err := scanProgram(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan program: %w", err)
+		}
+		programs = append(programs, p)
+	}
+	return programs, total, rows.Err()
+}
+
+func (r *mysqlProgramRepository) Get(ctx context.Context, id int) (*models.Program, error) {
+	p, err := scanProgram(r.db.QueryRowContext(ctx, programSelect+" WHERE sp.id = ? AND sp.deleted_at IS NULL", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &p, nil
+}
+
+func (r *mysqlProgramRepository) Create(ctx context.Context, req models.ProgramRequest, actor int) (int64, error) {
+	priority := 2
+	if req.Priority != nil && *req.Priority != 0 {
+		priority = *req.Priority
+	}
+	res, err := r.db.ExecContext(ctx, `INSERT INTO systems_program (name, priority, type, created_by) VALUES (?, ?, ?, ?)`, req.Name, priority, req.TypeID, actor)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (r *mysqlProgramRepository) Update(ctx context.Context, id int, req models.ProgramRequest, actor int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE systems_program SET name = ?, priority = IFNULL(?, priority), type = ?, updated_by = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`, req.Name, req.Priority, req.TypeID, actor, id)
+	return err
+}
+
+func (r *mysqlProgramRepository) Delete(ctx context.Context, id, actor int) error {
+	return softDelete(ctx, r.db, "systems_program", id, actor)
+}
+
+func (r *mysqlProgramRepository) IssueType(ctx context.Context, id int) (int, error) {
+	var typeID int
+	err := r.db.QueryRowContext(ctx, `SELECT IFNULL(type, 0) FROM systems_program WHERE id = ?`, id).Scan(&typeID)
+	return typeID, notFound(err)
+}
+
+func (r *mysqlProgramRepository) Name(ctx context.Context, id int) (string, error) {
+	var name string
+	err := r.db.QueryRowContext(ctx, `SELECT IFNULL(name, '') FROM systems_program WHERE id = ?`, id).Scan(&name)
+	return name, notFound(err)
+}
+
+type mysqlIssueTypeRepository struct {
+	db DBTX
+}
+
+func (r *mysqlIssueTypeRepository) List(ctx context.Context, search string) ([]models.Type, error) {
+	query := `SELECT id, name FROM issue_types`
+	var args []interface{}
+	if search != "" {
+		query += ` WHERE name LIKE ?`
+		args = append(args, likePattern(search))
+	}
+	rows, err := r.db.QueryContext(ctx, query+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query issue types: %w", err)
+	}
+	defer rows.Close()
+
+	var types []models.Type
+	for rows.Next() {
+		var t models.Type
+		if err := rows.Scan(&t.ID, &t.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan issue type: %w", err)
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
+
+func (r *mysqlIssueTypeRepository) Create(ctx context.Context, name *string) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO issue_types (name) VALUES (?)`, name)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (r *mysqlIssueTypeRepository) Update(ctx context.Context, id int, name *string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE issue_types SET name = ? WHERE id = ?`, name, id)
+	return err
+}
+
+func (r *mysqlIssueTypeRepository) Delete(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM issue_types WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reports-api/models"
+)
+
+// ProgressRepository reads and writes the progress entries of a task; reads skip trashed entries
+type ProgressRepository interface {
+	ListByTask(ctx context.Context, taskID int) ([]models.ProgressRecord, error)
+	// Get returns a progress entry of the given task
+	Get(ctx context.Context, id, taskID int) (*models.ProgressRecord, error)
+	Create(ctx context.Context, taskID int, text string, filePaths *string) (int64, error)
+	// UpdateText and Update return models.ErrVersionConflict when version is set and the entry has another version
+	UpdateText(ctx context.Context, id, version int, text string) error
+	// Update replaces the text and the attached files; nil filePaths stores NULL
+	Update(ctx context.Context, id, version int, text string, filePaths *string) error
+	// Delete moves a progress entry of the given task to the trash; it returns ErrNotFound when there is none
+	Delete(ctx context.Context, id, taskID, actor int) error
+	// DeleteByTask permanently deletes every entry of the task, trashed or not
+	DeleteByTask(ctx context.Context, taskID int) error
+}
+
+const progressSelect = `SELECT id, task_id, IFNULL(progress_text, ''), file_paths, created_at, updated_at, version FROM progress`
+
+type mysqlProgressRepository struct {
+	db DBTX
+}
+
+func scanProgress(row interface{ Scan(...interface{}) error }) (models.ProgressRecord, error) {
+	var p models.ProgressRecord
+	var filePaths sql.NullString
+	var createdAt, updatedAt sql.NullTime
+	if err := row.Scan(&p.ID, &p.TaskID, &p.Text, &filePaths, &createdAt, &updatedAt, &p.Version); err != nil {
+		return p, err
+	}
+	p.FilePaths = nullableString(filePaths)
+	p.CreatedAt = formatTime(createdAt)
+	p.UpdatedAt = formatTime(updatedAt)
+	return p, nil
+}
+
+func (r *mysqlProgressRepository) ListByTask(ctx context.Context, taskID int) ([]models.ProgressRecord, error) {
+	rows, err := r.db.QueryContext(ctx, progressSelect+` WHERE task_id = ? AND deleted_at IS NULL ORDER BY id`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query progress entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.ProgressRecord
+	for rows.Next() {
+		p, err := scanProgress(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan progress entry: %w", err)
+		}
+		entries = append(entries, p)
+	}
+	return entries, rows.Err()
+}
+
+func (r *mysqlProgressRepository) Get(ctx context.Context, id, taskID int) (*models.ProgressRecord, error) {
+	p, err := scanProgress(r.db.QueryRowContext(ctx, progressSelect+` WHERE id = ? AND task_id = ? AND deleted_at IS NULL`, id, taskID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &p, nil
+}
+
+func (r *mysqlProgressRepository) Create(ctx context.Context, taskID int, text string, filePaths *string) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO progress (task_id, progress_text, file_paths) VALUES (?, ?, ?)`, taskID, text, filePaths)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, indexDocument(ctx, r.db, models.SearchSourceProgress, id)
+}
+
+func (r *mysqlProgressRepository) UpdateText(ctx context.Context, id, version int, text string) error {
+	return r.update(ctx, id, version, "progress_text = ?", text)
+}
+
+func (r *mysqlProgressRepository) Update(ctx context.Context, id, version int, text string, filePaths *string) error {
+	return r.update(ctx, id, version, "progress_text = ?, file_paths = ?", text, filePaths)
+}
+
+func (r *mysqlProgressRepository) update(ctx context.Context, id, version int, set string, args ...interface{}) error {
+	query := `UPDATE progress SET ` + set + `, version = version + 1 WHERE id = ?`
+	args = append(args, id)
+	if version > 0 {
+		query += ` AND version = ?`
+		args = append(args, version)
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if err := versionConflict(res, version); err != nil {
+		return err
+	}
+	return indexDocument(ctx, r.db, models.SearchSourceProgress, int64(id))
+}
+
+func (r *mysqlProgressRepository) Delete(ctx context.Context, id, taskID, actor int) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE progress SET deleted_at = CURRENT_TIMESTAMP, deleted_by = ? WHERE id = ? AND task_id = ? AND deleted_at IS NULL`, nullIfZero(actor), id, taskID)
+	if err != nil {
+		return err
+	}
+	// ไม่มีแถวถูกลบ (id ของ task อื่น) ต้องไม่ลบเอกสารของรายการนั้นออกจากดัชนี
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return unindexDocument(ctx, r.db, models.SearchSourceProgress, int64(id))
+}
+
+func (r *mysqlProgressRepository) DeleteByTask(ctx context.Context, taskID int) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM progress WHERE task_id = ?`, taskID); err != nil {
+		return err
+	}
+	return unindexTask(ctx, r.db, models.SearchSourceProgress, taskID)
+}
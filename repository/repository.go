@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reports-api/models"
+	"time"
+)
+
+// ErrNotFound is returned when the requested row does not exist
+var ErrNotFound = errors.New("record not found")
+
+// DBTX is the subset of *sql.DB and *sql.Tx the repositories need
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// ListQuery pages and filters a master-data list; Limit 0 returns every row
+type ListQuery struct {
+	Search string
+	Limit  int
+	Offset int
+}
+
+// TxBeginner starts transactions; *sql.DB implements it
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Repositories groups every repository the handlers depend on.
+// Each field is an interface so handlers can be tested against fakes.
+type Repositories struct {
+	db DBTX
+
+	Tasks            TaskRepository
+	Resolutions      ResolutionRepository
+	Progress         ProgressRepository
+	TelegramChats    TelegramChatRepository
+	Branches         BranchRepository
+	Departments      DepartmentRepository
+	Phones           PhoneRepository
+	Programs         ProgramRepository
+	IssueTypes       IssueTypeRepository
+	Responsibilities ResponsibilityRepository
+	Scores           ScoreRepository
+	Tickets          TicketSequenceRepository
+	StatusHistory    TaskStatusHistoryRepository
+	SLA              SLARepository
+	Calendar         CalendarRepository
+	Search           SearchRepository
+	Trash            TrashRepository
+	MasterData       MasterDataRepository
+	Changes          TaskChangeRepository
+	Idempotency      IdempotencyRepository
+	Incidents        TaskIncidentRepository
+	Links            TaskLinkRepository
+	ProblemRecords   ProblemRecordRepository
+}
+
+// New creates the MySQL implementation of every repository on top of db
+func New(db DBTX) *Repositories {
+	return &Repositories{
+		db:               db,
+		Tasks:            &mysqlTaskRepository{db: db},
+		Resolutions:      &mysqlResolutionRepository{db: db},
+		Progress:         &mysqlProgressRepository{db: db},
+		TelegramChats:    &mysqlTelegramChatRepository{db: db},
+		Branches:         &mysqlBranchRepository{db: db},
+		Departments:      &mysqlDepartmentRepository{db: db},
+		Phones:           &mysqlPhoneRepository{db: db},
+		Programs:         &mysqlProgramRepository{db: db},
+		IssueTypes:       &mysqlIssueTypeRepository{db: db},
+		Responsibilities: &mysqlResponsibilityRepository{db: db},
+		Scores:           &mysqlScoreRepository{db: db},
+		Tickets:          &mysqlTicketSequenceRepository{db: db},
+		StatusHistory:    &mysqlTaskStatusHistoryRepository{db: db},
+		SLA:              &mysqlSLARepository{db: db},
+		Calendar:         &mysqlCalendarRepository{db: db},
+		Search:           &mysqlSearchRepository{db: db},
+		Trash:            &mysqlTrashRepository{db: db},
+		MasterData:       &mysqlMasterDataRepository{db: db},
+		Changes:          &mysqlTaskChangeRepository{db: db},
+		Idempotency:      &mysqlIdempotencyRepository{db: db},
+		Incidents:        &mysqlTaskIncidentRepository{db: db},
+		Links:            &mysqlTaskLinkRepository{db: db},
+		ProblemRecords:   &mysqlProblemRecordRepository{db: db},
+	}
+}
+
+// InTx runs fn with repositories bound to a single transaction and commits when fn returns nil.
+// Any error from fn rolls the transaction back. Calls made inside a transaction, or on
+// repositories not backed by a TxBeginner (fakes), run fn directly on the same repositories.
+// Side effects that cannot be rolled back (Telegram, MinIO) belong after InTx returns.
+func (r *Repositories) InTx(ctx context.Context, fn func(tx *Repositories) error) error {
+	beginner, ok := r.db.(TxBeginner)
+	if !ok {
+		return fn(r)
+	}
+
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(New(tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// softDelete stamps deleted_at and deleted_by on a live row of table; it returns ErrNotFound when there is none
+func softDelete(ctx context.Context, db DBTX, table string, id, actor int) error {
+	res, err := db.ExecContext(ctx, `UPDATE `+table+` SET deleted_at = CURRENT_TIMESTAMP, deleted_by = ? WHERE id = ? AND deleted_at IS NULL`, nullIfZero(actor), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// versionConflict returns models.ErrVersionConflict when an update guarded by version matched no row.
+// Every guarded update also increments version, so a matched row always counts as affected.
+func versionConflict(res sql.Result, version int) error {
+	if version == 0 {
+		return nil
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return models.ErrVersionConflict
+	}
+	return nil
+}
+
+// notFound maps sql.ErrNoRows to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// likePattern wraps a search term for a LIKE comparison
+func likePattern(search string) string {
+	return "%" + search + "%"
+}
+
+// dbTimeLayout is the layout handlers expect for timestamps read from the database (see common.Fixtimefeature)
+const dbTimeLayout = "2006-01-02 15:04:05"
+
+// formatTime renders a nullable timestamp as dbTimeLayout, or "" when NULL
+func formatTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(dbTimeLayout)
+}
+
+// formatTimeRFC3339 renders a nullable timestamp the way database/sql converts time.Time to string
+func formatTimeRFC3339(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(time.RFC3339Nano)
+}
+
+// nullableString returns nil for NULL so that optional JSON columns stay optional
+func nullableString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+// nullableInt returns nil for NULL so that optional references stay optional in JSON
+func nullableInt(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
+
+// nullIfZero stores 0 as NULL for optional references
+func nullIfZero(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
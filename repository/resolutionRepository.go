@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"reports-api/models"
+)
+
+// ResolutionRepository reads and writes the resolution of a task; Get skips trashed resolutions
+type ResolutionRepository interface {
+	Get(ctx context.Context, id int) (*models.Resolution, error)
+	Create(ctx context.Context, taskID int, text string, telegramID int, filePaths *string) (int64, error)
+	// UpdateText and Update return models.ErrVersionConflict when version is set and the resolution has another version
+	UpdateText(ctx context.Context, id, version int, text string) error
+	// Update replaces the text and the attached files; nil filePaths stores NULL
+	Update(ctx context.Context, id, version int, text string, filePaths *string) error
+	// Delete moves the resolution to the trash; it returns ErrNotFound when it is missing or already trashed
+	Delete(ctx context.Context, id, actor int) error
+	// DeleteByTask permanently deletes every resolution of the task, trashed or not
+	DeleteByTask(ctx context.Context, taskID int) error
+}
+
+type mysqlResolutionRepository struct {
+	db DBTX
+}
+
+func (r *mysqlResolutionRepository) Get(ctx context.Context, id int) (*models.Resolution, error) {
+	var res models.Resolution
+	var filePaths sql.NullString
+	var resolvedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, tasks_id, IFNULL(text, ''), IFNULL(telegram_id, 0), file_paths, resolved_at, version
+		FROM resolutions WHERE id = ? AND deleted_at IS NULL
+	`, id).Scan(&res.ID, &res.TaskID, &res.Text, &res.TelegramID, &filePaths, &resolvedAt, &res.Version)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	res.FilePaths = nullableString(filePaths)
+	res.ResolvedAt = formatTime(resolvedAt)
+	return &res, nil
+}
+
+func (r *mysqlResolutionRepository) Create(ctx context.Context, taskID int, text string, telegramID int, filePaths *string) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO resolutions (tasks_id, text, telegram_id, file_paths) VALUES (?, ?, ?, ?)`, taskID, text, telegramID, filePaths)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, indexDocument(ctx, r.db, models.SearchSourceResolution, id)
+}
+
+func (r *mysqlResolutionRepository) UpdateText(ctx context.Context, id, version int, text string) error {
+	return r.update(ctx, id, version, "text = ?", text)
+}
+
+func (r *mysqlResolutionRepository) Update(ctx context.Context, id, version int, text string, filePaths *string) error {
+	return r.update(ctx, id, version, "text = ?, file_paths = ?", text, filePaths)
+}
+
+func (r *mysqlResolutionRepository) update(ctx context.Context, id, version int, set string, args ...interface{}) error {
+	query := `UPDATE resolutions SET ` + set + `, version = version + 1 WHERE id = ?`
+	args = append(args, id)
+	if version > 0 {
+		query += ` AND version = ?`
+		args = append(args, version)
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if err := versionConflict(res, version); err != nil {
+		return err
+	}
+	return indexDocument(ctx, r.db, models.SearchSourceResolution, int64(id))
+}
+
+func (r *mysqlResolutionRepository) Delete(ctx context.Context, id, actor int) error {
+	if err := softDelete(ctx, r.db, "resolutions", id, actor); err != nil {
+		return err
+	}
+	return unindexDocument(ctx, r.db, models.SearchSourceResolution, int64(id))
+}
+
+func (r *mysqlResolutionRepository) DeleteByTask(ctx context.Context, taskID int) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM resolutions WHERE tasks_id = ?`, taskID); err != nil {
+		return err
+	}
+	return unindexTask(ctx, r.db, models.SearchSourceResolution, taskID)
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+	"reports-api/models"
+)
+
+// ResponsibilityRepository reads the people tasks can be assigned to
+type ResponsibilityRepository interface {
+	Get(ctx context.Context, id int) (*models.ResponseRequest, error)
+}
+
+type mysqlResponsibilityRepository struct {
+	db DBTX
+}
+
+func (r *mysqlResponsibilityRepository) Get(ctx context.Context, id int) (*models.ResponseRequest, error) {
+	var resp models.ResponseRequest
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, IFNULL(telegram_username, ''), IFNULL(name, '')
+		FROM responsibilities WHERE id = ?
+	`, id).Scan(&resp.ID, &resp.TelegramUsername, &resp.Name)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,32 @@
+package repository
+
+import "context"
+
+// ScoreRepository maintains the monthly score of each department
+type ScoreRepository interface {
+	// EnsureMonth creates the score row for the month with the initial score if it does not exist yet
+	EnsureMonth(ctx context.Context, departmentID, year, month, initial int) error
+	// Deduct lowers the score of the month by points without going below zero
+	Deduct(ctx context.Context, departmentID, year, month, points int) error
+}
+
+type mysqlScoreRepository struct {
+	db DBTX
+}
+
+func (r *mysqlScoreRepository) EnsureMonth(ctx context.Context, departmentID, year, month, initial int) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT IGNORE INTO scores (department_id, year, month, score)
+		VALUES (?, ?, ?, ?)
+	`, departmentID, year, month, initial)
+	return err
+}
+
+func (r *mysqlScoreRepository) Deduct(ctx context.Context, departmentID, year, month, points int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE scores
+		SET score = GREATEST(score - ?, 0)
+		WHERE department_id = ? AND year = ? AND month = ?
+	`, points, departmentID, year, month)
+	return err
+}
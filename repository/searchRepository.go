@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reports-api/models"
+	"strings"
+)
+
+// SearchRepository queries and maintains the full-text index in search_documents.
+// Task, progress and resolution writes update the index themselves; Reindex rebuilds it from scratch.
+type SearchRepository interface {
+	// Search returns one page of documents matching q ranked by relevance, and the number of matches
+	Search(ctx context.Context, q models.SearchQuery) ([]models.SearchHit, int, error)
+	// Reindex rebuilds every document and returns the number indexed
+	Reindex(ctx context.Context) (int, error)
+}
+
+type mysqlSearchRepository struct {
+	db DBTX
+}
+
+// searchSources maps each source to the query producing its documents (source_id, task_id, body).
+// Trashed records have no document.
+var searchSources = map[string]string{
+	models.SearchSourceTask:       `SELECT id AS source_id, id AS task_id, CONCAT_WS('\n', NULLIF(text, ''), NULLIF(issue_else, '')) AS body FROM tasks WHERE deleted_at IS NULL`,
+	models.SearchSourceProgress:   `SELECT id AS source_id, task_id, IFNULL(progress_text, '') AS body FROM progress WHERE deleted_at IS NULL`,
+	models.SearchSourceResolution: `SELECT id AS source_id, tasks_id AS task_id, IFNULL(text, '') AS body FROM resolutions WHERE deleted_at IS NULL`,
+}
+
+// indexDocument stores the current text of one record, or removes its document when the text is empty or the record is trashed
+func indexDocument(ctx context.Context, db DBTX, source string, id int64) error {
+	var sourceID, taskID int
+	var body sql.NullString
+	err := db.QueryRowContext(ctx, searchSources[source]+` AND id = ?`, id).Scan(&sourceID, &taskID, &body)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && strings.TrimSpace(body.String) == "") {
+		return unindexDocument(ctx, db, source, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s %d for indexing: %w", source, id, err)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO search_documents (source, source_id, task_id, body) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE task_id = VALUES(task_id), body = VALUES(body)
+	`, source, sourceID, taskID, body.String)
+	if err != nil {
+		return fmt.Errorf("failed to index %s %d: %w", source, id, err)
+	}
+	return nil
+}
+
+// unindexDocument removes the document of one record
+func unindexDocument(ctx context.Context, db DBTX, source string, id int64) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM search_documents WHERE source = ? AND source_id = ?`, source, id); err != nil {
+		return fmt.Errorf("failed to remove %s %d from the search index: %w", source, id, err)
+	}
+	return nil
+}
+
+// unindexTask removes the documents of a task; an empty source removes its progress and resolutions too
+func unindexTask(ctx context.Context, db DBTX, source string, taskID int) error {
+	query, args := `DELETE FROM search_documents WHERE task_id = ?`, []interface{}{taskID}
+	if source != "" {
+		query, args = query+` AND source = ?`, append(args, source)
+	}
+	if _, err := db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to remove task %d from the search index: %w", taskID, err)
+	}
+	return nil
+}
+
+func (r *mysqlSearchRepository) Search(ctx context.Context, q models.SearchQuery) ([]models.SearchHit, int, error) {
+	where := `MATCH(sd.body) AGAINST (? IN NATURAL LANGUAGE MODE)`
+	args := []interface{}{q.Text}
+	if len(q.Sources) > 0 {
+		where += ` AND sd.source IN (?` + strings.Repeat(`, ?`, len(q.Sources)-1) + `)`
+		for _, s := range q.Sources {
+			args = append(args, s)
+		}
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM search_documents sd JOIN tasks t ON t.id = sd.task_id AND t.deleted_at IS NULL WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT sd.task_id, IFNULL(t.ticket_no, ''), IFNULL(t.status, 0), sd.source, sd.source_id, sd.body, sd.updated_at,
+			MATCH(sd.body) AGAINST (? IN NATURAL LANGUAGE MODE) AS score
+		FROM search_documents sd
+		JOIN tasks t ON t.id = sd.task_id AND t.deleted_at IS NULL
+		WHERE `+where+`
+		ORDER BY score DESC, sd.updated_at DESC, sd.id DESC
+		LIMIT ? OFFSET ?
+	`, append(append([]interface{}{q.Text}, args...), q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+
+	hits := []models.SearchHit{}
+	for rows.Next() {
+		var h models.SearchHit
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&h.TaskID, &h.Ticket, &h.Status, &h.Source, &h.SourceID, &h.Body, &updatedAt, &h.Score); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		h.UpdatedAt = formatTimeRFC3339(updatedAt)
+		hits = append(hits, h)
+	}
+	return hits, total, rows.Err()
+}
+
+func (r *mysqlSearchRepository) Reindex(ctx context.Context) (int, error) {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM search_documents`); err != nil {
+		return 0, fmt.Errorf("failed to clear the search index: %w", err)
+	}
+
+	total := 0
+	for _, source := range []string{models.SearchSourceTask, models.SearchSourceProgress, models.SearchSourceResolution} {
+		res, err := r.db.ExecContext(ctx, `
+			INSERT INTO search_documents (source, source_id, task_id, body)
+			SELECT ?, d.source_id, d.task_id, d.body
+			FROM (`+searchSources[source]+`) AS d
+			WHERE d.task_id IS NOT NULL AND TRIM(IFNULL(d.body, '')) <> ''
+		`, source)
+		if err != nil {
+			return total, fmt.Errorf("failed to index %s records: %w", source, err)
+		}
+		n, _ := res.RowsAffected()
+		total += int(n)
+	}
+	return total, nil
+}
@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reports-api/models"
+	"time"
+)
+
+// SLARepository reads and writes SLA policies, task due dates and SLA events
+type SLARepository interface {
+	ListPolicies(ctx context.Context) ([]models.SLAPolicy, error)
+	GetPolicy(ctx context.Context, id int) (*models.SLAPolicy, error)
+	CreatePolicy(ctx context.Context, req models.SLAPolicyRequest, actor int) (int64, error)
+	UpdatePolicy(ctx context.Context, id int, req models.SLAPolicyRequest, actor int) error
+	DeletePolicy(ctx context.Context, id int) error
+	// MatchPolicy returns the most specific policy for the priority, branch and issue type
+	MatchPolicy(ctx context.Context, priority, branchID, issueTypeID int) (*models.SLAPolicy, error)
+
+	// Target returns the values a task's policy is matched on; defaultPriority is used without a program
+	Target(ctx context.Context, taskID, defaultPriority int) (*models.SLATarget, error)
+	// SetTaskDue stores the policy and due dates of a task; nil clears them
+	SetTaskDue(ctx context.Context, taskID int, policyID *int, responseDue, resolutionDue *time.Time) error
+	// MarkFirstResponse stamps first_response_at unless it is already set
+	MarkFirstResponse(ctx context.Context, taskID int) error
+
+	// DueTasks returns open tasks with a target due before the given time that has not been breached yet
+	DueTasks(ctx context.Context, before time.Time) ([]models.SLADueTask, error)
+	// RecordEvent stores an event once per task and kind; it reports false when it was already raised
+	RecordEvent(ctx context.Context, taskID int, kind string, dueAt time.Time) (bool, error)
+	ListEvents(ctx context.Context, q ListQuery) ([]models.SLAEvent, int, error)
+	DeleteTaskEvents(ctx context.Context, taskID int) error
+}
+
+const slaPolicySelect = `
+	SELECT id, priority, branch_id, issue_type_id, response_minutes, resolution_minutes, created_at, updated_at, created_by, updated_by
+	FROM sla_policies`
+
+type mysqlSLARepository struct {
+	db DBTX
+}
+
+func scanSLAPolicy(row interface{ Scan(...interface{}) error }) (models.SLAPolicy, error) {
+	var p models.SLAPolicy
+	err := row.Scan(&p.ID, &p.Priority, &p.BranchID, &p.IssueTypeID, &p.ResponseMinutes, &p.ResolutionMinutes,
+		&p.CreatedAt, &p.UpdatedAt, &p.CreatedBy, &p.UpdatedBy)
+	return p, err
+}
+
+func (r *mysqlSLARepository) ListPolicies(ctx context.Context) ([]models.SLAPolicy, error) {
+	rows, err := r.db.QueryContext(ctx, slaPolicySelect+` ORDER BY priority, branch_id, issue_type_id, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SLA policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := []models.SLAPolicy{}
+	for rows.Next() {
+		p, err := scanSLAPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan SLA policy: %w", err)
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+func (r *mysqlSLARepository) GetPolicy(ctx context.Context, id int) (*models.SLAPolicy, error) {
+	p, err := scanSLAPolicy(r.db.QueryRowContext(ctx, slaPolicySelect+` WHERE id = ?`, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &p, nil
+}
+
+func (r *mysqlSLARepository) CreatePolicy(ctx context.Context, req models.SLAPolicyRequest, actor int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO sla_policies (priority, branch_id, issue_type_id, response_minutes, resolution_minutes, created_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, req.Priority, req.BranchID, req.IssueTypeID, req.ResponseMinutes, req.ResolutionMinutes, nullIfZero(actor))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert SLA policy: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+func (r *mysqlSLARepository) UpdatePolicy(ctx context.Context, id int, req models.SLAPolicyRequest, actor int) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE sla_policies
+		SET priority = ?, branch_id = ?, issue_type_id = ?, response_minutes = ?, resolution_minutes = ?, updated_by = ?
+		WHERE id = ?
+	`, req.Priority, req.BranchID, req.IssueTypeID, req.ResponseMinutes, req.ResolutionMinutes, nullIfZero(actor), id)
+	if err != nil {
+		return fmt.Errorf("failed to update SLA policy: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if _, err := r.GetPolicy(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *mysqlSLARepository) DeletePolicy(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM sla_policies WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mysqlSLARepository) MatchPolicy(ctx context.Context, priority, branchID, issueTypeID int) (*models.SLAPolicy, error) {
+	p, err := scanSLAPolicy(r.db.QueryRowContext(ctx, slaPolicySelect+`
+		WHERE priority = ? AND (branch_id IS NULL OR branch_id = ?) AND (issue_type_id IS NULL OR issue_type_id = ?)
+		ORDER BY (branch_id IS NOT NULL) * 2 + (issue_type_id IS NOT NULL) DESC, id
+		LIMIT 1
+	`, priority, branchID, issueTypeID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &p, nil
+}
+
+func (r *mysqlSLARepository) Target(ctx context.Context, taskID, defaultPriority int) (*models.SLATarget, error) {
+	t := models.SLATarget{TaskID: taskID}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT IFNULL(s.priority, ?), IFNULL(d.branch_id, 0), IFNULL(t.issue_type, 0), t.created_at
+		FROM tasks t
+		LEFT JOIN systems_program s ON t.system_id = s.id AND t.system_id != 0
+		LEFT JOIN departments d ON t.department_id = d.id
+		WHERE t.id = ?
+	`, defaultPriority, taskID).Scan(&t.Priority, &t.BranchID, &t.IssueTypeID, &t.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &t, nil
+}
+
+func (r *mysqlSLARepository) SetTaskDue(ctx context.Context, taskID int, policyID *int, responseDue, resolutionDue *time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE tasks SET sla_policy_id = ?, response_due_at = ?, resolution_due_at = ? WHERE id = ?`,
+		policyID, responseDue, resolutionDue, taskID)
+	return err
+}
+
+func (r *mysqlSLARepository) MarkFirstResponse(ctx context.Context, taskID int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE tasks SET first_response_at = CURRENT_TIMESTAMP WHERE id = ? AND first_response_at IS NULL`, taskID)
+	return err
+}
+
+func (r *mysqlSLARepository) DueTasks(ctx context.Context, before time.Time) ([]models.SLADueTask, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, IFNULL(t.ticket_no, ''), IFNULL(tc.report_id, 0), t.response_due_at, t.resolution_due_at, t.first_response_at
+		FROM tasks t
+		LEFT JOIN telegram_chat tc ON t.telegram_id = tc.id
+		WHERE t.deleted_at IS NULL AND t.status NOT IN (?, ?)
+			AND ((t.first_response_at IS NULL AND t.response_due_at <= ?
+					AND NOT EXISTS (SELECT 1 FROM sla_events e WHERE e.task_id = t.id AND e.kind = ?))
+				OR (t.resolution_due_at <= ?
+					AND NOT EXISTS (SELECT 1 FROM sla_events e WHERE e.task_id = t.id AND e.kind = ?)))
+		ORDER BY t.resolution_due_at
+	`, int(models.TaskStatusDone), int(models.TaskStatusCancelled),
+		before, models.SLAEventResponseBreached, before, models.SLAEventResolutionBreached)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SLA due tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []models.SLADueTask
+	for rows.Next() {
+		var t models.SLADueTask
+		var responseDue, resolutionDue, firstResponse sql.NullTime
+		if err := rows.Scan(&t.TaskID, &t.Ticket, &t.ReportMessageID, &responseDue, &resolutionDue, &firstResponse); err != nil {
+			return nil, fmt.Errorf("failed to scan SLA due task: %w", err)
+		}
+		t.ResponseDueAt = nullableTime(responseDue)
+		t.ResolutionDueAt = nullableTime(resolutionDue)
+		t.FirstResponseAt = nullableTime(firstResponse)
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+func (r *mysqlSLARepository) RecordEvent(ctx context.Context, taskID int, kind string, dueAt time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT IGNORE INTO sla_events (task_id, kind, due_at) VALUES (?, ?, ?)`, taskID, kind, dueAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to record SLA event: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *mysqlSLARepository) ListEvents(ctx context.Context, q ListQuery) ([]models.SLAEvent, int, error) {
+	where := ""
+	var args []interface{}
+	if q.Search != "" {
+		where = " WHERE e.kind = ? OR t.ticket_no LIKE ?"
+		args = append(args, q.Search, likePattern(q.Search))
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sla_events e LEFT JOIN tasks t ON e.task_id = t.id"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count SLA events: %w", err)
+	}
+
+	query := `
+		SELECT e.id, e.task_id, IFNULL(t.ticket_no, ''), e.kind, e.due_at, e.raised_at
+		FROM sla_events e
+		LEFT JOIN tasks t ON e.task_id = t.id` + where + ` ORDER BY e.raised_at DESC, e.id DESC`
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query SLA events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.SLAEvent{}
+	for rows.Next() {
+		var e models.SLAEvent
+		var dueAt, raisedAt sql.NullTime
+		if err := rows.Scan(&e.ID, &e.TaskID, &e.Ticket, &e.Kind, &dueAt, &raisedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan SLA event: %w", err)
+		}
+		e.DueAt = formatTimeRFC3339(dueAt)
+		e.RaisedAt = formatTimeRFC3339(raisedAt)
+		events = append(events, e)
+	}
+	return events, total, rows.Err()
+}
+
+func (r *mysqlSLARepository) DeleteTaskEvents(ctx context.Context, taskID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sla_events WHERE task_id = ?`, taskID)
+	return err
+}
+
+// nullableTime returns nil for NULL timestamps
+func nullableTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// taskSLA derives the time remaining and breach flags of a task from its due dates.
+// Closed tasks without a resolution time (cancelled) report neither remaining time nor a resolution breach.
+func taskSLA(policyID sql.NullInt64, responseDue, resolutionDue, firstResponse, resolvedAt sql.NullTime, status models.TaskStatus, now time.Time) *models.TaskSLA {
+	if !responseDue.Valid && !resolutionDue.Valid {
+		return nil
+	}
+	sla := &models.TaskSLA{
+		ResponseDueAt:   formatTimeRFC3339(responseDue),
+		ResolutionDueAt: formatTimeRFC3339(resolutionDue),
+		FirstResponseAt: formatTimeRFC3339(firstResponse),
+	}
+	if policyID.Valid {
+		id := int(policyID.Int64)
+		sla.PolicyID = &id
+	}
+
+	if responseDue.Valid {
+		if firstResponse.Valid {
+			sla.ResponseBreached = firstResponse.Time.After(responseDue.Time)
+		} else if !status.IsClosed() {
+			remaining := int64(responseDue.Time.Sub(now).Seconds())
+			sla.ResponseRemainingSeconds = &remaining
+			sla.ResponseBreached = remaining < 0
+		}
+	}
+	if resolutionDue.Valid {
+		if resolvedAt.Valid {
+			sla.ResolutionBreached = resolvedAt.Time.After(resolutionDue.Time)
+		} else if !status.IsClosed() {
+			remaining := int64(resolutionDue.Time.Sub(now).Seconds())
+			sla.ResolutionRemainingSeconds = &remaining
+			sla.ResolutionBreached = remaining < 0
+		}
+	}
+	return sla
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reports-api/models"
+)
+
+// TaskChangeRepository records the field-level changes of tasks, progress entries and resolutions
+type TaskChangeRepository interface {
+	// Record appends a change; a nil Fields is stored as an empty list
+	Record(ctx context.Context, c models.TaskChange) error
+	// ListByTask returns the changes of a task and its progress entries and resolutions, oldest first
+	ListByTask(ctx context.Context, taskID int) ([]models.TaskChange, error)
+	DeleteByTask(ctx context.Context, taskID int) error
+}
+
+type mysqlTaskChangeRepository struct {
+	db DBTX
+}
+
+func (r *mysqlTaskChangeRepository) Record(ctx context.Context, c models.TaskChange) error {
+	fields := c.Fields
+	if fields == nil {
+		fields = []models.FieldChange{}
+	}
+	fieldsJSON, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	var changedBy interface{}
+	if c.ChangedBy != nil {
+		changedBy = nullIfZero(*c.ChangedBy)
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO task_changes (task_id, entity, entity_id, action, fields, changed_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, c.TaskID, c.Entity, c.EntityID, c.Action, string(fieldsJSON), changedBy)
+	if err != nil {
+		return fmt.Errorf("failed to record task change: %w", err)
+	}
+	return nil
+}
+
+func (r *mysqlTaskChangeRepository) ListByTask(ctx context.Context, taskID int) ([]models.TaskChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, task_id, entity, entity_id, action, fields, changed_by, changed_at
+		FROM task_changes
+		WHERE task_id = ?
+		ORDER BY changed_at, id
+	`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []models.TaskChange{}
+	for rows.Next() {
+		var c models.TaskChange
+		var fields string
+		var changedBy sql.NullInt64
+		var changedAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.Entity, &c.EntityID, &c.Action, &fields, &changedBy, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan task change: %w", err)
+		}
+		if err := json.Unmarshal([]byte(fields), &c.Fields); err != nil {
+			return nil, fmt.Errorf("failed to decode task change %d: %w", c.ID, err)
+		}
+		if changedBy.Valid {
+			id := int(changedBy.Int64)
+			c.ChangedBy = &id
+		}
+		c.ChangedAt = formatTime(changedAt)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func (r *mysqlTaskChangeRepository) DeleteByTask(ctx context.Context, taskID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM task_changes WHERE task_id = ?`, taskID)
+	return err
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reports-api/models"
+)
+
+// TaskIncidentRepository stores the duplicate reports attached to a task
+type TaskIncidentRepository interface {
+	Create(ctx context.Context, i models.TaskIncident) (int64, error)
+	// ListByTask returns the incidents of a task, oldest first
+	ListByTask(ctx context.Context, taskID int) ([]models.TaskIncident, error)
+	DeleteByTask(ctx context.Context, taskID int) error
+}
+
+type mysqlTaskIncidentRepository struct {
+	db DBTX
+}
+
+func (r *mysqlTaskIncidentRepository) Create(ctx context.Context, i models.TaskIncident) (int64, error) {
+	var createdBy interface{}
+	if i.CreatedBy != nil {
+		createdBy = nullIfZero(*i.CreatedBy)
+	}
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO task_incidents (task_id, phone_id, phone_else, department_id, text, reported_by, file_paths, similarity, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, i.TaskID, i.PhoneID, i.PhoneElse, nullIfZero(i.DepartmentID), i.Text, i.ReportedBy, i.FilePaths, i.Similarity, createdBy)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create task incident: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+func (r *mysqlTaskIncidentRepository) ListByTask(ctx context.Context, taskID int) ([]models.TaskIncident, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, task_id, phone_id, phone_else, IFNULL(department_id, 0), IFNULL(text, ''), IFNULL(reported_by, ''),
+		       file_paths, similarity, created_by, created_at
+		FROM task_incidents
+		WHERE task_id = ?
+		ORDER BY created_at, id
+	`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task incidents: %w", err)
+	}
+	defer rows.Close()
+
+	incidents := []models.TaskIncident{}
+	for rows.Next() {
+		var i models.TaskIncident
+		var phoneID, createdBy sql.NullInt64
+		var phoneElse, filePaths sql.NullString
+		var createdAt sql.NullTime
+		if err := rows.Scan(&i.ID, &i.TaskID, &phoneID, &phoneElse, &i.DepartmentID, &i.Text, &i.ReportedBy,
+			&filePaths, &i.Similarity, &createdBy, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan task incident: %w", err)
+		}
+		if phoneID.Valid {
+			id := int(phoneID.Int64)
+			i.PhoneID = &id
+		}
+		if createdBy.Valid {
+			id := int(createdBy.Int64)
+			i.CreatedBy = &id
+		}
+		i.PhoneElse = nullableString(phoneElse)
+		i.FilePaths = nullableString(filePaths)
+		i.CreatedAt = formatTime(createdAt)
+		incidents = append(incidents, i)
+	}
+	return incidents, rows.Err()
+}
+
+func (r *mysqlTaskIncidentRepository) DeleteByTask(ctx context.Context, taskID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM task_incidents WHERE task_id = ?`, taskID)
+	return err
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reports-api/models"
+)
+
+// TaskLinkRepository stores the typed links between tasks (see models.TaskLinkRecord)
+type TaskLinkRepository interface {
+	// Create stores a link; it returns models.ErrTaskLinkExists when the same link is already stored
+	Create(ctx context.Context, l models.TaskLinkRecord) (int64, error)
+	Get(ctx context.Context, id int) (*models.TaskLinkRecord, error)
+	// ListByTask returns the links of a task as seen from it, skipping linked tasks in the trash, oldest first
+	ListByTask(ctx context.Context, taskID int) ([]models.TaskLink, error)
+	// Targets returns the tasks linked from taskID by stored links of linkType
+	Targets(ctx context.Context, taskID int, linkType string) ([]int, error)
+	// Sources returns the tasks linking to taskID by stored links of linkType
+	Sources(ctx context.Context, taskID int, linkType string) ([]int, error)
+	// Followers returns the children and duplicates of a task that inherit its resolution
+	Followers(ctx context.Context, taskID int) ([]int, error)
+	Delete(ctx context.Context, id int) error
+	// DeleteByTask removes every link from or to a task
+	DeleteByTask(ctx context.Context, taskID int) error
+}
+
+type mysqlTaskLinkRepository struct {
+	db DBTX
+}
+
+func (r *mysqlTaskLinkRepository) Create(ctx context.Context, l models.TaskLinkRecord) (int64, error) {
+	var createdBy interface{}
+	if l.CreatedBy != nil {
+		createdBy = nullIfZero(*l.CreatedBy)
+	}
+	// rows affected is 0 when the link already exists
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO task_links (task_id, linked_task_id, type, inherit, created_by) VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE id = id
+	`, l.TaskID, l.LinkedTaskID, l.Type, l.Inherit, createdBy)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create task link: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return 0, models.ErrTaskLinkExists
+	}
+	return res.LastInsertId()
+}
+
+func (r *mysqlTaskLinkRepository) Get(ctx context.Context, id int) (*models.TaskLinkRecord, error) {
+	var l models.TaskLinkRecord
+	var createdBy sql.NullInt64
+	var createdAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, task_id, linked_task_id, type, inherit, created_by, created_at FROM task_links WHERE id = ?
+	`, id).Scan(&l.ID, &l.TaskID, &l.LinkedTaskID, &l.Type, &l.Inherit, &createdBy, &createdAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if createdBy.Valid {
+		actor := int(createdBy.Int64)
+		l.CreatedBy = &actor
+	}
+	l.CreatedAt = formatTime(createdAt)
+	return &l, nil
+}
+
+func (r *mysqlTaskLinkRepository) ListByTask(ctx context.Context, taskID int) ([]models.TaskLink, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT l.id, l.task_id, l.type, l.inherit, l.created_by, l.created_at, o.id, IFNULL(o.ticket_no, ''), o.status
+		FROM task_links l
+		JOIN tasks o ON o.id = IF(l.task_id = ?, l.linked_task_id, l.task_id)
+		WHERE (l.task_id = ? OR l.linked_task_id = ?) AND o.deleted_at IS NULL
+		ORDER BY l.created_at, l.id
+	`, taskID, taskID, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []models.TaskLink{}
+	for rows.Next() {
+		var l models.TaskLink
+		var fromID int
+		var createdBy sql.NullInt64
+		var createdAt sql.NullTime
+		if err := rows.Scan(&l.ID, &fromID, &l.Type, &l.Inherit, &createdBy, &createdAt, &l.TaskID, &l.Ticket, &l.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan task link: %w", err)
+		}
+		if fromID != taskID {
+			l.Type = models.InverseTaskLink(l.Type)
+		}
+		if createdBy.Valid {
+			actor := int(createdBy.Int64)
+			l.CreatedBy = &actor
+		}
+		l.CreatedAt = formatTime(createdAt)
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+func (r *mysqlTaskLinkRepository) Targets(ctx context.Context, taskID int, linkType string) ([]int, error) {
+	return r.ids(ctx, `SELECT linked_task_id FROM task_links WHERE task_id = ? AND type = ?`, taskID, linkType)
+}
+
+func (r *mysqlTaskLinkRepository) Sources(ctx context.Context, taskID int, linkType string) ([]int, error) {
+	return r.ids(ctx, `SELECT task_id FROM task_links WHERE linked_task_id = ? AND type = ?`, taskID, linkType)
+}
+
+func (r *mysqlTaskLinkRepository) Followers(ctx context.Context, taskID int) ([]int, error) {
+	return r.ids(ctx, `
+		SELECT linked_task_id FROM task_links WHERE task_id = ? AND type = ? AND inherit = 1
+		UNION
+		SELECT task_id FROM task_links WHERE linked_task_id = ? AND type = ? AND inherit = 1
+	`, taskID, models.TaskLinkParentOf, taskID, models.TaskLinkDuplicateOf)
+}
+
+// ids runs a query returning one task id per row
+func (r *mysqlTaskLinkRepository) ids(ctx context.Context, query string, args ...interface{}) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task links: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan task link: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *mysqlTaskLinkRepository) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM task_links WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mysqlTaskLinkRepository) DeleteByTask(ctx context.Context, taskID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM task_links WHERE task_id = ? OR linked_task_id = ?`, taskID, taskID)
+	return err
+}
@@ -0,0 +1,532 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reports-api/models"
+	"strings"
+	"time"
+)
+
+// TaskRepository reads and writes problem reports (tasks)
+type TaskRepository interface {
+	// List returns one page of tasks with their display joins and the total number of matches.
+	// Every read skips trashed tasks unless its name says otherwise.
+	List(ctx context.Context, q TaskQuery) ([]models.TaskWithDetails, int, error)
+	// Search returns one page of tasks matching every filter of s with the total number of matches
+	Search(ctx context.Context, s TaskSearch) (*TaskSearchResult, error)
+	// GetDetail returns a task with its display joins
+	GetDetail(ctx context.Context, id int) (*models.TaskWithDetails, error)
+	// Get returns the stored task row with its Telegram message ids
+	Get(ctx context.Context, id int) (*models.TaskRecord, error)
+	// GetDeleted returns a trashed task row
+	GetDeleted(ctx context.Context, id int) (*models.TaskRecord, error)
+	Create(ctx context.Context, t NewTask) (int64, error)
+	// Update returns models.ErrVersionConflict when u.Version is set and the task has another version
+	Update(ctx context.Context, id int, u TaskUpdate) error
+	// SetStatus writes the status without checking the transition (see common.TransitionTask).
+	// resolved_at is stamped when the status becomes done and cleared otherwise.
+	// It only moves a task that still has status from, and version when set, and returns
+	// models.ErrVersionConflict when the task was changed in the meantime.
+	SetStatus(ctx context.Context, id int, from, to models.TaskStatus, version int) error
+	Assign(ctx context.Context, id int, a TaskAssignment) error
+	// SetDepartment and SetProgram move a task without touching its other columns; SLA due dates are recomputed separately
+	SetDepartment(ctx context.Context, id, departmentID, actor int) error
+	SetProgram(ctx context.Context, id, programID, actor int) error
+	SetTelegramChat(ctx context.Context, id int, telegramChatID int64) error
+	// SetFilePaths stores the uploaded images of a new task; it does not count as an edit
+	SetFilePaths(ctx context.Context, id int, filePaths string) error
+	// Resolve links the resolution; the status change to done is a separate transition
+	Resolve(ctx context.Context, id int, resolutionID int64) error
+	// Unresolve removes the resolution link; the status change is a separate transition
+	Unresolve(ctx context.Context, id int) error
+	// Delete moves the task to the trash; it returns ErrNotFound when the task is missing or already trashed
+	Delete(ctx context.Context, id, actor int) error
+	// Purge permanently deletes the task row; rows belonging to it are removed by common.PurgeTrashed
+	Purge(ctx context.Context, id int) error
+	CountForDepartmentMonth(ctx context.Context, departmentID, year, month int) (int, error)
+	// DuplicateCandidates returns the open tasks a new report may duplicate, newest first; similarity is left to the caller
+	DuplicateCandidates(ctx context.Context, q DuplicateQuery) ([]models.DuplicateCandidate, error)
+}
+
+// TaskQuery filters and orders a task list. Only one of Search, Column or PreferColumn is normally set.
+type TaskQuery struct {
+	// Search matches ticket, phone, department, branch, program, issue type, text, assignee and status
+	Search string
+	// Column filters on one of the columns accepted by TaskFilterColumn; text columns use LIKE
+	Column string
+	Value  interface{}
+	// PreferColumn lists rows whose column equals PreferValue first without filtering the others
+	PreferColumn string
+	PreferValue  interface{}
+	Limit        int
+	Offset       int
+}
+
+// DuplicateQuery selects the open tasks reported within Within against the same program or the program's
+// issue type (without a program, the same issue type) by the same department or branch
+type DuplicateQuery struct {
+	SystemID     int
+	IssueTypeID  int
+	DepartmentID int
+	Within       time.Duration
+	Limit        int
+}
+
+// NewTask holds the columns written when a problem is reported
+type NewTask struct {
+	Ticket       string
+	PhoneID      *int
+	PhoneElse    *string
+	SystemID     int
+	IssueTypeID  int
+	IssueElse    *string
+	DepartmentID int
+	Text         string
+	ReportedBy   string
+	CreatedBy    int
+	FilePaths    *string
+}
+
+// TaskUpdate holds the columns written when a problem is edited.
+// ReportedBy and FilePaths are left unchanged when nil; the status is changed with SetStatus.
+// Version is the version the client edited; 0 skips the check.
+type TaskUpdate struct {
+	PhoneID      *int
+	PhoneElse    *string
+	SystemID     int
+	IssueTypeID  int
+	IssueElse    *string
+	DepartmentID int
+	AssignedtoID int
+	Assignto     *string
+	ReportedBy   *string
+	Text         string
+	UpdatedBy    int
+	FilePaths    *string
+	Version      int
+}
+
+// TaskAssignment changes the assignee; UpdatedBy is only written when set
+type TaskAssignment struct {
+	AssignedtoID int
+	Assignto     string
+	UpdatedBy    int
+}
+
+type taskColumn struct {
+	expr  string
+	isInt bool
+}
+
+// taskColumns maps the column names accepted by the API to SQL expressions of taskFrom
+var taskColumns = map[string]taskColumn{
+	"phone_id":        {"t.phone_id", true},
+	"issue_type":      {"t.issue_type", true},
+	"system_id":       {"t.system_id", true},
+	"department_id":   {"t.department_id", true},
+	"branch_id":       {"d.branch_id", true},
+	"status":          {"t.status", true},
+	"created_by":      {"t.created_by", true},
+	"updated_by":      {"t.updated_by", true},
+	"telegram_id":     {"t.telegram_id", true},
+	"ticket_no":       {"t.ticket_no", false},
+	"phone_else":      {"t.phone_else", false},
+	"number":          {"p.number", false},
+	"phone_name":      {"p.name", false},
+	"system_name":     {"s.name", false},
+	"issue_else":      {"t.issue_else", false},
+	"department_name": {"d.name", false},
+	"branch_name":     {"b.name", false},
+	"text":            {"t.text", false},
+	"assignto":        {"t.assignto", false},
+	"reported_by":     {"t.reported_by", false},
+	"solution":        {"t.solution", false},
+}
+
+// TaskFilterColumn reports whether name can be used in TaskQuery.Column / PreferColumn and whether it holds an integer
+func TaskFilterColumn(name string) (isInt bool, ok bool) {
+	col, ok := taskColumns[name]
+	return col.isInt, ok
+}
+
+const taskSelect = `
+	SELECT t.id, IFNULL(t.ticket_no, ''), IFNULL(t.phone_id, 0), IFNULL(t.phone_else, ''), IFNULL(p.number, 0), IFNULL(p.name, ''),
+		IFNULL(t.system_id, 0), IFNULL(s.name, ''), IFNULL(t.issue_type, 0), IFNULL(t.issue_else, ''), IFNULL(it.name, ''),
+		IFNULL(t.department_id, 0), IFNULL(d.name, ''), IFNULL(d.branch_id, 0), IFNULL(b.name, ''), IFNULL(t.text, ''),
+		IFNULL(t.assignto_id, 0), IFNULL(t.assignto, ''), IFNULL(t.reported_by, ''), IFNULL(t.status, 0),
+		t.created_at, t.updated_at, IFNULL(t.file_paths, '[]'),
+		t.sla_policy_id, t.response_due_at, t.resolution_due_at, t.first_response_at, t.resolved_at, t.version`
+
+const taskFrom = `
+	FROM tasks t
+	LEFT JOIN ip_phones p ON t.phone_id = p.id
+	LEFT JOIN departments d ON t.department_id = d.id
+	LEFT JOIN branches b ON d.branch_id = b.id
+	LEFT JOIN systems_program s ON t.system_id = s.id
+	LEFT JOIN issue_types it ON t.issue_type = it.id`
+
+const taskSearchWhere = `(t.ticket_no LIKE ? OR p.number LIKE ? OR p.name LIKE ? OR t.phone_else LIKE ? OR d.name LIKE ? OR b.name LIKE ?
+	OR s.name LIKE ? OR t.text LIKE ? OR it.name LIKE ? OR t.assignto LIKE ? OR t.status LIKE ?)`
+
+type mysqlTaskRepository struct {
+	db DBTX
+}
+
+// scanTask reads one row of taskSelect
+func scanTask(row interface{ Scan(...interface{}) error }) (models.TaskWithDetails, error) {
+	var t models.TaskWithDetails
+	var issueTypeName, filePathsJSON string
+	var createdAt, updatedAt, responseDue, resolutionDue, firstResponse, resolvedAt sql.NullTime
+	var slaPolicyID sql.NullInt64
+	err := row.Scan(&t.ID, &t.Ticket, &t.PhoneID, &t.PhoneElse, &t.Number, &t.PhoneName,
+		&t.SystemID, &t.SystemName, &t.IssueTypeID, &t.IssueElse, &issueTypeName,
+		&t.DepartmentID, &t.DepartmentName, &t.BranchID, &t.BranchName, &t.Text,
+		&t.AssignedtoID, &t.Assignto, &t.ReportedBy, &t.Status,
+		&createdAt, &updatedAt, &filePathsJSON,
+		&slaPolicyID, &responseDue, &resolutionDue, &firstResponse, &resolvedAt, &t.Version)
+	if err != nil {
+		return t, err
+	}
+	t.SystemType = issueTypeName
+	t.CreatedAt = formatTimeRFC3339(createdAt)
+	t.UpdatedAt = formatTimeRFC3339(updatedAt)
+	t.ResolvedAt = formatTimeRFC3339(resolvedAt)
+	t.FilePaths = indexedFileURLs(filePathsJSON)
+	t.SLA = taskSLA(slaPolicyID, responseDue, resolutionDue, firstResponse, resolvedAt, t.Status, time.Now())
+	return t, nil
+}
+
+// indexedFileURLs converts a file_paths JSON array to the image_{index} map returned by the API
+func indexedFileURLs(filePathsJSON string) map[string]string {
+	fileMap := make(map[string]string)
+	if filePathsJSON == "" || filePathsJSON == "[]" {
+		return fileMap
+	}
+	var files []map[string]interface{}
+	if err := json.Unmarshal([]byte(filePathsJSON), &files); err == nil {
+		for i, f := range files {
+			if url, ok := f["url"].(string); ok {
+				fileMap[fmt.Sprintf("image_%d", i)] = url
+			}
+		}
+	}
+	return fileMap
+}
+
+func (r *mysqlTaskRepository) List(ctx context.Context, q TaskQuery) ([]models.TaskWithDetails, int, error) {
+	where := []string{"t.deleted_at IS NULL"}
+	var args []interface{}
+
+	if q.Search != "" {
+		where = append(where, taskSearchWhere)
+		pattern := likePattern(q.Search)
+		for i := 0; i < strings.Count(taskSearchWhere, "?"); i++ {
+			args = append(args, pattern)
+		}
+	}
+	if q.Column != "" {
+		col, ok := taskColumns[q.Column]
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid task column %q", q.Column)
+		}
+		if col.isInt {
+			where = append(where, col.expr+" = ?")
+			args = append(args, q.Value)
+		} else {
+			where = append(where, col.expr+" LIKE ?")
+			args = append(args, likePattern(fmt.Sprint(q.Value)))
+		}
+	}
+
+	whereSQL := " WHERE " + strings.Join(where, " AND ")
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*)"+taskFrom+whereSQL, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count tasks: %w", err)
+	}
+
+	orderBy, orderArgs, err := taskOrderBy(q)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := taskSelect + taskFrom + whereSQL + orderBy + " LIMIT ? OFFSET ?"
+	args = append(append(args, orderArgs...), q.Limit, q.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []models.TaskWithDetails
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan task: %w", err)
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, total, rows.Err()
+}
+
+// taskOrderBy builds the ORDER BY clause; PreferColumn "status" keeps the models.TaskStatuses order after the chosen status
+func taskOrderBy(q TaskQuery) (string, []interface{}, error) {
+	if q.PreferColumn == "" {
+		return " ORDER BY t.id DESC", nil, nil
+	}
+	col, ok := taskColumns[q.PreferColumn]
+	if !ok {
+		return "", nil, fmt.Errorf("invalid task column %q", q.PreferColumn)
+	}
+	if q.PreferColumn == "status" {
+		preferred, ok := q.PreferValue.(int)
+		if !ok || !models.TaskStatus(preferred).Valid() {
+			return " ORDER BY t.status, t.id DESC", nil, nil
+		}
+		// สถานะที่เลือกขึ้นก่อน ที่เหลือเรียงตามลำดับของ workflow
+		whens := []string{fmt.Sprintf("WHEN t.status = %d THEN 0", preferred)}
+		for i, status := range models.TaskStatuses {
+			if int(status) != preferred {
+				whens = append(whens, fmt.Sprintf("WHEN t.status = %d THEN %d", status, i+1))
+			}
+		}
+		return " ORDER BY CASE " + strings.Join(whens, " ") + fmt.Sprintf(" ELSE %d END, t.id DESC", len(models.TaskStatuses)+1), nil, nil
+	}
+	return " ORDER BY CASE WHEN " + col.expr + " = ? THEN 0 ELSE 1 END, t.id DESC", []interface{}{q.PreferValue}, nil
+}
+
+func (r *mysqlTaskRepository) GetDetail(ctx context.Context, id int) (*models.TaskWithDetails, error) {
+	t, err := scanTask(r.db.QueryRowContext(ctx, taskSelect+taskFrom+" WHERE t.id = ? AND t.deleted_at IS NULL", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &t, nil
+}
+
+func (r *mysqlTaskRepository) Get(ctx context.Context, id int) (*models.TaskRecord, error) {
+	return r.get(ctx, id, "t.deleted_at IS NULL")
+}
+
+func (r *mysqlTaskRepository) GetDeleted(ctx context.Context, id int) (*models.TaskRecord, error) {
+	return r.get(ctx, id, "t.deleted_at IS NOT NULL")
+}
+
+func (r *mysqlTaskRepository) get(ctx context.Context, id int, trashed string) (*models.TaskRecord, error) {
+	var t models.TaskRecord
+	var phoneID sql.NullInt64
+	var phoneElse sql.NullString
+	var solutionID sql.NullInt64
+	var createdAt, updatedAt, resolvedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		SELECT t.id, IFNULL(t.ticket_no, ''), t.phone_id, t.phone_else, IFNULL(t.system_id, 0), IFNULL(t.issue_type, 0), IFNULL(t.issue_else, ''),
+			IFNULL(t.department_id, 0), IFNULL(t.text, ''), IFNULL(t.reported_by, ''), IFNULL(t.assignto_id, 0), IFNULL(t.assignto, ''),
+			IFNULL(rs.telegram_username, ''), IFNULL(t.status, 0), t.solution_id, IFNULL(t.telegram_id, 0),
+			IFNULL(tc.report_id, 0), IFNULL(tc.assignto_id, 0), IFNULL(tc.solution_id, 0), IFNULL(t.file_paths, '[]'),
+			t.created_at, t.updated_at, t.resolved_at, t.version
+		FROM tasks t
+		LEFT JOIN telegram_chat tc ON t.telegram_id = tc.id
+		LEFT JOIN responsibilities rs ON t.assignto_id = rs.id
+		WHERE t.id = ? AND `+trashed+`
+	`, id).Scan(&t.ID, &t.Ticket, &phoneID, &phoneElse, &t.SystemID, &t.IssueTypeID, &t.IssueElse,
+		&t.DepartmentID, &t.Text, &t.ReportedBy, &t.AssignedtoID, &t.Assignto,
+		&t.AssigneeTelegram, &t.Status, &solutionID, &t.TelegramID,
+		&t.ReportMessageID, &t.AssigneeMessageID, &t.SolutionMessageID, &t.FilePaths,
+		&createdAt, &updatedAt, &resolvedAt, &t.Version)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	if phoneID.Valid {
+		v := int(phoneID.Int64)
+		t.PhoneID = &v
+	}
+	t.PhoneElse = nullableString(phoneElse)
+	if solutionID.Valid {
+		v := int(solutionID.Int64)
+		t.SolutionID = &v
+	}
+	t.CreatedAt = formatTime(createdAt)
+	t.UpdatedAt = formatTime(updatedAt)
+	t.ResolvedAt = formatTime(resolvedAt)
+	return &t, nil
+}
+
+func (r *mysqlTaskRepository) Create(ctx context.Context, t NewTask) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO tasks (phone_id, phone_else, ticket_no, system_id, issue_type, issue_else, department_id, text, reported_by, status, created_by, file_paths)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?)
+	`, t.PhoneID, t.PhoneElse, t.Ticket, t.SystemID, t.IssueTypeID, t.IssueElse, t.DepartmentID, t.Text, t.ReportedBy, t.CreatedBy, t.FilePaths)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert task: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, indexDocument(ctx, r.db, models.SearchSourceTask, id)
+}
+
+func (r *mysqlTaskRepository) SetFilePaths(ctx context.Context, id int, filePaths string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE tasks SET file_paths = ? WHERE id = ?`, filePaths, id)
+	return err
+}
+
+func (r *mysqlTaskRepository) Update(ctx context.Context, id int, u TaskUpdate) error {
+	set := []string{
+		"phone_id = ?", "phone_else = ?", "system_id = ?", "issue_type = ?", "issue_else = ?", "department_id = ?",
+		"assignto_id = ?", "assignto = ?", "text = ?", "updated_at = CURRENT_TIMESTAMP", "updated_by = ?", "version = version + 1",
+	}
+	args := []interface{}{
+		u.PhoneID, u.PhoneElse, u.SystemID, u.IssueTypeID, u.IssueElse, u.DepartmentID,
+		u.AssignedtoID, u.Assignto, u.Text, u.UpdatedBy,
+	}
+	if u.ReportedBy != nil {
+		set = append(set, "reported_by = ?")
+		args = append(args, *u.ReportedBy)
+	}
+	if u.FilePaths != nil {
+		set = append(set, "file_paths = ?")
+		args = append(args, *u.FilePaths)
+	}
+
+	where := " WHERE id = ?"
+	args = append(args, id)
+	if u.Version > 0 {
+		where += " AND version = ?"
+		args = append(args, u.Version)
+	}
+	res, err := r.db.ExecContext(ctx, "UPDATE tasks SET "+strings.Join(set, ", ")+where, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+	if err := versionConflict(res, u.Version); err != nil {
+		return err
+	}
+	return indexDocument(ctx, r.db, models.SearchSourceTask, int64(id))
+}
+
+func (r *mysqlTaskRepository) SetStatus(ctx context.Context, id int, from, to models.TaskStatus, version int) error {
+	query := `UPDATE tasks SET status = ?, resolved_at = NULL, version = version + 1 WHERE id = ? AND status = ?`
+	if to == models.TaskStatusDone {
+		query = `UPDATE tasks SET status = ?, resolved_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = ? AND status = ?`
+	}
+	args := []interface{}{int(to), id, int(from)}
+	if version > 0 {
+		query += " AND version = ?"
+		args = append(args, version)
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	// status always changes, so a matched row always counts as affected
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return models.ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *mysqlTaskRepository) Assign(ctx context.Context, id int, a TaskAssignment) error {
+	set := []string{"assignto_id = ?", "assignto = ?", "version = version + 1"}
+	args := []interface{}{a.AssignedtoID, a.Assignto}
+	if a.UpdatedBy != 0 {
+		set = append(set, "updated_by = ?", "updated_at = NOW()")
+		args = append(args, a.UpdatedBy)
+	}
+
+	_, err := r.db.ExecContext(ctx, "UPDATE tasks SET "+strings.Join(set, ", ")+" WHERE id = ?", append(args, id)...)
+	return err
+}
+
+func (r *mysqlTaskRepository) SetDepartment(ctx context.Context, id, departmentID, actor int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE tasks SET department_id = ?, updated_by = ?, updated_at = NOW(), version = version + 1 WHERE id = ?`, departmentID, nullIfZero(actor), id)
+	return err
+}
+
+func (r *mysqlTaskRepository) SetProgram(ctx context.Context, id, programID, actor int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE tasks SET system_id = ?, updated_by = ?, updated_at = NOW(), version = version + 1 WHERE id = ?`, programID, nullIfZero(actor), id)
+	return err
+}
+
+func (r *mysqlTaskRepository) SetTelegramChat(ctx context.Context, id int, telegramChatID int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE tasks SET telegram_id = ? WHERE id = ?`, telegramChatID, id)
+	return err
+}
+
+func (r *mysqlTaskRepository) Resolve(ctx context.Context, id int, resolutionID int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE tasks SET solution_id = ?, version = version + 1 WHERE id = ?`, resolutionID, id)
+	return err
+}
+
+func (r *mysqlTaskRepository) Unresolve(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE tasks SET solution_id = NULL, version = version + 1 WHERE id = ?`, id)
+	return err
+}
+
+func (r *mysqlTaskRepository) Delete(ctx context.Context, id, actor int) error {
+	if err := softDelete(ctx, r.db, "tasks", id, actor); err != nil {
+		return err
+	}
+	return indexDocument(ctx, r.db, models.SearchSourceTask, int64(id))
+}
+
+func (r *mysqlTaskRepository) Purge(ctx context.Context, id int) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM tasks WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return unindexTask(ctx, r.db, "", id)
+}
+
+func (r *mysqlTaskRepository) CountForDepartmentMonth(ctx context.Context, departmentID, year, month int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM tasks
+		WHERE department_id = ? AND YEAR(created_at) = ? AND MONTH(created_at) = ? AND deleted_at IS NULL
+	`, departmentID, year, month).Scan(&count)
+	return count, err
+}
+
+func (r *mysqlTaskRepository) DuplicateCandidates(ctx context.Context, q DuplicateQuery) ([]models.DuplicateCandidate, error) {
+	match, matchArgs := `t.system_id = ?`, []interface{}{q.SystemID}
+	switch {
+	case q.SystemID == 0:
+		match, matchArgs = `t.system_id = 0 AND t.issue_type = ?`, []interface{}{q.IssueTypeID}
+	case q.IssueTypeID > 0:
+		// ปัญหาเดียวกันอาจถูกแจ้งโดยไม่ระบุโปรแกรม แต่ระบุประเภทเดียวกัน
+		match, matchArgs = `(t.system_id = ? OR t.issue_type = ?)`, []interface{}{q.SystemID, q.IssueTypeID}
+	}
+	args := []interface{}{int(models.TaskStatusDone), int(models.TaskStatusCancelled), int(q.Within.Seconds())}
+	args = append(args, matchArgs...)
+	args = append(args, q.DepartmentID, q.DepartmentID, q.Limit)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, IFNULL(t.ticket_no, ''), IFNULL(t.text, ''), IFNULL(t.department_id, 0), t.status, t.created_at
+		FROM tasks t
+		LEFT JOIN departments d ON d.id = t.department_id
+		WHERE t.deleted_at IS NULL AND t.status NOT IN (?, ?)
+		  AND t.created_at >= NOW() - INTERVAL ? SECOND
+		  AND `+match+`
+		  AND (t.department_id = ? OR d.branch_id = (SELECT branch_id FROM departments WHERE id = ?))
+		ORDER BY t.created_at DESC, t.id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate candidates: %w", err)
+	}
+	defer rows.Close()
+
+	candidates := []models.DuplicateCandidate{}
+	for rows.Next() {
+		var c models.DuplicateCandidate
+		var createdAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.Ticket, &c.Text, &c.DepartmentID, &c.Status, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate candidate: %w", err)
+		}
+		c.CreatedAt = formatTime(createdAt)
+		c.SameDepartment = c.DepartmentID == q.DepartmentID
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reports-api/models"
+	"strings"
+	"time"
+)
+
+// SLA states accepted by TaskSearch.SLAState
+const (
+	TaskSLANone     = "none"     // no SLA policy matched
+	TaskSLAOnTrack  = "on_track" // has targets, not at risk and not breached
+	TaskSLAAtRisk   = "at_risk"  // open and a pending target is due before SLAWarnUntil
+	TaskSLABreached = "breached" // a target was missed or is overdue
+)
+
+// TaskSort orders a task search by one field of TaskSortFields
+type TaskSort struct {
+	Field string
+	Desc  bool
+}
+
+// TaskSearch combines task filters; empty fields match everything and list fields match any of their values.
+// Results are ordered by Sort followed by t.id and paged with the keys returned for the last row.
+type TaskSearch struct {
+	Statuses      []int
+	BranchIDs     []int
+	DepartmentIDs []int
+	ProgramIDs    []int
+	IssueTypeIDs  []int
+	AssigneeIDs   []int
+	CreatedByIDs  []int
+	// ReportedBy matches the reporter name with LIKE
+	ReportedBy string
+	// Text matches the same columns as TaskQuery.Search
+	Text string
+
+	CreatedFrom, CreatedTo   *time.Time
+	UpdatedFrom, UpdatedTo   *time.Time
+	ResolvedFrom, ResolvedTo *time.Time
+
+	// SLAState is one of the TaskSLA* states, evaluated at Now; at risk means due before SLAWarnUntil
+	SLAState     string
+	Now          time.Time
+	SLAWarnUntil time.Time
+
+	Sort []TaskSort
+	// After holds the sort keys of the last row of the previous page (see TaskSearchResult.NextKeys)
+	After []string
+	Limit int
+}
+
+// TaskSearchResult is one page of a task search; NextKeys is nil on the last page
+type TaskSearchResult struct {
+	Tasks    []models.TaskWithDetails
+	Total    int
+	NextKeys []string
+}
+
+// TaskSortFields maps the sort fields accepted by the API to non-null SQL expressions of taskFrom
+var TaskSortFields = map[string]string{
+	"id":                "t.id",
+	"created_at":        "t.created_at",
+	"updated_at":        "t.updated_at",
+	"status":            "t.status",
+	"ticket_no":         "IFNULL(t.ticket_no, '')",
+	"response_due_at":   "IFNULL(t.response_due_at, TIMESTAMP('9999-12-31 23:59:59'))",
+	"resolution_due_at": "IFNULL(t.resolution_due_at, TIMESTAMP('9999-12-31 23:59:59'))",
+	"resolved_at":       "IFNULL(t.resolved_at, TIMESTAMP('9999-12-31 23:59:59'))",
+	"branch_name":       "IFNULL(b.name, '')",
+	"department_name":   "IFNULL(d.name, '')",
+	"program_name":      "IFNULL(s.name, '')",
+	"assignto":          "IFNULL(t.assignto, '')",
+}
+
+// taskSortKeys returns the sort with the t.id tie-breaker appended, so every row has a unique key
+func taskSortKeys(sort []TaskSort) ([]TaskSort, error) {
+	keys := make([]TaskSort, 0, len(sort)+1)
+	for _, s := range sort {
+		if _, ok := TaskSortFields[s.Field]; !ok {
+			return nil, fmt.Errorf("invalid sort field %q", s.Field)
+		}
+		keys = append(keys, s)
+		if s.Field == "id" {
+			return keys, nil
+		}
+	}
+	desc := true
+	if len(sort) > 0 {
+		desc = sort[len(sort)-1].Desc
+	}
+	return append(keys, TaskSort{Field: "id", Desc: desc}), nil
+}
+
+// closedStatusList returns the closed statuses as a SQL list
+func closedStatusList() string {
+	var closed []string
+	for _, s := range models.TaskStatuses {
+		if s.IsClosed() {
+			closed = append(closed, fmt.Sprint(int(s)))
+		}
+	}
+	return strings.Join(closed, ", ")
+}
+
+// taskSLAWhere returns the condition of an SLA state; it mirrors taskSLA
+func taskSLAWhere(state string, now, warnUntil time.Time) (string, []interface{}, error) {
+	open := "t.status NOT IN (" + closedStatusList() + ")"
+	// ทุกเงื่อนไขตรวจ IS NULL ก่อนเปรียบเทียบ เพื่อไม่ให้ผลเป็น NULL เมื่อใช้กับ NOT
+	breached := `((t.response_due_at IS NOT NULL AND ((t.first_response_at IS NOT NULL AND t.first_response_at > t.response_due_at)
+			OR (t.first_response_at IS NULL AND ` + open + ` AND t.response_due_at < ?)))
+		OR (t.resolution_due_at IS NOT NULL AND ((t.resolved_at IS NOT NULL AND t.resolved_at > t.resolution_due_at)
+			OR (t.resolved_at IS NULL AND ` + open + ` AND t.resolution_due_at < ?))))`
+	atRisk := `(` + open + ` AND ((t.response_due_at IS NOT NULL AND t.first_response_at IS NULL AND t.response_due_at < ?)
+		OR (t.resolution_due_at IS NOT NULL AND t.resolved_at IS NULL AND t.resolution_due_at < ?)))`
+
+	switch state {
+	case TaskSLANone:
+		return "t.response_due_at IS NULL AND t.resolution_due_at IS NULL", nil, nil
+	case TaskSLABreached:
+		return breached, []interface{}{now, now}, nil
+	case TaskSLAAtRisk:
+		return "NOT " + breached + " AND " + atRisk, []interface{}{now, now, warnUntil, warnUntil}, nil
+	case TaskSLAOnTrack:
+		return "(t.response_due_at IS NOT NULL OR t.resolution_due_at IS NOT NULL) AND NOT " + breached + " AND NOT " + atRisk,
+			[]interface{}{now, now, warnUntil, warnUntil}, nil
+	}
+	return "", nil, fmt.Errorf("invalid SLA state %q", state)
+}
+
+// taskSearchConditions builds the filter conditions of s without the page position; trashed tasks never match
+func taskSearchConditions(s TaskSearch) ([]string, []interface{}, error) {
+	where := []string{"t.deleted_at IS NULL"}
+	var args []interface{}
+
+	in := func(expr string, values []int) {
+		if len(values) == 0 {
+			return
+		}
+		where = append(where, expr+" IN (?"+strings.Repeat(", ?", len(values)-1)+")")
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+	in("t.status", s.Statuses)
+	in("d.branch_id", s.BranchIDs)
+	in("t.department_id", s.DepartmentIDs)
+	in("t.system_id", s.ProgramIDs)
+	in("t.issue_type", s.IssueTypeIDs)
+	in("t.assignto_id", s.AssigneeIDs)
+	in("t.created_by", s.CreatedByIDs)
+
+	between := func(expr string, from, to *time.Time) {
+		if from != nil {
+			where = append(where, expr+" >= ?")
+			args = append(args, *from)
+		}
+		if to != nil {
+			where = append(where, expr+" < ?")
+			args = append(args, *to)
+		}
+	}
+	between("t.created_at", s.CreatedFrom, s.CreatedTo)
+	between("t.updated_at", s.UpdatedFrom, s.UpdatedTo)
+	between("t.resolved_at", s.ResolvedFrom, s.ResolvedTo)
+
+	if s.ReportedBy != "" {
+		where = append(where, "t.reported_by LIKE ?")
+		args = append(args, likePattern(s.ReportedBy))
+	}
+	if s.Text != "" {
+		where = append(where, taskSearchWhere)
+		pattern := likePattern(s.Text)
+		for i := 0; i < strings.Count(taskSearchWhere, "?"); i++ {
+			args = append(args, pattern)
+		}
+	}
+	if s.SLAState != "" {
+		cond, condArgs, err := taskSLAWhere(s.SLAState, s.Now, s.SLAWarnUntil)
+		if err != nil {
+			return nil, nil, err
+		}
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+	return where, args, nil
+}
+
+// taskKeysetWhere returns the condition selecting the rows after the given keys:
+// (k1 > a1) OR (k1 = a1 AND k2 > a2) OR ..., with < for descending keys
+func taskKeysetWhere(keys []TaskSort, after []string) (string, []interface{}) {
+	var ors []string
+	var args []interface{}
+	for i, k := range keys {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, TaskSortFields[keys[j].Field]+" = ?")
+			args = append(args, after[j])
+		}
+		op := " > ?"
+		if k.Desc {
+			op = " < ?"
+		}
+		ands = append(ands, TaskSortFields[k.Field]+op)
+		args = append(args, after[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+// extraScanner appends destinations to every Scan so scanTask can read rows with extra columns
+type extraScanner struct {
+	row   interface{ Scan(...interface{}) error }
+	extra []interface{}
+}
+
+func (s extraScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+func (r *mysqlTaskRepository) Search(ctx context.Context, s TaskSearch) (*TaskSearchResult, error) {
+	keys, err := taskSortKeys(s.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if s.After != nil && len(s.After) != len(keys) {
+		return nil, fmt.Errorf("cursor does not match the sort order")
+	}
+
+	where, args, err := taskSearchConditions(s)
+	if err != nil {
+		return nil, err
+	}
+	whereSQL := " WHERE " + strings.Join(where, " AND ")
+
+	result := &TaskSearchResult{Tasks: []models.TaskWithDetails{}}
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*)"+taskFrom+whereSQL, args...).Scan(&result.Total); err != nil {
+		return nil, fmt.Errorf("failed to count tasks: %w", err)
+	}
+
+	if s.After != nil {
+		cond, condArgs := taskKeysetWhere(keys, s.After)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+	whereSQL = " WHERE " + strings.Join(where, " AND ")
+
+	// คอลัมน์ท้ายสุดคือค่าของคีย์เรียงลำดับ ใช้สร้าง cursor ของหน้าถัดไป
+	keyCols := make([]string, len(keys))
+	orderBy := make([]string, len(keys))
+	for i, k := range keys {
+		keyCols[i] = "CAST(" + TaskSortFields[k.Field] + " AS CHAR)"
+		orderBy[i] = TaskSortFields[k.Field]
+		if k.Desc {
+			orderBy[i] += " DESC"
+		}
+	}
+	query := taskSelect + ", " + strings.Join(keyCols, ", ") + taskFrom + whereSQL +
+		" ORDER BY " + strings.Join(orderBy, ", ") + " LIMIT ?"
+	args = append(args, s.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var lastKeys []string
+	for rows.Next() {
+		if len(result.Tasks) == s.Limit {
+			// มีแถวเกินหนึ่งแถว แปลว่ายังมีหน้าถัดไป
+			result.NextKeys = lastKeys
+			break
+		}
+		rowKeys := make([]string, len(keys))
+		extra := make([]interface{}, len(keys))
+		for i := range rowKeys {
+			extra[i] = &rowKeys[i]
+		}
+		t, err := scanTask(extraScanner{rows, extra})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task: %w", err)
+		}
+		result.Tasks = append(result.Tasks, t)
+		lastKeys = rowKeys
+	}
+	return result, rows.Err()
+}
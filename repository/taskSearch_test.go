@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTaskSortKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		sort    []TaskSort
+		want    []TaskSort
+		wantErr bool
+	}{
+		{"default is newest id first", nil, []TaskSort{{"id", true}}, false},
+		{"id tie-breaker follows the last direction", []TaskSort{{"created_at", false}}, []TaskSort{{"created_at", false}, {"id", false}}, false},
+		{"descending tie-breaker", []TaskSort{{"status", false}, {"updated_at", true}}, []TaskSort{{"status", false}, {"updated_at", true}, {"id", true}}, false},
+		{"explicit id ends the keys", []TaskSort{{"id", false}, {"status", true}}, []TaskSort{{"id", false}}, false},
+		{"unknown field", []TaskSort{{"password", false}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := taskSortKeys(tt.sort)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("taskSortKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("taskSortKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskKeysetWhere(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []TaskSort
+		after    []string
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			"single ascending key",
+			[]TaskSort{{"id", false}},
+			[]string{"10"},
+			"((t.id > ?))",
+			[]interface{}{"10"},
+		},
+		{
+			"descending key with tie-breaker",
+			[]TaskSort{{"created_at", true}, {"id", true}},
+			[]string{"2026-01-02 03:04:05", "7"},
+			"((t.created_at < ?) OR (t.created_at = ? AND t.id < ?))",
+			[]interface{}{"2026-01-02 03:04:05", "2026-01-02 03:04:05", "7"},
+		},
+		{
+			"mixed directions",
+			[]TaskSort{{"status", false}, {"ticket_no", true}, {"id", true}},
+			[]string{"1", "T-1", "3"},
+			"((t.status > ?) OR (t.status = ? AND IFNULL(t.ticket_no, '') < ?) OR (t.status = ? AND IFNULL(t.ticket_no, '') = ? AND t.id < ?))",
+			[]interface{}{"1", "1", "T-1", "1", "T-1", "3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSQL, gotArgs := taskKeysetWhere(tt.keys, tt.after)
+			if gotSQL != tt.wantSQL {
+				t.Errorf("taskKeysetWhere() sql = %q, want %q", gotSQL, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("taskKeysetWhere() args = %v, want %v", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reports-api/models"
+)
+
+// TaskStatusHistoryRepository records every status change of a task
+type TaskStatusHistoryRepository interface {
+	// Record appends a status change; a nil from marks the initial status of a new task
+	Record(ctx context.Context, taskID int, from *models.TaskStatus, to models.TaskStatus, changedBy int, reason string) error
+	// ListByTask returns the changes of a task, oldest first
+	ListByTask(ctx context.Context, taskID int) ([]models.TaskStatusChange, error)
+	DeleteByTask(ctx context.Context, taskID int) error
+}
+
+type mysqlTaskStatusHistoryRepository struct {
+	db DBTX
+}
+
+func (r *mysqlTaskStatusHistoryRepository) Record(ctx context.Context, taskID int, from *models.TaskStatus, to models.TaskStatus, changedBy int, reason string) error {
+	var fromValue, reasonValue interface{}
+	if from != nil {
+		fromValue = int(*from)
+	}
+	if reason != "" {
+		reasonValue = reason
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO task_status_history (task_id, from_status, to_status, changed_by, reason)
+		VALUES (?, ?, ?, ?, ?)
+	`, taskID, fromValue, int(to), nullIfZero(changedBy), reasonValue)
+	if err != nil {
+		return fmt.Errorf("failed to record status change: %w", err)
+	}
+	return nil
+}
+
+func (r *mysqlTaskStatusHistoryRepository) ListByTask(ctx context.Context, taskID int) ([]models.TaskStatusChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, task_id, from_status, to_status, changed_by, IFNULL(reason, ''), changed_at
+		FROM task_status_history
+		WHERE task_id = ?
+		ORDER BY changed_at, id
+	`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query status history: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []models.TaskStatusChange{}
+	for rows.Next() {
+		var h models.TaskStatusChange
+		var from, changedBy sql.NullInt64
+		var changedAt sql.NullTime
+		if err := rows.Scan(&h.ID, &h.TaskID, &from, &h.ToStatus, &changedBy, &h.Reason, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan status change: %w", err)
+		}
+		if from.Valid {
+			status := models.TaskStatus(from.Int64)
+			h.FromStatus = &status
+			h.FromLabel = status.Label()
+		}
+		if changedBy.Valid {
+			id := int(changedBy.Int64)
+			h.ChangedBy = &id
+		}
+		h.ToLabel = h.ToStatus.Label()
+		h.ChangedAt = formatTime(changedAt)
+		changes = append(changes, h)
+	}
+	return changes, rows.Err()
+}
+
+func (r *mysqlTaskStatusHistoryRepository) DeleteByTask(ctx context.Context, taskID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM task_status_history WHERE task_id = ?`, taskID)
+	return err
+}
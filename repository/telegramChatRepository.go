@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"reports-api/models"
+)
+
+// TelegramChatRepository stores the Telegram message ids of a task.
+// A message id of 0 is stored as NULL.
+type TelegramChatRepository interface {
+	Create(ctx context.Context, chatID int, chatName string, reportMessageID int) (int64, error)
+	Get(ctx context.Context, id int) (*models.TelegramChat, error)
+	SetAssigneeMessage(ctx context.Context, id int, messageID int) error
+	SetSolutionMessage(ctx context.Context, id int, messageID int) error
+	Delete(ctx context.Context, id int) error
+}
+
+type mysqlTelegramChatRepository struct {
+	db DBTX
+}
+
+func (r *mysqlTelegramChatRepository) Create(ctx context.Context, chatID int, chatName string, reportMessageID int) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO telegram_chat (chat_id, chat_name, report_id) VALUES (?, ?, ?)`, chatID, chatName, reportMessageID)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (r *mysqlTelegramChatRepository) Get(ctx context.Context, id int) (*models.TelegramChat, error) {
+	var tc models.TelegramChat
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, IFNULL(chat_id, ''), IFNULL(chat_name, ''), IFNULL(report_id, 0), IFNULL(assignto_id, 0), IFNULL(solution_id, 0)
+		FROM telegram_chat WHERE id = ?
+	`, id).Scan(&tc.ID, &tc.ChatID, &tc.ChatName, &tc.ReportMessageID, &tc.AssigneeMessageID, &tc.SolutionMessageID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &tc, nil
+}
+
+func (r *mysqlTelegramChatRepository) SetAssigneeMessage(ctx context.Context, id int, messageID int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE telegram_chat SET assignto_id = ? WHERE id = ?`, nullIfZero(messageID), id)
+	return err
+}
+
+func (r *mysqlTelegramChatRepository) SetSolutionMessage(ctx context.Context, id int, messageID int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE telegram_chat SET solution_id = ? WHERE id = ?`, nullIfZero(messageID), id)
+	return err
+}
+
+func (r *mysqlTelegramChatRepository) Delete(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM telegram_chat WHERE id = ?`, id)
+	return err
+}
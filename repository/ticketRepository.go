@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reports-api/models"
+	"time"
+)
+
+// TicketSequenceRepository allocates ticket running numbers
+type TicketSequenceRepository interface {
+	// Next atomically returns the next running number of the period. The first call of a period
+	// seeds the counter from the highest number already used by tasks created in [since, until),
+	// so switching from the old MAX()+1 numbering does not reuse a ticket.
+	Next(ctx context.Context, periodKey string, since, until time.Time) (int, error)
+	// Duplicates lists ticket numbers shared by more than one task
+	Duplicates(ctx context.Context) ([]models.DuplicateTicket, error)
+}
+
+type mysqlTicketSequenceRepository struct {
+	db DBTX
+}
+
+func (r *mysqlTicketSequenceRepository) Next(ctx context.Context, periodKey string, since, until time.Time) (int, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		// LAST_INSERT_ID(expr) makes the new value the statement's insert id, so the number is
+		// read back from the same statement without a second query on another connection
+		res, err := r.db.ExecContext(ctx, `
+			UPDATE ticket_sequences SET last_no = LAST_INSERT_ID(last_no + 1) WHERE period_key = ?
+		`, periodKey)
+		if err != nil {
+			return 0, fmt.Errorf("failed to increment ticket sequence: %w", err)
+		}
+		if affected, _ := res.RowsAffected(); affected == 1 {
+			next, err := res.LastInsertId()
+			return int(next), err
+		}
+
+		// First ticket of the period; concurrent callers race on INSERT IGNORE and then all increment
+		_, err = r.db.ExecContext(ctx, `
+			INSERT IGNORE INTO ticket_sequences (period_key, last_no)
+			SELECT ?, IFNULL(MAX(CAST(SUBSTRING_INDEX(ticket_no, '-', -1) AS UNSIGNED)), 0)
+			FROM tasks
+			WHERE created_at >= ? AND created_at < ? AND ticket_no REGEXP '[0-9]+$'
+		`, periodKey, since, until)
+		if err != nil {
+			return 0, fmt.Errorf("failed to seed ticket sequence: %w", err)
+		}
+	}
+	return 0, fmt.Errorf("ticket sequence %s could not be allocated", periodKey)
+}
+
+func (r *mysqlTicketSequenceRepository) Duplicates(ctx context.Context) ([]models.DuplicateTicket, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT ticket_no, COUNT(*), GROUP_CONCAT(id ORDER BY id)
+		FROM tasks
+		WHERE ticket_no IS NOT NULL AND ticket_no <> ''
+		GROUP BY ticket_no
+		HAVING COUNT(*) > 1
+		ORDER BY ticket_no
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate tickets: %w", err)
+	}
+	defer rows.Close()
+
+	var duplicates []models.DuplicateTicket
+	for rows.Next() {
+		var d models.DuplicateTicket
+		if err := rows.Scan(&d.TicketNo, &d.Count, &d.TaskIDs); err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate ticket: %w", err)
+		}
+		duplicates = append(duplicates, d)
+	}
+	return duplicates, rows.Err()
+}
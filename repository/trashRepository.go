@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reports-api/models"
+	"strings"
+	"time"
+)
+
+// TrashRepository lists, restores and purges soft-deleted rows of every models.TrashKinds kind
+type TrashRepository interface {
+	List(ctx context.Context, kind string, q ListQuery) ([]models.TrashItem, int, error)
+	// Restore clears deleted_at of a trashed row and returns the task it belongs to (the id itself for a task,
+	// 0 for master data and users); it returns ErrNotFound when the row is not in the trash
+	Restore(ctx context.Context, kind string, id int) (int, error)
+	// Attachments returns the files and Telegram messages to remove after the row is purged
+	Attachments(ctx context.Context, kind string, id int) (*models.TrashAttachments, error)
+	// Purge permanently deletes a trashed row; rows belonging to a task are removed by common.PurgeTrashed
+	Purge(ctx context.Context, kind string, id int) error
+	// Expired returns up to limit rows trashed before the given time, oldest first, leaving out the skipped ids
+	Expired(ctx context.Context, kind string, before time.Time, skip []int, limit int) ([]int, error)
+}
+
+type trashTable struct {
+	table  string
+	label  string // SQL expression shown in the trash list
+	taskID string // column holding the parent task, if any
+	source string // search document source, if the row is indexed
+}
+
+var trashTables = map[string]trashTable{
+	models.TrashTasks:          {"tasks", "CONCAT_WS(' ', ticket_no, LEFT(text, 100))", "", models.SearchSourceTask},
+	models.TrashResolutions:    {"resolutions", "LEFT(IFNULL(text, ''), 100)", "tasks_id", models.SearchSourceResolution},
+	models.TrashProgress:       {"progress", "LEFT(IFNULL(progress_text, ''), 100)", "task_id", models.SearchSourceProgress},
+	models.TrashBranches:       {"branches", "IFNULL(name, '')", "", ""},
+	models.TrashDepartments:    {"departments", "IFNULL(name, '')", "", ""},
+	models.TrashPhones:         {"ip_phones", "CONCAT_WS(' ', number, name)", "", ""},
+	models.TrashPrograms:       {"systems_program", "IFNULL(name, '')", "", ""},
+	models.TrashUsers:          {"users", "username", "", ""},
+	models.TrashProblemRecords: {"problem_records", "title", "", ""},
+}
+
+func trashTableOf(kind string) (trashTable, error) {
+	t, ok := trashTables[kind]
+	if !ok {
+		return t, fmt.Errorf("invalid trash kind %q", kind)
+	}
+	return t, nil
+}
+
+type mysqlTrashRepository struct {
+	db DBTX
+}
+
+func (r *mysqlTrashRepository) List(ctx context.Context, kind string, q ListQuery) ([]models.TrashItem, int, error) {
+	t, err := trashTableOf(kind)
+	if err != nil {
+		return nil, 0, err
+	}
+	where := " WHERE deleted_at IS NOT NULL"
+	var args []interface{}
+	if q.Search != "" {
+		where += " AND " + t.label + " LIKE ?"
+		args = append(args, likePattern(q.Search))
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+t.table+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count trashed %s: %w", kind, err)
+	}
+
+	taskID := "NULL"
+	if t.taskID != "" {
+		taskID = t.taskID
+	}
+	query := "SELECT id, IFNULL(" + t.label + ", ''), " + taskID + ", deleted_at, deleted_by FROM " + t.table + where + " ORDER BY deleted_at DESC, id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query trashed %s: %w", kind, err)
+	}
+	defer rows.Close()
+
+	items := []models.TrashItem{}
+	for rows.Next() {
+		item := models.TrashItem{Kind: kind}
+		var parent sql.NullInt64
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&item.ID, &item.Label, &parent, &deletedAt, &item.DeletedBy); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan trashed %s: %w", kind, err)
+		}
+		if parent.Valid {
+			v := int(parent.Int64)
+			item.TaskID = &v
+		}
+		item.DeletedAt = formatTimeRFC3339(deletedAt)
+		items = append(items, item)
+	}
+	return items, total, rows.Err()
+}
+
+func (r *mysqlTrashRepository) Restore(ctx context.Context, kind string, id int) (int, error) {
+	t, err := trashTableOf(kind)
+	if err != nil {
+		return 0, err
+	}
+	res, err := r.db.ExecContext(ctx, "UPDATE "+t.table+" SET deleted_at = NULL, deleted_by = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore %s %d: %w", kind, id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return 0, ErrNotFound
+	}
+	if t.source != "" {
+		if err := indexDocument(ctx, r.db, t.source, int64(id)); err != nil {
+			return 0, err
+		}
+	}
+
+	switch {
+	case kind == models.TrashTasks:
+		return id, nil
+	case t.taskID != "":
+		var taskID int
+		err := r.db.QueryRowContext(ctx, "SELECT IFNULL("+t.taskID+", 0) FROM "+t.table+" WHERE id = ?", id).Scan(&taskID)
+		return taskID, err
+	}
+	return 0, nil
+}
+
+func (r *mysqlTrashRepository) Attachments(ctx context.Context, kind string, id int) (*models.TrashAttachments, error) {
+	t, err := trashTableOf(kind)
+	if err != nil {
+		return nil, err
+	}
+	var exists int
+	if err := r.db.QueryRowContext(ctx, "SELECT 1 FROM "+t.table+" WHERE id = ? AND deleted_at IS NOT NULL", id).Scan(&exists); err != nil {
+		return nil, notFound(err)
+	}
+
+	att := &models.TrashAttachments{}
+	collect := func(query string, args ...interface{}) error {
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to query attachments of %s %d: %w", kind, id, err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var text, filePaths sql.NullString
+			if err := rows.Scan(&text, &filePaths); err != nil {
+				return fmt.Errorf("failed to scan attachments of %s %d: %w", kind, id, err)
+			}
+			att.FileURLs = append(att.FileURLs, progressTextURLs(text.String)...)
+			att.FileURLs = append(att.FileURLs, attachmentURLs(filePaths.String)...)
+		}
+		return rows.Err()
+	}
+
+	switch kind {
+	case models.TrashTasks:
+		if err := collect(`SELECT NULL, file_paths FROM tasks WHERE id = ?`, id); err != nil {
+			return nil, err
+		}
+		if err := collect(`SELECT progress_text, file_paths FROM progress WHERE task_id = ?`, id); err != nil {
+			return nil, err
+		}
+		if err := collect(`SELECT NULL, file_paths FROM resolutions WHERE tasks_id = ?`, id); err != nil {
+			return nil, err
+		}
+		if err := collect(`SELECT NULL, file_paths FROM task_incidents WHERE task_id = ?`, id); err != nil {
+			return nil, err
+		}
+		var report, assignee, solution int
+		err := r.db.QueryRowContext(ctx, `
+			SELECT IFNULL(tc.report_id, 0), IFNULL(tc.assignto_id, 0), IFNULL(tc.solution_id, 0)
+			FROM tasks t JOIN telegram_chat tc ON t.telegram_id = tc.id
+			WHERE t.id = ?
+		`, id).Scan(&report, &assignee, &solution)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to query Telegram messages of task %d: %w", id, err)
+		}
+		for _, messageID := range []int{assignee, report, solution} {
+			if messageID > 0 {
+				att.TelegramMessageIDs = append(att.TelegramMessageIDs, messageID)
+			}
+		}
+	case models.TrashProgress:
+		if err := collect(`SELECT progress_text, file_paths FROM progress WHERE id = ?`, id); err != nil {
+			return nil, err
+		}
+	case models.TrashResolutions:
+		if err := collect(`SELECT NULL, file_paths FROM resolutions WHERE id = ?`, id); err != nil {
+			return nil, err
+		}
+	}
+	return att, nil
+}
+
+func (r *mysqlTrashRepository) Purge(ctx context.Context, kind string, id int) error {
+	t, err := trashTableOf(kind)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx, "DELETE FROM "+t.table+" WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return fmt.Errorf("failed to purge %s %d: %w", kind, id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	switch {
+	case kind == models.TrashTasks:
+		return unindexTask(ctx, r.db, "", id)
+	case t.source != "":
+		return unindexDocument(ctx, r.db, t.source, int64(id))
+	}
+	return nil
+}
+
+func (r *mysqlTrashRepository) Expired(ctx context.Context, kind string, before time.Time, skip []int, limit int) ([]int, error) {
+	t, err := trashTableOf(kind)
+	if err != nil {
+		return nil, err
+	}
+	where := " WHERE deleted_at IS NOT NULL AND deleted_at < ?"
+	args := []interface{}{before}
+	if len(skip) > 0 {
+		where += " AND id NOT IN (?" + strings.Repeat(", ?", len(skip)-1) + ")"
+		for _, id := range skip {
+			args = append(args, id)
+		}
+	}
+	args = append(args, limit)
+	rows, err := r.db.QueryContext(ctx, "SELECT id FROM "+t.table+where+" ORDER BY deleted_at, id LIMIT ?", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired %s: %w", kind, err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// attachmentURLs reads the URLs of a file_paths column: [{"url": ...}] or the legacy ["url", ...]
+func attachmentURLs(filePathsJSON string) []string {
+	if filePathsJSON == "" || filePathsJSON == "[]" {
+		return nil
+	}
+	var urls []string
+	var files []map[string]interface{}
+	if err := json.Unmarshal([]byte(filePathsJSON), &files); err == nil {
+		for _, f := range files {
+			if url, ok := f["url"].(string); ok {
+				urls = append(urls, url)
+			}
+		}
+		return urls
+	}
+	_ = json.Unmarshal([]byte(filePathsJSON), &urls)
+	return urls
+}
+
+// progressTextURLs reads the files embedded in a progress text stored as {"text": ..., "files": [{"url": ...}]}
+func progressTextURLs(text string) []string {
+	var data struct {
+		Files []struct {
+			URL string `json:"url"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(text), &data); err != nil {
+		return nil
+	}
+	var urls []string
+	for _, f := range data.Files {
+		if f.URL != "" {
+			urls = append(urls, f.URL)
+		}
+	}
+	return urls
+}
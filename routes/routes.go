@@ -3,134 +3,268 @@ package routes
 import (
 	"reports-api/handlers"
 	"reports-api/middleware"
+	"reports-api/models"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// can is shorthand for the permission required by a route
+func can(permissions ...string) fiber.Handler {
+	return middleware.RequirePermission(permissions...)
+}
+
+// Rate limiters are built once per endpoint class so their counters persist across requests
+var (
+	authLimit   fiber.Handler
+	listLimit   fiber.Handler
+	createLimit fiber.Handler
+	updateLimit fiber.Handler
+	deleteLimit fiber.Handler
+)
+
+func initRateLimiters() {
+	authLimit = middleware.RateLimiter(models.RateLimitAuth)
+	listLimit = middleware.RateLimiter(models.RateLimitList)
+	createLimit = middleware.RateLimiter(models.RateLimitCreate)
+	updateLimit = middleware.RateLimiter(models.RateLimitUpdate)
+	deleteLimit = middleware.RateLimiter(models.RateLimitDelete)
+}
+
 // MainRoutes registers all API routes
 func MainRoutes(r *fiber.App) {
 	//Dashboard routes
-	r.Get("/api/v1/dashboard/data", handlers.GetDashboardDataHandler)
+	r.Get("/api/v1/dashboard/data", can(models.PermTasksRead), handlers.GetDashboardDataHandler)
 
 	//Data export routes
-	r.Get("/api/v1/dashboard/data/phonecsv", handlers.IpphonesExportCsv)
-	r.Get("/api/v1/dashboard/data/departmetcsv", handlers.DepartmentsExportCsv)
-	r.Get("/api/v1/dashboard/data/branchcsv", handlers.BranchExportCsv)
-	r.Get("/api/v1/dashboard/data/systemcsv", handlers.SystemExportCsv)
-	r.Get("/api/v1/dashboard/data/taskscsv", handlers.TasksExportCsv)
+	r.Get("/api/v1/dashboard/data/phonecsv", can(models.PermReportsExport), handlers.IpphonesExportCsv)
+	r.Get("/api/v1/dashboard/data/departmetcsv", can(models.PermReportsExport), handlers.DepartmentsExportCsv)
+	r.Get("/api/v1/dashboard/data/branchcsv", can(models.PermReportsExport), handlers.BranchExportCsv)
+	r.Get("/api/v1/dashboard/data/systemcsv", can(models.PermReportsExport), handlers.SystemExportCsv)
+	r.Get("/api/v1/dashboard/data/taskscsv", can(models.PermReportsExport), handlers.TasksExportCsv)
 
-	r.Get("/api/v1/scores/list", handlers.ListScoresHandler)
-	r.Get("/api/v1/scores/:id", handlers.GetScoreDetailHandler)
-	r.Put("/api/v1/scores/update/:id", handlers.UpdateScoreHandler)
-	r.Delete("/api/v1/scores/delete/:id", handlers.DeleteScoreHandler)
+	r.Get("/api/v1/scores/list", can(models.PermMasterDataRead), handlers.ListScoresHandler)
+	r.Get("/api/v1/scores/:id", can(models.PermMasterDataRead), handlers.GetScoreDetailHandler)
+	r.Put("/api/v1/scores/update/:id", can(models.PermScoresWrite), handlers.UpdateScoreHandler)
+	r.Delete("/api/v1/scores/delete/:id", can(models.PermScoresWrite), handlers.DeleteScoreHandler)
 
-	r.Get("/api/v1/respons/list", handlers.GetresponsHandler)
-	r.Get("/api/v1/respons/:id", handlers.GetResponsDetailHandler)
-	r.Post("/api/v1/respons/create", handlers.AddresponsHandler)
-	r.Put("/api/v1/respons/update/:id", handlers.UpdateResponsHandler)
-	r.Delete("/api/v1/respons/delete/:id", handlers.DeleteResponsHandler)
+	r.Get("/api/v1/respons/list", can(models.PermMasterDataRead), handlers.GetresponsHandler)
+	r.Get("/api/v1/respons/:id", can(models.PermMasterDataRead), handlers.GetResponsDetailHandler)
+	r.Post("/api/v1/respons/create", can(models.PermMasterDataWrite), handlers.AddresponsHandler)
+	r.Put("/api/v1/respons/update/:id", can(models.PermMasterDataWrite), handlers.UpdateResponsHandler)
+	r.Delete("/api/v1/respons/delete/:id", can(models.PermMasterDataWrite), handlers.DeleteResponsHandler)
+
+	r.Get("/api/v1/merges", listLimit, can(models.PermMasterDataRead), handlers.ListMergesHandler)
 }
 
 // problemRoutes registers all problem-related routes
 func problemRoutes(r *fiber.App) {
-	r.Get("/api/v1/problem/list", handlers.GetTasksHandler)
-	r.Get("/api/v1/problem/list/:query", handlers.GetTasksWithQueryHandler)
-	r.Get("/api/v1/problem/list/:column/:query", handlers.GetTasksWithColumnQueryHandler)
-	r.Get("/api/v1/problem/list/sort/:column/:query", handlers.GetTaskSort)
-	r.Post("/api/v1/problem/create", middleware.RateLimiter(), handlers.CreateTaskHandler)
-	r.Get("/api/v1/problem/:id", handlers.GetTaskDetailHandler)
-	r.Put("/api/v1/problem/update/:id", middleware.RateLimiter(), handlers.UpdateTaskHandler)
-	r.Delete("/api/v1/problem/delete/:id", middleware.RateLimiter(), handlers.DeleteTaskHandler)
-	r.Put("/api/v1/problem/update/assignto/:id", handlers.UpdateAssignedTo)
+	r.Get("/api/v1/problems", listLimit, can(models.PermTasksRead), handlers.QueryTasksHandler)
+	r.Post("/api/v1/problems/bulk/assign", updateLimit, can(models.PermTasksAssign), handlers.BulkAssignTasksHandler)
+	r.Post("/api/v1/problems/bulk/status", updateLimit, can(models.PermTasksWrite), handlers.BulkTaskStatusHandler)
+	r.Post("/api/v1/problems/bulk/department", updateLimit, can(models.PermTasksWrite), handlers.BulkTaskDepartmentHandler)
+	r.Post("/api/v1/problems/bulk/program", updateLimit, can(models.PermTasksWrite), handlers.BulkTaskProgramHandler)
+	r.Post("/api/v1/problems/bulk/progress", updateLimit, can(models.PermProgressWrite), handlers.BulkTaskProgressHandler)
+	r.Post("/api/v1/problems/bulk/delete", deleteLimit, can(models.PermTasksDelete), handlers.BulkDeleteTasksHandler)
+	r.Get("/api/v1/search", listLimit, can(models.PermTasksRead), handlers.SearchHandler)
+	r.Get("/api/v1/problem/list", listLimit, can(models.PermTasksRead), handlers.GetTasksHandler)
+	r.Get("/api/v1/problem/list/:query", listLimit, can(models.PermTasksRead), handlers.GetTasksWithQueryHandler)
+	r.Get("/api/v1/problem/list/:column/:query", listLimit, can(models.PermTasksRead), handlers.GetTasksWithColumnQueryHandler)
+	r.Get("/api/v1/problem/list/sort/:column/:query", listLimit, can(models.PermTasksRead), handlers.GetTaskSort)
+	r.Post("/api/v1/problem/create", createLimit, can(models.PermTasksCreate), handlers.Idempotent, handlers.CreateTaskHandler)
+	r.Post("/api/v1/problem/duplicates", can(models.PermTasksCreate), handlers.CheckTaskDuplicatesHandler)
+	r.Get("/api/v1/problem/statuses", can(models.PermTasksRead), handlers.ListTaskStatusesHandler)
+	r.Get("/api/v1/problem/status/history/:id", can(models.PermTasksRead), handlers.GetTaskStatusHistoryHandler)
+	r.Put("/api/v1/problem/status/:id", updateLimit, can(models.PermTasksWrite), handlers.UpdateTaskStatusHandler)
+	r.Get("/api/v1/problem/:id", can(models.PermTasksRead), handlers.GetTaskDetailHandler)
+	r.Get("/api/v1/problem/:id/timeline", can(models.PermTasksRead), handlers.GetTaskTimelineHandler)
+	r.Get("/api/v1/problem/:id/changes", can(models.PermTasksRead), handlers.GetTaskChangesHandler)
+	r.Get("/api/v1/problem/:id/incidents", can(models.PermTasksRead), handlers.GetTaskIncidentsHandler)
+	r.Get("/api/v1/problem/:id/links", can(models.PermTasksRead), handlers.GetTaskLinksHandler)
+	r.Post("/api/v1/problem/:id/links", updateLimit, can(models.PermTasksWrite), handlers.CreateTaskLinkHandler)
+	r.Delete("/api/v1/problem/:id/links/:linkId", updateLimit, can(models.PermTasksWrite), handlers.DeleteTaskLinkHandler)
+	r.Put("/api/v1/problem/update/:id", updateLimit, can(models.PermTasksWrite), handlers.UpdateTaskHandler)
+	r.Delete("/api/v1/problem/delete/:id", deleteLimit, can(models.PermTasksDelete), handlers.DeleteTaskHandler)
+	r.Put("/api/v1/problem/update/assignto/:id", can(models.PermTasksAssign), handlers.UpdateAssignedTo)
 }
 
 // resolutionRoutes registers all resolution-related routes
 func resolutionRoutes(r *fiber.App) {
-	r.Get("/api/v1/resolution/:id", handlers.GetResolutionHandler)
-	r.Post("/api/v1/resolution/create/:id", handlers.CreateResolutionHandler)
-	r.Put("/api/v1/resolution/update/:id", handlers.UpdateResolutionHandler)
-	r.Delete("/api/v1/resolution/delete/:id", handlers.DeleteResolutionHandler)
+	r.Get("/api/v1/resolution/:id", can(models.PermTasksRead), handlers.GetResolutionHandler)
+	r.Post("/api/v1/resolution/create/:id", can(models.PermResolutionsWrite), handlers.Idempotent, handlers.CreateResolutionHandler)
+	r.Put("/api/v1/resolution/update/:id", can(models.PermResolutionsWrite), handlers.UpdateResolutionHandler)
+	r.Delete("/api/v1/resolution/delete/:id", can(models.PermResolutionsWrite), handlers.DeleteResolutionHandler)
+}
+
+// problemRecordRoutes registers the problem record routes
+func problemRecordRoutes(r *fiber.App) {
+	r.Get("/api/v1/problemrecord/list", listLimit, can(models.PermTasksRead), handlers.ListProblemRecordsHandler)
+	r.Get("/api/v1/problemrecord/workarounds", can(models.PermTasksCreate), handlers.GetWorkaroundsHandler)
+	r.Post("/api/v1/problemrecord/create", createLimit, can(models.PermProblemRecordsWrite), handlers.CreateProblemRecordHandler)
+	r.Get("/api/v1/problemrecord/:id", can(models.PermTasksRead), handlers.GetProblemRecordHandler)
+	r.Put("/api/v1/problemrecord/update/:id", updateLimit, can(models.PermProblemRecordsWrite), handlers.UpdateProblemRecordHandler)
+	r.Delete("/api/v1/problemrecord/delete/:id", deleteLimit, can(models.PermProblemRecordsWrite), handlers.DeleteProblemRecordHandler)
+	r.Post("/api/v1/problemrecord/:id/tasks", updateLimit, can(models.PermProblemRecordsWrite), handlers.AttachProblemRecordTasksHandler)
+	r.Delete("/api/v1/problemrecord/:id/tasks/:taskId", updateLimit, can(models.PermProblemRecordsWrite), handlers.DetachProblemRecordTaskHandler)
 }
 
 // progressRoutes registers all progress-related routes
 func progressRoutes(r *fiber.App) {
-	r.Get("/api/v1/progress/:id", handlers.GetProgressHandler)
-	r.Post("/api/v1/progress/create/:id", handlers.CreateProgressHandler)
-	r.Put("/api/v1/progress/update/:id/:pgid", handlers.UpdateProgressHandler)
-	r.Delete("/api/v1/progress/delete/:id/:pgid", handlers.DeleteProgressHandler)
+	r.Get("/api/v1/progress/:id", can(models.PermTasksRead), handlers.GetProgressHandler)
+	r.Post("/api/v1/progress/create/:id", can(models.PermProgressWrite), handlers.Idempotent, handlers.CreateProgressHandler)
+	r.Put("/api/v1/progress/update/:id/:pgid", can(models.PermProgressWrite), handlers.UpdateProgressHandler)
+	r.Delete("/api/v1/progress/delete/:id/:pgid", can(models.PermProgressWrite), handlers.DeleteProgressHandler)
 }
 
 // ipphoneRoutes registers all IP phone-related routes
 func ipphoneRoutes(r *fiber.App) {
-	r.Get("/api/v1/ipphone/list", handlers.ListIPPhonesHandler)
-	r.Get("/api/v1/ipphone/list/:query", handlers.ListIPPhonesQueryHandler)
-	r.Get("/api/v1/ipphone/:id", handlers.GetIPPhonesDetailHandler)
-	r.Get("/api/v1/ipphone/listall", handlers.AllIPPhonesHandler)
-	r.Post("/api/v1/ipphone/create", handlers.CreateIPPhoneHandler)
-	r.Put("/api/v1/ipphone/update/:id", handlers.UpdateIPPhoneHandler)
-	r.Delete("/api/v1/ipphone/delete/:id", handlers.DeleteIPPhoneHandler)
+	r.Get("/api/v1/ipphone/list", can(models.PermMasterDataRead), handlers.ListIPPhonesHandler)
+	r.Get("/api/v1/ipphone/list/:query", can(models.PermMasterDataRead), handlers.ListIPPhonesQueryHandler)
+	r.Get("/api/v1/ipphone/duplicates", can(models.PermMasterDataRead), handlers.GetIPPhoneDuplicatesHandler)
+	r.Post("/api/v1/ipphone/merge", updateLimit, can(models.PermMasterDataWrite), handlers.MergeIPPhonesHandler)
+	r.Get("/api/v1/ipphone/:id", can(models.PermMasterDataRead), handlers.GetIPPhonesDetailHandler)
+	r.Get("/api/v1/ipphone/listall", can(models.PermMasterDataRead), handlers.AllIPPhonesHandler)
+	r.Post("/api/v1/ipphone/create", can(models.PermMasterDataWrite), handlers.CreateIPPhoneHandler)
+	r.Put("/api/v1/ipphone/update/:id", can(models.PermMasterDataWrite), handlers.UpdateIPPhoneHandler)
+	r.Get("/api/v1/ipphone/dependents/:id", can(models.PermMasterDataRead), handlers.GetIPPhoneDependentsHandler)
+	r.Delete("/api/v1/ipphone/delete/:id", can(models.PermMasterDataWrite), handlers.DeleteIPPhoneHandler)
 }
 
 // programRoutes registers all program-related routes
 func programRoutes(r *fiber.App) {
-	r.Get("/api/v1/program/list", handlers.ListProgramsHandler)
-	r.Get("/api/v1/program/list/:query", handlers.ListProgramsQueryHandler)
-	r.Post("/api/v1/program/create", handlers.CreateProgramHandler)
-	r.Get("/api/v1/program/type/list", handlers.GETTypeProgramHandler)
-	r.Get("/api/v1/program/type/list/:query", handlers.GetTypeWithQueryHandler)
-	r.Post("/api/v1/program/type/create", handlers.AddTypeProgramHandler)
-	r.Post("/api/v1/program/type/update/:id", handlers.UpdateTypeProgramHandler)
-	r.Delete("/api/v1/program/type/delete/:id", handlers.DeleteTypeHandler)
-	r.Get("/api/v1/program/:id", handlers.GetProgramDetailHandler)
-	r.Put("/api/v1/program/update/:id", handlers.UpdateProgramHandler)
-	r.Delete("/api/v1/program/delete/:id", handlers.DeleteProgramHandler)
+	r.Get("/api/v1/program/list", can(models.PermMasterDataRead), handlers.ListProgramsHandler)
+	r.Get("/api/v1/program/list/:query", can(models.PermMasterDataRead), handlers.ListProgramsQueryHandler)
+	r.Post("/api/v1/program/create", can(models.PermMasterDataWrite), handlers.CreateProgramHandler)
+	r.Get("/api/v1/program/type/list", can(models.PermMasterDataRead), handlers.GETTypeProgramHandler)
+	r.Get("/api/v1/program/type/list/:query", can(models.PermMasterDataRead), handlers.GetTypeWithQueryHandler)
+	r.Post("/api/v1/program/type/create", can(models.PermMasterDataWrite), handlers.AddTypeProgramHandler)
+	r.Post("/api/v1/program/type/update/:id", can(models.PermMasterDataWrite), handlers.UpdateTypeProgramHandler)
+	r.Delete("/api/v1/program/type/delete/:id", can(models.PermMasterDataWrite), handlers.DeleteTypeHandler)
+	r.Get("/api/v1/program/duplicates", can(models.PermMasterDataRead), handlers.GetProgramDuplicatesHandler)
+	r.Post("/api/v1/program/merge", updateLimit, can(models.PermMasterDataWrite), handlers.MergeProgramsHandler)
+	r.Get("/api/v1/program/:id", can(models.PermMasterDataRead), handlers.GetProgramDetailHandler)
+	r.Put("/api/v1/program/update/:id", can(models.PermMasterDataWrite), handlers.UpdateProgramHandler)
+	r.Get("/api/v1/program/dependents/:id", can(models.PermMasterDataRead), handlers.GetProgramDependentsHandler)
+	r.Delete("/api/v1/program/delete/:id", can(models.PermMasterDataWrite), handlers.DeleteProgramHandler)
 }
 
 // departmentRoutes registers all department-related routes
 func departmentRoutes(r *fiber.App) {
-	r.Get("/api/v1/department/list", handlers.ListDepartmentsHandler)
-	r.Get("/api/v1/department/list/:query", handlers.ListDepartmentsQueryHandler)
-	r.Get("/api/v1/department/listall", handlers.AllDepartmentsHandler)
-	r.Post("/api/v1/department/create", handlers.CreateDepartmentHandler)
-	r.Get("/api/v1/department/:id", handlers.GetDepartmentDetailHandler)
-	r.Put("/api/v1/department/update/:id", handlers.UpdateDepartmentHandler)
-	r.Delete("/api/v1/department/delete/:id", handlers.DeleteDepartmentHandler)
+	r.Get("/api/v1/department/list", can(models.PermMasterDataRead), handlers.ListDepartmentsHandler)
+	r.Get("/api/v1/department/list/:query", can(models.PermMasterDataRead), handlers.ListDepartmentsQueryHandler)
+	r.Get("/api/v1/department/listall", can(models.PermMasterDataRead), handlers.AllDepartmentsHandler)
+	r.Post("/api/v1/department/create", can(models.PermMasterDataWrite), handlers.CreateDepartmentHandler)
+	r.Get("/api/v1/department/duplicates", can(models.PermMasterDataRead), handlers.GetDepartmentDuplicatesHandler)
+	r.Post("/api/v1/department/merge", updateLimit, can(models.PermMasterDataWrite), handlers.MergeDepartmentsHandler)
+	r.Get("/api/v1/department/:id", can(models.PermMasterDataRead), handlers.GetDepartmentDetailHandler)
+	r.Put("/api/v1/department/update/:id", can(models.PermMasterDataWrite), handlers.UpdateDepartmentHandler)
+	r.Get("/api/v1/department/dependents/:id", can(models.PermMasterDataRead), handlers.GetDepartmentDependentsHandler)
+	r.Delete("/api/v1/department/delete/:id", can(models.PermMasterDataWrite), handlers.DeleteDepartmentHandler)
 }
 
 // branchRoutes registers all branch-related routes
 func branchRoutes(r *fiber.App) {
-	r.Get("/api/v1/branch/list", handlers.ListBranchesHandler)
-	r.Get("/api/v1/branch/list/:query", handlers.ListBranchesQueryHandler)
-	r.Post("/api/v1/branch/create", handlers.CreateBranchHandler)
-	r.Get("/api/v1/branch/:id", handlers.GetBranchDetailHandler)
-	r.Put("/api/v1/branch/update/:id", handlers.UpdateBranchHandler)
-	r.Delete("/api/v1/branch/delete/:id", handlers.DeleteBranchHandler)
+	r.Get("/api/v1/branch/list", can(models.PermMasterDataRead), handlers.ListBranchesHandler)
+	r.Get("/api/v1/branch/list/:query", can(models.PermMasterDataRead), handlers.ListBranchesQueryHandler)
+	r.Post("/api/v1/branch/create", can(models.PermMasterDataWrite), handlers.CreateBranchHandler)
+	r.Get("/api/v1/branch/duplicates", can(models.PermMasterDataRead), handlers.GetBranchDuplicatesHandler)
+	r.Post("/api/v1/branch/merge", updateLimit, can(models.PermMasterDataWrite), handlers.MergeBranchesHandler)
+	r.Get("/api/v1/branch/:id", can(models.PermMasterDataRead), handlers.GetBranchDetailHandler)
+	r.Put("/api/v1/branch/update/:id", can(models.PermMasterDataWrite), handlers.UpdateBranchHandler)
+	r.Get("/api/v1/branch/dependents/:id", can(models.PermMasterDataRead), handlers.GetBranchDependentsHandler)
+	r.Delete("/api/v1/branch/delete/:id", can(models.PermMasterDataWrite), handlers.DeleteBranchHandler)
+}
+
+// slaRoutes registers SLA policy and event routes
+func slaRoutes(r *fiber.App) {
+	r.Get("/api/v1/sla/list", can(models.PermMasterDataRead), handlers.ListSLAPoliciesHandler)
+	r.Get("/api/v1/sla/events", can(models.PermTasksRead), handlers.ListSLAEventsHandler)
+	r.Post("/api/v1/sla/create", can(models.PermMasterDataWrite), handlers.CreateSLAPolicyHandler)
+	r.Get("/api/v1/sla/:id", can(models.PermMasterDataRead), handlers.GetSLAPolicyHandler)
+	r.Put("/api/v1/sla/update/:id", can(models.PermMasterDataWrite), handlers.UpdateSLAPolicyHandler)
+	r.Delete("/api/v1/sla/delete/:id", can(models.PermMasterDataWrite), handlers.DeleteSLAPolicyHandler)
+}
+
+// calendarRoutes registers working hours, holiday and business time routes
+func calendarRoutes(r *fiber.App) {
+	r.Get("/api/v1/calendar/hours", can(models.PermMasterDataRead), handlers.GetWorkingHoursHandler)
+	r.Put("/api/v1/calendar/hours/update", updateLimit, can(models.PermMasterDataWrite), handlers.UpdateWorkingHoursHandler)
+	r.Get("/api/v1/calendar/holidays", can(models.PermMasterDataRead), handlers.ListHolidaysHandler)
+	r.Post("/api/v1/calendar/holidays/create", createLimit, can(models.PermMasterDataWrite), handlers.CreateHolidayHandler)
+	r.Post("/api/v1/calendar/holidays/import", createLimit, can(models.PermMasterDataWrite), handlers.ImportHolidaysHandler)
+	r.Delete("/api/v1/calendar/holidays/delete/:id", deleteLimit, can(models.PermMasterDataWrite), handlers.DeleteHolidayHandler)
+	r.Get("/api/v1/calendar/elapsed", can(models.PermTasksRead), handlers.GetBusinessElapsedHandler)
+}
+
+// trashPermissions is the permission needed to list, restore and purge each trash kind
+var trashPermissions = map[string]string{
+	models.TrashTasks:          models.PermTasksDelete,
+	models.TrashResolutions:    models.PermResolutionsWrite,
+	models.TrashProgress:       models.PermProgressWrite,
+	models.TrashBranches:       models.PermMasterDataWrite,
+	models.TrashDepartments:    models.PermMasterDataWrite,
+	models.TrashPhones:         models.PermMasterDataWrite,
+	models.TrashPrograms:       models.PermMasterDataWrite,
+	models.TrashUsers:          models.PermUsersManage,
+	models.TrashProblemRecords: models.PermProblemRecordsWrite,
+}
+
+// trashRoutes registers the list, restore and purge routes of every trash kind
+func trashRoutes(r *fiber.App) {
+	for _, kind := range models.TrashKinds {
+		perm := can(trashPermissions[kind])
+		r.Get("/api/v1/trash/"+kind, listLimit, perm, handlers.ListTrashHandler(kind))
+		r.Put("/api/v1/trash/"+kind+"/restore/:id", updateLimit, perm, handlers.RestoreTrashHandler(kind))
+		r.Delete("/api/v1/trash/"+kind+"/purge/:id", deleteLimit, perm, handlers.PurgeTrashHandler(kind))
+	}
 }
 
 // RegisterAuthRoutes registers all authentication-related routes
 func RegisterAuthRoutes(r *fiber.App) {
-	// Authentication routes with rate limiting
-	r.Post("/api/authEntry/login", middleware.RateLimiter(), handlers.LoginHandler)
-	r.Post("/api/authEntry/registerUser", middleware.RateLimiter(), handlers.RegisterHandler("user"))
-	r.Post("/api/authEntry/registerAdmin", middleware.RateLimiter(), handlers.RegisterHandler("admin"))
-	r.Put("/api/authEntry/updateUser", middleware.RateLimiter(), handlers.UpdateUserHandler)
-	r.Delete("/api/authEntry/deleteUser", middleware.RateLimiter(), handlers.DeleteUserHandler)
+	// Public authentication routes with the strict auth rate limit
+	r.Post("/api/authEntry/login", authLimit, handlers.LoginHandler)
+	r.Post("/api/authEntry/refresh", authLimit, handlers.RefreshTokenHandler)
 	r.Post("/api/authEntry/logout", handlers.LogoutHandler)
+	r.Post("/api/authEntry/password/reset", authLimit, handlers.ResetPasswordHandler)
+
+	// Authenticated account routes
+	auth := middleware.AuthMiddleware()
+	r.Post("/api/authEntry/registerUser", createLimit, auth, can(models.PermUsersManage), handlers.RegisterHandler(models.RoleUser))
+	r.Post("/api/authEntry/registerTechnician", createLimit, auth, can(models.PermUsersManage), handlers.RegisterHandler(models.RoleTechnician))
+	r.Post("/api/authEntry/registerAdmin", createLimit, auth, can(models.PermUsersManage), handlers.RegisterHandler(models.RoleAdmin))
+	r.Put("/api/authEntry/updateUser", updateLimit, auth, can(models.PermUsersManage), handlers.UpdateUserHandler)
+	r.Delete("/api/authEntry/deleteUser", deleteLimit, auth, can(models.PermUsersManage), handlers.DeleteUserHandler)
+	r.Put("/api/authEntry/password", authLimit, auth, handlers.ChangePasswordHandler)
+	r.Post("/api/authEntry/user/:id/password-reset", authLimit, auth, can(models.PermUsersManage), handlers.CreatePasswordResetHandler)
 
 	// User management routes
-	r.Get("/api/authEntry/users", handlers.GetAllUsersHandler)
-	r.Get("/api/authEntry/user/:id", handlers.GetUserDetailHandler)
+	r.Get("/api/authEntry/users", auth, can(models.PermUsersManage), handlers.GetAllUsersHandler)
+	r.Get("/api/authEntry/user/:id", auth, can(models.PermUsersManage), handlers.GetUserDetailHandler)
+	r.Post("/api/authEntry/users/unlock", auth, can(models.PermUsersManage), handlers.UnlockUserHandler)
+	r.Get("/api/authEntry/login-history", auth, can(models.PermUsersManage), handlers.GetLoginHistoryHandler)
+
+	// Role management routes
+	r.Get("/api/authEntry/roles", auth, can(models.PermRolesManage), handlers.ListRolesHandler)
+	r.Put("/api/authEntry/roles/:role", auth, can(models.PermRolesManage), handlers.UpdateRolePermissionsHandler)
 }
 
 // RegisterRoutes registers all routes
 func RegisterRoutes(r *fiber.App) {
+	initRateLimiters()
 	RegisterAuthRoutes(r)
+
+	// Every /api/v1 route requires a valid access token
+	r.Use("/api/v1", middleware.AuthMiddleware())
+
 	MainRoutes(r)
 	problemRoutes(r)
 	resolutionRoutes(r)
+	problemRecordRoutes(r)
 	progressRoutes(r)
 	ipphoneRoutes(r)
 	programRoutes(r)
 	departmentRoutes(r)
 	branchRoutes(r)
+	slaRoutes(r)
+	calendarRoutes(r)
+	trashRoutes(r)
 }
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"errors"
+	"reports-api/models"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AccessTokenCookie is the cookie name used to carry the access token for browser clients
+const AccessTokenCookie = "access_token"
+
+// RefreshTokenCookie is the cookie name used to carry the refresh token for browser clients
+const RefreshTokenCookie = "refresh_token"
+
+// ErrNoActor is returned when an audit actor is requested on an unauthenticated request
+var ErrNoActor = errors.New("authentication required")
+
+// ErrActorMismatch is returned when a client supplied created_by / updated_by / deleted_by
+// does not match the authenticated caller
+var ErrActorMismatch = errors.New("audit actor does not match the authenticated user")
+
+// userLocalsKey is the fiber Locals key holding the authenticated token claims
+const userLocalsKey = "user"
+
+// BearerToken extracts the access token from the Authorization header or the access token cookie
+func BearerToken(c *fiber.Ctx) string {
+	header := c.Get(fiber.HeaderAuthorization)
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return c.Cookies(AccessTokenCookie)
+}
+
+// SetCurrentUser stores the authenticated claims on the request context
+func SetCurrentUser(c *fiber.Ctx, claims *models.TokenClaims) {
+	c.Locals(userLocalsKey, claims)
+}
+
+// CurrentUser returns the authenticated claims, or nil when the request is anonymous
+func CurrentUser(c *fiber.Ctx) *models.TokenClaims {
+	claims, _ := c.Locals(userLocalsKey).(*models.TokenClaims)
+	return claims
+}
+
+// AuditActor returns the authenticated user ID to record in created_by / updated_by / deleted_by.
+// Client supplied values are ignored when empty (nil or 0) and rejected when they name someone else.
+func AuditActor(c *fiber.Ctx, supplied ...*int) (int, error) {
+	claims := CurrentUser(c)
+	if claims == nil || claims.UserID == 0 {
+		return 0, ErrNoActor
+	}
+	for _, value := range supplied {
+		if value != nil && *value != 0 && *value != claims.UserID {
+			return 0, ErrActorMismatch
+		}
+	}
+	return claims.UserID, nil
+}
+
+// AuditActorError writes the response for an error returned by AuditActor
+func AuditActorError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, ErrNoActor) {
+		return c.Status(401).JSON(fiber.Map{"error": "Authentication required"})
+	}
+	return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+}
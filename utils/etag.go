@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrIfMatchMissing is returned when an update is sent without the If-Match header
+var ErrIfMatchMissing = errors.New("If-Match header is required")
+
+// ErrIfMatchInvalid is returned when If-Match does not hold an ETag returned by this API
+var ErrIfMatchInvalid = errors.New("If-Match must be the ETag of the current version")
+
+// SetETag sets the ETag header to the row version of the returned record
+func SetETag(c *fiber.Ctx, version int) {
+	c.Set(fiber.HeaderETag, `"`+strconv.Itoa(version)+`"`)
+}
+
+// IfMatchVersion returns the row version named by the If-Match header.
+// Quoted ("3"), weak (W/"3") and bare (3) forms are accepted.
+func IfMatchVersion(c *fiber.Ctx) (int, error) {
+	value := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if value == "" {
+		return 0, ErrIfMatchMissing
+	}
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, err := strconv.Atoi(value)
+	if err != nil || version <= 0 {
+		return 0, ErrIfMatchInvalid
+	}
+	return version, nil
+}
+
+// IfMatchError writes the response for an error returned by IfMatchVersion
+func IfMatchError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, ErrIfMatchMissing) {
+		return c.Status(428).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+}